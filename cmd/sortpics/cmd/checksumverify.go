@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	"github.com/alitto/pond"
+	"github.com/cacack/sortpics-go/internal/duplicate"
+	"github.com/cacack/sortpics-go/internal/rename"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checksumVerifyWorkers int
+)
+
+var checksumVerifyCmd = &cobra.Command{
+	Use:   "checksum-verify [flags] DIRECTORY...",
+	Short: "Detect bit rot using .sha256 checksum sidecars",
+	Long: `Detect bit rot in an archive using the checksum sidecars written by
+--write-checksums.
+
+Each supported file's content hash is recomputed and compared with the hash
+in its "<file>.sha256" sidecar. Files whose hash differs are reported as
+mismatches and files with no sidecar are reported as missing.
+
+Unlike "verify", this checks file content only, not EXIF metadata or names.
+Exits with an error if any mismatch is found or a file can't be checked.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runChecksumVerify,
+}
+
+func init() {
+	rootCmd.AddCommand(checksumVerifyCmd)
+
+	checksumVerifyCmd.Flags().IntVarP(&checksumVerifyWorkers, "workers", "w", runtime.NumCPU(), "number of files hashed concurrently")
+}
+
+func runChecksumVerify(cmd *cobra.Command, args []string) error {
+	files, err := collectFilesRecursive(args)
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		fmt.Println("No files to verify")
+		return nil
+	}
+
+	fmt.Printf("Found %d files to verify\n\n", len(files))
+
+	stats := &ChecksumVerifyStats{}
+	checksumVerifyFiles(files, checksumVerifyWorkers, stats)
+
+	printChecksumVerifySummary(stats)
+
+	if stats.Mismatches > 0 || stats.Errors > 0 {
+		if cmd != nil {
+			cmd.SilenceUsage = true
+		}
+		if stats.Mismatches > 0 {
+			return fmt.Errorf("%w: %d of %d do not match their checksum", ErrFilesFailed, stats.Mismatches, len(files))
+		}
+		return fmt.Errorf("%w: %d of %d", ErrFilesFailed, stats.Errors, len(files))
+	}
+	return nil
+}
+
+// ChecksumVerifyStats tracks checksum verification statistics
+type ChecksumVerifyStats struct {
+	Verified   int64
+	Matched    int64
+	Mismatches int64
+	Missing    int64
+	Errors     int64
+}
+
+// checksumVerifyFiles checks every file against its sidecar using a worker pool
+func checksumVerifyFiles(files []string, workers int, stats *ChecksumVerifyStats) {
+	if workers < 1 {
+		workers = 1
+	}
+	pool := pond.New(workers, len(files))
+
+	for _, file := range files {
+		file := file // Capture for closure
+		pool.Submit(func() {
+			if err := checksumVerifyFile(file, stats); err != nil {
+				atomic.AddInt64(&stats.Errors, 1)
+				fmt.Fprintf(os.Stderr, "Error verifying %s: %v\n", file, err)
+			}
+		})
+	}
+
+	pool.StopAndWait()
+}
+
+// checksumVerifyFile compares a file's SHA256 with the hash in its sidecar
+func checksumVerifyFile(file string, stats *ChecksumVerifyStats) error {
+	atomic.AddInt64(&stats.Verified, 1)
+
+	data, err := os.ReadFile(file + rename.ChecksumExt)
+	if os.IsNotExist(err) {
+		atomic.AddInt64(&stats.Missing, 1)
+		fmt.Printf("MISSING: %s\n", file)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read checksum: %w", err)
+	}
+
+	expected, err := parseChecksumSidecar(string(data))
+	if err != nil {
+		return err
+	}
+
+	actual, err := duplicate.FileSHA256(file)
+	if err != nil {
+		return fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	if actual != expected {
+		atomic.AddInt64(&stats.Mismatches, 1)
+		fmt.Printf("MISMATCH: %s\n", file)
+		fmt.Printf("  Expected: %s\n", expected)
+		fmt.Printf("  Actual:   %s\n", actual)
+		return nil
+	}
+
+	atomic.AddInt64(&stats.Matched, 1)
+	return nil
+}
+
+// parseChecksumSidecar extracts the hash from sha256sum-style sidecar content
+func parseChecksumSidecar(content string) (string, error) {
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum sidecar")
+	}
+
+	hash := strings.ToLower(fields[0])
+	if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != 32 {
+		return "", fmt.Errorf("invalid SHA256 in checksum sidecar: %q", fields[0])
+	}
+	return hash, nil
+}
+
+// printChecksumVerifySummary prints checksum verification statistics
+func printChecksumVerifySummary(stats *ChecksumVerifyStats) {
+	fmt.Println("\nChecksum Verification Summary:")
+	fmt.Printf("  Verified:   %d\n", stats.Verified)
+	fmt.Printf("  Matched:    %d\n", stats.Matched)
+
+	if stats.Mismatches > 0 {
+		fmt.Printf("  Mismatches: %d\n", stats.Mismatches)
+	}
+
+	if stats.Missing > 0 {
+		fmt.Printf("  Missing:    %d\n", stats.Missing)
+	}
+
+	if stats.Errors > 0 {
+		fmt.Printf("  Errors:     %d\n", stats.Errors)
+	}
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cacack/sortpics-go/internal/duplicate"
+	"github.com/cacack/sortpics-go/internal/rename"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumVerifyFiles(t *testing.T) {
+	setup := func(t *testing.T) (string, string, string) {
+		tmpDir := t.TempDir()
+		dayDir := filepath.Join(tmpDir, "2024", "01", "2024-01-15")
+		require.NoError(t, os.MkdirAll(dayDir, 0755))
+
+		good := filepath.Join(dayDir, "20240115-123045.123456_Canon-EOS5d.jpg")
+		rotten := filepath.Join(dayDir, "20240115-123046.000000_Canon-EOS5d.jpg")
+		unchecked := filepath.Join(dayDir, "20240115-123047.000000_Canon-EOS5d.jpg")
+		for _, path := range []string{good, rotten, unchecked} {
+			require.NoError(t, os.WriteFile(path, []byte("image data "+path), 0644))
+		}
+		for _, path := range []string{good, rotten} {
+			hash, err := duplicate.FileSHA256(path)
+			require.NoError(t, err)
+			line := hash + "  " + filepath.Base(path) + "\n"
+			require.NoError(t, os.WriteFile(path+rename.ChecksumExt, []byte(line), 0644))
+		}
+
+		return tmpDir, rotten, unchecked
+	}
+
+	t.Run("flags corrupted and unchecked files", func(t *testing.T) {
+		tmpDir, rotten, _ := setup(t)
+
+		// Flip bytes after the sidecar was written
+		require.NoError(t, os.WriteFile(rotten, []byte("bit rot"), 0644))
+
+		files, err := collectFilesRecursive([]string{tmpDir})
+		require.NoError(t, err)
+
+		stats := &ChecksumVerifyStats{}
+		checksumVerifyFiles(files, 2, stats)
+
+		assert.Equal(t, int64(3), stats.Verified)
+		assert.Equal(t, int64(1), stats.Matched)
+		assert.Equal(t, int64(1), stats.Mismatches)
+		assert.Equal(t, int64(1), stats.Missing)
+		assert.Equal(t, int64(0), stats.Errors)
+	})
+
+	t.Run("invalid sidecar is an error", func(t *testing.T) {
+		_, rotten, _ := setup(t)
+		require.NoError(t, os.WriteFile(rotten+rename.ChecksumExt, []byte("not-a-hash  x.jpg\n"), 0644))
+
+		stats := &ChecksumVerifyStats{}
+		checksumVerifyFiles([]string{rotten}, 1, stats)
+
+		assert.Equal(t, int64(1), stats.Errors)
+		assert.Equal(t, int64(0), stats.Mismatches)
+	})
+
+	t.Run("mismatches and errors fail the run", func(t *testing.T) {
+		tmpDir, rotten, _ := setup(t)
+		require.NoError(t, runChecksumVerify(nil, []string{tmpDir}))
+
+		require.NoError(t, os.WriteFile(rotten, []byte("bit rot"), 0644))
+		assert.ErrorIs(t, runChecksumVerify(nil, []string{tmpDir}), ErrFilesFailed)
+
+		tmpDir, rotten, _ = setup(t)
+		require.NoError(t, os.WriteFile(rotten+rename.ChecksumExt, []byte("not-a-hash  x.jpg\n"), 0644))
+		assert.ErrorIs(t, runChecksumVerify(nil, []string{tmpDir}), ErrFilesFailed)
+	})
+}
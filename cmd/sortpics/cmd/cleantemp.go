@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cacack/sortpics-go/internal/rename"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cleanTempOlderThan time.Duration
+	cleanTempDryRun    bool
+)
+
+var cleanTempCmd = &cobra.Command{
+	Use:   "clean-temp [flags] DIRECTORY...",
+	Short: "Remove orphaned temporary files left by interrupted runs",
+	Long: `Remove orphaned temporary files left in an archive by interrupted runs.
+
+Copies are written to a hidden ".tmp-*" file in the destination directory
+and renamed into place once complete. If sortpics is killed mid-copy these
+temp files are left behind. This command walks each directory and removes
+them.
+
+Only files matching the exact temp-file pattern are removed, and only if
+they are older than --older-than (default 1h) so a run in progress is not
+disturbed.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runCleanTemp,
+}
+
+func init() {
+	rootCmd.AddCommand(cleanTempCmd)
+
+	cleanTempCmd.Flags().DurationVar(&cleanTempOlderThan, "older-than", time.Hour, "only remove temp files older than this age")
+	cleanTempCmd.Flags().BoolVar(&cleanTempDryRun, "dry-run", false, "list temp files without removing them")
+}
+
+func runCleanTemp(cmd *cobra.Command, args []string) error {
+	stats, err := cleanTempFiles(args, cleanTempOlderThan, cleanTempDryRun)
+	if err != nil {
+		return err
+	}
+
+	if cleanTempDryRun {
+		fmt.Printf("Would remove %d temp files\n", stats.Removed)
+	} else {
+		fmt.Printf("Removed %d temp files\n", stats.Removed)
+	}
+	if stats.TooNew > 0 {
+		fmt.Printf("Kept %d temp files newer than %s\n", stats.TooNew, cleanTempOlderThan)
+	}
+
+	return nil
+}
+
+// CleanTempStats tracks temp file cleanup statistics
+type CleanTempStats struct {
+	Removed int
+	TooNew  int
+}
+
+// cleanTempFiles removes SafeCopy temp files older than olderThan from dirs
+func cleanTempFiles(dirs []string, olderThan time.Duration, dryRun bool) (*CleanTempStats, error) {
+	stats := &CleanTempStats{}
+	cutoff := time.Now().Add(-olderThan)
+
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !rename.IsTempFile(d.Name()) {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if info.ModTime().After(cutoff) {
+				stats.TooNew++
+				return nil
+			}
+
+			if dryRun {
+				fmt.Printf("Would remove: %s\n", path)
+				stats.Removed++
+				return nil
+			}
+
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			fmt.Printf("Removed: %s\n", path)
+			stats.Removed++
+			return nil
+		})
+		if err != nil {
+			return stats, fmt.Errorf("failed to walk directory %s: %w", dir, err)
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanTempFiles(t *testing.T) {
+	setup := func(t *testing.T) (string, string, string, string) {
+		tmpDir := t.TempDir()
+		dayDir := filepath.Join(tmpDir, "2024", "01", "2024-01-15")
+		require.NoError(t, os.MkdirAll(dayDir, 0755))
+
+		staleTemp := filepath.Join(dayDir, ".tmp-abc")
+		freshTemp := filepath.Join(dayDir, ".tmp-123")
+		photo := filepath.Join(dayDir, "20240115-123045.123456_Canon-EOS5d.jpg")
+		for _, path := range []string{staleTemp, freshTemp, photo} {
+			require.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+		}
+
+		old := time.Now().Add(-2 * time.Hour)
+		require.NoError(t, os.Chtimes(staleTemp, old, old))
+		require.NoError(t, os.Chtimes(photo, old, old))
+
+		return tmpDir, staleTemp, freshTemp, photo
+	}
+
+	t.Run("removes stale temp files only", func(t *testing.T) {
+		tmpDir, staleTemp, freshTemp, photo := setup(t)
+
+		stats, err := cleanTempFiles([]string{tmpDir}, time.Hour, false)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, stats.Removed)
+		assert.Equal(t, 1, stats.TooNew)
+		assert.NoFileExists(t, staleTemp)
+		assert.FileExists(t, freshTemp)
+		assert.FileExists(t, photo)
+	})
+
+	t.Run("dry run keeps files", func(t *testing.T) {
+		tmpDir, staleTemp, _, _ := setup(t)
+
+		stats, err := cleanTempFiles([]string{tmpDir}, time.Hour, true)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, stats.Removed)
+		assert.FileExists(t, staleTemp)
+	})
+
+	t.Run("invalid directory", func(t *testing.T) {
+		_, err := cleanTempFiles([]string{"/nonexistent/directory"}, time.Hour, false)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+
+	"github.com/cacack/sortpics-go/internal/duplicate"
+	"github.com/cacack/sortpics-go/pkg/processor"
+	"github.com/spf13/cobra"
+)
+
+// Which copy of a duplicate group dedup keeps
+const (
+	keepFirst  = "first"
+	keepOldest = "oldest"
+)
+
+var (
+	dedupDelete  bool
+	dedupKeep    string
+	dedupWorkers int
+)
+
+var dedupCmd = &cobra.Command{
+	Use:   "dedup [flags] DIRECTORY...",
+	Short: "Find and remove files with identical content",
+	Long: `Find files with identical content in already organized directories,
+without renaming or moving anything.
+
+Every supported file is hashed (SHA256) and files with the same content are
+reported in groups. With --delete, all but one copy of each group is removed:
+the first by path, or with --keep oldest the one modified longest ago.
+
+Without --delete nothing is changed.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runDedup,
+}
+
+func init() {
+	rootCmd.AddCommand(dedupCmd)
+
+	dedupCmd.Flags().BoolVar(&dedupDelete, "delete", false, "remove all but one copy of each duplicate group")
+	dedupCmd.Flags().StringVar(&dedupKeep, "keep", keepFirst, "copy to keep: first (by path) or oldest (by modification time)")
+	dedupCmd.Flags().IntVarP(&dedupWorkers, "workers", "w", runtime.NumCPU(), "number of files hashed concurrently")
+}
+
+func runDedup(cmd *cobra.Command, args []string) error {
+	if dedupKeep != keepFirst && dedupKeep != keepOldest {
+		return fmt.Errorf("invalid --keep %q: must be %s or %s", dedupKeep, keepFirst, keepOldest)
+	}
+
+	files, err := collectFilesRecursive(args)
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		fmt.Println("No files to check")
+		return nil
+	}
+
+	fmt.Printf("Found %d files to check\n\n", len(files))
+
+	stats := dedupFiles(files, dedupKeep, dedupDelete, dedupWorkers)
+	printDedupSummary(stats, dedupDelete)
+
+	if stats.Errors > 0 {
+		if cmd != nil {
+			cmd.SilenceUsage = true
+		}
+		return fmt.Errorf("%w: %d of %d", ErrFilesFailed, stats.Errors, len(files))
+	}
+	return nil
+}
+
+// DedupStats tracks duplicate search statistics
+type DedupStats struct {
+	Groups    int
+	Redundant int
+	Bytes     int64
+	Removed   int
+	Errors    int
+}
+
+// dedupFiles groups files with identical content and prints each group with
+// the copy it keeps first. With del, the other copies are removed.
+func dedupFiles(files []string, keep string, del bool, workers int) *DedupStats {
+	stats := &DedupStats{}
+
+	groups, failed := duplicate.New().GroupDuplicates(files, workers)
+	stats.Errors += failed
+
+	for _, group := range groups {
+		if keep == keepOldest {
+			sortOldestFirst(group)
+		}
+
+		var size int64
+		if info, err := os.Stat(group[0]); err == nil {
+			size = info.Size()
+		}
+		stats.Groups++
+		stats.Redundant += len(group) - 1
+		stats.Bytes += size * int64(len(group)-1)
+
+		fmt.Printf("DUPLICATES (%d copies, %s each):\n", len(group), processor.FormatBytes(size))
+		fmt.Printf("  keep:    %s\n", group[0])
+		for _, file := range group[1:] {
+			if !del {
+				fmt.Printf("  copy:    %s\n", file)
+				continue
+			}
+			if err := os.Remove(file); err != nil {
+				stats.Errors++
+				fmt.Fprintf(os.Stderr, "Error removing %s: %v\n", file, err)
+				continue
+			}
+			stats.Removed++
+			fmt.Printf("  removed: %s\n", file)
+		}
+	}
+
+	return stats
+}
+
+// sortOldestFirst orders a group by modification time, oldest first, keeping
+// the path order for ties and for files that can't be stat'ed
+func sortOldestFirst(group []string) {
+	modTimes := make(map[string]int64, len(group))
+	for _, file := range group {
+		if info, err := os.Stat(file); err == nil {
+			modTimes[file] = info.ModTime().UnixNano()
+		}
+	}
+	slices.SortStableFunc(group, func(a, b string) int {
+		ta, okA := modTimes[a]
+		tb, okB := modTimes[b]
+		switch {
+		case okA != okB:
+			if okA {
+				return -1
+			}
+			return 1
+		case ta < tb:
+			return -1
+		case ta > tb:
+			return 1
+		}
+		return 0
+	})
+}
+
+// printDedupSummary prints duplicate search statistics
+func printDedupSummary(stats *DedupStats, del bool) {
+	fmt.Println("\nDedup Summary:")
+	fmt.Printf("  Duplicate groups: %d\n", stats.Groups)
+	fmt.Printf("  Redundant copies: %d (%s)\n", stats.Redundant, processor.FormatBytes(stats.Bytes))
+
+	if del {
+		fmt.Printf("  Removed:          %d\n", stats.Removed)
+	} else if stats.Redundant > 0 {
+		fmt.Println("  Run with --delete to remove the redundant copies")
+	}
+
+	if stats.Errors > 0 {
+		fmt.Printf("  Errors:           %d\n", stats.Errors)
+	}
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupFiles(t *testing.T) {
+	plant := func(t *testing.T) (string, []string) {
+		dir := t.TempDir()
+		write := func(name, content string, age time.Duration) {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+			require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+			modTime := time.Now().Add(-age)
+			require.NoError(t, os.Chtimes(path, modTime, modTime))
+		}
+		write("2024/a.jpg", "beach", time.Hour)
+		write("2024/b.jpg", "beach", 48*time.Hour)
+		write("2023/c.jpg", "beach", 24*time.Hour)
+		write("2024/d.mov", "clip", time.Hour)
+		write("2024/e.mov", "clip", 2*time.Hour)
+		write("2024/f.jpg", "sunset", time.Hour)
+
+		files, err := collectFilesRecursive([]string{dir})
+		require.NoError(t, err)
+		return dir, files
+	}
+
+	remaining := func(t *testing.T, dir string) []string {
+		files, err := collectFilesRecursive([]string{dir})
+		require.NoError(t, err)
+		var rel []string
+		for _, file := range files {
+			r, err := filepath.Rel(dir, file)
+			require.NoError(t, err)
+			rel = append(rel, filepath.ToSlash(r))
+		}
+		return rel
+	}
+
+	t.Run("report only", func(t *testing.T) {
+		dir, files := plant(t)
+		stats := dedupFiles(files, keepFirst, false, 2)
+		assert.Equal(t, 2, stats.Groups)
+		assert.Equal(t, 3, stats.Redundant)
+		assert.Equal(t, int64(2*len("beach")+len("clip")), stats.Bytes)
+		assert.Zero(t, stats.Removed)
+		assert.Len(t, remaining(t, dir), 6)
+	})
+
+	t.Run("delete keeping first", func(t *testing.T) {
+		dir, files := plant(t)
+		stats := dedupFiles(files, keepFirst, true, 2)
+		assert.Equal(t, 3, stats.Removed)
+		assert.Zero(t, stats.Errors)
+		assert.ElementsMatch(t, []string{"2023/c.jpg", "2024/d.mov", "2024/f.jpg"}, remaining(t, dir))
+	})
+
+	t.Run("delete keeping oldest", func(t *testing.T) {
+		dir, files := plant(t)
+		stats := dedupFiles(files, keepOldest, true, 2)
+		assert.Equal(t, 3, stats.Removed)
+		assert.ElementsMatch(t, []string{"2024/b.jpg", "2024/e.mov", "2024/f.jpg"}, remaining(t, dir))
+	})
+
+	t.Run("symlink is not a copy", func(t *testing.T) {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "b.jpg")
+		require.NoError(t, os.WriteFile(target, []byte("beach"), 0644))
+		require.NoError(t, os.Symlink(target, filepath.Join(dir, "a.jpg")))
+
+		files, err := collectFilesRecursive([]string{dir})
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+
+		stats := dedupFiles(files, keepFirst, true, 2)
+		assert.Zero(t, stats.Groups)
+		assert.Zero(t, stats.Removed)
+		content, err := os.ReadFile(filepath.Join(dir, "a.jpg"))
+		require.NoError(t, err)
+		assert.Equal(t, "beach", string(content))
+	})
+}
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// validatePatterns checks that every glob pattern is well formed
+func validatePatterns(flag string, patterns []string) error {
+	for _, pattern := range patterns {
+		if !doublestar.ValidatePattern(filepath.ToSlash(pattern)) {
+			return fmt.Errorf("invalid %s pattern %q", flag, pattern)
+		}
+	}
+	return nil
+}
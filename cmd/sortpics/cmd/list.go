@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+
+	"github.com/alitto/pond"
+	"github.com/cacack/sortpics-go/internal/exifpool"
+	"github.com/cacack/sortpics-go/internal/rename"
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/cacack/sortpics-go/pkg/processor"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listRecursive bool
+	listPrecision int
+	listOldNaming bool
+	listRawPath   string
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list [flags] SOURCE... DESTINATION",
+	Short: "Print where each file would be organized, without changing anything",
+	Long: `Print "source -> destination" for every supported file in the sources,
+in source path order, and exit.
+
+Nothing is created, copied, or moved. If the destination already exists,
+collisions with files in it are resolved as a real run would (adding _N or
+marking the file as a duplicate), which hashes the colliding files. If it
+doesn't exist yet, nothing can collide, so no file is hashed and the
+destinations are shown without collision suffixes.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runList,
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().BoolVarP(&listRecursive, "recursive", "r", false, "list subdirectories recursively")
+	listCmd.Flags().IntVarP(&listPrecision, "precision", "p", 6, "subsecond precision (0-6)")
+	listCmd.Flags().BoolVar(&listOldNaming, "old-naming", false, "use old naming format (no separator)")
+	listCmd.Flags().StringVar(&listRawPath, "raw-path", "", "separate destination path for RAW files")
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	if err := checkExifTool(); err != nil {
+		return err
+	}
+	if err := checkPrecision(listPrecision); err != nil {
+		return err
+	}
+
+	sources := args[:len(args)-1]
+	destDir := args[len(args)-1]
+	for _, source := range sources {
+		if info, err := os.Stat(source); err != nil || !info.IsDir() {
+			return fmt.Errorf("source directory does not exist: %s", source)
+		}
+	}
+
+	files, err := processor.New(destDir, nil, processor.Options{Recursive: listRecursive}).Collect(sources)
+	if err != nil {
+		return err
+	}
+
+	cfg := &config.ProcessingConfig{
+		Precision: listPrecision,
+		OldNaming: listOldNaming,
+		RawPath:   listRawPath,
+	}
+	failed := listDestinations(os.Stdout, files, destDir, cfg)
+	if failed > 0 {
+		if cmd != nil {
+			cmd.SilenceUsage = true
+		}
+		return fmt.Errorf("%w: %d of %d", ErrFilesFailed, failed, len(files))
+	}
+	return nil
+}
+
+// listDestinations writes "source -> destination" for each file to w, in
+// source path order, and returns how many files couldn't be planned (reported
+// on stderr). Collisions are only resolved when the destination exists.
+func listDestinations(w io.Writer, files []string, destDir string, cfg *config.ProcessingConfig) int {
+	files = slices.Sorted(slices.Values(files))
+	resolve := dirExists(destDir) || (cfg.RawPath != "" && dirExists(cfg.RawPath))
+
+	// Workers borrow ExifTool processes rather than each file starting its own
+	workers := runtime.NumCPU()
+	exifTools := exifpool.New(exifpool.DefaultSize(workers))
+	defer exifTools.Close()
+	run := rename.NewRun(exifTools)
+
+	lines := make([]string, len(files))
+	errs := make([]error, len(files))
+	pool := pond.New(workers, len(files))
+	for i, file := range files {
+		i, file := i, file // Capture for closure
+		pool.Submit(func() {
+			lines[i], errs[i] = listDestination(file, destDir, cfg, run, resolve)
+		})
+	}
+	pool.StopAndWait()
+
+	failed := 0
+	for i, file := range files {
+		if errs[i] != nil {
+			fmt.Fprintf(os.Stderr, "Error planning %s: %v\n", file, errs[i])
+			failed++
+			continue
+		}
+		if lines[i] != "" {
+			fmt.Fprintln(w, lines[i])
+		}
+	}
+	return failed
+}
+
+// listDestination returns the output line for one file, or "" if its
+// extension isn't supported
+func listDestination(file, destDir string, cfg *config.ProcessingConfig, run *rename.Run, resolve bool) (string, error) {
+	ir, err := rename.NewImageRename(file, destDir, cfg, rename.InRun(run))
+	if err != nil {
+		return "", err
+	}
+	defer ir.Close()
+
+	if !ir.IsValidExtension() {
+		return "", nil
+	}
+
+	if !resolve {
+		destination, err := ir.PlannedDestination()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s -> %s", file, destination), nil
+	}
+
+	if err := ir.ParseMetadata(context.Background()); err != nil {
+		return "", err
+	}
+	line := fmt.Sprintf("%s -> %s", file, ir.GetDestination())
+	if ir.IsDuplicate() {
+		line += " (duplicate)"
+	}
+	return line, nil
+}
+
+// dirExists reports whether path is an existing directory
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
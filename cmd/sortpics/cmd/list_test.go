@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/cacack/sortpics-go/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListDestinations(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	testDataDir := filepath.Join("..", "..", "..", "test", "testdata", "basic")
+	files, err := processor.New("", nil, processor.Options{}).Collect([]string{testDataDir})
+	require.NoError(t, err)
+	require.Len(t, files, 5)
+	src := filepath.Dir(files[0])
+	cfg := &config.ProcessingConfig{Precision: 6}
+
+	t.Run("missing destination", func(t *testing.T) {
+		destDir := filepath.Join(t.TempDir(), "dest")
+
+		var out bytes.Buffer
+		assert.Equal(t, 0, listDestinations(&out, files, destDir, cfg))
+		assert.Equal(t, []string{
+			filepath.Join(src, "test_001.jpg") + " -> " + filepath.Join(destDir, "2024", "01", "2024-01-15", "20240115-123045.123456_Canon-Eos5d.jpg"),
+			filepath.Join(src, "test_002.jpg") + " -> " + filepath.Join(destDir, "2024", "01", "2024-01-15", "20240115-144530.654321_Nikon-D850.jpg"),
+			filepath.Join(src, "test_003.jpg") + " -> " + filepath.Join(destDir, "2024", "02", "2024-02-20", "20240220-091522.111111_Sony-IlceA7Iii.jpg"),
+			filepath.Join(src, "test_004.jpg") + " -> " + filepath.Join(destDir, "2024", "03", "2024-03-10", "20240310-182010.999999_Fujifilm-X-T4.jpg"),
+			filepath.Join(src, "test_005.jpg") + " -> " + filepath.Join(destDir, "2024", "12", "2024-12-31", "20241231-235959.000000_Olympus-Om-DE-M1.jpg"),
+		}, strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"))
+		assert.NoDirExists(t, destDir, "list never creates the destination")
+	})
+
+	t.Run("existing destination resolves collisions", func(t *testing.T) {
+		destDir := t.TempDir()
+		dayDir := filepath.Join(destDir, "2024", "01", "2024-01-15")
+		require.NoError(t, os.MkdirAll(dayDir, 0755))
+		data, err := os.ReadFile(filepath.Join(src, "test_001.jpg"))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dayDir, "20240115-123045.123456_Canon-Eos5d.jpg"), data, 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dayDir, "20240115-144530.654321_Nikon-D850.jpg"), []byte("other"), 0644))
+
+		var out bytes.Buffer
+		assert.Equal(t, 0, listDestinations(&out, files[:2], destDir, cfg))
+		assert.Equal(t, []string{
+			filepath.Join(src, "test_001.jpg") + " -> " + filepath.Join(dayDir, "20240115-123045.123456_Canon-Eos5d.jpg") + " (duplicate)",
+			filepath.Join(src, "test_002.jpg") + " -> " + filepath.Join(dayDir, "20240115-144530.654321_Nikon-D850_1.jpg"),
+		}, strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"))
+
+		entries, err := os.ReadDir(dayDir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2, "list never writes to the destination")
+	})
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/cacack/sortpics-go/internal/pathgen"
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/cacack/sortpics-go/pkg/processor"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reorganizePrecision int
+	reorganizeOldNaming bool
+	reorganizeNameCase  string
+	reorganizeDryRun    bool
+	reorganizeWorkers   int
+	reorganizeVerbose   int
+)
+
+var reorganizeCmd = &cobra.Command{
+	Use:   "reorganize [flags] DIRECTORY",
+	Short: "Re-sort an organized archive in place with new naming settings",
+	Long: `Re-sort an already organized archive with new naming settings, such as
+a different --precision, without importing it again.
+
+DIRECTORY is both the source and the destination: every supported file in
+it is moved to the path the settings give it. The file list is taken before
+anything moves, so files are never picked up twice. Files already at their
+path are left alone, metadata is not rewritten, and directories emptied by
+the moves are removed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runReorganize,
+}
+
+func init() {
+	rootCmd.AddCommand(reorganizeCmd)
+
+	reorganizeCmd.Flags().IntVarP(&reorganizePrecision, "precision", "p", 6, "subsecond precision (0-6)")
+	reorganizeCmd.Flags().BoolVar(&reorganizeOldNaming, "old-naming", false, "use old naming format (no separator)")
+	reorganizeCmd.Flags().StringVar(&reorganizeNameCase, "name-case", pathgen.NameCaseCamel, "camera make/model case in filenames (camel, upper, lower, preserve)")
+	reorganizeCmd.Flags().BoolVar(&reorganizeDryRun, "dry-run", false, "show what would be moved without moving anything")
+	reorganizeCmd.Flags().IntVarP(&reorganizeWorkers, "workers", "w", runtime.NumCPU(), "number of worker goroutines")
+	reorganizeCmd.Flags().CountVarP(&reorganizeVerbose, "verbose", "v", "increase verbosity (-v, -vv, -vvv)")
+}
+
+func runReorganize(cmd *cobra.Command, args []string) error {
+	if err := checkExifTool(); err != nil {
+		return err
+	}
+
+	dir := args[0]
+	if !dirExists(dir) {
+		return fmt.Errorf("directory does not exist: %s", dir)
+	}
+	if !slices.Contains(pathgen.NameCases, reorganizeNameCase) {
+		return fmt.Errorf("invalid --name-case %q: must be one of %s", reorganizeNameCase, strings.Join(pathgen.NameCases, ", "))
+	}
+	if err := checkPrecision(reorganizePrecision); err != nil {
+		return err
+	}
+
+	cfg := &config.ProcessingConfig{
+		Move:            true,
+		DryRun:          reorganizeDryRun,
+		Precision:       reorganizePrecision,
+		OldNaming:       reorganizeOldNaming,
+		NameCase:        reorganizeNameCase,
+		NoMetadataWrite: true,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// A dry run is only useful if it shows the moves
+	verbose := reorganizeVerbose
+	if reorganizeDryRun {
+		verbose = max(verbose, 1)
+	}
+
+	stats, total, err := reorganize(ctx, dir, cfg, reorganizeWorkers, verbose)
+	if stats != nil {
+		printSummary(stats, reorganizeVerbose)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !reorganizeDryRun {
+		if removed := removeEmptyDirs(dir); removed > 0 {
+			fmt.Printf("Removed %d empty directories\n", removed)
+		}
+	}
+
+	if stats.Errors > 0 {
+		if cmd != nil {
+			cmd.SilenceUsage = true
+		}
+		return fmt.Errorf("%w: %d of %d", ErrFilesFailed, stats.Errors, total)
+	}
+	return nil
+}
+
+// reorganize moves every supported file under dir to the path cfg gives it
+// within dir. The files are collected before any is moved, so moved files
+// aren't processed again. It returns the stats and the number of files found.
+func reorganize(ctx context.Context, dir string, cfg *config.ProcessingConfig, workers, verbose int) (*processor.Stats, int, error) {
+	proc := processor.New(dir, cfg, processor.Options{
+		Workers:   workers,
+		Verbose:   verbose,
+		Recursive: true,
+	})
+	defer proc.Close()
+
+	files, err := proc.Collect([]string{dir})
+	if err != nil {
+		return nil, 0, err
+	}
+	stats, err := proc.Process(ctx, files)
+	return stats, len(files), err
+}
+
+// removeEmptyDirs removes the directories under dir (never dir itself) that
+// are empty, deepest first, and returns how many were removed. Unlike --clean
+// it never deletes files.
+func removeEmptyDirs(dir string) int {
+	var removed int
+	var walk func(path string) bool
+	walk = func(path string) bool {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return false
+		}
+		remaining := 0
+		for _, entry := range entries {
+			if entry.IsDir() && walk(filepath.Join(path, entry.Name())) {
+				continue
+			}
+			remaining++
+		}
+		if remaining > 0 || path == dir {
+			return false
+		}
+		if err := os.Remove(path); err != nil {
+			return false
+		}
+		removed++
+		return true
+	}
+	walk(dir)
+	return removed
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReorganize(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	archive := t.TempDir()
+	incoming := filepath.Join(archive, "incoming")
+	require.NoError(t, os.MkdirAll(incoming, 0755))
+	testDataDir := filepath.Join("..", "..", "..", "test", "testdata", "basic")
+	for _, name := range []string{"test_001.jpg", "test_002.jpg", "test_003.jpg", "test_004.jpg", "test_005.jpg"} {
+		data, err := os.ReadFile(filepath.Join(testDataDir, name))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(incoming, name), data, 0644))
+	}
+
+	archiveFiles := func() []string {
+		var files []string
+		require.NoError(t, filepath.WalkDir(archive, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				rel, err := filepath.Rel(archive, path)
+				require.NoError(t, err)
+				files = append(files, filepath.ToSlash(rel))
+			}
+			return nil
+		}))
+		return files
+	}
+
+	reorganizeAt := func(precision int) {
+		cfg := &config.ProcessingConfig{Move: true, Precision: precision, NoMetadataWrite: true}
+		stats, total, err := reorganize(context.Background(), archive, cfg, 2, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 5, total)
+		assert.Equal(t, int64(5), stats.Processed)
+		assert.Zero(t, stats.Errors)
+		assert.Zero(t, stats.Duplicates, "files already in place don't collide with themselves")
+		removeEmptyDirs(archive)
+	}
+
+	reorganizeAt(6)
+	assert.Equal(t, []string{
+		"2024/01/2024-01-15/20240115-123045.123456_Canon-Eos5d.jpg",
+		"2024/01/2024-01-15/20240115-144530.654321_Nikon-D850.jpg",
+		"2024/02/2024-02-20/20240220-091522.111111_Sony-IlceA7Iii.jpg",
+		"2024/03/2024-03-10/20240310-182010.999999_Fujifilm-X-T4.jpg",
+		"2024/12/2024-12-31/20241231-235959.000000_Olympus-Om-DE-M1.jpg",
+	}, archiveFiles())
+	assert.NoDirExists(t, incoming)
+
+	// Re-sorting with new settings renames the organized files
+	reorganizeAt(3)
+	assert.Equal(t, []string{
+		"2024/01/2024-01-15/20240115-123045.123_Canon-Eos5d.jpg",
+		"2024/01/2024-01-15/20240115-144530.654_Nikon-D850.jpg",
+		"2024/02/2024-02-20/20240220-091522.111_Sony-IlceA7Iii.jpg",
+		"2024/03/2024-03-10/20240310-182010.999_Fujifilm-X-T4.jpg",
+		"2024/12/2024-12-31/20241231-235959.000_Olympus-Om-DE-M1.jpg",
+	}, archiveFiles())
+
+	// Running again with the same settings leaves everything in place
+	reorganizeAt(3)
+	assert.Len(t, archiveFiles(), 5)
+}
+
+func TestCheckPrecision(t *testing.T) {
+	assert.NoError(t, checkPrecision(0))
+	assert.NoError(t, checkPrecision(6))
+	assert.Error(t, checkPrecision(-1), "would panic slicing the subsecond digits")
+	assert.Error(t, checkPrecision(7))
+}
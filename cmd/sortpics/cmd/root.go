@@ -1,57 +1,135 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"io/fs"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
-	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/alitto/pond"
+	"github.com/cacack/sortpics-go/internal/duplicate"
+	"github.com/cacack/sortpics-go/internal/exifpool"
+	"github.com/cacack/sortpics-go/internal/metadata"
+	"github.com/cacack/sortpics-go/internal/pathgen"
 	"github.com/cacack/sortpics-go/internal/rename"
 	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/cacack/sortpics-go/pkg/processor"
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
 )
 
 const version = "0.1.0"
 
+// minFilenameLength is the smallest --max-filename-length accepted
+const minFilenameLength = 48
+
 var (
 	// Operation mode flags
 	copyMode  bool
 	moveMode  bool
+	inPlace   bool
 	dryRun    bool
 	recursive bool
+	maxDepth  int
 	clean     bool
 	verbose   int
+	quiet     bool
+	eventMode bool
+	treeMode  bool
+	rollback  bool
+	execCmd   string
 
 	// Path flags
-	rawPath string
+	rawPath          string
+	manifestPath     string
+	filesFrom        string
+	stateFile        string
+	groupBursts      int
+	quarantineNoDate bool
+	sourcePrefixes   []string
+	preserveTree     bool
+	keepLivePhotos   bool
+	chmod            string
+	owner            string
 
 	// Naming flags
-	precision int
-	oldNaming bool
+	precision           int
+	oldNaming           bool
+	nameCase            string
+	separator           string
+	modelMapFile        string
+	keepOriginalName    bool
+	ratingDirs          bool
+	maxCollisions       int
+	maxFilenameLength   int
+	windowsSafe         bool
+	unknownLabel        string
+	preserveCompoundExt bool
+	fixExtension        bool
+	sequenceOrder       bool
+	deterministic       bool
+	hashSuffix          bool
+	overwrite           bool
+	dedupSiblings       bool
+	backup              bool
+	backupDir           string
 
 	// Time adjustment flags
-	timeAdjust string
-	dayAdjust  int
+	timeAdjust         string
+	dayAdjust          string
+	quickTimeUTC       bool
+	timezone           string
+	preferFilenameDate bool
 
 	// Metadata flags
-	album        string
-	albumFromDir bool
-	tags         []string
+	album             string
+	albumFromDir      bool
+	albumTemplate     string
+	tags              []string
+	noMetadataWrite   bool
+	sidecarMetadata   bool
+	stripGPS          bool
+	writeChecksums    bool
+	failOnUnparseable bool
+
+	// Filter flags
+	minSize            string
+	followSymlinks     bool
+	skipExistingHashes bool
+	skipOrganized      bool
+	deleteDupSource    bool
+	excludePatterns    []string
+	includePatterns    []string
+	scanZips           bool
+	skipDerivatives    bool
+	derivativePatterns []string
 
 	// Performance flags
 	numWorkers int
+	exifTools  int
+	durable    bool
+	verifyCopy bool
+
+	// Limit flags
+	limit      int
+	limitBytes string
 )
 
+// ErrFilesFailed is returned by a run that completed but failed on some
+// files, so scripts can tell partial failures (exit code 2) from fatal ones
+var ErrFilesFailed = errors.New("some files failed")
+
 var rootCmd = &cobra.Command{
 	Use:   "sortpics [flags] SOURCE... DESTINATION",
 	Short: "Organize photos and videos by EXIF metadata",
@@ -69,7 +147,7 @@ Features:
   - RAW file segregation
   - Album and keyword tagging`,
 	Version: version,
-	Args:    cobra.MinimumNArgs(2),
+	Args:    validateArgs,
 	RunE:    run,
 }
 
@@ -77,38 +155,145 @@ func Execute() error {
 	return rootCmd.Execute()
 }
 
+// validateArgs requires SOURCE... DESTINATION. --files-from replaces the
+// sources and --in-place drops the destination.
+func validateArgs(cmd *cobra.Command, args []string) error {
+	switch {
+	case filesFrom != "" && inPlace:
+		if len(args) != 0 {
+			return fmt.Errorf("--files-from with --in-place takes no arguments, got %d args", len(args))
+		}
+		return nil
+	case filesFrom != "":
+		if len(args) != 1 {
+			return fmt.Errorf("--files-from takes only the DESTINATION argument, got %d args", len(args))
+		}
+		return nil
+	case inPlace:
+		return cobra.MinimumNArgs(1)(cmd, args)
+	}
+	return cobra.MinimumNArgs(2)(cmd, args)
+}
+
 func init() {
 	// Operation mode flags
 	rootCmd.Flags().BoolVarP(&copyMode, "copy", "c", false, "copy files (leave originals)")
 	rootCmd.Flags().BoolVarP(&moveMode, "move", "m", false, "move files (remove originals)")
+	rootCmd.Flags().BoolVar(&inPlace, "in-place", false, "rename files within their current directory (no DESTINATION)")
+	rootCmd.Flags().BoolVar(&inPlace, "rename-only", false, "alias for --in-place")
 	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "preview operations without executing")
 	rootCmd.Flags().BoolVar(&dryRun, "pretend", false, "alias for --dry-run")
+	rootCmd.Flags().BoolVar(&rollback, "rollback-on-error", false, "all-or-nothing: if any file fails, stop and undo every file already copied or moved in this run")
+	rootCmd.Flags().StringVar(&execCmd, "exec", "", "run CMD after each file is organized; {src} and {dst} are replaced by the file's paths (printed instead with --dry-run)")
+	rootCmd.Flags().BoolVar(&treeMode, "tree", false, "with --dry-run, print the planned destination directories as a tree with file counts")
 	rootCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "process subdirectories recursively")
+	rootCmd.Flags().IntVar(&maxDepth, "max-depth", -1, "with --recursive, descend at most N directory levels below each source (0 = source only, -1 = unlimited)")
 	rootCmd.Flags().BoolVarP(&clean, "clean", "C", false, "remove empty directories after move")
+	rootCmd.Flags().StringSliceVar(&cameraMetadataExtensions, "junk-ext", defaultCameraMetadataExtensions, "camera junk file extensions or glob patterns removed by --clean")
 	rootCmd.Flags().CountVarP(&verbose, "verbose", "v", "increase verbosity (-v, -vv, -vvv)")
+	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "print only the final summary (errors still go to stderr); no progress bar")
+	rootCmd.Flags().BoolVar(&eventMode, "events", false, "write one JSON object per file event (start, copied, skipped, duplicate, error) to stdout instead of text output")
 
 	// Path flags
 	rootCmd.Flags().StringVar(&rawPath, "raw-path", "", "separate path for RAW files")
+	rootCmd.Flags().StringVar(&manifestPath, "manifest", "", "write a CSV manifest of every operation to this file")
+	rootCmd.Flags().IntVar(&groupBursts, "group-bursts", 0, "group shots taken within N milliseconds of each other into a burst_HHMMSS/ subdirectory")
+	rootCmd.Flags().BoolVar(&quarantineNoDate, "quarantine-no-date", false, "put files with no metadata or filename date into no-date/ under their original name instead of dating them by file time")
+	rootCmd.Flags().StringArrayVar(&sourcePrefixes, "prefix", []string{}, "prefix the top-level destination directory of files from a source, as SOURCE=PREFIX (can be repeated, e.g. /media/card1=card1 gives card1-2024/...)")
+	rootCmd.Flags().BoolVar(&keepLivePhotos, "keep-live-photos", false, "keep each Live Photo's .mov next to its still with the same basename (paired by ContentIdentifier, else by filename)")
+	rootCmd.Flags().BoolVar(&preserveTree, "preserve-tree", false, "keep each file's subdirectory below its source under the date folder (e.g. .../2024-01-15/100CANON/)")
+	rootCmd.Flags().StringVar(&chmod, "chmod", "", "set the permissions of organized files to this octal MODE (e.g. 644) instead of keeping the source's")
+	rootCmd.Flags().StringVar(&owner, "owner", "", "give organized files this numeric UID[:GID] or :GID owner (Unix only; usually needs root)")
+	rootCmd.Flags().StringVar(&filesFrom, "files-from", "", "read newline-separated source files from this list file (- for stdin) instead of walking directories")
+	rootCmd.Flags().StringVar(&stateFile, "state-file", "", "only process files modified since the last successful run recorded in this file, and record this run on success")
 
 	// Naming flags
 	rootCmd.Flags().IntVarP(&precision, "precision", "p", 6, "subsecond precision (digits)")
 	rootCmd.Flags().BoolVar(&oldNaming, "old-naming", false, "use old naming format (no separator)")
+	rootCmd.Flags().StringVar(&nameCase, "name-case", pathgen.NameCaseCamel, "camera make/model case in filenames (camel, upper, lower, preserve)")
+	rootCmd.Flags().StringVar(&separator, "separator", pathgen.DefaultSeparator, "delimiter between camera make and model in filenames (-, _, +, ~)")
+	rootCmd.Flags().StringVar(&modelMapFile, "model-map", "", "JSON file mapping camera models to the names to use in filenames (e.g. {\"ILCE-7M3\": \"A7III\"})")
+	rootCmd.Flags().BoolVar(&keepOriginalName, "keep-original-name", false, "append the source filename stem to generated filenames (e.g. _Canon-EOS5d_IMG_1234.jpg)")
+	rootCmd.Flags().BoolVar(&ratingDirs, "rating-dirs", false, "file images under rating-N/ (or unrated/, rejected/) directories by their XMP star rating")
+	rootCmd.Flags().IntVar(&maxCollisions, "max-collisions", duplicate.DefaultMaxCollisions, "maximum _N suffix tried for filename collisions")
+	rootCmd.Flags().BoolVar(&windowsSafe, "windows-safe", false, "avoid names NTFS/SMB destinations reject (trailing dots, reserved names like CON) and treat names differing only in case as collisions")
+	rootCmd.Flags().StringVar(&unknownLabel, "unknown-label", pathgen.DefaultUnknownLabel, "directory and filename prefix for files with no date (e.g. _NoDate)")
+	rootCmd.Flags().IntVar(&maxFilenameLength, "max-filename-length", pathgen.DefaultMaxFilenameLength, "longest generated filename in bytes; longer camera names are shortened to fit")
+	rootCmd.Flags().BoolVar(&hashSuffix, "hash-suffix", false, "resolve filename collisions with _ and the first 8 hex digits of the file's SHA256 instead of _N")
+	rootCmd.Flags().BoolVar(&overwrite, "overwrite", false, "replace an existing destination with different content instead of adding a _N suffix (duplicates are still skipped)")
+	rootCmd.Flags().BoolVar(&dedupSiblings, "dedup-siblings", false, "also skip a file when a file from the same second next to its destination (other subseconds, camera, or _N) has identical content")
+	rootCmd.Flags().BoolVar(&backup, "backup", false, "with --overwrite, keep each replaced file or sidecar next to it with a .bak extension")
+	rootCmd.Flags().StringVar(&backupDir, "backup-replaced", "", "with --overwrite, move each replaced file into DIR, keeping its path relative to the destination")
+	rootCmd.Flags().BoolVar(&sequenceOrder, "sequence-order", false, "assign _N collision suffixes in source filename order (IMG_0123 before IMG_0124)")
+	rootCmd.Flags().BoolVar(&deterministic, "deterministic", false, "assign _N collision suffixes by datetime, then source path, so re-runs give the same names")
+	rootCmd.Flags().BoolVar(&preserveCompoundExt, "preserve-compound-ext", false, "keep compound extensions like .tar.gz intact when adding _N suffixes")
+	rootCmd.Flags().BoolVar(&fixExtension, "fix-extension", false, "name destinations with the extension of the file type ExifTool detects (e.g. .png for a PNG named .jpg)")
 
 	// Time adjustment flags
 	rootCmd.Flags().StringVar(&timeAdjust, "time-adjust", "", "adjust time (HH:MM:SS or -HH:MM:SS)")
-	rootCmd.Flags().IntVar(&dayAdjust, "day-adjust", 0, "adjust days (positive or negative)")
+	rootCmd.Flags().StringVar(&dayAdjust, "day-adjust", "", "adjust days (positive or negative, decimals allowed)")
+	rootCmd.Flags().BoolVar(&quickTimeUTC, "quicktime-utc", false, "treat video (QuickTime) dates as UTC and convert to local time")
+	rootCmd.Flags().StringVar(&timezone, "timezone", "", "time zone (e.g. America/New_York) UTC dates are converted to before bucketing by day (default: the system's)")
+	rootCmd.Flags().BoolVar(&preferFilenameDate, "prefer-filename-date", false, "date files from their filename (e.g. 1985-07_scan.jpg) before EXIF, for scans whose EXIF holds the scan date")
 
 	// Metadata flags
 	rootCmd.Flags().StringVar(&album, "album", "", "set album metadata")
 	rootCmd.Flags().BoolVar(&albumFromDir, "album-from-directory", false, "use parent directory as album")
-	rootCmd.Flags().StringSliceVarP(&tags, "tag", "t", []string{}, "add keyword tags (can be repeated)")
+	rootCmd.Flags().StringVar(&albumTemplate, "album-template", "", "derive the album from metadata, overriding --album (placeholders {year}, {month}, {day}, {make}, {model}, {lens}, {origname}; e.g. '{year}-{month}')")
+	rootCmd.Flags().StringSliceVarP(&tags, "tag", "t", []string{}, "add keyword tags (can be repeated; use | for hierarchy, e.g. Places|France)")
+	rootCmd.Flags().BoolVar(&writeChecksums, "write-checksums", false, "write a <file>.sha256 sidecar next to each organized file for later integrity checks")
+	rootCmd.Flags().BoolVar(&stripGPS, "strip-gps", false, "remove GPS location tags from organized files (sources are untouched)")
+	rootCmd.Flags().BoolVar(&noMetadataWrite, "no-metadata-write", false, "leave EXIF untouched so destinations are byte-identical to sources")
+	rootCmd.Flags().BoolVar(&sidecarMetadata, "sidecar-metadata", false, "write datetime, album and keywords of RAW files to an .xmp sidecar instead of the RAW")
+	rootCmd.Flags().BoolVar(&failOnUnparseable, "fail-on-unparseable", false, "exit with code 2 if ExifTool couldn't parse any file, even ones dated from their filename or file time")
+
+	// Filter flags
+	rootCmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "follow symlinked directories when scanning recursively")
+	rootCmd.Flags().BoolVar(&skipExistingHashes, "skip-existing-hashes", false, "skip files whose content already exists anywhere in the destination")
+	rootCmd.Flags().BoolVar(&deleteDupSource, "delete-duplicate-source", false, "with --move, delete sources whose content is already in the archive instead of leaving them")
+	rootCmd.Flags().BoolVar(&skipOrganized, "skip-organized", false, "skip files that already have their canonical name and directory, without reading metadata")
+	rootCmd.Flags().StringArrayVar(&excludePatterns, "exclude", []string{}, "skip paths matching this glob, relative to the source (can be repeated, e.g. 'private/**', '*.LRV')")
+	rootCmd.Flags().StringArrayVar(&includePatterns, "include", []string{}, "only process files matching this glob, relative to the source (can be repeated, e.g. 'DCIM/100CANON/*')")
+	rootCmd.Flags().BoolVar(&scanZips, "scan-zips", false, "extract supported files from .zip archives in the sources and organize them too")
+	rootCmd.Flags().BoolVar(&skipDerivatives, "skip-derivatives", false, "skip edited derivatives and edit sidecars such as Apple's IMG_E1234.JPG and .AAE files")
+	rootCmd.Flags().StringSliceVar(&derivativePatterns, "derivative-pattern", processor.DefaultDerivativePatterns, "filename globs treated as derivatives by --skip-derivatives (replaces the defaults)")
+	rootCmd.Flags().StringVar(&minSize, "min-size", "", "skip files smaller than this size (e.g. 500, 100KB, 2MB)")
 
 	// Performance flags
-	rootCmd.Flags().IntVarP(&numWorkers, "workers", "w", runtime.NumCPU(), "number of worker goroutines")
+	rootCmd.Flags().IntVarP(&numWorkers, "workers", "w", runtime.NumCPU(), fmt.Sprintf("number of worker goroutines (0: auto, twice the CPU count up to %d)", processor.MaxAutoWorkers))
+	rootCmd.Flags().IntVar(&exifTools, "exiftool-processes", 0, fmt.Sprintf("ExifTool processes shared by the workers (default: one per worker, up to %d)", exifpool.DefaultMaxSize))
+	rootCmd.Flags().BoolVar(&durable, "durable", false, "fsync each copy before a move deletes its source, so files survive a power failure (slower)")
+	rootCmd.Flags().BoolVar(&verifyCopy, "verify-copy", false, "read each copy back and compare its SHA256 with the source before keeping it or deleting the source")
+
+	// Limit flags
+	rootCmd.Flags().IntVar(&limit, "limit", 0, "stop after this many files, e.g. for a spot check (0 for no limit)")
+	rootCmd.Flags().StringVar(&limitBytes, "limit-bytes", "", "stop once the files processed add up to this size (e.g. 500MB)")
 
 	// Mark mutually exclusive flags
 	rootCmd.MarkFlagsMutuallyExclusive("copy", "move")
+	rootCmd.MarkFlagsMutuallyExclusive("copy", "in-place")
+	rootCmd.MarkFlagsMutuallyExclusive("move", "in-place")
+	rootCmd.MarkFlagsMutuallyExclusive("copy", "rename-only")
+	rootCmd.MarkFlagsMutuallyExclusive("move", "rename-only")
+	rootCmd.MarkFlagsMutuallyExclusive("old-naming", "separator")
+	rootCmd.MarkFlagsMutuallyExclusive("hash-suffix", "sequence-order")
+	rootCmd.MarkFlagsMutuallyExclusive("overwrite", "hash-suffix")
+	rootCmd.MarkFlagsMutuallyExclusive("overwrite", "sequence-order")
+	rootCmd.MarkFlagsMutuallyExclusive("deterministic", "sequence-order")
+	rootCmd.MarkFlagsMutuallyExclusive("deterministic", "overwrite")
+	rootCmd.MarkFlagsMutuallyExclusive("backup", "backup-replaced")
+	rootCmd.MarkFlagsMutuallyExclusive("events", "verbose")
+	rootCmd.MarkFlagsMutuallyExclusive("quiet", "verbose")
+	rootCmd.MarkFlagsMutuallyExclusive("quiet", "events")
+	rootCmd.MarkFlagsMutuallyExclusive("events", "tree")
 	rootCmd.MarkFlagsMutuallyExclusive("album", "album-from-directory")
+	rootCmd.MarkFlagsMutuallyExclusive("album-template", "album-from-directory")
+	rootCmd.MarkFlagsMutuallyExclusive("no-metadata-write", "album-template")
+	rootCmd.MarkFlagsMutuallyExclusive("no-metadata-write", "album")
+	rootCmd.MarkFlagsMutuallyExclusive("no-metadata-write", "album-from-directory")
+	rootCmd.MarkFlagsMutuallyExclusive("no-metadata-write", "sidecar-metadata")
+	rootCmd.MarkFlagsMutuallyExclusive("no-metadata-write", "tag")
+	rootCmd.MarkFlagsMutuallyExclusive("no-metadata-write", "strip-gps")
 }
 
 func run(cmd *cobra.Command, args []string) error {
@@ -118,17 +303,224 @@ func run(cmd *cobra.Command, args []string) error {
 	}
 
 	// Validate flags
-	if !copyMode && !moveMode {
-		return fmt.Errorf("must specify either --copy or --move")
+	if !copyMode && !moveMode && !inPlace {
+		return fmt.Errorf("must specify either --copy, --move, or --in-place")
+	}
+
+	if inPlace && (rawPath != "" || skipExistingHashes || quarantineNoDate || scanZips) {
+		return fmt.Errorf("--in-place cannot be combined with --raw-path, --skip-existing-hashes, --quarantine-no-date, or --scan-zips")
 	}
 
 	if clean && !moveMode {
 		return fmt.Errorf("--clean requires --move")
 	}
 
-	// Parse arguments
-	sourceDirs := args[:len(args)-1]
-	destDir := args[len(args)-1]
+	if treeMode && !dryRun {
+		return fmt.Errorf("--tree requires --dry-run")
+	}
+
+	if deleteDupSource && !moveMode {
+		return fmt.Errorf("--delete-duplicate-source requires --move")
+	}
+
+	// A deleted duplicate source can't be brought back
+	if rollback && deleteDupSource {
+		return fmt.Errorf("--rollback-on-error cannot be combined with --delete-duplicate-source")
+	}
+
+	if backup && !overwrite {
+		return fmt.Errorf("--backup requires --overwrite")
+	}
+	if backupDir != "" && !overwrite {
+		return fmt.Errorf("--backup-replaced requires --overwrite")
+	}
+
+	// A replaced file can only be put back from its backup
+	if rollback && overwrite && !backup && backupDir == "" {
+		return fmt.Errorf("--rollback-on-error with --overwrite requires --backup or --backup-replaced")
+	}
+
+	if execCmd != "" {
+		if err := processor.ValidateExec(execCmd); err != nil {
+			return fmt.Errorf("invalid --exec: %w", err)
+		}
+	}
+
+	if groupBursts < 0 {
+		return fmt.Errorf("--group-bursts must not be negative")
+	}
+
+	if inPlace && groupBursts > 0 {
+		return fmt.Errorf("--in-place cannot be combined with --group-bursts")
+	}
+
+	if inPlace && ratingDirs {
+		return fmt.Errorf("--in-place cannot be combined with --rating-dirs")
+	}
+
+	if inPlace && len(sourcePrefixes) > 0 {
+		return fmt.Errorf("--in-place cannot be combined with --prefix")
+	}
+
+	// In place the destination is the source itself
+	if inPlace && stripGPS {
+		return fmt.Errorf("--in-place cannot be combined with --strip-gps")
+	}
+
+	// The video half would keep the location the still loses
+	if stripGPS && keepLivePhotos {
+		return fmt.Errorf("--strip-gps cannot be combined with --keep-live-photos")
+	}
+
+	if inPlace && preserveTree {
+		return fmt.Errorf("--in-place cannot be combined with --preserve-tree")
+	}
+
+	// A file list has no source directories to be relative to
+	if filesFrom != "" && preserveTree {
+		return fmt.Errorf("--files-from cannot be combined with --preserve-tree")
+	}
+
+	// A file list is processed as given, without the walk that applies the state
+	if filesFrom != "" && stateFile != "" {
+		return fmt.Errorf("--files-from cannot be combined with --state-file")
+	}
+
+	// Re-running would append the stem to names that already carry it
+	if inPlace && keepOriginalName {
+		return fmt.Errorf("--in-place cannot be combined with --keep-original-name")
+	}
+
+	if skipDerivatives {
+		for _, pattern := range derivativePatterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid --derivative-pattern %q: %w", pattern, err)
+			}
+		}
+	}
+
+	if maxDepth < -1 {
+		return fmt.Errorf("--max-depth must be at least -1 (unlimited)")
+	}
+	if maxDepth >= 0 && !recursive {
+		return fmt.Errorf("--max-depth requires --recursive")
+	}
+
+	if maxCollisions < 1 {
+		return fmt.Errorf("--max-collisions must be at least 1")
+	}
+
+	// Room for the date prefix, a collision suffix, and a long extension
+	if maxFilenameLength < minFilenameLength {
+		return fmt.Errorf("--max-filename-length must be at least %d", minFilenameLength)
+	}
+
+	if err := pathgen.ValidateUnknownLabel(unknownLabel); err != nil {
+		return fmt.Errorf("invalid --unknown-label: %w", err)
+	}
+
+	if !slices.Contains(pathgen.NameCases, nameCase) {
+		return fmt.Errorf("invalid --name-case %q: must be one of %s", nameCase, strings.Join(pathgen.NameCases, ", "))
+	}
+
+	if err := pathgen.ValidateSeparator(separator); err != nil {
+		return fmt.Errorf("invalid --separator: %w", err)
+	}
+
+	var modelMap map[string]string
+	if modelMapFile != "" {
+		m, err := metadata.LoadModelMap(modelMapFile)
+		if err != nil {
+			return fmt.Errorf("invalid --model-map: %w", err)
+		}
+		modelMap = m
+	}
+
+	if err := pathgen.ValidateTemplate(albumTemplate); err != nil {
+		return fmt.Errorf("invalid --album-template: %w", err)
+	}
+
+	if timeAdjust != "" {
+		if _, err := rename.CalculateTimeDelta(timeAdjust); err != nil {
+			return fmt.Errorf("invalid --time-adjust: %w", err)
+		}
+	}
+
+	if dayAdjust != "" {
+		if _, err := rename.CalculateDayDelta(dayAdjust); err != nil {
+			return fmt.Errorf("invalid --day-adjust: %w", err)
+		}
+	}
+
+	var location *time.Location
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return fmt.Errorf("invalid --timezone: %w", err)
+		}
+		location = loc
+	}
+
+	if err := validatePatterns("--exclude", excludePatterns); err != nil {
+		return err
+	}
+
+	if err := validatePatterns("--include", includePatterns); err != nil {
+		return err
+	}
+
+	prefixes, err := parseSourcePrefixes(sourcePrefixes)
+	if err != nil {
+		return err
+	}
+
+	minSizeBytes, err := parseSize(minSize)
+	if err != nil {
+		return fmt.Errorf("invalid --min-size: %w", err)
+	}
+
+	if exifTools < 0 {
+		return fmt.Errorf("--exiftool-processes must not be negative")
+	}
+
+	// 0 picks a worker count suited to the IO-bound workload
+	if numWorkers < 0 {
+		return fmt.Errorf("--workers must not be negative")
+	}
+	workers := numWorkers
+	if workers == 0 {
+		workers = processor.AutoWorkers()
+	}
+
+	if limit < 0 {
+		return fmt.Errorf("--limit must not be negative")
+	}
+	fileMode, err := parseFileMode(chmod)
+	if err != nil {
+		return fmt.Errorf("invalid --chmod: %w", err)
+	}
+	fileOwner, err := parseOwner(owner)
+	if err != nil {
+		return fmt.Errorf("invalid --owner: %w", err)
+	}
+	// Fail before any file is organized rather than on each one
+	if fileOwner != nil && !dryRun {
+		if err := rename.CheckOwner(*fileOwner); err != nil {
+			return fmt.Errorf("--owner: %w", err)
+		}
+	}
+	limitBytesValue, err := parseSize(limitBytes)
+	if err != nil {
+		return fmt.Errorf("invalid --limit-bytes: %w", err)
+	}
+
+	// Parse arguments (in place, every argument is a source)
+	sourceDirs := args
+	destDir := ""
+	if !inPlace {
+		sourceDirs = args[:len(args)-1]
+		destDir = args[len(args)-1]
+	}
 
 	// Validate paths
 	for _, src := range sourceDirs {
@@ -137,6 +529,27 @@ func run(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	absSourceDirs := make([]string, 0, len(sourceDirs))
+	for _, src := range sourceDirs {
+		absSrc, err := filepath.Abs(src)
+		if err != nil {
+			return fmt.Errorf("failed to resolve source %s: %w", src, err)
+		}
+		absSourceDirs = append(absSourceDirs, absSrc)
+	}
+
+	// Output inside a source would be re-scanned as input, unless it's skipped as organized
+	if destDir != "" && !skipOrganized {
+		if err := checkNotNested("destination", destDir, sourceDirs); err != nil {
+			return err
+		}
+	}
+	if rawPath != "" && !skipOrganized {
+		if err := checkNotNested("--raw-path", rawPath, sourceDirs); err != nil {
+			return err
+		}
+	}
+
 	// Setup signal handling for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -146,191 +559,384 @@ func run(cmd *cobra.Command, args []string) error {
 
 	go func() {
 		<-sigChan
-		fmt.Fprintln(os.Stderr, "\nReceived interrupt signal. Canceling...")
+		fmt.Fprintln(os.Stderr, "\nReceived interrupt signal. Stopping and rolling back in-flight copies (press Ctrl-C again to force exit)...")
 		cancel()
 
-		// Force exit after 2 seconds if graceful shutdown fails
-		time.Sleep(2 * time.Second)
-		fmt.Fprintln(os.Stderr, "Force exit (timeout)")
+		// A second signal aborts immediately, possibly leaving in-flight files half-done
+		<-sigChan
+		fmt.Fprintln(os.Stderr, "Force exit")
 		os.Exit(130)
 	}()
 
-	// Convert day adjust to string if needed
-	dayAdjustStr := ""
-	if dayAdjust != 0 {
-		dayAdjustStr = fmt.Sprintf("%d", dayAdjust)
-	}
-
 	// Build processing config
 	cfg := &config.ProcessingConfig{
-		OldNaming:    oldNaming,
-		RawPath:      rawPath,
-		Move:         moveMode,
-		Precision:    precision,
-		DryRun:       dryRun,
-		TimeAdjust:   timeAdjust,
-		DayAdjust:    dayAdjustStr,
-		Tags:         tags,
-		Album:        album,
-		AlbumFromDir: albumFromDir,
+		OldNaming:             oldNaming,
+		NameCase:              nameCase,
+		Separator:             separator,
+		ModelMap:              modelMap,
+		KeepOriginalName:      keepOriginalName,
+		RatingDirs:            ratingDirs,
+		RawPath:               rawPath,
+		Move:                  moveMode || inPlace,
+		Precision:             precision,
+		DryRun:                dryRun,
+		TimeAdjust:            timeAdjust,
+		DayAdjust:             dayAdjust,
+		QuickTimeUTC:          quickTimeUTC,
+		Location:              location,
+		PreferFilenameDate:    preferFilenameDate,
+		Tags:                  tags,
+		Album:                 album,
+		AlbumFromDir:          albumFromDir,
+		AlbumTemplate:         albumTemplate,
+		Durable:               durable,
+		VerifyCopy:            verifyCopy,
+		FileMode:              fileMode,
+		Owner:                 fileOwner,
+		NoMetadataWrite:       noMetadataWrite,
+		SidecarMetadata:       sidecarMetadata,
+		StripGPS:              stripGPS,
+		WriteChecksums:        writeChecksums,
+		MinSize:               minSizeBytes,
+		DerivativePatterns:    activeDerivativePatterns(),
+		SkipExistingHashes:    skipExistingHashes,
+		SkipOrganized:         skipOrganized,
+		DeleteDuplicateSource: deleteDupSource,
+		MaxCollisions:         maxCollisions,
+		MaxFilenameLength:     maxFilenameLength,
+		WindowsSafe:           windowsSafe,
+		UnknownLabel:          unknownLabel,
+		PreserveCompoundExt:   preserveCompoundExt,
+		FixExtension:          fixExtension,
+		InPlace:               inPlace,
+		QuarantineNoDate:      quarantineNoDate,
+		SequenceOrder:         sequenceOrder,
+		Deterministic:         deterministic,
+		HashSuffix:            hashSuffix,
+		Overwrite:             overwrite,
+		DedupSiblings:         dedupSiblings,
+		Backup:                backup,
+		BackupDir:             backupDir,
+		BurstWindow:           time.Duration(groupBursts) * time.Millisecond,
+		SourcePrefixes:        prefixes,
+		PreserveTree:          preserveTree,
+		KeepLivePhotos:        keepLivePhotos,
+		SourceDirs:            absSourceDirs,
 	}
 
-	if dryRun {
+	// In event mode stdout carries only JSON lines, and quiet mode only the summary
+	chatty := !eventMode && !quiet
+	if dryRun && chatty {
 		fmt.Println("DRY RUN - no files will be modified")
 	}
 
 	// Print operation summary
 	if verbose > 0 {
 		fmt.Printf("Operation: ")
-		if copyMode {
+		switch {
+		case copyMode:
 			fmt.Println("copy")
-		} else {
+		case inPlace:
+			fmt.Println("rename in place")
+		default:
 			fmt.Println("move")
 		}
-		fmt.Printf("Workers: %d\n", numWorkers)
+		fmt.Printf("Workers: %d\n", workers)
 		fmt.Printf("Source(s): %v\n", sourceDirs)
-		fmt.Printf("Destination: %s\n", destDir)
+		if !inPlace {
+			fmt.Printf("Destination: %s\n", destDir)
+		}
 		if rawPath != "" {
 			fmt.Printf("RAW path: %s\n", rawPath)
 		}
 	}
 
-	// Collect files to process
-	files, err := collectFiles(sourceDirs, recursive, verbose)
-	if err != nil {
-		return err
+	var manifest *processor.ManifestWriter
+	if manifestPath != "" {
+		manifest, err = processor.NewManifestWriter(manifestPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The run is recorded as starting before the walk, so files written
+	// while it is in progress are picked up next time
+	runStart := time.Now()
+	var lastRun time.Time
+	if stateFile != "" {
+		lastRun, err = processor.ReadLastRun(stateFile)
+		if err != nil {
+			manifest.Close()
+			return err
+		}
+		if verbose > 0 && !lastRun.IsZero() {
+			fmt.Printf("Last run: %s\n", lastRun.Format(time.RFC3339))
+		}
+	}
+
+	var events *processor.EventWriter
+	if eventMode {
+		events = processor.NewEventWriter(os.Stdout)
+	}
+
+	proc := processor.New(destDir, cfg, processor.Options{
+		Workers:          workers,
+		ExifTools:        exifTools,
+		Verbose:          verbose,
+		Progress:         chatty,
+		Manifest:         manifest,
+		Events:           events,
+		Recursive:        recursive && maxDepth != 0,
+		MaxDepth:         max(maxDepth, 0),
+		FollowSymlinks:   followSymlinks,
+		Exclude:          excludePatterns,
+		Include:          includePatterns,
+		ScanZips:         scanZips,
+		ModifiedSince:    lastRun,
+		RollbackOnError:  rollback,
+		Exec:             execCmd,
+		Limit:            limit,
+		LimitBytes:       limitBytesValue,
+		KeepDestinations: treeMode,
+	})
+	defer func() {
+		// Extracted zip entries live in a temp directory until processing is done
+		if err := proc.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove extracted zip files: %v\n", err)
+		}
+	}()
+
+	// Count files up front so the progress bar has a total, without holding every path in memory.
+	// A --files-from list replaces the directory walk entirely.
+	var fileList []string
+	var total int
+	if filesFrom != "" {
+		fileList, err = readFilesFrom(filesFrom, verbose)
+		if err != nil {
+			manifest.Close()
+			events.Close()
+			return err
+		}
+		total = len(fileList)
+	} else {
+		total, err = proc.Count(sourceDirs)
+		if err != nil {
+			manifest.Close()
+			events.Close()
+			return err
+		}
 	}
 
-	if len(files) == 0 {
-		fmt.Println("No files to process")
+	if total == 0 {
+		if chatty {
+			fmt.Println("No files to process")
+		}
+		if err := manifest.Close(); err != nil {
+			events.Close()
+			return err
+		}
+		if err := events.Close(); err != nil {
+			return err
+		}
+		if err := saveLastRun(runStart, 0, false); err != nil {
+			return err
+		}
 
 		// If clean flag is set, ask user if they want to proceed with cleaning
-		if clean && moveMode && !dryRun {
-			fmt.Print("\n--clean flag is set. Proceed with cleaning empty directories? [y/N]: ")
-			var response string
-			fmt.Scanln(&response)
-
-			if strings.ToLower(strings.TrimSpace(response)) != "y" {
-				fmt.Println("Cleanup canceled")
-				return nil
+		// (a dry run only previews, so there is nothing to confirm)
+		if clean && moveMode {
+			if !dryRun {
+				// On stderr, so the prompt stays out of an --events stream
+				fmt.Fprint(os.Stderr, "\n--clean flag is set. Proceed with cleaning empty directories? [y/N]: ")
+				var response string
+				fmt.Scanln(&response)
+
+				if strings.ToLower(strings.TrimSpace(response)) != "y" {
+					fmt.Fprintln(os.Stderr, "Cleanup canceled")
+					return nil
+				}
 			}
 
-			fmt.Println("\nCleaning empty directories...")
-			cleanStats := cleanEmptyDirectories(sourceDirs, recursive, verbose)
-			if cleanStats.FilesRemoved > 0 {
-				fmt.Printf("Removed %d camera metadata files\n", cleanStats.FilesRemoved)
-			}
-			if cleanStats.Removed > 0 {
-				fmt.Printf("Removed %d empty directories\n", cleanStats.Removed)
-			}
-			if cleanStats.FilesRemoved == 0 && cleanStats.Removed == 0 {
-				fmt.Println("No camera metadata files or empty directories found")
-			}
+			runClean(sourceDirs, recursive, dryRun, chatty, verbose)
 		}
 
 		return nil
 	}
 
-	fmt.Printf("Found %d files to process\n", len(files))
+	if chatty {
+		fmt.Printf("Found %d files to process\n", total)
+	}
 
-	// Process files
-	stats, err := processFiles(ctx, files, destDir, cfg, numWorkers, verbose)
+	// Process files as they are discovered
+	var stats *processor.Stats
+	if filesFrom != "" {
+		stats, err = proc.Process(ctx, fileList)
+	} else {
+		// A --limit, or an error, stops reading files before the walk is done
+		walkCtx, stopWalk := context.WithCancel(ctx)
+		defer stopWalk()
+		files, walkErr := proc.Stream(walkCtx, sourceDirs)
+		stats, err = proc.ProcessStream(ctx, files, total)
+		stopWalk()
+		if err == nil && !stats.LimitReached {
+			err = <-walkErr
+		}
+	}
+	if closeErr := manifest.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if closeErr := events.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if errors.Is(err, processor.ErrCanceled) || errors.Is(err, processor.ErrRolledBack) {
+		if !eventMode {
+			printSummary(stats, verbose)
+		}
+		return err
+	}
 	if err != nil {
 		return err
 	}
 
 	// Print summary
-	printSummary(stats, verbose)
+	if !eventMode {
+		printSummary(stats, verbose)
+	}
 
-	// Clean empty directories if requested (only for move operations)
-	if clean && moveMode && !dryRun {
-		fmt.Println("\nCleaning empty directories...")
-		cleanStats := cleanEmptyDirectories(sourceDirs, recursive, verbose)
-		if cleanStats.FilesRemoved > 0 {
-			fmt.Printf("Removed %d camera metadata files\n", cleanStats.FilesRemoved)
-		}
-		if cleanStats.Removed > 0 {
-			fmt.Printf("Removed %d empty directories\n", cleanStats.Removed)
+	if err := saveLastRun(runStart, stats.Errors, stats.LimitReached); err != nil {
+		return err
+	}
+
+	if treeMode {
+		fmt.Println("\nPlanned tree:")
+		printTree(os.Stdout, treeRoots(destDir, absSourceDirs), stats.Destinations)
+	}
+
+	// Clean empty directories if requested (only for move operations; previewed in dry-run)
+	if clean && moveMode {
+		runClean(sourceDirs, recursive, dryRun, chatty, verbose)
+	}
+
+	if stats.Errors > 0 {
+		// The summary already explains the failures; usage would only bury it
+		if cmd != nil {
+			cmd.SilenceUsage = true
 		}
-		if cleanStats.FilesRemoved == 0 && cleanStats.Removed == 0 {
-			fmt.Println("No camera metadata files or empty directories found")
+		return fmt.Errorf("%w: %d of %d", ErrFilesFailed, stats.Errors, total)
+	}
+	if failOnUnparseable && len(stats.Unparseable) > 0 {
+		if cmd != nil {
+			cmd.SilenceUsage = true
 		}
+		return fmt.Errorf("%w: ExifTool couldn't parse %d of %d", ErrFilesFailed, len(stats.Unparseable), total)
 	}
 
 	return nil
 }
 
-// Stats tracks processing statistics
-type Stats struct {
-	Processed  int64
-	Duplicates int64
-	Skipped    int64
-	Errors     int64
+// saveLastRun records start in the --state-file after a run with the given
+// number of failed files. A dry run changes nothing, and a run with errors or
+// one stopped by --limit isn't recorded so the files it missed are picked up
+// next time.
+func saveLastRun(start time.Time, failed int64, limited bool) error {
+	if stateFile == "" || dryRun {
+		return nil
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s not updated because of errors\n", stateFile)
+		return nil
+	}
+	if limited {
+		fmt.Fprintf(os.Stderr, "Warning: %s not updated because the run stopped at its limit\n", stateFile)
+		return nil
+	}
+	return processor.WriteLastRun(stateFile, start)
 }
 
-// collectFiles walks source directories and collects all supported image/video files
-func collectFiles(sourceDirs []string, recursive bool, verbose int) ([]string, error) {
-	var files []string
-	seen := make(map[string]bool) // Deduplicate if multiple sources overlap
+// runClean cleans (or, in dry-run, previews cleaning) source directories and
+// prints the result. Without chatty (quiet and event modes) it prints nothing.
+func runClean(sourceDirs []string, recursive bool, dryRun bool, chatty bool, verbose int) {
+	verb := "Removed"
+	if chatty {
+		if dryRun {
+			fmt.Println("\n[DRY RUN] Previewing empty directory cleanup...")
+			verb = "Would remove"
+		} else {
+			fmt.Println("\nCleaning empty directories...")
+		}
+	}
 
-	for _, sourceDir := range sourceDirs {
-		if recursive {
-			err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
-				if err != nil {
-					return err
-				}
-				if d.IsDir() {
-					return nil
-				}
+	cleanStats := cleanEmptyDirectories(sourceDirs, recursive, dryRun, chatty, verbose)
+	if !chatty {
+		return
+	}
+	if cleanStats.FilesRemoved > 0 {
+		fmt.Printf("%s %d camera metadata files\n", verb, cleanStats.FilesRemoved)
+	}
+	if cleanStats.Removed > 0 {
+		fmt.Printf("%s %d empty directories\n", verb, cleanStats.Removed)
+	}
+	if cleanStats.FilesRemoved == 0 && cleanStats.Removed == 0 {
+		fmt.Println("No camera metadata files or empty directories found")
+	}
+}
 
-				// Check if file has valid extension
-				ext := strings.TrimPrefix(filepath.Ext(path), ".")
-				if rename.IsValidExtension(ext) {
-					absPath, err := filepath.Abs(path)
-					if err != nil {
-						return err
-					}
-					if !seen[absPath] {
-						files = append(files, absPath)
-						seen[absPath] = true
-					}
-				}
-				return nil
-			})
-			if err != nil {
-				return nil, fmt.Errorf("failed to walk directory %s: %w", sourceDir, err)
-			}
-		} else {
-			// Non-recursive: only process files directly in the directory
-			entries, err := os.ReadDir(sourceDir)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read directory %s: %w", sourceDir, err)
-			}
+// readFilesFrom reads a --files-from list from path, or from stdin when path is "-"
+func readFilesFrom(path string, verbose int) ([]string, error) {
+	if path == "-" {
+		return readFileList(os.Stdin, verbose)
+	}
 
-			for _, entry := range entries {
-				if entry.IsDir() {
-					continue
-				}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file list: %w", err)
+	}
+	defer f.Close()
 
-				path := filepath.Join(sourceDir, entry.Name())
-				ext := strings.TrimPrefix(filepath.Ext(path), ".")
-				if rename.IsValidExtension(ext) {
-					absPath, err := filepath.Abs(path)
-					if err != nil {
-						return nil, err
-					}
-					if !seen[absPath] {
-						files = append(files, absPath)
-						seen[absPath] = true
-					}
-				}
+	return readFileList(f, verbose)
+}
+
+// readFileList reads newline-separated paths and returns the absolute paths
+// of those with a supported extension. Blank lines are ignored.
+func readFileList(r io.Reader, verbose int) ([]string, error) {
+	var files []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		path := strings.TrimSpace(scanner.Text())
+		if path == "" {
+			continue
+		}
+
+		ext := strings.TrimPrefix(filepath.Ext(path), ".")
+		if ext == "" || !rename.IsValidExtension(ext) {
+			if verbose > 1 {
+				fmt.Printf("Skipping (unsupported): %s\n", path)
 			}
+			continue
 		}
+
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, absPath)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read file list: %w", err)
 	}
 
 	return files, nil
 }
 
+// checkPrecision rejects a --precision outside the 0-6 digits the
+// subcommands document, before any file is touched
+func checkPrecision(precision int) error {
+	if precision < 0 || precision > 6 {
+		return fmt.Errorf("invalid --precision %d: must be 0-6", precision)
+	}
+	return nil
+}
+
 // checkExifTool verifies that exiftool is installed and available
 func checkExifTool() error {
 	_, err := exec.LookPath("exiftool")
@@ -346,165 +952,261 @@ After installation, verify with: exiftool -ver`)
 	return nil
 }
 
-// processFiles processes all files using a worker pool
-func processFiles(ctx context.Context, files []string, destDir string, cfg *config.ProcessingConfig, workers int, verbose int) (*Stats, error) {
-	stats := &Stats{}
-
-	// Create progress bar (only if not verbose)
-	var bar *progressbar.ProgressBar
-	if verbose == 0 {
-		bar = progressbar.NewOptions(len(files),
-			progressbar.OptionSetDescription("Processing"),
-			progressbar.OptionSetWriter(os.Stderr),
-			progressbar.OptionShowCount(),
-			progressbar.OptionSetItsString("files"),
-			progressbar.OptionShowIts(),
-			progressbar.OptionSetWidth(40),
-			progressbar.OptionThrottle(65*1000000), // 65ms
-			progressbar.OptionShowElapsedTimeOnFinish(),
-			progressbar.OptionOnCompletion(func() {
-				fmt.Fprint(os.Stderr, "\n")
-			}),
-		)
-	}
-
-	// Create worker pool with bounded queue and context cancellation
-	pool := pond.New(workers, len(files), pond.Context(ctx))
-
-	// Submit tasks in a separate goroutine to avoid blocking on full queue
-	submitDone := make(chan struct{})
-	go func() {
-		defer close(submitDone)
-		for _, file := range files {
-			file := file // Capture for closure
-
-			// Check if context is canceled before submitting
-			if ctx.Err() != nil {
-				return
-			}
+// activeDerivativePatterns returns the --derivative-pattern list when --skip-derivatives is set
+func activeDerivativePatterns() []string {
+	if !skipDerivatives {
+		return nil
+	}
+	return derivativePatterns
+}
 
-			// Submit blocks if queue is full, but we run this in a goroutine
-			// so the main thread can respond to cancellation signals
-			pool.Submit(func() {
-				// Check if context is canceled
-				if ctx.Err() != nil {
-					if bar != nil {
-						bar.Add(1)
-					}
-					return
-				}
+// sizeUnits maps human-readable size suffixes to their byte multipliers
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"KB", 1 << 10},
+	{"MB", 1 << 20},
+	{"GB", 1 << 30},
+	{"K", 1 << 10},
+	{"M", 1 << 20},
+	{"G", 1 << 30},
+	{"B", 1},
+}
 
-				if err := processFile(file, destDir, cfg, stats, verbose); err != nil {
-					atomic.AddInt64(&stats.Errors, 1)
-					if verbose > 0 {
-						fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", file, err)
-					}
-				}
-				// Update progress bar
-				if bar != nil {
-					bar.Add(1)
-				}
-			})
-		}
-	}()
+// parseFileMode parses an octal permission mode such as "644" or "0640".
+// An empty string returns 0, keeping source permissions.
+func parseFileMode(mode string) (os.FileMode, error) {
+	s := strings.TrimSpace(mode)
+	if s == "" {
+		return 0, nil
+	}
 
-	// Wait for either all submissions to complete or context cancellation
-	select {
-	case <-submitDone:
-		// All tasks submitted - wait for completion
-		pool.StopAndWait()
+	value, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || value == 0 || value > 0777 {
+		return 0, fmt.Errorf("invalid mode %q: must be octal permissions between 1 and 777", mode)
+	}
+	return os.FileMode(value), nil
+}
 
-	case <-ctx.Done():
-		// Context cancelled - stop immediately and discard queued tasks
-		stopCtx := pool.Stop()
+// parseOwner parses a numeric owner as UID:GID, UID (group unchanged) or
+// :GID (user unchanged). An empty string returns nil, keeping the owner.
+func parseOwner(owner string) (*config.Owner, error) {
+	s := strings.TrimSpace(owner)
+	if s == "" {
+		return nil, nil
+	}
 
-		// Wait briefly for running tasks to complete
-		select {
-		case <-stopCtx.Done():
-			// Pool stopped cleanly
-		case <-time.After(1 * time.Second):
-			// Timeout - tasks are taking too long
+	uidPart, gidPart, hasGID := strings.Cut(s, ":")
+	parseID := func(id string) (int, error) {
+		if id == "" {
+			return -1, nil
+		}
+		value, err := strconv.ParseUint(id, 10, 31)
+		if err != nil {
+			return 0, fmt.Errorf("invalid owner %q: IDs must be numeric, as UID:GID", owner)
 		}
+		return int(value), nil
+	}
+
+	uid, err := parseID(uidPart)
+	if err != nil {
+		return nil, err
+	}
+	gid, err := parseID(gidPart)
+	if err != nil {
+		return nil, err
+	}
+	if (uid == -1 && gid == -1) || (hasGID && gidPart == "") {
+		return nil, fmt.Errorf("invalid owner %q: expected UID:GID, UID or :GID", owner)
+	}
+	return &config.Owner{UID: uid, GID: gid}, nil
+}
+
+// parseSize parses a size in bytes or with a KB/MB/GB suffix (e.g. "100KB").
+// An empty string returns 0.
+func parseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	if s == "" {
+		return 0, nil
+	}
 
-		if bar != nil {
-			bar.Finish()
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			multiplier = unit.multiplier
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			break
 		}
-		return stats, fmt.Errorf("processing canceled by user")
 	}
 
-	// Finish progress bar
-	if bar != nil {
-		bar.Finish()
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
 	}
 
-	return stats, nil
+	return int64(value * float64(multiplier)), nil
 }
 
-// processFile processes a single file
-func processFile(file string, destDir string, cfg *config.ProcessingConfig, stats *Stats, verbose int) error {
-	// Create ImageRename instance
-	ir, err := rename.NewImageRename(file, destDir, cfg)
+// checkNotNested returns an error if dir is one of sources or inside one
+func checkNotNested(name, dir string, sources []string) error {
+	absDir, err := filepath.Abs(dir)
 	if err != nil {
-		return fmt.Errorf("failed to create rename instance: %w", err)
+		return fmt.Errorf("failed to resolve %s: %w", name, err)
 	}
-	defer ir.Close()
 
-	// Check if valid extension
-	if !ir.IsValidExtension() {
-		atomic.AddInt64(&stats.Skipped, 1)
-		if verbose > 1 {
-			fmt.Printf("Skipping (unsupported): %s\n", file)
+	for _, src := range sources {
+		absSrc, err := filepath.Abs(src)
+		if err != nil {
+			return fmt.Errorf("failed to resolve source %s: %w", src, err)
 		}
-		return nil
-	}
 
-	// Parse metadata
-	if err := ir.ParseMetadata(); err != nil {
-		return fmt.Errorf("failed to parse metadata: %w", err)
+		prefix := absSrc
+		if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+			prefix += string(filepath.Separator)
+		}
+		if absDir == absSrc || strings.HasPrefix(absDir, prefix) {
+			return fmt.Errorf("%s %s is inside source directory %s; choose a destination outside the sources", name, dir, src)
+		}
 	}
+	return nil
+}
 
-	// Check if duplicate
-	if ir.IsDuplicate() {
-		atomic.AddInt64(&stats.Duplicates, 1)
-		if verbose > 1 {
-			fmt.Printf("Skipping (duplicate): %s\n", file)
-		}
-		return nil
+// treeRoots returns the directories the --tree output is drawn from: the
+// destination and --raw-path, or the sources when renaming in place
+func treeRoots(destDir string, absSourceDirs []string) []string {
+	if inPlace {
+		return absSourceDirs
 	}
 
-	// Show what we're doing
-	if verbose > 0 {
-		operation := "Copying"
-		if cfg.Move {
-			operation = "Moving"
+	var roots []string
+	for _, dir := range []string{destDir, rawPath} {
+		if dir == "" {
+			continue
 		}
-		if cfg.DryRun {
-			operation = "[DRY RUN] " + operation
+		if absDir, err := filepath.Abs(dir); err == nil {
+			roots = append(roots, absDir)
 		}
-		fmt.Printf("%s: %s -> %s\n", operation, file, ir.GetDestination())
 	}
+	return roots
+}
 
-	// Perform the operation
-	if err := ir.Perform(); err != nil {
-		return fmt.Errorf("failed to perform operation: %w", err)
+// parseSourcePrefixes parses --prefix values of the form SOURCE=PREFIX into a
+// map keyed by the absolute source directory
+func parseSourcePrefixes(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
 	}
 
-	atomic.AddInt64(&stats.Processed, 1)
-	return nil
+	prefixes := make(map[string]string, len(values))
+	for _, value := range values {
+		i := strings.LastIndex(value, "=")
+		if i <= 0 || i == len(value)-1 {
+			return nil, fmt.Errorf("invalid --prefix %q: expected SOURCE=PREFIX", value)
+		}
+		source, prefix := value[:i], value[i+1:]
+		if strings.ContainsAny(prefix, `/\`) {
+			return nil, fmt.Errorf("invalid --prefix %q: prefix must not contain a path separator", value)
+		}
+
+		info, err := os.Stat(source)
+		if err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("invalid --prefix %q: %s is not a directory", value, source)
+		}
+		absSource, err := filepath.Abs(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve --prefix source %s: %w", source, err)
+		}
+		if _, ok := prefixes[absSource]; ok {
+			return nil, fmt.Errorf("invalid --prefix %q: %s already has a prefix", value, source)
+		}
+		prefixes[absSource] = prefix
+	}
+	return prefixes, nil
 }
 
 // printSummary prints processing statistics
-func printSummary(stats *Stats, verbose int) {
+func printSummary(stats *processor.Stats, verbose int) {
 	fmt.Println("\nSummary:")
 	fmt.Printf("  Processed:  %d\n", stats.Processed)
+	if stats.BytesProcessed > 0 {
+		fmt.Printf("  Data:       %s in %s (%s/s)\n", processor.FormatBytes(stats.BytesProcessed), stats.Elapsed.Round(10*time.Millisecond), processor.FormatBytes(int64(stats.Throughput())))
+	}
 	if stats.Duplicates > 0 {
 		fmt.Printf("  Duplicates: %d\n", stats.Duplicates)
 	}
+	if stats.DuplicatesDeleted > 0 {
+		fmt.Printf("    sources deleted: %d\n", stats.DuplicatesDeleted)
+	}
 	if stats.Skipped > 0 {
 		fmt.Printf("  Skipped:    %d\n", stats.Skipped)
 	}
+	if stats.Replaced > 0 {
+		fmt.Printf("  Replaced:   %d\n", stats.Replaced)
+	}
+	if stats.SourcesKept > 0 {
+		fmt.Printf("  Copied, source kept (could not delete): %d\n", stats.SourcesKept)
+	}
+	if len(stats.Unparseable) > 0 {
+		fmt.Printf("  Unparseable by ExifTool: %d\n", len(stats.Unparseable))
+	}
 	if stats.Errors > 0 {
 		fmt.Printf("  Errors:     %d\n", stats.Errors)
+		for _, category := range []struct {
+			name  string
+			count int64
+		}{
+			{"metadata", stats.MetadataErrors},
+			{"I/O", stats.IOErrors},
+			{"permission", stats.PermissionErrors},
+			{"other", stats.OtherErrors},
+		} {
+			if category.count > 0 {
+				fmt.Printf("    %-12s%d\n", category.name+":", category.count)
+			}
+		}
+	}
+	if stats.HookErrors > 0 {
+		fmt.Printf("  Exec failed: %d\n", stats.HookErrors)
+	}
+	if stats.Canceled > 0 {
+		fmt.Printf("  Canceled:   %d\n", stats.Canceled)
+	}
+	if stats.LimitReached {
+		fmt.Println("  Stopped at the limit; remaining files were not processed")
+	}
+	if stats.RolledBack > 0 {
+		fmt.Printf("  Rolled back: %d\n", stats.RolledBack)
+	}
+	if stats.RollbackFailed > 0 {
+		fmt.Printf("  Rollback failed: %d (left at their destination)\n", stats.RollbackFailed)
+	}
+
+	// Report how files were dated so unreliable (ctime-only) imports stand out
+	if len(stats.DateSources) > 0 {
+		var parts []string
+		for _, source := range config.DateSources {
+			if count := stats.DateSources[source]; count > 0 {
+				parts = append(parts, fmt.Sprintf("%s %d", source, count))
+			}
+		}
+		fmt.Printf("  Dated by:   %s\n", strings.Join(parts, ", "))
+	}
+	if verbose > 1 && len(stats.CtimeFiles) > 0 {
+		files := slices.Clone(stats.CtimeFiles)
+		sort.Strings(files)
+		fmt.Println("\nDated by file time only (no metadata or filename date):")
+		for _, file := range files {
+			fmt.Printf("  %s\n", file)
+		}
+	}
+	if verbose > 0 && len(stats.Unparseable) > 0 {
+		files := slices.Clone(stats.Unparseable)
+		sort.Strings(files)
+		fmt.Println("\nExifTool couldn't parse (corrupt or unsupported content):")
+		for _, file := range files {
+			fmt.Printf("  %s\n", file)
+		}
 	}
 }
 
@@ -513,28 +1215,48 @@ type CleanStats struct {
 	Checked      int
 	Removed      int
 	FilesRemoved int
+
+	// bar counts checked directories while cleaning quietly on a terminal
+	bar *progressbar.ProgressBar
 }
 
-// cameraMetadataExtensions lists file extensions for camera-specific metadata files that should be cleaned up
-var cameraMetadataExtensions = []string{
-	".dsc", // Nikon camera metadata files (e.g., NIKON001.DSC)
+// defaultCameraMetadataExtensions lists file extensions for camera-specific metadata files that should be cleaned up
+var defaultCameraMetadataExtensions = []string{
+	".dsc",  // Nikon camera metadata files (e.g., NIKON001.DSC)
+	".thm",  // Canon/GoPro video thumbnails (e.g., MVI_0001.THM)
+	".ctg",  // Canon catalog files (e.g., CANONMSC/M0100.CTG)
+	".moi",  // JVC/Panasonic MOD video index files
+	".modd", // Sony camcorder metadata files
 }
 
+// cameraMetadataExtensions is the active junk list, set by --junk-ext.
+// Entries are extensions (".thm" or "thm") or glob patterns ("*.lrv").
+var cameraMetadataExtensions = defaultCameraMetadataExtensions
+
 // cleanEmptyDirectories removes empty directories from source paths
-func cleanEmptyDirectories(sourceDirs []string, recursive bool, verbose int) *CleanStats {
+//
+// With dryRun set nothing is deleted; the stats report what would be removed
+// and each candidate is printed as a "Would remove" line. Without chatty
+// (quiet and event modes) nothing is printed and no progress bar is shown.
+func cleanEmptyDirectories(sourceDirs []string, recursive bool, dryRun bool, chatty bool, verbose int) *CleanStats {
 	stats := &CleanStats{}
 
+	// A dry run lists every candidate, which a redrawn bar would garble
+	if chatty && verbose == 0 && !dryRun {
+		stats.bar = processor.NewProgressBar(processor.PhaseCleaning, -1, "dirs")
+		if stats.bar != nil {
+			defer stats.bar.Finish()
+		}
+	}
+
 	for _, sourceDir := range sourceDirs {
 		if recursive {
 			// Walk bottom-up to remove nested empty directories
-			cleanEmptyDirsRecursive(sourceDir, stats, verbose)
+			cleanEmptyDirsRecursive(sourceDir, stats, dryRun, chatty, verbose)
 		} else {
 			// Only check the source directory itself
 			if isEmpty, _ := isDirEmpty(sourceDir); isEmpty {
-				if verbose > 0 {
-					fmt.Printf("Removing empty directory: %s\n", sourceDir)
-				}
-				if err := os.Remove(sourceDir); err == nil {
+				if removePath(sourceDir, "empty directory", dryRun, chatty, verbose) {
 					stats.Removed++
 				}
 				stats.Checked++
@@ -546,59 +1268,92 @@ func cleanEmptyDirectories(sourceDirs []string, recursive bool, verbose int) *Cl
 }
 
 // cleanEmptyDirsRecursive recursively removes empty directories
-func cleanEmptyDirsRecursive(dir string, stats *CleanStats, verbose int) {
+//
+// Returns true if dir was removed (or would be, in dry-run), so parents can
+// tell whether they will end up empty without re-reading the disk.
+func cleanEmptyDirsRecursive(dir string, stats *CleanStats, dryRun bool, chatty bool, verbose int) bool {
 	// Read directory contents
 	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return
+		return false
 	}
 
+	remaining := 0
+
 	// First, remove camera metadata files
 	for _, entry := range entries {
-		if !entry.IsDir() {
-			if isCameraMetadataFile(entry.Name()) {
-				filePath := filepath.Join(dir, entry.Name())
-				if verbose > 0 {
-					fmt.Printf("Removing camera metadata file: %s\n", filePath)
-				}
-				if err := os.Remove(filePath); err == nil {
-					stats.FilesRemoved++
-				}
+		if entry.IsDir() {
+			continue
+		}
+		if isCameraMetadataFile(entry.Name()) {
+			filePath := filepath.Join(dir, entry.Name())
+			if removePath(filePath, "camera metadata file", dryRun, chatty, verbose) {
+				stats.FilesRemoved++
+				continue
 			}
 		}
-	}
-
-	// Re-read directory contents after removing metadata files
-	entries, err = os.ReadDir(dir)
-	if err != nil {
-		return
+		remaining++
 	}
 
 	// Recursively clean subdirectories
 	for _, entry := range entries {
 		if entry.IsDir() {
 			subdir := filepath.Join(dir, entry.Name())
-			cleanEmptyDirsRecursive(subdir, stats, verbose)
+			if !cleanEmptyDirsRecursive(subdir, stats, dryRun, chatty, verbose) {
+				remaining++
+			}
 		}
 	}
 
 	// Now check if this directory is empty and remove it
 	stats.Checked++
-	if isEmpty, _ := isDirEmpty(dir); isEmpty {
-		if verbose > 0 {
-			fmt.Printf("Removing empty directory: %s\n", dir)
-		}
-		if err := os.Remove(dir); err == nil {
-			stats.Removed++
+	if stats.bar != nil {
+		stats.bar.Add(1)
+	}
+	if remaining == 0 && removePath(dir, "empty directory", dryRun, chatty, verbose) {
+		stats.Removed++
+		return true
+	}
+	return false
+}
+
+// removePath removes a file or empty directory, or only reports it in dry-run.
+// Returns true if the path was removed (or would be).
+func removePath(path string, kind string, dryRun bool, chatty bool, verbose int) bool {
+	if dryRun {
+		if chatty {
+			fmt.Printf("Would remove %s: %s\n", kind, path)
 		}
+		return true
+	}
+	if chatty && verbose > 0 {
+		fmt.Printf("Removing %s: %s\n", kind, path)
 	}
+	return os.Remove(path) == nil
 }
 
 // isCameraMetadataFile checks if a file is a camera metadata file that should be cleaned
 func isCameraMetadataFile(filename string) bool {
-	ext := strings.ToLower(filepath.Ext(filename))
-	for _, metaExt := range cameraMetadataExtensions {
-		if ext == metaExt {
+	name := strings.ToLower(filename)
+	ext := filepath.Ext(name)
+	for _, entry := range cameraMetadataExtensions {
+		pattern := strings.ToLower(strings.TrimSpace(entry))
+		if pattern == "" {
+			continue
+		}
+
+		// Glob patterns match the whole filename
+		if strings.ContainsAny(pattern, "*?[") {
+			if matched, _ := filepath.Match(pattern, name); matched {
+				return true
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(pattern, ".") {
+			pattern = "." + pattern
+		}
+		if ext == pattern {
 			return true
 		}
 	}
@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/cacack/sortpics-go/pkg/processor"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -39,7 +45,7 @@ func TestIntegrationCLI(t *testing.T) {
 		albumFromDir = false
 		tags = []string{}
 		timeAdjust = ""
-		dayAdjust = 0
+		dayAdjust = ""
 
 		// Run command
 		err := run(nil, []string{testDataDir, destDir})
@@ -77,7 +83,7 @@ func TestIntegrationCLI(t *testing.T) {
 		albumFromDir = false
 		tags = []string{}
 		timeAdjust = ""
-		dayAdjust = 0
+		dayAdjust = ""
 
 		// Run command
 		err := run(nil, []string{testDataDir, destDir})
@@ -106,7 +112,7 @@ func TestIntegrationCLI(t *testing.T) {
 		albumFromDir = false
 		tags = []string{}
 		timeAdjust = ""
-		dayAdjust = 0
+		dayAdjust = ""
 
 		// Run command with testdata directory (should find files in subdirs)
 		err := run(nil, []string{testMultiDir, destDir})
@@ -137,7 +143,7 @@ func TestIntegrationCLI(t *testing.T) {
 		albumFromDir = false
 		tags = []string{}
 		timeAdjust = ""
-		dayAdjust = 0
+		dayAdjust = ""
 
 		// Run command
 		err := run(nil, []string{rawTestDir, destDir})
@@ -202,7 +208,7 @@ func TestIntegrationCleanAfterMove(t *testing.T) {
 	albumFromDir = false
 	tags = []string{}
 	timeAdjust = ""
-	dayAdjust = 0
+	dayAdjust = ""
 
 	// Run command
 	err = run(nil, []string{srcDir, destDir})
@@ -231,7 +237,7 @@ func TestCleanEmptyDirectoriesNonRecursive(t *testing.T) {
 	require.NoError(t, os.Mkdir(subDir, 0755))
 
 	// Run non-recursive cleanup
-	stats := cleanEmptyDirectories([]string{tmpDir}, false, 0)
+	stats := cleanEmptyDirectories([]string{tmpDir}, false, false, true, 0)
 
 	// Verify subdirectory still exists (non-recursive doesn't descend)
 	assert.DirExists(t, subDir, "Subdirectory should still exist in non-recursive mode")
@@ -252,6 +258,10 @@ func TestCameraMetadataFileDetection(t *testing.T) {
 		{"Regular file", "photo.jpg", false},
 		{"Hidden file", ".hidden", false},
 		{"DSC in filename but not extension", "DSC_0001.jpg", false},
+		{"Canon video thumbnail", "MVI_0001.THM", true},
+		{"Canon catalog file", "M0100.ctg", true},
+		{"Sony camcorder metadata", "MEDIA.MODD", true},
+		{"Real photo", "IMG_0001.JPG", false},
 	}
 
 	for _, tt := range tests {
@@ -260,6 +270,46 @@ func TestCameraMetadataFileDetection(t *testing.T) {
 			assert.Equal(t, tt.expected, result)
 		})
 	}
+
+	t.Run("custom list", func(t *testing.T) {
+		saved := cameraMetadataExtensions
+		defer func() { cameraMetadataExtensions = saved }()
+
+		cameraMetadataExtensions = []string{"LRV", "*_thumb.jpg"}
+		assert.True(t, isCameraMetadataFile("GL010001.LRV"))
+		assert.True(t, isCameraMetadataFile("IMG_0001_THUMB.JPG"))
+		assert.False(t, isCameraMetadataFile("IMG_0001.JPG"))
+		assert.False(t, isCameraMetadataFile("NIKON001.DSC"))
+	})
+}
+
+func TestCleanEmptyDirectoriesWithJunk(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	dcimDir := filepath.Join(tmpDir, "DCIM", "100CANON")
+	require.NoError(t, os.MkdirAll(dcimDir, 0755))
+	junkFiles := []string{
+		filepath.Join(dcimDir, "MVI_0001.THM"),
+		filepath.Join(tmpDir, "DCIM", "M0100.CTG"),
+	}
+	for _, path := range junkFiles {
+		require.NoError(t, os.WriteFile(path, []byte{}, 0644))
+	}
+
+	// A real photo keeps its directory alive
+	photoDir := filepath.Join(tmpDir, "keep")
+	require.NoError(t, os.MkdirAll(photoDir, 0755))
+	photo := filepath.Join(photoDir, "IMG_0002.JPG")
+	require.NoError(t, os.WriteFile(photo, []byte("photo"), 0644))
+
+	stats := cleanEmptyDirectories([]string{tmpDir}, true, false, true, 0)
+
+	assert.Equal(t, 2, stats.FilesRemoved)
+	for _, path := range junkFiles {
+		assert.NoFileExists(t, path)
+	}
+	assert.NoDirExists(t, filepath.Join(tmpDir, "DCIM"))
+	assert.FileExists(t, photo)
 }
 
 func TestCleanEmptyDirectoriesWithDSC(t *testing.T) {
@@ -279,7 +329,7 @@ func TestCleanEmptyDirectoriesWithDSC(t *testing.T) {
 	assert.FileExists(t, dscFile)
 
 	// Run cleanup
-	stats := cleanEmptyDirectories([]string{tmpDir}, true, 0)
+	stats := cleanEmptyDirectories([]string{tmpDir}, true, false, true, 0)
 
 	// Verify .DSC file was removed
 	assert.Equal(t, 1, stats.FilesRemoved, "Should remove 1 camera metadata file")
@@ -290,42 +340,374 @@ func TestCleanEmptyDirectoriesWithDSC(t *testing.T) {
 	assert.NoDirExists(t, miscDir, "MISC directory should be removed")
 }
 
-func TestCollectFiles(t *testing.T) {
-	testDataDir := filepath.Join("..", "..", "..", "test", "testdata", "basic")
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"", 0},
+		{"500", 500},
+		{"500B", 500},
+		{"1KB", 1024},
+		{"100kb", 100 * 1024},
+		{"1.5MB", 1536 * 1024},
+		{"2G", 2 << 30},
+	}
 
-	t.Run("non-recursive", func(t *testing.T) {
-		files, err := collectFiles([]string{testDataDir}, false, 0)
-		require.NoError(t, err)
-		assert.NotEmpty(t, files)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := parseSize(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
 
-		// All files should be from the basic directory
-		for _, file := range files {
-			assert.Contains(t, file, "basic", "file should be from basic directory")
-		}
-	})
+	for _, invalid := range []string{"abc", "KB", "-5", "10TB"} {
+		_, err := parseSize(invalid)
+		assert.Error(t, err, "expected error for %q", invalid)
+	}
+}
 
-	t.Run("recursive", func(t *testing.T) {
-		testDataRoot := filepath.Join("..", "..", "..", "test", "testdata")
-		files, err := collectFiles([]string{testDataRoot}, true, 0)
-		require.NoError(t, err)
-		assert.NotEmpty(t, files)
-
-		// Should find files from multiple subdirectories
-		hasBasic := false
-		hasRaw := false
-		for _, file := range files {
-			if filepath.Dir(file) == filepath.Clean(testDataDir) {
-				hasBasic = true
-			}
-			if filepath.Base(filepath.Dir(file)) == "raw" {
-				hasRaw = true
+func TestParseFileMode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected os.FileMode
+	}{
+		{"", 0},
+		{"644", 0644},
+		{"0640", 0640},
+		{" 755 ", 0755},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := parseFileMode(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	for _, invalid := range []string{"abc", "0", "888", "1777", "-644", "rw-r--r--"} {
+		_, err := parseFileMode(invalid)
+		assert.Error(t, err, "expected error for %q", invalid)
+	}
+}
+
+func TestParseOwner(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected *config.Owner
+	}{
+		{"", nil},
+		{"1000:100", &config.Owner{UID: 1000, GID: 100}},
+		{" 0:0 ", &config.Owner{UID: 0, GID: 0}},
+		{"1000", &config.Owner{UID: 1000, GID: -1}},
+		{":100", &config.Owner{UID: -1, GID: 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := parseOwner(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	for _, invalid := range []string{":", "1000:", "media:media", "1000:users", "-1:100", "1000:100:1", "1.5"} {
+		_, err := parseOwner(invalid)
+		assert.Error(t, err, "expected error for %q", invalid)
+	}
+}
+
+func TestCheckNotNested(t *testing.T) {
+	photos := filepath.Join(t.TempDir(), "photos")
+
+	tests := []struct {
+		dest   string
+		nested bool
+	}{
+		{filepath.Join(photos, "sorted"), true},
+		{filepath.Join(photos, "a", "b"), true},
+		{photos, true},
+		{photos + string(filepath.Separator), true},
+		{filepath.Join(photos, "sorted", "..", "..", "archive"), false},
+		{photos + "-sorted", false},
+		{filepath.Dir(photos), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dest, func(t *testing.T) {
+			err := checkNotNested("destination", tt.dest, []string{photos})
+			if tt.nested {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "inside source directory")
+			} else {
+				assert.NoError(t, err)
 			}
-		}
-		assert.True(t, hasBasic || hasRaw, "should find files from subdirectories")
-	})
+		})
+	}
 
-	t.Run("invalid directory", func(t *testing.T) {
-		_, err := collectFiles([]string{"/nonexistent/directory"}, false, 0)
-		assert.Error(t, err)
-	})
+	// Relative paths are resolved before comparing
+	t.Chdir(filepath.Dir(photos))
+	assert.Error(t, checkNotNested("--raw-path", filepath.Join("photos", "raw"), []string{"./photos"}))
+	assert.NoError(t, checkNotNested("destination", "sorted", []string{"./photos"}))
+}
+
+func TestParseSourcePrefixes(t *testing.T) {
+	card1 := t.TempDir()
+	card2 := t.TempDir()
+
+	prefixes, err := parseSourcePrefixes([]string{card1 + "=card1", card2 + "=card2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{card1: "card1", card2: "card2"}, prefixes)
+
+	prefixes, err = parseSourcePrefixes(nil)
+	require.NoError(t, err)
+	assert.Nil(t, prefixes)
+
+	// Relative sources are resolved
+	t.Chdir(filepath.Dir(card1))
+	prefixes, err = parseSourcePrefixes([]string{filepath.Base(card1) + "=b"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{card1: "b"}, prefixes)
+
+	for _, invalid := range []string{
+		"card1",
+		"=card1",
+		card1 + "=",
+		card1 + "=a/b",
+		filepath.Join(card1, "missing") + "=card1",
+	} {
+		_, err := parseSourcePrefixes([]string{invalid})
+		assert.Error(t, err, "expected error for %q", invalid)
+	}
+
+	_, err = parseSourcePrefixes([]string{card1 + "=a", card1 + "=b"})
+	assert.Error(t, err)
+}
+
+func TestCleanEmptyDirectoriesDryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	miscDir := filepath.Join(tmpDir, "MISC")
+	emptyDir := filepath.Join(tmpDir, "DCIM", "100NIKON")
+	require.NoError(t, os.MkdirAll(miscDir, 0755))
+	require.NoError(t, os.MkdirAll(emptyDir, 0755))
+	dscFile := filepath.Join(miscDir, "NIKON001.DSC")
+	require.NoError(t, os.WriteFile(dscFile, []byte{}, 0644))
+
+	stats := cleanEmptyDirectories([]string{tmpDir}, true, true, true, 0)
+
+	// Reports the same removals a real run would make...
+	assert.Equal(t, 1, stats.FilesRemoved)
+	assert.Equal(t, 4, stats.Removed, "MISC, 100NIKON, DCIM and the root would be removed")
+
+	// ...but leaves everything in place
+	assert.FileExists(t, dscFile)
+	assert.DirExists(t, miscDir)
+	assert.DirExists(t, emptyDir)
+
+	// A real run matches the preview
+	realStats := cleanEmptyDirectories([]string{tmpDir}, true, false, true, 0)
+	assert.Equal(t, stats.FilesRemoved, realStats.FilesRemoved)
+	assert.Equal(t, stats.Removed, realStats.Removed)
+	assert.NoDirExists(t, tmpDir)
+}
+
+func TestRunCleanQuiet(t *testing.T) {
+	tmpDir := t.TempDir()
+	emptyDir := filepath.Join(tmpDir, "DCIM")
+	require.NoError(t, os.MkdirAll(emptyDir, 0755))
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	oldStdout := os.Stdout
+	os.Stdout = w
+	runClean([]string{tmpDir}, true, true, false, 0)
+	os.Stdout = oldStdout
+	require.NoError(t, w.Close())
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	// Quiet and event modes keep stdout free of cleanup text
+	assert.Empty(t, string(out))
+	assert.DirExists(t, emptyDir)
+}
+
+func TestReadFileList(t *testing.T) {
+	input := "/photos/a.jpg\n\n  /photos/b.NEF  \r\n/photos/notes.txt\nrelative/c.mov\n/photos/noext\n"
+
+	files, err := readFileList(strings.NewReader(input), 0)
+	require.NoError(t, err)
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"/photos/a.jpg",
+		"/photos/b.NEF",
+		filepath.Join(cwd, "relative", "c.mov"),
+	}, files)
+}
+
+func TestFilesFromStdin(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	testDataDir := filepath.Join("..", "..", "..", "test", "testdata", "basic")
+
+	// Pipe three of the fixtures through stdin
+	var list strings.Builder
+	for _, name := range []string{"test_001.jpg", "test_002.jpg", "test_004.jpg"} {
+		list.WriteString(filepath.Join(testDataDir, name) + "\n")
+	}
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	_, err = w.WriteString(list.String())
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	oldStdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = oldStdin }()
+
+	files, err := readFilesFrom("-", 0)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	cfg := &config.ProcessingConfig{Precision: 6}
+	stats, err := processor.New(filepath.Join(tmpDir, "dest"), cfg, processor.Options{Workers: 2}).Process(context.Background(), files)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(3), stats.Processed)
+	assert.Equal(t, int64(0), stats.Errors)
+}
+
+func TestRunInPlace(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	albumDir := filepath.Join(tmpDir, "already-sorted")
+	require.NoError(t, os.MkdirAll(albumDir, 0755))
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "..", "test", "testdata", "basic", "test_001.jpg"))
+	require.NoError(t, err)
+	original := filepath.Join(albumDir, "IMG_0001.jpg")
+	require.NoError(t, os.WriteFile(original, data, 0644))
+
+	copyMode = false
+	moveMode = false
+	inPlace = true
+	dryRun = false
+	recursive = false
+	verbose = 0
+	numWorkers = 1
+	precision = 6
+	rawPath = ""
+	clean = false
+	defer func() { inPlace = false }()
+
+	require.NoError(t, run(nil, []string{albumDir}))
+
+	entries, err := os.ReadDir(albumDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "file should stay in its original directory")
+
+	assert.NoFileExists(t, original)
+	assert.Regexp(t, `^(\d{8}-\d{6}\.\d{6}|unknown)_.+\.jpg$`, entries[0].Name())
+
+	// Running again is a no-op: the name is already canonical
+	renamed := filepath.Join(albumDir, entries[0].Name())
+	require.NoError(t, run(nil, []string{albumDir}))
+	assert.FileExists(t, renamed)
+}
+
+func TestRunQuiet(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+	data, err := os.ReadFile(filepath.Join("..", "..", "..", "test", "testdata", "basic", "test_001.jpg"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "IMG_0001.jpg"), data, 0644))
+
+	copyMode = true
+	moveMode = false
+	inPlace = false
+	dryRun = true
+	recursive = false
+	verbose = 0
+	quiet = true
+	numWorkers = 1
+	precision = 6
+	rawPath = ""
+	clean = false
+	defer func() {
+		copyMode = false
+		dryRun = false
+		quiet = false
+	}()
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	oldStdout := os.Stdout
+	os.Stdout = w
+	runErr := run(nil, []string{sourceDir, filepath.Join(tmpDir, "dest")})
+	os.Stdout = oldStdout
+	require.NoError(t, w.Close())
+	require.NoError(t, runErr)
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	// No dry-run banner, file count, or progress: just the summary
+	assert.True(t, strings.HasPrefix(string(out), "\nSummary:\n"), "unexpected output:\n%s", out)
+	assert.Contains(t, string(out), "Processed:  1")
+}
+
+func TestRunFilesFailed(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+	data, err := os.ReadFile(filepath.Join("..", "..", "..", "test", "testdata", "basic", "test_001.jpg"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "IMG_0001.jpg"), data, 0644))
+
+	// A destination that is a regular file fails every copy
+	destFile := filepath.Join(tmpDir, "dest")
+	require.NoError(t, os.WriteFile(destFile, nil, 0644))
+
+	copyMode = true
+	moveMode = false
+	inPlace = false
+	dryRun = false
+	recursive = false
+	verbose = 0
+	numWorkers = 1
+	precision = 6
+	rawPath = ""
+	clean = false
+	defer func() { copyMode = false }()
+
+	err = run(nil, []string{sourceDir, destFile})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrFilesFailed)
+	assert.FileExists(t, filepath.Join(sourceDir, "IMG_0001.jpg"))
+}
+
+func TestValidatePatterns(t *testing.T) {
+	assert.NoError(t, validatePatterns("--exclude", []string{"private/**", "*.LRV", "DCIM/1??CANON/*"}))
+	assert.Error(t, validatePatterns("--exclude", []string{"[unclosed"}))
 }
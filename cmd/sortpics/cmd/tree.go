@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// treeNode is a directory in the planned destination tree
+type treeNode struct {
+	// files counts the files in this directory and everything below it
+	files    int
+	children map[string]*treeNode
+}
+
+// add counts one file in the directory reached by following parts from n
+func (n *treeNode) add(parts []string) {
+	n.files++
+	if len(parts) == 0 {
+		return
+	}
+	if n.children == nil {
+		n.children = make(map[string]*treeNode)
+	}
+	child, ok := n.children[parts[0]]
+	if !ok {
+		child = &treeNode{}
+		n.children[parts[0]] = child
+	}
+	child.add(parts[1:])
+}
+
+// printTree renders the directories of destinations as a nested tree with
+// file counts, one tree per root they lie under (the innermost root wins, so
+// a --raw-path inside the destination gets its own tree). A destination
+// under no root is shown under its own directory.
+func printTree(w io.Writer, roots []string, destinations []string) {
+	trees := make(map[string]*treeNode)
+	for _, destination := range destinations {
+		dir := filepath.Dir(destination)
+
+		root := dir
+		best := ""
+		for _, r := range roots {
+			if (dir == r || strings.HasPrefix(dir, r+string(filepath.Separator))) && len(r) > len(best) {
+				best = r
+			}
+		}
+		if best != "" {
+			root = best
+		}
+
+		var parts []string
+		if rel, err := filepath.Rel(root, dir); err == nil && rel != "." {
+			parts = strings.Split(rel, string(filepath.Separator))
+		}
+
+		tree, ok := trees[root]
+		if !ok {
+			tree = &treeNode{}
+			trees[root] = tree
+		}
+		tree.add(parts)
+	}
+
+	for _, root := range slices.Sorted(maps.Keys(trees)) {
+		fmt.Fprintf(w, "%s (%s)\n", root, fileCount(trees[root].files))
+		printTreeChildren(w, trees[root], "")
+	}
+}
+
+// printTreeChildren prints the subdirectories of n in name order, indented by prefix
+func printTreeChildren(w io.Writer, n *treeNode, prefix string) {
+	names := slices.Sorted(maps.Keys(n.children))
+	for i, name := range names {
+		branch, indent := "├── ", "│   "
+		if i == len(names)-1 {
+			branch, indent = "└── ", "    "
+		}
+		child := n.children[name]
+		fmt.Fprintf(w, "%s%s%s (%s)\n", prefix, branch, name, fileCount(child.files))
+		printTreeChildren(w, child, prefix+indent)
+	}
+}
+
+// fileCount formats n as "1 file" or "N files"
+func fileCount(n int) string {
+	if n == 1 {
+		return "1 file"
+	}
+	return fmt.Sprintf("%d files", n)
+}
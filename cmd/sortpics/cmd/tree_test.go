@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintTree(t *testing.T) {
+	archive := filepath.Join(string(filepath.Separator), "archive")
+	raw := filepath.Join(archive, "raw")
+	destinations := []string{
+		filepath.Join(archive, "2024", "01", "2024-01-15", "20240115-123045.123456_Canon-Eos5d.jpg"),
+		filepath.Join(archive, "2024", "01", "2024-01-15", "20240115-123046.000000_Canon-Eos5d.jpg"),
+		filepath.Join(archive, "2024", "01", "2024-01-16", "20240116-090000.000000_Canon-Eos5d.jpg"),
+		filepath.Join(archive, "2023", "12", "2023-12-31", "20231231-235959.000000_Apple-Iphone15.heic"),
+		filepath.Join(raw, "2024", "01", "2024-01-15", "20240115-123045.123456_Canon-Eos5d.cr2"),
+	}
+
+	var out bytes.Buffer
+	printTree(&out, []string{archive, raw}, destinations)
+
+	// Trees are sorted by root and name; RAW files get their own tree
+	want := archive + ` (4 files)
+├── 2023 (1 file)
+│   └── 12 (1 file)
+│       └── 2023-12-31 (1 file)
+└── 2024 (3 files)
+    └── 01 (3 files)
+        ├── 2024-01-15 (2 files)
+        └── 2024-01-16 (1 file)
+` + raw + ` (1 file)
+└── 2024 (1 file)
+    └── 01 (1 file)
+        └── 2024-01-15 (1 file)
+`
+	assert.Equal(t, want, out.String())
+}
+
+func TestPrintTreeEmpty(t *testing.T) {
+	var out bytes.Buffer
+	printTree(&out, []string{"/archive"}, nil)
+	assert.Empty(t, out.String())
+}
@@ -37,7 +37,7 @@ func TestVerifyCommand(t *testing.T) {
 	albumFromDir = false
 	tags = []string{}
 	timeAdjust = ""
-	dayAdjust = 0
+	dayAdjust = ""
 	clean = false
 
 	err = run(nil, []string{testDataDir, destDir})
@@ -193,7 +193,7 @@ func TestVerifyFile(t *testing.T) {
 	albumFromDir = false
 	tags = []string{}
 	timeAdjust = ""
-	dayAdjust = 0
+	dayAdjust = ""
 	clean = false
 
 	err = run(nil, []string{testDataDir, destDir})
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	_ "time/tzdata" // --timezone works without a system zoneinfo database (Windows)
 
 	"github.com/cacack/sortpics-go/cmd/sortpics/cmd"
 )
 
+// Exit codes: 0 when every file succeeded, 2 when the run completed with
+// per-file errors, and 1 for fatal errors
 func main() {
 	if err := cmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, cmd.ErrFilesFailed) {
+			os.Exit(2)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,64 @@
+// Package burst detects burst sequences: runs of shots taken in rapid succession.
+package burst
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DirPrefix is the prefix of burst subdirectory names
+const DirPrefix = "burst_"
+
+// Shot is a file and its capture time.
+type Shot struct {
+	Path string
+	Time time.Time
+}
+
+// Group finds bursts and returns the subdirectory name for each shot in one.
+//
+// Shots are sorted by time; consecutive shots at most window apart belong to
+// the same run. Runs of two or more shots are bursts, named after the first
+// shot's time: "burst_HHMMSS". A later burst starting in the same second (or
+// at the same time of day on another date) gets a suffix, "burst_HHMMSS_2",
+// so separate bursts never share a folder. Shots that are not part of a
+// burst are absent from the returned map.
+func Group(shots []Shot, window time.Duration) map[string]string {
+	groups := make(map[string]string)
+	if window <= 0 || len(shots) < 2 {
+		return groups
+	}
+
+	sorted := make([]Shot, len(shots))
+	copy(sorted, shots)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Time.Equal(sorted[j].Time) {
+			return sorted[i].Path < sorted[j].Path
+		}
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	used := make(map[string]int)
+	start := 0
+	for i := 1; i <= len(sorted); i++ {
+		// A run ends at the last shot or when the next shot is outside the window
+		if i < len(sorted) && sorted[i].Time.Sub(sorted[i-1].Time) <= window {
+			continue
+		}
+
+		if i-start >= 2 {
+			name := DirPrefix + sorted[start].Time.Format("150405")
+			used[name]++
+			if n := used[name]; n > 1 {
+				name = fmt.Sprintf("%s_%d", name, n)
+			}
+			for _, shot := range sorted[start:i] {
+				groups[shot.Path] = name
+			}
+		}
+		start = i
+	}
+
+	return groups
+}
@@ -0,0 +1,82 @@
+package burst
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGroup tests burst detection across runs of shots
+func TestGroup(t *testing.T) {
+	base := time.Date(2024, 1, 15, 12, 30, 45, 0, time.UTC)
+	shots := []Shot{
+		{Path: "c.jpg", Time: base.Add(200 * time.Millisecond)},
+		{Path: "a.jpg", Time: base},
+		{Path: "b.jpg", Time: base.Add(100 * time.Millisecond)},
+		{Path: "lone.jpg", Time: base.Add(5 * time.Second)},
+		{Path: "d.jpg", Time: base.Add(time.Minute)},
+		{Path: "e.jpg", Time: base.Add(time.Minute + 50*time.Millisecond)},
+	}
+
+	groups := Group(shots, 150*time.Millisecond)
+
+	assert.Equal(t, map[string]string{
+		"a.jpg": "burst_123045",
+		"b.jpg": "burst_123045",
+		"c.jpg": "burst_123045",
+		"d.jpg": "burst_123145",
+		"e.jpg": "burst_123145",
+	}, groups)
+}
+
+// TestGroupSameInstant tests that identical timestamps form a burst
+func TestGroupSameInstant(t *testing.T) {
+	base := time.Date(2024, 1, 15, 8, 5, 9, 0, time.UTC)
+	shots := []Shot{
+		{Path: "1.jpg", Time: base},
+		{Path: "2.jpg", Time: base},
+		{Path: "3.jpg", Time: base},
+	}
+
+	groups := Group(shots, time.Millisecond)
+
+	assert.Len(t, groups, 3)
+	for _, name := range groups {
+		assert.Equal(t, "burst_080509", name)
+	}
+}
+
+// TestGroupSameSecond tests that separate bursts starting in the same second
+// get their own folders
+func TestGroupSameSecond(t *testing.T) {
+	base := time.Date(2024, 1, 15, 8, 5, 9, 0, time.UTC)
+	shots := []Shot{
+		{Path: "a1.jpg", Time: base},
+		{Path: "a2.jpg", Time: base.Add(10 * time.Millisecond)},
+		{Path: "b1.jpg", Time: base.Add(500 * time.Millisecond)},
+		{Path: "b2.jpg", Time: base.Add(510 * time.Millisecond)},
+		{Path: "c1.jpg", Time: base.AddDate(0, 0, 1)},
+		{Path: "c2.jpg", Time: base.AddDate(0, 0, 1).Add(10 * time.Millisecond)},
+	}
+
+	groups := Group(shots, 50*time.Millisecond)
+
+	assert.Equal(t, map[string]string{
+		"a1.jpg": "burst_080509",
+		"a2.jpg": "burst_080509",
+		"b1.jpg": "burst_080509_2",
+		"b2.jpg": "burst_080509_2",
+		"c1.jpg": "burst_080509_3",
+		"c2.jpg": "burst_080509_3",
+	}, groups)
+}
+
+// TestGroupDisabled tests that a zero window or a single shot yields no bursts
+func TestGroupDisabled(t *testing.T) {
+	base := time.Now()
+	shots := []Shot{{Path: "a.jpg", Time: base}, {Path: "b.jpg", Time: base}}
+
+	assert.Empty(t, Group(shots, 0))
+	assert.Empty(t, Group(shots[:1], time.Second))
+}
@@ -5,20 +5,68 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/alitto/pond"
 )
 
+// DefaultMaxCollisions is the default number of _N suffixes tried before giving up.
+const DefaultMaxCollisions = 1000
+
+// hashSuffixLength is how many hex digits of the SHA256 HashSuffix appends
+const hashSuffixLength = 8
+
 // Detector detects duplicate files and resolves filename collisions.
 //
 // Uses SHA256 hashing to determine if files are identical.
 // Resolves collisions by appending _N suffix to filenames.
-type Detector struct{}
+type Detector struct {
+	// MaxCollisions is the highest _N suffix tried before ResolveCollision errors.
+	MaxCollisions int
+
+	// PreserveCompoundExt keeps compound extensions such as ".tar.gz" intact
+	// when adding a _N suffix ("file_1.tar.gz" rather than "file.tar_1.gz").
+	PreserveCompoundExt bool
+
+	// CaseInsensitive treats names that differ only in case as colliding, as
+	// they do on NTFS and SMB shares, even if the local filesystem is case-sensitive.
+	CaseInsensitive bool
+
+	// HashSuffix names a colliding file with "_" and the first 8 hex digits of
+	// its SHA256 instead of the next _N, so its name doesn't depend on the
+	// order files were processed in. _N only follows if that name is taken too.
+	HashSuffix bool
+
+	// Overwrite resolves a collision with a different file by returning the
+	// existing path, so the caller replaces it, instead of finding a free
+	// name. Identical files are still reported as duplicates.
+	Overwrite bool
+
+	// Protected, if set, reports paths claimed by another writer, such as
+	// files written earlier in the same run or being written. Overwrite
+	// doesn't replace them, and one that isn't there yet is not free either.
+	Protected func(path string) bool
+}
 
 // New creates a new duplicate detector.
 func New() *Detector {
-	return &Detector{}
+	return &Detector{MaxCollisions: DefaultMaxCollisions}
+}
+
+// NewWithLimit creates a duplicate detector that tries at most limit _N suffixes.
+// A limit of 0 or less uses DefaultMaxCollisions.
+func NewWithLimit(limit int) *Detector {
+	if limit <= 0 {
+		limit = DefaultMaxCollisions
+	}
+	return &Detector{MaxCollisions: limit}
 }
 
 // CalculateSHA256 calculates the SHA256 hash of a file.
@@ -34,7 +82,15 @@ func (d *Detector) CalculateSHA256(filePath string) (string, error) {
 		hashPath = originalPath
 	}
 
-	file, err := os.Open(hashPath)
+	return FileSHA256(hashPath)
+}
+
+// FileSHA256 calculates the SHA256 hash of exactly the file at path.
+//
+// Unlike CalculateSHA256 it never substitutes an _original backup, so it
+// reflects the bytes currently on disk.
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file for hashing: %w", err)
 	}
@@ -62,7 +118,8 @@ func (d *Detector) CalculateSHA256(filePath string) (string, error) {
 // Returns true if files have the same SHA256 hash, false otherwise.
 func (d *Detector) IsDuplicate(source, destination string) (bool, error) {
 	// If destination doesn't exist, it's not a duplicate
-	if _, err := os.Stat(destination); os.IsNotExist(err) {
+	destination = d.existingPath(destination)
+	if destination == "" {
 		return false, nil
 	}
 
@@ -81,14 +138,19 @@ func (d *Detector) IsDuplicate(source, destination string) (bool, error) {
 
 // ResolveCollision resolves filename collision by finding a unique path.
 //
-// If initialPath exists:
+// If initialPath exists or is Protected:
 //   - If files are identical (same hash), return initialPath with source hash
-//   - If files differ, append _N suffix until unique filename found
+//   - If files differ and Overwrite is set, return initialPath to be replaced,
+//     unless it is Protected
+//   - Otherwise, append _N suffix until unique filename found
 //
 // Returns the resolved path and the source hash (nil if no collision occurred).
+// With CaseInsensitive, an identical file whose name differs only in case is
+// returned under its own name.
 func (d *Detector) ResolveCollision(source, initialPath string) (string, *string, error) {
 	// No collision - file doesn't exist
-	if _, err := os.Stat(initialPath); os.IsNotExist(err) {
+	existing, taken := d.occupant(initialPath)
+	if !taken {
 		return initialPath, nil, nil
 	}
 
@@ -98,58 +160,192 @@ func (d *Detector) ResolveCollision(source, initialPath string) (string, *string
 		return "", nil, fmt.Errorf("failed to hash source: %w", err)
 	}
 
-	// Check if files are identical
-	destHash, err := d.CalculateSHA256(initialPath)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to hash initial destination: %w", err)
+	// A protected path that isn't there yet can't be compared
+	if existing != "" {
+		// Check if files are identical
+		destHash, err := d.CalculateSHA256(existing)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to hash initial destination: %w", err)
+		}
+
+		if sourceHash == destHash {
+			// Files are identical - this is a duplicate
+			return existing, &sourceHash, nil
+		}
+
+		// Files differ - replace the existing file if requested
+		if d.Overwrite && !d.protected(existing) {
+			return existing, &sourceHash, nil
+		}
 	}
 
-	if sourceHash == destHash {
-		// Files are identical - this is a duplicate
-		return initialPath, &sourceHash, nil
+	// Files differ - name the file by its content if requested
+	if d.HashSuffix {
+		initialPath = d.addSuffix(initialPath, sourceHash[:hashSuffixLength])
+		existing, taken := d.occupant(initialPath)
+		if !taken {
+			return initialPath, &sourceHash, nil
+		}
+
+		if existing != "" {
+			destHash, err := d.CalculateSHA256(existing)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to hash collision path: %w", err)
+			}
+			if sourceHash == destHash {
+				return existing, &sourceHash, nil
+			}
+		}
 	}
 
-	// Files differ - find unique filename with increment
+	// Find unique filename with increment
 	increment := 1
 
 	for {
 		// Generate new path with increment
 		currentPath := addIncrement(initialPath, increment)
+		if d.PreserveCompoundExt {
+			currentPath = addIncrementCompound(initialPath, increment)
+		}
 
-		if _, err := os.Stat(currentPath); os.IsNotExist(err) {
+		existing, taken := d.occupant(currentPath)
+		if !taken {
 			// Found unique path
 			return currentPath, &sourceHash, nil
 		}
 
 		// Check if this existing file matches source
-		destHash, err := d.CalculateSHA256(currentPath)
-		if err != nil {
-			return "", nil, fmt.Errorf("failed to hash collision path: %w", err)
-		}
+		if existing != "" {
+			destHash, err := d.CalculateSHA256(existing)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to hash collision path: %w", err)
+			}
 
-		if sourceHash == destHash {
-			// Found matching file at this increment
-			return currentPath, &sourceHash, nil
+			if sourceHash == destHash {
+				// Found matching file at this increment
+				return existing, &sourceHash, nil
+			}
 		}
 
 		// Try next increment
 		increment++
 
 		// Safety limit
-		if increment > 1000 {
+		if increment > d.maxCollisions() {
 			return "", nil, fmt.Errorf("too many collisions for %s", initialPath)
 		}
 	}
 }
 
+// occupant returns the file occupying path, or "" if there is none, and
+// whether path is taken: by that file, or by a Protected one not there yet
+func (d *Detector) occupant(path string) (string, bool) {
+	existing := d.existingPath(path)
+	return existing, existing != "" || d.protected(path)
+}
+
+// protected reports whether Protected is set and reports path
+func (d *Detector) protected(path string) bool {
+	return d.Protected != nil && d.Protected(path)
+}
+
+// existingPath returns the path of the file occupying path, or "" if it is free.
+// With CaseInsensitive, a file whose name differs only in case occupies it too.
+func (d *Detector) existingPath(path string) string {
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return path
+	}
+	if !d.CaseInsensitive {
+		return ""
+	}
+
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name(), name) {
+			return filepath.Join(dir, entry.Name())
+		}
+	}
+	return ""
+}
+
+// FindIdenticalSibling looks next to destination for a file with the same
+// extension whose name starts with prefix (e.g. the date and time shared by
+// names that differ in subseconds or camera) and whose content matches
+// source. It returns that file, or "" if there is none.
+//
+// destination itself is skipped, as ResolveCollision has compared it, and so
+// are source and ExifTool "_original" backups. A missing directory has no
+// siblings.
+func (d *Detector) FindIdenticalSibling(source, destination, prefix string) (string, error) {
+	dir := filepath.Dir(destination)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	ext := filepath.Ext(destination)
+	var sourceHash string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasPrefix(name, prefix) || !strings.EqualFold(filepath.Ext(name), ext) {
+			continue
+		}
+		sibling := filepath.Join(dir, name)
+		if sibling == destination || sibling == source {
+			continue
+		}
+
+		if sourceHash == "" {
+			if sourceHash, err = d.CalculateSHA256(source); err != nil {
+				return "", fmt.Errorf("failed to hash source: %w", err)
+			}
+		}
+		siblingHash, err := d.CalculateSHA256(sibling)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash %s: %w", sibling, err)
+		}
+		if siblingHash == sourceHash {
+			return sibling, nil
+		}
+	}
+	return "", nil
+}
+
+// maxCollisions returns the configured limit, defaulting for zero-value Detectors
+func (d *Detector) maxCollisions() int {
+	if d.MaxCollisions <= 0 {
+		return DefaultMaxCollisions
+	}
+	return d.MaxCollisions
+}
+
 // CheckAndResolve checks for collisions and resolves them.
 //
 // Returns the final destination path and whether the file is a duplicate.
 // is_duplicate is true if the file already exists with the same hash.
 func (d *Detector) CheckAndResolve(source, initialDestination string) (string, bool, error) {
+	finalPath, isDuplicate, _, err := d.CheckAndResolveWithHash(source, initialDestination)
+	return finalPath, isDuplicate, err
+}
+
+// CheckAndResolveWithHash is CheckAndResolve that also returns the source hash.
+//
+// The hash is only calculated when a collision occurred, so it is nil when
+// initialDestination was free.
+func (d *Detector) CheckAndResolveWithHash(source, initialDestination string) (string, bool, *string, error) {
 	finalPath, sourceHash, err := d.ResolveCollision(source, initialDestination)
 	if err != nil {
-		return "", false, err
+		return "", false, nil, err
 	}
 
 	// If source_hash is not nil, we calculated it (collision occurred)
@@ -159,24 +355,251 @@ func (d *Detector) CheckAndResolve(source, initialDestination string) (string, b
 		if _, err := os.Stat(finalPath); err == nil {
 			destHash, err := d.CalculateSHA256(finalPath)
 			if err != nil {
-				return "", false, fmt.Errorf("failed to verify duplicate: %w", err)
+				return "", false, nil, fmt.Errorf("failed to verify duplicate: %w", err)
 			}
 			isDuplicate = *sourceHash == destHash
 		}
 	}
 
-	return finalPath, isDuplicate, nil
+	return finalPath, isDuplicate, sourceHash, nil
+}
+
+// HashIndex is a concurrency-safe set of known SHA256 hashes.
+//
+// Used to detect duplicates anywhere in an archive rather than only at the
+// computed destination path.
+type HashIndex struct {
+	mu     sync.RWMutex
+	hashes map[string]bool
+}
+
+// NewHashIndex creates an empty hash index.
+func NewHashIndex() *HashIndex {
+	return &HashIndex{hashes: make(map[string]bool)}
+}
+
+// Add records a hash in the index.
+func (h *HashIndex) Add(hash string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hashes[hash] = true
+}
+
+// Contains reports whether a hash is present in the index.
+func (h *HashIndex) Contains(hash string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.hashes[hash]
+}
+
+// Len returns the number of hashes in the index.
+func (h *HashIndex) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.hashes)
+}
+
+// IndexDirectoryParallel hashes every file under dir and adds it to index,
+// spreading the hashing over a pond worker pool.
+//
+// Missing directories are ignored (nothing to index yet). ExifTool "_original"
+// backups and in-flight ".tmp-*" files are skipped; the backup is still used
+// via CalculateSHA256 to hash the pre-modification content of its file. Files (or subdirectories) that can't be read are skipped and counted in
+// failed instead of aborting the scan; only an error reading dir itself is
+// returned. A workers value below 1 uses a single worker.
+func (d *Detector) IndexDirectoryParallel(dir string, index *HashIndex, workers int) (failed int, err error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return 0, nil
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	var failures int64
+	pool := pond.New(workers, workers*2)
+
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			atomic.AddInt64(&failures, 1)
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		name := entry.Name()
+		if strings.HasSuffix(name, "_original") || strings.HasPrefix(name, ".tmp-") {
+			return nil
+		}
+
+		pool.Submit(func() {
+			hash, err := d.CalculateSHA256(path)
+			if err != nil {
+				atomic.AddInt64(&failures, 1)
+				return
+			}
+			index.Add(hash)
+		})
+		return nil
+	})
+	pool.StopAndWait()
+
+	return int(atomic.LoadInt64(&failures)), err
+}
+
+// GroupDuplicates hashes files and returns the groups of two or more with
+// identical content, each sorted by path and the groups by their first path.
+//
+// Only regular files are considered: a symlink is not a copy of its target,
+// so removing either would lose the file. Paths to the same file (hard links)
+// count once, under the first by path. Only files sharing their size with
+// another are hashed. Unlike CalculateSHA256, the bytes on disk are hashed,
+// never an _original backup, since the groups decide which copies are
+// redundant. Files that can't be read are left out and counted in failed. A
+// workers value below 1 uses a single worker.
+func (d *Detector) GroupDuplicates(files []string, workers int) (groups [][]string, failed int) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	sorted := slices.Clone(files)
+	slices.Sort(sorted)
+
+	bySize := make(map[int64][]string)
+	infos := make(map[string]os.FileInfo)
+	for _, file := range sorted {
+		info, err := os.Lstat(file)
+		if err != nil {
+			failed++
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		size := info.Size()
+		if slices.ContainsFunc(bySize[size], func(other string) bool { return os.SameFile(infos[other], info) }) {
+			continue
+		}
+		infos[file] = info
+		bySize[size] = append(bySize[size], file)
+	}
+
+	var mu sync.Mutex
+	byHash := make(map[string][]string)
+	var failures int64
+	pool := pond.New(workers, workers*2)
+	for _, candidates := range bySize {
+		if len(candidates) < 2 {
+			continue
+		}
+		for _, file := range candidates {
+			pool.Submit(func() {
+				hash, err := FileSHA256(file)
+				if err != nil {
+					atomic.AddInt64(&failures, 1)
+					return
+				}
+				mu.Lock()
+				byHash[hash] = append(byHash[hash], file)
+				mu.Unlock()
+			})
+		}
+	}
+	pool.StopAndWait()
+
+	for _, group := range byHash {
+		if len(group) < 2 {
+			continue
+		}
+		slices.Sort(group)
+		groups = append(groups, group)
+	}
+	slices.SortFunc(groups, func(a, b []string) int { return strings.Compare(a[0], b[0]) })
+
+	return groups, failed + int(failures)
+}
+
+// addSuffix adds "_" and suffix to a filename before its extension,
+// keeping compound extensions intact with PreserveCompoundExt
+func (d *Detector) addSuffix(path, suffix string) string {
+	if d.PreserveCompoundExt {
+		return addSuffixCompound(path, suffix)
+	}
+	return addSuffixSimple(path, suffix)
 }
 
 // addIncrement adds an increment suffix to a filename before the extension.
 //
 // Example: addIncrement("/path/file.jpg", 1) -> "/path/file_1.jpg"
 func addIncrement(path string, increment int) string {
+	return addSuffixSimple(path, strconv.Itoa(increment))
+}
+
+// addSuffixSimple adds "_" and suffix before the last extension
+func addSuffixSimple(path, suffix string) string {
 	dir := filepath.Dir(path)
 	base := filepath.Base(path)
 	ext := filepath.Ext(base)
 	stem := strings.TrimSuffix(base, ext)
 
-	newStem := fmt.Sprintf("%s_%d", stem, increment)
-	return filepath.Join(dir, newStem+ext)
+	return filepath.Join(dir, stem+"_"+suffix+ext)
+}
+
+// addIncrementCompound adds an increment suffix before a compound extension.
+//
+// Every trailing dot-separated segment made only of letters and digits is
+// treated as part of the extension, so "archive.tar.gz" keeps ".tar.gz".
+// Generated names stay safe: their subsecond segment always contains "_"
+// (e.g. "20240115-123045.123456_Canon.jpg"), which ends the extension.
+//
+// Example: addIncrementCompound("/path/file.backup.tar.gz", 1) -> "/path/file_1.backup.tar.gz"
+func addIncrementCompound(path string, increment int) string {
+	return addSuffixCompound(path, strconv.Itoa(increment))
+}
+
+// addSuffixCompound adds "_" and suffix before a compound extension (see addIncrementCompound)
+func addSuffixCompound(path, suffix string) string {
+	dir := filepath.Dir(path)
+	base := filepath.Base(path)
+
+	// Ignore a leading dot so hidden files keep their name as the stem
+	offset := 0
+	if strings.HasPrefix(base, ".") {
+		offset = 1
+	}
+
+	segments := strings.Split(base[offset:], ".")
+	stemEnd := len(segments)
+	for stemEnd > 1 && isExtensionSegment(segments[stemEnd-1]) {
+		stemEnd--
+	}
+
+	stem := base[:offset] + strings.Join(segments[:stemEnd], ".")
+	ext := ""
+	if stemEnd < len(segments) {
+		ext = "." + strings.Join(segments[stemEnd:], ".")
+	}
+
+	return filepath.Join(dir, stem+"_"+suffix+ext)
+}
+
+// isExtensionSegment reports whether a dot-separated segment looks like part of an extension
+func isExtensionSegment(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	for _, r := range segment {
+		isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		isDigit := r >= '0' && r <= '9'
+		if !isLetter && !isDigit {
+			return false
+		}
+	}
+	return true
 }
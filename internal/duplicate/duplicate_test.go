@@ -13,6 +13,12 @@ import (
 func TestNew(t *testing.T) {
 	detector := New()
 	assert.NotNil(t, detector)
+	assert.Equal(t, DefaultMaxCollisions, detector.MaxCollisions)
+}
+
+func TestNewWithLimit(t *testing.T) {
+	assert.Equal(t, 3, NewWithLimit(3).MaxCollisions)
+	assert.Equal(t, DefaultMaxCollisions, NewWithLimit(0).MaxCollisions)
 }
 
 func TestCalculateSHA256(t *testing.T) {
@@ -321,6 +327,29 @@ func TestResolveCollision(t *testing.T) {
 	})
 }
 
+func TestResolveCollisionLimit(t *testing.T) {
+	tmpDir := t.TempDir()
+	source := filepath.Join(tmpDir, "source.jpg")
+	require.NoError(t, os.WriteFile(source, []byte("source content"), 0644))
+
+	// Occupy the base name and the first three increments with different content
+	dest := filepath.Join(tmpDir, "dest.jpg")
+	require.NoError(t, os.WriteFile(dest, []byte("other 0"), 0644))
+	for i := 1; i <= 3; i++ {
+		path := filepath.Join(tmpDir, fmt.Sprintf("dest_%d.jpg", i))
+		require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf("other %d", i)), 0644))
+	}
+
+	_, _, err := NewWithLimit(3).ResolveCollision(source, dest)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "too many collisions")
+
+	// One more increment is enough with a higher limit
+	finalPath, _, err := NewWithLimit(4).ResolveCollision(source, dest)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(tmpDir, "dest_4.jpg"), finalPath)
+}
+
 func TestCheckAndResolve(t *testing.T) {
 	t.Run("no collision", func(t *testing.T) {
 		tmpDir := t.TempDir()
@@ -373,6 +402,109 @@ func TestCheckAndResolve(t *testing.T) {
 	})
 }
 
+func TestFileSHA256IgnoresOriginalBackup(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.jpg")
+	require.NoError(t, os.WriteFile(testFile, []byte("modified"), 0644))
+	require.NoError(t, os.WriteFile(testFile+"_original", []byte("original"), 0644))
+
+	hash, err := FileSHA256(testFile)
+	require.NoError(t, err)
+	backupHash, err := New().CalculateSHA256(testFile)
+	require.NoError(t, err)
+
+	// sha256("modified")
+	assert.Equal(t, "b80012851cf027c6d8adda328907d400c95773958fb4fec3e544a02cd5eeab0e", hash)
+	assert.NotEqual(t, backupHash, hash)
+}
+
+func TestIndexDirectoryParallel(t *testing.T) {
+	t.Run("indexes nested files", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		for i := 0; i < 20; i++ {
+			dir := filepath.Join(tmpDir, fmt.Sprintf("%02d", i%4))
+			require.NoError(t, os.MkdirAll(dir, 0755))
+			path := filepath.Join(dir, fmt.Sprintf("file_%02d.jpg", i))
+			require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf("content %d", i)), 0644))
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "a.jpg_original"), []byte("original"), 0644))
+
+		detector := New()
+		index := NewHashIndex()
+		failed, err := detector.IndexDirectoryParallel(tmpDir, index, 4)
+		require.NoError(t, err)
+		assert.Equal(t, 0, failed)
+		assert.Equal(t, 20, index.Len())
+
+		hash, err := detector.CalculateSHA256(filepath.Join(tmpDir, "01", "file_01.jpg"))
+		require.NoError(t, err)
+		assert.True(t, index.Contains(hash))
+		assert.False(t, index.Contains("not-a-hash"))
+	})
+
+	t.Run("skips exiftool backups and temp files", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "a.jpg"), []byte("modified"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "a.jpg_original"), []byte("original"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, ".tmp-123"), []byte("partial"), 0644))
+
+		index := NewHashIndex()
+		failed, err := New().IndexDirectoryParallel(tmpDir, index, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 0, failed)
+		assert.Equal(t, 1, index.Len())
+	})
+
+	t.Run("unreadable files are counted, not fatal", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "a.jpg"), []byte("content a"), 0644))
+		if err := os.Symlink(filepath.Join(tmpDir, "missing.jpg"), filepath.Join(tmpDir, "broken.jpg")); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+
+		index := NewHashIndex()
+		failed, err := New().IndexDirectoryParallel(tmpDir, index, 2)
+		require.NoError(t, err)
+		assert.Equal(t, 1, failed)
+		assert.Equal(t, 1, index.Len())
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		index := NewHashIndex()
+		failed, err := New().IndexDirectoryParallel("/nonexistent/directory", index, 2)
+		require.NoError(t, err)
+		assert.Equal(t, 0, failed)
+		assert.Equal(t, 0, index.Len())
+	})
+}
+
+// BenchmarkIndexDirectory compares single and multi-worker hashing of a destination tree
+func BenchmarkIndexDirectory(b *testing.B) {
+	tmpDir := b.TempDir()
+	content := make([]byte, 256*1024)
+	for i := 0; i < 64; i++ {
+		dir := filepath.Join(tmpDir, fmt.Sprintf("%02d", i%8))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			b.Fatal(err)
+		}
+		content[0] = byte(i)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file_%02d.jpg", i)), content, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	detector := New()
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := detector.IndexDirectoryParallel(tmpDir, NewHashIndex(), workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 func TestAddIncrement(t *testing.T) {
 	t.Run("basic increment", func(t *testing.T) {
 		path := "/path/to/file.jpg"
@@ -398,3 +530,236 @@ func TestAddIncrement(t *testing.T) {
 		assert.Equal(t, "/path/to/file.backup.tar_1.gz", result)
 	})
 }
+
+func TestAddIncrementCompound(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"simple extension", "/path/to/file.jpg", "/path/to/file_1.jpg"},
+		{"compound extension", "/path/to/archive.tar.gz", "/path/to/archive_1.tar.gz"},
+		{"multiple dots", "/path/to/file.backup.tar.gz", "/path/to/file_1.backup.tar.gz"},
+		{"no extension", "/path/to/file", "/path/to/file_1"},
+		{"hidden file", "/path/to/.config.bak", "/path/to/.config_1.bak"},
+		{"generated name", "/path/to/20240115-123045.123456_Canon-EOS5d.jpg", "/path/to/20240115-123045.123456_Canon-EOS5d_1.jpg"},
+		{"generated name without subseconds", "/path/to/20240115-123045._Canon.jpg", "/path/to/20240115-123045._Canon_1.jpg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, addIncrementCompound(tt.path, 1))
+		})
+	}
+}
+
+func TestResolveCollisionPreserveCompoundExt(t *testing.T) {
+	tmpDir := t.TempDir()
+	source := filepath.Join(tmpDir, "source.tar.gz")
+	dest := filepath.Join(tmpDir, "backup.tar.gz")
+	require.NoError(t, os.WriteFile(source, []byte("source"), 0644))
+	require.NoError(t, os.WriteFile(dest, []byte("different"), 0644))
+
+	detector := New()
+	detector.PreserveCompoundExt = true
+
+	finalPath, _, err := detector.ResolveCollision(source, dest)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(tmpDir, "backup_1.tar.gz"), finalPath)
+}
+
+func TestResolveCollisionCaseInsensitive(t *testing.T) {
+	tmpDir := t.TempDir()
+	source := filepath.Join(tmpDir, "source.jpg")
+	require.NoError(t, os.WriteFile(source, []byte("source content"), 0644))
+
+	// Names differing only in case collide on NTFS/SMB
+	other := filepath.Join(tmpDir, "20240115-123045_CANON-EOS5D.jpg")
+	require.NoError(t, os.WriteFile(other, []byte("other content"), 0644))
+	dest := filepath.Join(tmpDir, "20240115-123045_Canon-Eos5d.jpg")
+
+	detector := New()
+	detector.CaseInsensitive = true
+	resolved, sourceHash, err := detector.ResolveCollision(source, dest)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(tmpDir, "20240115-123045_Canon-Eos5d_1.jpg"), resolved)
+	assert.NotNil(t, sourceHash)
+
+	// An identical file under a different case is the duplicate
+	same := filepath.Join(tmpDir, "20240115-123045_Canon-Eos5d_1.JPG")
+	require.NoError(t, os.WriteFile(same, []byte("source content"), 0644))
+	finalPath, isDuplicate, err := detector.CheckAndResolve(source, dest)
+	require.NoError(t, err)
+	assert.True(t, isDuplicate)
+	assert.Equal(t, same, finalPath)
+}
+
+func TestResolveCollisionHashSuffix(t *testing.T) {
+	tmpDir := t.TempDir()
+	dest := filepath.Join(tmpDir, "20240115-123045.123456_Canon-Eos5d.jpg")
+	require.NoError(t, os.WriteFile(dest, []byte("first shot"), 0644))
+
+	detector := New()
+	detector.HashSuffix = true
+
+	// Two different files with the same timestamp each get their own hash suffix
+	var resolved []string
+	for _, content := range []string{"second shot", "third shot"} {
+		source := filepath.Join(tmpDir, content+".jpg")
+		require.NoError(t, os.WriteFile(source, []byte(content), 0644))
+
+		hash, err := FileSHA256(source)
+		require.NoError(t, err)
+
+		finalPath, isDuplicate, err := detector.CheckAndResolve(source, dest)
+		require.NoError(t, err)
+		assert.False(t, isDuplicate)
+		assert.Equal(t, filepath.Join(tmpDir, "20240115-123045.123456_Canon-Eos5d_"+hash[:8]+".jpg"), finalPath)
+		require.NoError(t, os.WriteFile(finalPath, []byte(content), 0644))
+		resolved = append(resolved, finalPath)
+	}
+	assert.NotEqual(t, resolved[0], resolved[1])
+
+	// Re-running finds the hash-named copy as the duplicate
+	source := filepath.Join(tmpDir, "second shot.jpg")
+	finalPath, isDuplicate, err := detector.CheckAndResolve(source, dest)
+	require.NoError(t, err)
+	assert.True(t, isDuplicate)
+	assert.Equal(t, resolved[0], finalPath)
+
+	// A clash on the hash suffix itself falls back to _N
+	clashing := filepath.Join(tmpDir, "clash.jpg")
+	require.NoError(t, os.WriteFile(clashing, []byte("clash"), 0644))
+	hash, err := FileSHA256(clashing)
+	require.NoError(t, err)
+	hashed := filepath.Join(tmpDir, "20240115-123045.123456_Canon-Eos5d_"+hash[:8]+".jpg")
+	require.NoError(t, os.WriteFile(hashed, []byte("not the clash"), 0644))
+	finalPath, _, err = detector.CheckAndResolve(clashing, dest)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(tmpDir, "20240115-123045.123456_Canon-Eos5d_"+hash[:8]+"_1.jpg"), finalPath)
+}
+
+func TestResolveCollisionOverwrite(t *testing.T) {
+	tmpDir := t.TempDir()
+	dest := filepath.Join(tmpDir, "20240115-123045.123456_Canon-Eos5d.jpg")
+	require.NoError(t, os.WriteFile(dest, []byte("existing"), 0644))
+
+	detector := New()
+	detector.Overwrite = true
+
+	// A different file gets the existing name, to replace it
+	source := filepath.Join(tmpDir, "new.jpg")
+	require.NoError(t, os.WriteFile(source, []byte("authoritative"), 0644))
+	finalPath, isDuplicate, err := detector.CheckAndResolve(source, dest)
+	require.NoError(t, err)
+	assert.False(t, isDuplicate)
+	assert.Equal(t, dest, finalPath)
+
+	// An identical file is still a duplicate
+	same := filepath.Join(tmpDir, "same.jpg")
+	require.NoError(t, os.WriteFile(same, []byte("existing"), 0644))
+	finalPath, isDuplicate, err = detector.CheckAndResolve(same, dest)
+	require.NoError(t, err)
+	assert.True(t, isDuplicate)
+	assert.Equal(t, dest, finalPath)
+
+	// A protected file is not replaced, and a protected path is taken even
+	// before anything is there
+	claimed := filepath.Join(tmpDir, "claimed.jpg")
+	detector.Protected = func(path string) bool { return path == dest || path == claimed }
+	finalPath, isDuplicate, err = detector.CheckAndResolve(source, dest)
+	require.NoError(t, err)
+	assert.False(t, isDuplicate)
+	assert.Equal(t, filepath.Join(tmpDir, "20240115-123045.123456_Canon-Eos5d_1.jpg"), finalPath)
+	finalPath, isDuplicate, err = detector.CheckAndResolve(source, claimed)
+	require.NoError(t, err)
+	assert.False(t, isDuplicate)
+	assert.Equal(t, filepath.Join(tmpDir, "claimed_1.jpg"), finalPath)
+}
+
+func TestFindIdenticalSibling(t *testing.T) {
+	tmpDir := t.TempDir()
+	dayDir := filepath.Join(tmpDir, "2024-01-15")
+	require.NoError(t, os.MkdirAll(dayDir, 0755))
+	write := func(name, content string) string {
+		path := filepath.Join(dayDir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	write("20240115-123045.000000_Canon-Eos5d.jpg", "other")
+	write("20240115-123046.000000_Canon-Eos5d.jpg", "shot")
+	write("20240115-123045.000000_Canon-Eos5d.cr2", "shot")
+	write("20240115-123045.000000_Canon-Eos5d.jpg_original", "shot")
+	sibling := write("20240115-123045.000000_Canon-Eos5d_1.JPG", "shot")
+	source := filepath.Join(tmpDir, "IMG_0001.jpg")
+	require.NoError(t, os.WriteFile(source, []byte("shot"), 0644))
+
+	detector := New()
+	dest := filepath.Join(dayDir, "20240115-123045.000000_Canon-Eos5d.jpg")
+	found, err := detector.FindIdenticalSibling(source, dest, "20240115-123045")
+	require.NoError(t, err)
+	assert.Equal(t, sibling, found)
+
+	// The source and the destination themselves are never matches
+	found, err = detector.FindIdenticalSibling(filepath.Join(dayDir, "20240115-123045.000000_Canon-Eos5d.jpg"), sibling, "20240115-123045")
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	// A directory that doesn't exist yet has no siblings
+	found, err = detector.FindIdenticalSibling(source, filepath.Join(tmpDir, "missing", "x.jpg"), "20240115-123045")
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func TestGroupDuplicates(t *testing.T) {
+	tmpDir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(tmpDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	a1 := write("b/a.jpg", "apple")
+	a2 := write("a.jpg", "apple")
+	a3 := write("c/a copy.jpg", "apple")
+	o1 := write("orange.jpg", "orange")
+	o2 := write("z/orange.jpg", "orange")
+	write("lemon.jpg", "lemon") // same size as apple, different content
+	write("unique.jpg", "one of a kind")
+
+	// A stale _original backup doesn't make a file look like a duplicate
+	edited := write("edited.jpg", "apple-edited")
+	write("edited.jpg_original", "apple")
+
+	files := []string{a1, a2, a3, o1, o2, filepath.Join(tmpDir, "lemon.jpg"), filepath.Join(tmpDir, "unique.jpg"), edited, filepath.Join(tmpDir, "missing.jpg")}
+	groups, failed := New().GroupDuplicates(files, 2)
+
+	assert.Equal(t, 1, failed, "the missing file")
+	assert.Equal(t, [][]string{{a2, a1, a3}, {o1, o2}}, groups)
+}
+
+func TestGroupDuplicatesLinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "b.jpg")
+	require.NoError(t, os.WriteFile(target, []byte("only copy"), 0644))
+
+	// A symlink isn't a copy of its target
+	link := filepath.Join(tmpDir, "a.jpg")
+	require.NoError(t, os.Symlink(target, link))
+
+	// Nor is a hard link, which is the same file
+	hardLink := filepath.Join(tmpDir, "c.jpg")
+	require.NoError(t, os.Link(target, hardLink))
+
+	groups, failed := New().GroupDuplicates([]string{target, link, hardLink}, 2)
+	assert.Zero(t, failed)
+	assert.Empty(t, groups)
+
+	// A real copy is still found, once
+	copied := filepath.Join(tmpDir, "d.jpg")
+	require.NoError(t, os.WriteFile(copied, []byte("only copy"), 0644))
+	groups, _ = New().GroupDuplicates([]string{link, hardLink, copied, target}, 2)
+	assert.Equal(t, [][]string{{target, copied}}, groups)
+}
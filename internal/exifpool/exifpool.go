@@ -0,0 +1,119 @@
+// Package exifpool shares a bounded set of ExifTool processes between
+// goroutines, so a run with many workers doesn't start a process per file.
+package exifpool
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/barasher/go-exiftool"
+)
+
+// DefaultMaxSize caps DefaultSize. Each ExifTool process costs tens of MB,
+// and past a handful of them reading metadata is bound by disk, not CPU.
+const DefaultMaxSize = 8
+
+// ErrClosed is returned by Get once the pool is closed
+var ErrClosed = errors.New("exiftool pool is closed")
+
+// DefaultSize returns the pool size for a number of workers: one process
+// each, up to DefaultMaxSize
+func DefaultSize(workers int) int {
+	return max(1, min(workers, DefaultMaxSize))
+}
+
+// Pool lends out at most Size ExifTool processes at a time. Processes are
+// started on first use and kept for reuse until Close.
+//
+// Safe for concurrent use.
+type Pool struct {
+	// slots holds a token for every process lent out
+	slots chan struct{}
+
+	// start launches a process; replaced in tests
+	start func() (*exiftool.Exiftool, error)
+
+	mu     sync.Mutex
+	idle   []*exiftool.Exiftool
+	live   int // Started and not yet closed
+	peak   int // Highest live
+	closed bool
+}
+
+// New creates a pool of up to size processes (at least 1)
+func New(size int) *Pool {
+	return &Pool{
+		slots: make(chan struct{}, max(size, 1)),
+		start: func() (*exiftool.Exiftool, error) { return exiftool.NewExiftool() },
+	}
+}
+
+// Size returns the most processes the pool runs at once
+func (p *Pool) Size() int {
+	return cap(p.slots)
+}
+
+// Get borrows a process, starting one if none is idle. It blocks while all
+// Size processes are lent out. Every process borrowed must be given back
+// with Put.
+func (p *Pool) Get() (*exiftool.Exiftool, error) {
+	p.slots <- struct{}{}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.slots
+		return nil, ErrClosed
+	}
+	if n := len(p.idle); n > 0 {
+		et := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return et, nil
+	}
+	p.live++
+	p.peak = max(p.peak, p.live)
+	p.mu.Unlock()
+
+	et, err := p.start()
+	if err != nil {
+		p.mu.Lock()
+		p.live--
+		p.mu.Unlock()
+		<-p.slots
+		return nil, err
+	}
+	return et, nil
+}
+
+// Put gives back a process borrowed with Get. After Close it is shut down
+// instead.
+func (p *Pool) Put(et *exiftool.Exiftool) {
+	p.mu.Lock()
+	if p.closed {
+		p.live--
+		p.mu.Unlock()
+		et.Close()
+	} else {
+		p.idle = append(p.idle, et)
+		p.mu.Unlock()
+	}
+	<-p.slots
+}
+
+// Close shuts down the idle processes; those still lent out are shut down
+// as they are put back. Get fails afterwards.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.live -= len(idle)
+	p.closed = true
+	p.mu.Unlock()
+
+	var errs []error
+	for _, et := range idle {
+		errs = append(errs, et.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,64 @@
+package exifpool
+
+import (
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultSize(t *testing.T) {
+	assert.Equal(t, 1, DefaultSize(0))
+	assert.Equal(t, 4, DefaultSize(4))
+	assert.Equal(t, DefaultMaxSize, DefaultSize(32))
+}
+
+func TestPoolBoundsProcesses(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	pool := New(2)
+	testFile := filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg")
+
+	var borrowed, maxBorrowed atomic.Int64
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			et, err := pool.Get()
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer pool.Put(et)
+
+			n := borrowed.Add(1)
+			for {
+				m := maxBorrowed.Load()
+				if n <= m || maxBorrowed.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			infos := et.ExtractMetadata(testFile)
+			assert.Len(t, infos, 1)
+			time.Sleep(10 * time.Millisecond)
+			borrowed.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, maxBorrowed.Load(), int64(2))
+	assert.LessOrEqual(t, pool.peak, 2, "no more processes than the pool size")
+	assert.Equal(t, pool.live, len(pool.idle), "every process was put back")
+
+	require.NoError(t, pool.Close())
+	assert.Equal(t, 0, pool.live)
+	_, err := pool.Get()
+	assert.ErrorIs(t, err, ErrClosed)
+}
@@ -0,0 +1,19 @@
+//go:build darwin
+
+package metadata
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// birthTime returns the file's creation time from Birthtimespec, or ModTime
+// when unavailable. The earlier of the two is returned.
+func birthTime(path string, info os.FileInfo) time.Time {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.ModTime()
+	}
+	return earliest(time.Unix(stat.Birthtimespec.Sec, stat.Birthtimespec.Nsec), info.ModTime())
+}
@@ -0,0 +1,20 @@
+//go:build linux
+
+package metadata
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// birthTime returns the file's creation time via statx(2), or ModTime when the
+// kernel or filesystem doesn't record it. The earlier of the two is returned.
+func birthTime(path string, info os.FileInfo) time.Time {
+	var stx unix.Statx_t
+	if err := unix.Statx(unix.AT_FDCWD, path, 0, unix.STATX_BTIME, &stx); err != nil || stx.Mask&unix.STATX_BTIME == 0 {
+		return info.ModTime()
+	}
+	return earliest(time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec)), info.ModTime())
+}
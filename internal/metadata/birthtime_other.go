@@ -0,0 +1,13 @@
+//go:build !darwin && !linux && !windows
+
+package metadata
+
+import (
+	"os"
+	"time"
+)
+
+// birthTime returns ModTime; creation time isn't available on this platform.
+func birthTime(path string, info os.FileInfo) time.Time {
+	return info.ModTime()
+}
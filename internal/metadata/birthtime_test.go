@@ -0,0 +1,64 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBirthTime tests that a later modification doesn't move the fallback date
+func TestBirthTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+
+	created := time.Now()
+	modified := created.Add(48 * time.Hour)
+	require.NoError(t, os.Chtimes(path, modified, modified))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	bt := birthTime(path, info)
+
+	assert.False(t, bt.After(info.ModTime()), "birth time should never be after ModTime")
+
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		assert.WithinDuration(t, created, bt, time.Minute)
+	case "linux":
+		if bt.Equal(info.ModTime()) {
+			t.Skip("filesystem doesn't record birth time")
+		}
+		assert.WithinDuration(t, created, bt, time.Minute)
+	default:
+		assert.Equal(t, info.ModTime(), bt)
+	}
+}
+
+// TestBirthTimeKeepsOlderModTime tests that copies preserving mtime keep the older date
+func TestBirthTimeKeepsOlderModTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+
+	captured := time.Date(2015, 6, 1, 10, 0, 0, 0, time.Local)
+	require.NoError(t, os.Chtimes(path, captured, captured))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	assert.True(t, captured.Equal(birthTime(path, info)))
+}
+
+// TestEarliest tests choosing between birth and modification times
+func TestEarliest(t *testing.T) {
+	mod := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, mod.Add(-time.Hour), earliest(mod.Add(-time.Hour), mod))
+	assert.Equal(t, mod, earliest(mod.Add(time.Hour), mod))
+	assert.Equal(t, mod, earliest(time.Time{}, mod))
+	assert.Equal(t, mod, earliest(time.Unix(0, 0), mod))
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package metadata
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// birthTime returns the file's CreationTime, or ModTime when unavailable.
+// The earlier of the two is returned.
+func birthTime(path string, info os.FileInfo) time.Time {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return info.ModTime()
+	}
+	return earliest(time.Unix(0, data.CreationTime.Nanoseconds()), info.ModTime())
+}
@@ -0,0 +1,45 @@
+//go:build windows
+
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBirthTimeCreationTime tests that the fallback date is the file's
+// CreationTime rather than its later ModTime
+func TestBirthTimeCreationTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+
+	created := time.Date(2019, 7, 4, 9, 15, 0, 0, time.UTC)
+	modified := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	setCreationTime(t, path, created)
+	require.NoError(t, os.Chtimes(path, modified, modified))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	assert.True(t, created.Equal(birthTime(path, info)), "got %v", birthTime(path, info))
+}
+
+// setCreationTime sets the CreationTime of path, which os.Chtimes can't
+func setCreationTime(t *testing.T, path string, created time.Time) {
+	t.Helper()
+
+	name, err := syscall.UTF16PtrFromString(path)
+	require.NoError(t, err)
+	handle, err := syscall.CreateFile(name, syscall.FILE_WRITE_ATTRIBUTES, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE, nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	require.NoError(t, err)
+	defer syscall.CloseHandle(handle)
+
+	ft := syscall.NsecToFiletime(created.UnixNano())
+	require.NoError(t, syscall.SetFileTime(handle, &ft, nil, nil))
+}
@@ -1,15 +1,19 @@
 package metadata
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/barasher/go-exiftool"
+	"github.com/cacack/sortpics-go/internal/exifpool"
 	"github.com/cacack/sortpics-go/pkg/config"
 )
 
@@ -17,6 +21,10 @@ import (
 // Example: 20240115-123045.123456
 var DATE_PATTERN = regexp.MustCompile(`([0-9]{8})(.)?([0-9]{6})?(.)?([0-9]+)?`)
 
+// PARTIAL_DATE_PATTERN matches the dates scans are often named with:
+// YYYY-MM-DD, YYYY-MM, or YYYY_MM (e.g. 1985-07_grandma.jpg)
+var PARTIAL_DATE_PATTERN = regexp.MustCompile(`(?:^|[^0-9])((?:18|19|20)[0-9]{2})[-_](0[1-9]|1[0-2])(?:[-_](0[1-9]|[12][0-9]|3[01]))?(?:[^0-9]|$)`)
+
 // ExifNotFoundError is returned when exiftool is not available
 type ExifNotFoundError struct {
 	Err error
@@ -26,15 +34,78 @@ func (e *ExifNotFoundError) Error() string {
 	return fmt.Sprintf("exiftool not found: %v", e.Err)
 }
 
+// UnparseableError is returned when ExifTool couldn't read a file at all
+type UnparseableError struct {
+	Err error
+}
+
+func (e *UnparseableError) Error() string {
+	return fmt.Sprintf("exiftool error: %v", e.Err)
+}
+
+func (e *UnparseableError) Unwrap() error {
+	return e.Err
+}
+
 // MetadataExtractor extracts and parses metadata from image files.
 //
 // Uses a fallback hierarchy for datetime extraction:
-// 1. EXIF:DateTimeOriginal or EXIF:ModifyDate (with SubSecTimeOriginal)
-// 2. QuickTime:CreateDate (for MOV files)
+// 1. EXIF:DateTimeOriginal, DateTimeDigitized, CreateDate, or ModifyDate (with SubSecTimeOriginal, SubSecTimeDigitized, or SubSecTime)
+// 2. QuickTime creation dates (for MOV/MP4 files)
 // 3. Datetime pattern in filename (YYYYMMDD-HHMMSS.subsec)
-// 4. File's ctime from filesystem
+// 4. GPS datetime (UTC, converted to Location)
+// 5. File's birth time from filesystem (or ModTime, whichever is earlier)
 type MetadataExtractor struct {
 	et *exiftool.Exiftool
+
+	// pool, when set, lends a process for each extraction instead of et
+	pool *exifpool.Pool
+
+	// QuickTimeLocation, when set, treats QuickTime dates without an explicit
+	// offset as UTC (as the spec requires) and converts them to this location.
+	// When nil they are used as-is, which suits cameras that write local time.
+	QuickTimeLocation *time.Location
+
+	// Location is the time zone UTC timestamps (GPS) are converted to, so
+	// files are named and bucketed by the local day they were shot on. When
+	// nil the system's local time zone is used.
+	Location *time.Location
+
+	// ModelMap replaces camera models, keyed by the model tag as written or
+	// with the make removed (e.g. "ILCE-7M3" -> "A7III"). Mapped names are
+	// used as-is, without CamelCase. See LoadModelMap.
+	ModelMap map[string]string
+
+	// PreferFilenameDate tries the filename date before EXIF and QuickTime,
+	// for scans whose metadata holds the scan date rather than the photo's
+	PreferFilenameDate bool
+
+	// abandoned is set when ExtractContext gave up on a running extraction
+	abandoned atomic.Bool
+}
+
+// exifDatetimeKeys lists photo datetime tags in order of preference. Some older
+// RAW formats have no EXIF DateTimeOriginal and only carry it in their maker
+// notes or the XMP of the embedded preview. The digitized and create dates are
+// when the image was stored, which beats ModifyDate after an edit.
+var exifDatetimeKeys = []string{
+	"EXIF:DateTimeOriginal", "DateTimeOriginal",
+	"MakerNotes:DateTimeOriginal",
+	"XMP:DateTimeOriginal",
+	"Composite:DateTimeOriginal",
+	"H264:DateTimeOriginal", // AVCHD (.mts/.m2ts) video stream
+	"EXIF:DateTimeDigitized", "DateTimeDigitized",
+	"EXIF:CreateDate", "CreateDate",
+	"EXIF:ModifyDate", "ModifyDate",
+}
+
+// videoDatetimeKeys lists video datetime tags in order of preference.
+// Apple's CreationDate carries its own UTC offset; the rest usually do not.
+var videoDatetimeKeys = []string{
+	"QuickTime:CreationDate", "CreationDate",
+	"QuickTime:CreateDate", "CreateDate",
+	"QuickTime:MediaCreateDate", "MediaCreateDate",
+	"QuickTime:TrackCreateDate", "TrackCreateDate",
 }
 
 // NewMetadataExtractor creates a new MetadataExtractor with an ExifTool instance.
@@ -47,12 +118,26 @@ func NewMetadataExtractor() (*MetadataExtractor, error) {
 	return &MetadataExtractor{et: et}, nil
 }
 
+// NewPooledMetadataExtractor creates an extractor that borrows an ExifTool
+// process from pool for each extraction rather than starting its own.
+// Closing it leaves the pool open.
+func NewPooledMetadataExtractor(pool *exifpool.Pool) *MetadataExtractor {
+	return &MetadataExtractor{pool: pool}
+}
+
 // Close closes the ExifTool process.
+//
+// If ExtractContext abandoned an extraction, ExifTool is shut down in the
+// background once that extraction finishes, so Close doesn't block on it.
 func (m *MetadataExtractor) Close() error {
-	if m.et != nil {
-		return m.et.Close()
+	if m.et == nil {
+		return nil
 	}
-	return nil
+	if m.abandoned.Load() {
+		go m.et.Close()
+		return nil
+	}
+	return m.et.Close()
 }
 
 // Extract extracts metadata from a file.
@@ -77,7 +162,7 @@ func (m *MetadataExtractor) Extract(filePath string, timeAdjust, dayAdjust *time
 	}
 
 	// Parse datetime with fallback hierarchy
-	dt := m.parseDatetime(filePath, rawMetadata, fileStat)
+	dt, dateSource := m.parseDatetimeWithSource(filePath, rawMetadata, fileStat)
 
 	// Apply time/day adjustments if provided
 	if timeAdjust != nil && dt != nil {
@@ -92,50 +177,139 @@ func (m *MetadataExtractor) Extract(filePath string, timeAdjust, dayAdjust *time
 	// Parse make and model
 	make := m.parseMake(rawMetadata)
 	model := m.parseModel(make, rawMetadata)
+	lens := m.parseLens(rawMetadata)
 
 	return &config.ImageMetadata{
-		DateTime:    dt,
-		Make:        make,
-		Model:       model,
-		RawMetadata: rawMetadata,
+		DateTime:      dt,
+		DateSource:    dateSource,
+		Make:          make,
+		Model:         model,
+		MakeOriginal:  m.parseMakeOriginal(rawMetadata),
+		ModelOriginal: m.parseModelOriginal(make, rawMetadata),
+		Lens:          lens,
+		Rating:        m.parseRating(rawMetadata),
+		ParseError:    parseError(rawMetadata),
+		RawMetadata:   rawMetadata,
 	}, nil
 }
 
+// ExtractContext is Extract, but returns ctx.Err() as soon as ctx is canceled.
+//
+// ExifTool can't be interrupted mid-command, so an abandoned extraction runs
+// to completion in the background and its result is discarded. The extractor
+// should be closed afterwards rather than reused.
+func (m *MetadataExtractor) ExtractContext(ctx context.Context, filePath string, timeAdjust, dayAdjust *time.Duration) (*config.ImageMetadata, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		meta *config.ImageMetadata
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		meta, err := m.Extract(filePath, timeAdjust, dayAdjust)
+		done <- result{meta, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.meta, r.err
+	case <-ctx.Done():
+		m.abandoned.Store(true)
+		return nil, ctx.Err()
+	}
+}
+
 // getMetadata gets raw metadata from file using exiftool
 func (m *MetadataExtractor) getMetadata(filePath string) (map[string]interface{}, error) {
-	fileInfos := m.et.ExtractMetadata(filePath)
+	et := m.et
+	if m.pool != nil {
+		pooled, err := m.pool.Get()
+		if err != nil {
+			if errors.Is(err, exifpool.ErrClosed) {
+				return nil, err
+			}
+			return nil, &ExifNotFoundError{Err: err}
+		}
+		defer m.pool.Put(pooled)
+		et = pooled
+	}
+
+	fileInfos := et.ExtractMetadata(filePath)
 	if len(fileInfos) == 0 {
 		return nil, fmt.Errorf("no metadata returned for file: %s", filePath)
 	}
 
 	fileInfo := fileInfos[0]
 	if fileInfo.Err != nil {
-		return nil, fmt.Errorf("exiftool error: %w", fileInfo.Err)
+		return nil, &UnparseableError{Err: fileInfo.Err}
 	}
 
 	return fileInfo.Fields, nil
 }
 
+// parseError returns the error ExifTool reported for a file it couldn't
+// parse (e.g. "File format error" for a corrupt JPEG), or ""
+func parseError(rawMetadata map[string]interface{}) string {
+	for _, key := range []string{"ExifTool:Error", "Error"} {
+		if msg, ok := rawMetadata[key].(string); ok && msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
 // parseDatetime parses datetime from metadata with fallback hierarchy
 //
 // Tries in order:
-// 1. EXIF datetime fields (DateTimeOriginal or ModifyDate with SubSecTimeOriginal)
-// 2. QuickTime datetime fields (CreateDate for videos)
-// 3. Datetime pattern in filename
-// 4. File ctime
+// 1. EXIF datetime fields (DateTimeOriginal, the copies RAW formats keep in
+// maker notes, XMP, or Composite, or the one in AVCHD video streams, then
+// DateTimeDigitized, CreateDate, or ModifyDate, with SubSecTimeOriginal,
+// SubSecTimeDigitized, or SubSecTime)
+// 2. QuickTime datetime fields (CreationDate, CreateDate, MediaCreateDate, TrackCreateDate)
+// 3. Datetime pattern in filename (YYYYMMDD-HHMMSS.subsec, then a partial
+// YYYY-MM-DD or YYYY-MM date with the day defaulting to the 1st)
+// 4. GPS datetime (GPSDateTime, or GPSDateStamp + GPSTimeStamp)
+// 5. File birth time
+//
+// With PreferFilenameDate, the filename is tried first.
 func (m *MetadataExtractor) parseDatetime(filePath string, rawMetadata map[string]interface{}, fileStat os.FileInfo) *time.Time {
+	dt, _ := m.parseDatetimeWithSource(filePath, rawMetadata, fileStat)
+	return dt
+}
+
+// parseDatetimeWithSource is parseDatetime that also reports which tier matched
+func (m *MetadataExtractor) parseDatetimeWithSource(filePath string, rawMetadata map[string]interface{}, fileStat os.FileInfo) (*time.Time, config.DateSource) {
+	// Scans are often named with the real date but carry the scan date
+	if m.PreferFilenameDate {
+		if dt := parseFilenameDatetime(filePath); dt != nil {
+			return dt, config.DateSourceFilename
+		}
+	}
+
 	// Try EXIF datetime fields (with and without EXIF: prefix)
-	for _, key := range []string{"EXIF:DateTimeOriginal", "DateTimeOriginal", "EXIF:ModifyDate", "ModifyDate"} {
+	for _, key := range exifDatetimeKeys {
+		// A video's unprefixed CreateDate is QuickTime's, which is UTC
+		if key == "CreateDate" && isVideoMIMEType(rawMetadata) {
+			continue
+		}
 		if dateTimeRaw, ok := rawMetadata[key]; ok {
 			if dateTimeStr, ok := dateTimeRaw.(string); ok {
 				// Parse base datetime: "2024:01:15 12:30:45"
-				dt, err := time.Parse("2006:01:02 15:04:05", dateTimeStr)
+				dt, err := parseEXIFDatetime(dateTimeStr)
 				if err != nil {
 					continue
 				}
 
-				// Try to get subsecond precision and add it (with and without EXIF: prefix)
-				for _, subsecKey := range []string{"EXIF:SubSecTimeOriginal", "SubSecTimeOriginal"} {
+				// Try to get subsecond precision and add it (with and without EXIF: prefix).
+				// Some cameras (e.g. Panasonic RW2) only record the digitized or modify subseconds.
+				for _, subsecKey := range []string{
+					"EXIF:SubSecTimeOriginal", "SubSecTimeOriginal",
+					"EXIF:SubSecTimeDigitized", "SubSecTimeDigitized",
+					"EXIF:SubSecTime", "SubSecTime",
+				} {
 					if subsec, ok := rawMetadata[subsecKey]; ok {
 						// Handle both string and numeric types
 						var subsecStr string
@@ -153,23 +327,34 @@ func (m *MetadataExtractor) parseDatetime(filePath string, rawMetadata map[strin
 					}
 				}
 
-				return &dt
+				return &dt, config.DateSourceEXIF
 			}
 		}
 	}
 
-	// Try QuickTime (MOV files) (with and without QuickTime: prefix)
-	for _, key := range []string{"QuickTime:CreateDate", "CreateDate"} {
-		if dateTimeRaw, ok := rawMetadata[key]; ok {
-			if dateTimeStr, ok := dateTimeRaw.(string); ok {
-				if dt, err := time.Parse("2006:01:02 15:04:05", dateTimeStr); err == nil {
-					return &dt
-				}
-			}
-		}
+	// Try QuickTime (MOV/MP4 files) (with and without QuickTime: prefix)
+	if dt := m.parseVideoDatetime(rawMetadata); dt != nil {
+		return dt, config.DateSourceQuickTime
 	}
 
 	// Try to extract from filename
+	if dt := parseFilenameDatetime(filePath); dt != nil {
+		return dt, config.DateSourceFilename
+	}
+
+	// Try GPS datetime (drones and action cams often have nothing else)
+	if dt := parseGPSDatetime(rawMetadata, m.location()); dt != nil {
+		return dt, config.DateSourceGPS
+	}
+
+	// Fall back to file birth time (ModTime where the platform doesn't record it)
+	dt := birthTime(filePath, fileStat)
+	return &dt, config.DateSourceCtime
+}
+
+// parseFilenameDatetime parses a YYYYMMDD-HHMMSS.subsec date from the
+// filename, falling back to a partial date (see parsePartialDate), or nil
+func parseFilenameDatetime(filePath string) *time.Time {
 	if match := DATE_PATTERN.FindStringSubmatch(filepath.Base(filePath)); match != nil {
 		timestamp := ""
 		if match[1] != "" {
@@ -197,29 +382,155 @@ func (m *MetadataExtractor) parseDatetime(filePath string, rawMetadata map[strin
 		}
 	}
 
-	// Fall back to file ctime
-	// Note: Go's FileInfo doesn't expose ctime directly, using ModTime as fallback
-	dt := fileStat.ModTime()
+	// Scans often carry only a partial date (1985-07_grandma.jpg)
+	return parsePartialDate(filepath.Base(filePath))
+}
+
+// parsePartialDate parses the first PARTIAL_DATE_PATTERN date in name, at
+// midnight on the 1st when it has no day, or returns nil
+func parsePartialDate(name string) *time.Time {
+	match := PARTIAL_DATE_PATTERN.FindStringSubmatch(name)
+	if match == nil {
+		return nil
+	}
+	day := match[3]
+	if day == "" {
+		day = "01"
+	}
+	dt, err := time.Parse("2006-01-02", fmt.Sprintf("%s-%s-%s", match[1], match[2], day))
+	if err != nil {
+		return nil
+	}
 	return &dt
 }
 
-// parseMake parses camera make from metadata
+// earliest returns the earlier of a file's birth and modification times.
 //
-// Handles special cases like HTC, LG, and filters out "Research".
-// Returns "Unknown" if make is not found.
-func (m *MetadataExtractor) parseMake(rawMetadata map[string]interface{}) string {
-	var make string
+// Birth time wins for files modified after capture, but copies that preserve
+// mtime (cp -p, rsync -t) get a new birth time, so the older value is kept.
+// A zero birth time (not recorded) is ignored.
+func earliest(birth, mod time.Time) time.Time {
+	if birth.IsZero() || birth.Unix() <= 0 || !birth.Before(mod) {
+		return mod
+	}
+	return birth
+}
 
-	// Try various make keys (with and without prefixes)
-	for _, key := range []string{"EXIF:Make", "Make", "MakerNotes:Make"} {
-		if makeRaw, ok := rawMetadata[key]; ok {
-			if makeStr, ok := makeRaw.(string); ok {
-				make = makeStr
-				break
+// parseVideoDatetime parses the first usable QuickTime datetime tag.
+//
+// Fractional seconds are kept. Dates with an explicit offset (e.g.
+// "2024:01:15 12:30:45+01:00") keep their own wall clock; others are
+// converted from UTC when QuickTimeLocation is set and the file is a video.
+// Unset dates ("0000:00:00 00:00:00") fail to parse and are skipped.
+func (m *MetadataExtractor) parseVideoDatetime(rawMetadata map[string]interface{}) *time.Time {
+	for _, key := range videoDatetimeKeys {
+		dateTimeStr, ok := rawMetadata[key].(string)
+		if !ok {
+			continue
+		}
+
+		if dt, err := time.Parse("2006:01:02 15:04:05Z07:00", dateTimeStr); err == nil {
+			return &dt
+		}
+
+		dt, err := time.Parse("2006:01:02 15:04:05", dateTimeStr)
+		if err != nil {
+			continue
+		}
+
+		// Unprefixed CreateDate can also come from a photo's EXIF, which is local time
+		isVideo := strings.HasPrefix(key, "QuickTime:") || isVideoMIMEType(rawMetadata)
+		if m.QuickTimeLocation != nil && isVideo {
+			dt = dt.In(m.QuickTimeLocation)
+		}
+		return &dt
+	}
+
+	return nil
+}
+
+// parseEXIFDatetime parses "2024:01:15 12:30:45". XMP values may add
+// fractional seconds or a UTC offset ("2024:01:15 12:30:45.12+01:00"), which
+// are dropped so every tag yields the same wall-clock time; subseconds come
+// from the SubSecTime tags.
+func parseEXIFDatetime(value string) (time.Time, error) {
+	const layout = "2006:01:02 15:04:05"
+	if len(value) > len(layout) && strings.ContainsRune(".+-Z", rune(value[len(layout)])) {
+		value = value[:len(layout)]
+	}
+	return time.Parse(layout, value)
+}
+
+// parseGPSDatetime parses the GPS timestamp, which is always UTC, and
+// converts it to loc.
+//
+// Uses Composite:GPSDateTime when present, otherwise combines
+// EXIF:GPSDateStamp ("2024:01:15") with EXIF:GPSTimeStamp ("12:30:45").
+func parseGPSDatetime(rawMetadata map[string]interface{}, loc *time.Location) *time.Time {
+	for _, key := range []string{"Composite:GPSDateTime", "GPSDateTime"} {
+		if dateTimeStr, ok := rawMetadata[key].(string); ok {
+			if dt, ok := parseGPSTimestamp(strings.TrimSuffix(dateTimeStr, "Z"), loc); ok {
+				return &dt
 			}
 		}
 	}
 
+	dateStr := firstString(rawMetadata, "EXIF:GPSDateStamp", "GPSDateStamp")
+	timeStr := firstString(rawMetadata, "EXIF:GPSTimeStamp", "GPSTimeStamp")
+	if dateStr == "" || timeStr == "" {
+		return nil
+	}
+	if dt, ok := parseGPSTimestamp(dateStr+" "+strings.TrimSuffix(timeStr, "Z"), loc); ok {
+		return &dt
+	}
+
+	return nil
+}
+
+// parseGPSTimestamp parses "2006:01:02 15:04:05[.fff]" as UTC and returns it in loc
+func parseGPSTimestamp(value string, loc *time.Location) (time.Time, bool) {
+	dt, err := time.ParseInLocation("2006:01:02 15:04:05", value, time.UTC)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return dt.In(loc), true
+}
+
+// location returns Location, or time.Local if it is nil
+func (m *MetadataExtractor) location() *time.Location {
+	if m.Location == nil {
+		return time.Local
+	}
+	return m.Location
+}
+
+// firstString returns the first non-empty string value among keys
+func firstString(rawMetadata map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := rawMetadata[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// isVideoMIMEType reports whether exiftool identified the file as a video
+func isVideoMIMEType(rawMetadata map[string]interface{}) bool {
+	for _, key := range []string{"File:MIMEType", "MIMEType"} {
+		if mimeType, ok := rawMetadata[key].(string); ok {
+			return strings.HasPrefix(mimeType, "video/")
+		}
+	}
+	return false
+}
+
+// parseMake parses camera make from metadata
+//
+// Handles special cases like HTC, LG, and filters out "Research".
+// Returns "Unknown" if make is not found.
+func (m *MetadataExtractor) parseMake(rawMetadata map[string]interface{}) string {
+	make := rawMake(rawMetadata)
+
 	if make != "" {
 		// Take first word and capitalize
 		words := strings.Fields(make)
@@ -248,40 +559,166 @@ func (m *MetadataExtractor) parseMake(rawMetadata map[string]interface{}) string
 // Removes make from model name and normalizes formatting.
 // Returns empty string if model is not found.
 func (m *MetadataExtractor) parseModel(make string, rawMetadata map[string]interface{}) string {
-	var model string
+	if mapped, ok := m.mapModel(make, rawMetadata); ok {
+		return mapped
+	}
+
+	model := modelWithoutMake(make, rawMetadata)
+
+	// Normalize spaces to CamelCase
+	if strings.Contains(model, " ") {
+		words := strings.Fields(model)
+		var camelCaseParts []string
+		for _, word := range words {
+			camelCaseParts = append(camelCaseParts, strings.Title(strings.ToLower(word)))
+		}
+		model = strings.Join(camelCaseParts, "")
+	}
+
+	if model == "" {
+		return ""
+	}
+
+	return model
+}
 
+// parseMakeOriginal parses camera make keeping its EXIF capitalization
+//
+// Takes the first word like parseMake ("NIKON CORPORATION" -> "NIKON").
+// Returns "" for "Research" and "Unknown" if make is not found.
+func (m *MetadataExtractor) parseMakeOriginal(rawMetadata map[string]interface{}) string {
+	words := strings.Fields(rawMake(rawMetadata))
+	if len(words) == 0 {
+		return "Unknown"
+	}
+	if strings.EqualFold(words[0], "Research") {
+		return ""
+	}
+	return words[0]
+}
+
+// parseModelOriginal parses camera model keeping its EXIF capitalization
+//
+// Removes make like parseModel, then drops spaces without changing case
+// ("EOS 5D Mark II" -> "EOS5DMarkII").
+func (m *MetadataExtractor) parseModelOriginal(make string, rawMetadata map[string]interface{}) string {
+	if mapped, ok := m.mapModel(make, rawMetadata); ok {
+		return mapped
+	}
+	return strings.Join(strings.Fields(modelWithoutMake(make, rawMetadata)), "")
+}
+
+// rawMake returns the unprocessed make tag, or "" if absent
+func rawMake(rawMetadata map[string]interface{}) string {
+	// Try various make keys (with and without prefixes)
+	for _, key := range []string{"EXIF:Make", "Make", "MakerNotes:Make"} {
+		if makeRaw, ok := rawMetadata[key]; ok {
+			if makeStr, ok := makeRaw.(string); ok {
+				return makeStr
+			}
+		}
+	}
+	return ""
+}
+
+// mapModel looks the model up in ModelMap, first as written in the tag, then
+// with the make removed
+func (m *MetadataExtractor) mapModel(make string, rawMetadata map[string]interface{}) (string, bool) {
+	if len(m.ModelMap) == 0 {
+		return "", false
+	}
+	if mapped, ok := m.ModelMap[strings.TrimSpace(rawModel(rawMetadata))]; ok {
+		return mapped, true
+	}
+	mapped, ok := m.ModelMap[modelWithoutMake(make, rawMetadata)]
+	return mapped, ok
+}
+
+// rawModel returns the unprocessed model tag, or "" if absent
+func rawModel(rawMetadata map[string]interface{}) string {
 	// Try various model keys (with and without prefixes)
 	for _, key := range []string{"EXIF:Model", "Model", "MakerNotes:Model"} {
 		if modelRaw, ok := rawMetadata[key]; ok {
 			if modelStr, ok := modelRaw.(string); ok {
-				model = modelStr
-				break
+				return modelStr
 			}
 		}
 	}
+	return ""
+}
 
-	// Remove make from the model
-	if make != "" && model != "" {
-		model = strings.ReplaceAll(model, make, "")
-		model = strings.ReplaceAll(model, strings.ToUpper(make), "")
-		model = strings.TrimSpace(model)
+// modelWithoutMake returns the model tag with the (normalized) make removed
+func modelWithoutMake(make string, rawMetadata map[string]interface{}) string {
+	model := rawModel(rawMetadata)
+
+	// Remove a leading make, in any case ("NIKON D5300" with make "Nikon").
+	// Only the first occurrence goes, so "Sony Sony A7" keeps one.
+	if make != "" && len(model) >= len(make) && strings.EqualFold(model[:len(make)], make) {
+		model = strings.TrimSpace(model[len(make):])
+	}
+
+	return model
+}
+
+// parseLens parses lens model from metadata
+//
+// Normalizes formatting the same way as model (spaces to CamelCase).
+// Returns empty string if lens is not found.
+func (m *MetadataExtractor) parseLens(rawMetadata map[string]interface{}) string {
+	var lens string
+
+	// Try various lens keys (with and without prefixes)
+	for _, key := range []string{"EXIF:LensModel", "LensModel", "Composite:LensID", "LensID"} {
+		if lensRaw, ok := rawMetadata[key]; ok {
+			if lensStr, ok := lensRaw.(string); ok {
+				lens = strings.TrimSpace(lensStr)
+				break
+			}
+		}
 	}
 
 	// Normalize spaces to CamelCase
-	if strings.Contains(model, " ") {
-		words := strings.Fields(model)
+	if strings.Contains(lens, " ") {
+		words := strings.Fields(lens)
 		var camelCaseParts []string
 		for _, word := range words {
 			camelCaseParts = append(camelCaseParts, strings.Title(strings.ToLower(word)))
 		}
-		model = strings.Join(camelCaseParts, "")
+		lens = strings.Join(camelCaseParts, "")
 	}
 
-	if model == "" {
-		return ""
-	}
+	return lens
+}
 
-	return model
+// parseRating parses the star rating from metadata
+//
+// Ratings run from 1 to 5, with -1 meaning rejected and 0 meaning unrated
+// (per the XMP spec). Returns nil if no rating is present or it's out of range.
+func (m *MetadataExtractor) parseRating(rawMetadata map[string]interface{}) *int {
+	for _, key := range []string{"XMP:Rating", "Rating", "EXIF:Rating"} {
+		var rating int
+		switch value := rawMetadata[key].(type) {
+		case float64:
+			if value != float64(int(value)) {
+				continue
+			}
+			rating = int(value)
+		case string:
+			parsed, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				continue
+			}
+			rating = parsed
+		default:
+			continue
+		}
+
+		if rating < -1 || rating > 5 {
+			return nil
+		}
+		return &rating
+	}
+	return nil
 }
 
 // parseSubseconds parses subsecond string to microseconds
@@ -2,9 +2,11 @@ package metadata
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/cacack/sortpics-go/pkg/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -31,6 +33,37 @@ func TestDatePattern(t *testing.T) {
 	})
 }
 
+// TestParsePartialDate tests the YYYY-MM-DD and YYYY-MM dates of scans
+func TestParsePartialDate(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		expected time.Time // zero for no date
+	}{
+		{"YYYY-MM-DD", "1985-07-04_picnic.jpg", time.Date(1985, 7, 4, 0, 0, 0, 0, time.UTC)},
+		{"YYYY_MM_DD", "scan 1985_07_04.tif", time.Date(1985, 7, 4, 0, 0, 0, 0, time.UTC)},
+		{"YYYY-MM", "1985-07_grandma.jpg", time.Date(1985, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"YYYY_MM", "1962_12.png", time.Date(1962, 12, 1, 0, 0, 0, 0, time.UTC)},
+		{"invalid day", "1985-02-30_scan.jpg", time.Time{}},
+		{"invalid month", "1985-13_scan.jpg", time.Time{}},
+		{"counter, not a year", "DSC_0001-12.jpg", time.Time{}},
+		{"longer number", "IMG_2024_0001.jpg", time.Time{}},
+		{"no date", "grandma.jpg", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dt := parsePartialDate(tt.filename)
+			if tt.expected.IsZero() {
+				assert.Nil(t, dt)
+				return
+			}
+			require.NotNil(t, dt)
+			assert.Equal(t, tt.expected, *dt)
+		})
+	}
+}
+
 // TestNewMetadataExtractor tests initialization
 func TestNewMetadataExtractor(t *testing.T) {
 	extractor, err := NewMetadataExtractor()
@@ -129,6 +162,19 @@ func TestParseModel(t *testing.T) {
 		assert.Equal(t, "Eos5d", model)
 	})
 
+	t.Run("remove make in any case", func(t *testing.T) {
+		metadata := map[string]interface{}{
+			"EXIF:Model": "NIKON D5300",
+		}
+		assert.Equal(t, "D5300", extractor.parseModel("Nikon", metadata))
+		assert.Equal(t, "D5300", extractor.parseModelOriginal("Nikon", metadata))
+	})
+
+	t.Run("remove only a leading make", func(t *testing.T) {
+		assert.Equal(t, "SonyA7", extractor.parseModel("Sony", map[string]interface{}{"EXIF:Model": "Sony Sony A7"}))
+		assert.Equal(t, "A7Sony", extractor.parseModel("Sony", map[string]interface{}{"EXIF:Model": "A7 Sony"}))
+	})
+
 	t.Run("convert spaces to CamelCase", func(t *testing.T) {
 		metadata := map[string]interface{}{
 			"EXIF:Model": "Canon PowerShot S410",
@@ -150,6 +196,157 @@ func TestParseModel(t *testing.T) {
 		model := extractor.parseModel("Nikon", metadata)
 		assert.Equal(t, "D850", model)
 	})
+
+	t.Run("model map", func(t *testing.T) {
+		modelMapPath := filepath.Join(t.TempDir(), "models.json")
+		require.NoError(t, os.WriteFile(modelMapPath, []byte(`{"ILCE-7M3": "A7III", "Canon EOS 5D Mark IV": "5DMarkIV"}`), 0644))
+		modelMap, err := LoadModelMap(modelMapPath)
+		require.NoError(t, err)
+		extractor := &MetadataExtractor{ModelMap: modelMap}
+
+		tests := []struct {
+			make         string
+			model        string
+			want         string
+			wantOriginal string
+		}{
+			{"Sony", "ILCE-7M3", "A7III", "A7III"},
+			{"Canon", "Canon EOS 5D Mark IV", "5DMarkIV", "5DMarkIV"},
+			{"Canon", "Canon EOS 5D", "Eos5d", "EOS5D"}, // Not in the map
+		}
+		for _, tt := range tests {
+			metadata := map[string]interface{}{"EXIF:Model": tt.model}
+			assert.Equal(t, tt.want, extractor.parseModel(tt.make, metadata), tt.model)
+			assert.Equal(t, tt.wantOriginal, extractor.parseModelOriginal(tt.make, metadata), tt.model)
+		}
+	})
+}
+
+// TestLoadModelMap tests reading and validating model map files
+func TestLoadModelMap(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	_, err := LoadModelMap(filepath.Join(tmpDir, "missing.json"))
+	assert.Error(t, err)
+
+	for _, content := range []string{`["ILCE-7M3"]`, `{"ILCE-7M3": 3}`, `{"ILCE-7M3": ""}`} {
+		path := filepath.Join(tmpDir, "models.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		_, err := LoadModelMap(path)
+		assert.Error(t, err, content)
+	}
+}
+
+// TestParseOriginalCase tests make/model parsing that keeps EXIF capitalization
+func TestParseOriginalCase(t *testing.T) {
+	extractor := &MetadataExtractor{}
+
+	t.Run("keep uppercase make", func(t *testing.T) {
+		metadata := map[string]interface{}{
+			"EXIF:Make":  "NIKON CORPORATION",
+			"EXIF:Model": "NIKON D850",
+		}
+		make := extractor.parseMake(metadata)
+		assert.Equal(t, "NIKON", extractor.parseMakeOriginal(metadata))
+		assert.Equal(t, "D850", extractor.parseModelOriginal(make, metadata))
+	})
+
+	t.Run("remove spaces without changing case", func(t *testing.T) {
+		metadata := map[string]interface{}{
+			"EXIF:Make":  "Canon",
+			"EXIF:Model": "Canon EOS 5D Mark II",
+		}
+		make := extractor.parseMake(metadata)
+		assert.Equal(t, "Canon", extractor.parseMakeOriginal(metadata))
+		assert.Equal(t, "EOS5DMarkII", extractor.parseModelOriginal(make, metadata))
+	})
+
+	t.Run("missing make and model", func(t *testing.T) {
+		metadata := map[string]interface{}{}
+		assert.Equal(t, "Unknown", extractor.parseMakeOriginal(metadata))
+		assert.Equal(t, "", extractor.parseModelOriginal("Unknown", metadata))
+	})
+
+	t.Run("filter out Research In Motion", func(t *testing.T) {
+		metadata := map[string]interface{}{
+			"EXIF:Make": "Research In Motion",
+		}
+		assert.Equal(t, "", extractor.parseMakeOriginal(metadata))
+	})
+}
+
+// TestParseLens tests lens parsing
+func TestParseError(t *testing.T) {
+	assert.Equal(t, "File format error", parseError(map[string]interface{}{"Error": "File format error"}))
+	assert.Equal(t, "File format error", parseError(map[string]interface{}{"ExifTool:Error": "File format error"}))
+	assert.Equal(t, "", parseError(map[string]interface{}{"Warning": "Bad MakerNotes"}))
+	assert.Equal(t, "", parseError(map[string]interface{}{}))
+}
+
+func TestParseRating(t *testing.T) {
+	extractor := &MetadataExtractor{}
+
+	tests := []struct {
+		name     string
+		metadata map[string]interface{}
+		want     *int
+	}{
+		{"five stars", map[string]interface{}{"Rating": float64(5)}, intPtr(5)},
+		{"prefixed key", map[string]interface{}{"XMP:Rating": float64(3)}, intPtr(3)},
+		{"string value", map[string]interface{}{"Rating": " 4 "}, intPtr(4)},
+		{"unrated", map[string]interface{}{"Rating": float64(0)}, intPtr(0)},
+		{"rejected", map[string]interface{}{"Rating": float64(-1)}, intPtr(-1)},
+		{"missing", map[string]interface{}{}, nil},
+		{"out of range", map[string]interface{}{"Rating": float64(7)}, nil},
+		{"fractional", map[string]interface{}{"Rating": 2.5}, nil},
+		{"not a number", map[string]interface{}{"Rating": "five"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, extractor.parseRating(tt.metadata))
+		})
+	}
+}
+
+// intPtr returns a pointer to n
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestParseLens(t *testing.T) {
+	extractor := &MetadataExtractor{}
+
+	t.Run("parse LensModel", func(t *testing.T) {
+		metadata := map[string]interface{}{
+			"EXIF:LensModel": "EF24-105mm f4L IS USM",
+		}
+		lens := extractor.parseLens(metadata)
+		assert.Equal(t, "Ef24-105mmF4lIsUsm", lens)
+	})
+
+	t.Run("use LensID as fallback", func(t *testing.T) {
+		metadata := map[string]interface{}{
+			"Composite:LensID": "AF-S Nikkor 50mm",
+		}
+		lens := extractor.parseLens(metadata)
+		assert.Equal(t, "Af-SNikkor50mm", lens)
+	})
+
+	t.Run("prefer LensModel over LensID", func(t *testing.T) {
+		metadata := map[string]interface{}{
+			"LensModel": "XF23mm",
+			"LensID":    "Other Lens",
+		}
+		lens := extractor.parseLens(metadata)
+		assert.Equal(t, "XF23mm", lens)
+	})
+
+	t.Run("return empty for missing lens", func(t *testing.T) {
+		metadata := map[string]interface{}{}
+		lens := extractor.parseLens(metadata)
+		assert.Equal(t, "", lens)
+	})
 }
 
 // TestParseDatetimeFromFilename tests extracting datetime from filename
@@ -214,6 +411,84 @@ func TestParseDatetimeFromEXIF(t *testing.T) {
 		assert.Equal(t, 123456000, dt.Nanosecond())
 	})
 
+	t.Run("subseconds from other SubSec tags", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			metadata map[string]interface{}
+			want     int
+		}{
+			{"SubSecTimeDigitized", map[string]interface{}{"EXIF:SubSecTimeDigitized": "25"}, 250000000},
+			{"SubSecTime", map[string]interface{}{"SubSecTime": "123"}, 123000000},
+			{"numeric SubSecTime", map[string]interface{}{"EXIF:SubSecTime": float64(5)}, 500000000},
+			{"SubSecTimeOriginal wins", map[string]interface{}{"EXIF:SubSecTimeOriginal": "1", "EXIF:SubSecTimeDigitized": "2", "EXIF:SubSecTime": "3"}, 100000000},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				tt.metadata["EXIF:DateTimeOriginal"] = "2024:01:15 12:30:45"
+				stat, _ := os.Stat(".")
+				dt := extractor.parseDatetime("/test/P1000001.RW2", tt.metadata, stat)
+
+				require.NotNil(t, dt)
+				assert.Equal(t, 45, dt.Second())
+				assert.Equal(t, tt.want, dt.Nanosecond())
+			})
+		}
+	})
+
+	t.Run("DateTimeOriginal from RAW maker notes and previews", func(t *testing.T) {
+		tests := []struct {
+			key   string
+			value string
+		}{
+			{"MakerNotes:DateTimeOriginal", "2024:01:15 12:30:45"},
+			{"XMP:DateTimeOriginal", "2024:01:15 12:30:45"},
+			{"XMP:DateTimeOriginal", "2024:01:15 12:30:45.12+01:00"},
+			{"Composite:DateTimeOriginal", "2024:01:15 12:30:45"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.key+" "+tt.value, func(t *testing.T) {
+				metadata := map[string]interface{}{
+					tt.key:                    tt.value,
+					"EXIF:ModifyDate":         "2024:02:01 08:00:00",
+					"EXIF:SubSecTimeOriginal": "5",
+				}
+				stat, _ := os.Stat(".")
+				dt, source := extractor.parseDatetimeWithSource("/test/IMG_0001.CRW", metadata, stat)
+
+				require.NotNil(t, dt)
+				assert.Equal(t, time.Date(2024, 1, 15, 12, 30, 45, 500000000, time.UTC), *dt, "preferred over ModifyDate")
+				assert.Equal(t, config.DateSourceEXIF, source)
+			})
+		}
+	})
+
+	t.Run("priority of conflicting dates", func(t *testing.T) {
+		metadata := map[string]interface{}{
+			"EXIF:DateTimeOriginal":  "2024:01:15 12:30:45",
+			"EXIF:DateTimeDigitized": "2024:01:16 12:30:45",
+			"EXIF:CreateDate":        "2024:01:17 12:30:45",
+			"EXIF:ModifyDate":        "2024:01:18 12:30:45",
+		}
+		stat, _ := os.Stat(".")
+
+		// Remove the winning tag each round so the next one takes over
+		for _, want := range []struct {
+			key string
+			day int
+		}{
+			{"EXIF:DateTimeOriginal", 15},
+			{"EXIF:DateTimeDigitized", 16},
+			{"EXIF:CreateDate", 17},
+			{"EXIF:ModifyDate", 18},
+		} {
+			dt, source := extractor.parseDatetimeWithSource("/test/image.jpg", metadata, stat)
+			require.NotNil(t, dt)
+			assert.Equal(t, want.day, dt.Day(), want.key)
+			assert.Equal(t, config.DateSourceEXIF, source)
+			delete(metadata, want.key)
+		}
+	})
+
 	t.Run("parse ModifyDate as fallback", func(t *testing.T) {
 		metadata := map[string]interface{}{
 			"EXIF:ModifyDate": "2024:01:15 12:30:45",
@@ -239,6 +514,225 @@ func TestParseDatetimeFromEXIF(t *testing.T) {
 	})
 }
 
+// TestParseDatetimeVideoTags tests the broader QuickTime datetime fallback list
+func TestParseDatetimeVideoTags(t *testing.T) {
+	stat, _ := os.Stat(".")
+
+	t.Run("MediaCreateDate", func(t *testing.T) {
+		extractor := &MetadataExtractor{}
+		metadata := map[string]interface{}{
+			"QuickTime:CreateDate":      "0000:00:00 00:00:00",
+			"QuickTime:MediaCreateDate": "2024:01:15 23:30:45",
+		}
+		dt := extractor.parseDatetime("/test/video.mp4", metadata, stat)
+
+		require.NotNil(t, dt)
+		assert.Equal(t, time.Date(2024, 1, 15, 23, 30, 45, 0, time.UTC), *dt)
+	})
+
+	t.Run("TrackCreateDate with subseconds", func(t *testing.T) {
+		extractor := &MetadataExtractor{}
+		metadata := map[string]interface{}{
+			"TrackCreateDate": "2024:01:15 23:30:45.25",
+		}
+		dt := extractor.parseDatetime("/test/video.mp4", metadata, stat)
+
+		require.NotNil(t, dt)
+		assert.Equal(t, 250000000, dt.Nanosecond())
+	})
+
+	t.Run("CreationDate with offset is preferred", func(t *testing.T) {
+		extractor := &MetadataExtractor{QuickTimeLocation: time.UTC}
+		metadata := map[string]interface{}{
+			"QuickTime:CreateDate":   "2024:01:15 23:30:45",
+			"QuickTime:CreationDate": "2024:01:16 00:30:45+01:00",
+		}
+		dt := extractor.parseDatetime("/test/video.mov", metadata, stat)
+
+		require.NotNil(t, dt)
+		assert.Equal(t, 16, dt.Day())
+		assert.Equal(t, 0, dt.Hour())
+	})
+
+	t.Run("UTC converted to location", func(t *testing.T) {
+		eastern := time.FixedZone("EST", -5*60*60)
+		extractor := &MetadataExtractor{QuickTimeLocation: eastern}
+		metadata := map[string]interface{}{
+			"MIMEType":   "video/mp4",
+			"CreateDate": "2024:01:16 02:30:45",
+		}
+		dt := extractor.parseDatetime("/test/video.mp4", metadata, stat)
+
+		require.NotNil(t, dt)
+		assert.Equal(t, 15, dt.Day(), "UTC early morning is the previous evening in EST")
+		assert.Equal(t, 21, dt.Hour())
+	})
+
+	t.Run("AVCHD DateTimeOriginal keeps its wall clock", func(t *testing.T) {
+		// The camcorder's local time, converted by neither QuickTimeLocation nor
+		// Location, with the DST flag ExifTool appends
+		extractor := &MetadataExtractor{QuickTimeLocation: time.UTC}
+		for _, key := range []string{"H264:DateTimeOriginal", "DateTimeOriginal"} {
+			metadata := map[string]interface{}{
+				"MIMEType": "video/m2ts",
+				key:        "2010:05:22 13:42:11+02:00 DST",
+			}
+			dt, source := extractor.parseDatetimeWithSource("/test/00001.MTS", metadata, stat)
+
+			require.NotNil(t, dt)
+			assert.Equal(t, config.DateSourceEXIF, source)
+			assert.Equal(t, time.Date(2010, 5, 22, 13, 42, 11, 0, time.UTC), *dt, key)
+		}
+	})
+
+	t.Run("photo CreateDate is not converted", func(t *testing.T) {
+		extractor := &MetadataExtractor{QuickTimeLocation: time.FixedZone("EST", -5*60*60)}
+		metadata := map[string]interface{}{
+			"MIMEType":   "image/jpeg",
+			"CreateDate": "2024:01:16 02:30:45",
+		}
+		dt := extractor.parseDatetime("/test/image.jpg", metadata, stat)
+
+		require.NotNil(t, dt)
+		assert.Equal(t, 16, dt.Day())
+		assert.Equal(t, 2, dt.Hour())
+	})
+}
+
+// TestParseDatetimeGPS tests the GPS datetime fallback
+func TestParseDatetimeGPS(t *testing.T) {
+	extractor := &MetadataExtractor{}
+	stat, _ := os.Stat(".")
+	expected := time.Date(2024, 1, 15, 12, 30, 45, 0, time.UTC)
+
+	t.Run("Composite GPSDateTime", func(t *testing.T) {
+		metadata := map[string]interface{}{
+			"Composite:GPSDateTime": "2024:01:15 12:30:45Z",
+		}
+		dt := extractor.parseDatetime("/test/DJI_0001.mp4", metadata, stat)
+
+		require.NotNil(t, dt)
+		assert.True(t, expected.Equal(*dt), "got %v", dt)
+		assert.Equal(t, time.Local, dt.Location())
+	})
+
+	t.Run("GPSDateStamp and GPSTimeStamp", func(t *testing.T) {
+		metadata := map[string]interface{}{
+			"GPSDateStamp": "2024:01:15",
+			"GPSTimeStamp": "12:30:45",
+		}
+		dt := extractor.parseDatetime("/test/GOPR0001.mp4", metadata, stat)
+
+		require.NotNil(t, dt)
+		assert.True(t, expected.Equal(*dt), "got %v", dt)
+	})
+
+	t.Run("converted to Location", func(t *testing.T) {
+		// An 11pm shot in New York is stored as 4am the next day UTC
+		eastern := time.FixedZone("EST", -5*60*60)
+		extractor := &MetadataExtractor{Location: eastern}
+		metadata := map[string]interface{}{
+			"Composite:GPSDateTime": "2024:01:16 04:00:00Z",
+		}
+		dt := extractor.parseDatetime("/test/DJI_0001.mp4", metadata, stat)
+
+		require.NotNil(t, dt)
+		assert.Equal(t, eastern, dt.Location())
+		assert.Equal(t, 15, dt.Day())
+		assert.Equal(t, 23, dt.Hour())
+	})
+
+	t.Run("filename pattern takes precedence", func(t *testing.T) {
+		metadata := map[string]interface{}{
+			"GPSDateTime": "2024:01:15 12:30:45Z",
+		}
+		dt := extractor.parseDatetime("/test/20200101-000000_clip.mp4", metadata, stat)
+
+		require.NotNil(t, dt)
+		assert.Equal(t, 2020, dt.Year())
+	})
+
+	t.Run("incomplete GPS tags fall back to ctime", func(t *testing.T) {
+		metadata := map[string]interface{}{
+			"GPSDateStamp": "2024:01:15",
+		}
+		dt := extractor.parseDatetime("/test/clip.mp4", metadata, stat)
+
+		require.NotNil(t, dt)
+		assert.Equal(t, birthTime("/test/clip.mp4", stat).Unix(), dt.Unix())
+	})
+}
+
+// TestParseDatetimeSource tests that the tier producing the datetime is reported
+func TestParseDatetimeSource(t *testing.T) {
+	extractor := &MetadataExtractor{}
+	stat, _ := os.Stat(".")
+
+	tests := []struct {
+		name     string
+		filePath string
+		metadata map[string]interface{}
+		expected config.DateSource
+	}{
+		{
+			name:     "EXIF",
+			filePath: "/test/20200101-000000_image.jpg",
+			metadata: map[string]interface{}{
+				"EXIF:DateTimeOriginal":  "2024:01:15 12:30:45",
+				"QuickTime:CreationDate": "2023:01:15 12:30:45",
+			},
+			expected: config.DateSourceEXIF,
+		},
+		{
+			name:     "QuickTime",
+			filePath: "/test/20200101-000000_clip.mov",
+			metadata: map[string]interface{}{
+				"QuickTime:CreateDate": "2024:01:15 12:30:45",
+			},
+			expected: config.DateSourceQuickTime,
+		},
+		{
+			name:     "filename",
+			filePath: "/test/20240115-123045_image.jpg",
+			metadata: map[string]interface{}{
+				"GPSDateTime": "2024:01:15 12:30:45Z",
+			},
+			expected: config.DateSourceFilename,
+		},
+		{
+			name:     "partial filename date",
+			filePath: "/test/1985-07_grandma.jpg",
+			metadata: map[string]interface{}{
+				"GPSDateTime": "2024:01:15 12:30:45Z",
+			},
+			expected: config.DateSourceFilename,
+		},
+		{
+			name:     "GPS",
+			filePath: "/test/DJI_0001.mp4",
+			metadata: map[string]interface{}{
+				"Composite:GPSDateTime": "2024:01:15 12:30:45Z",
+			},
+			expected: config.DateSourceGPS,
+		},
+		{
+			name:     "ctime",
+			filePath: "/test/no_date.jpg",
+			metadata: map[string]interface{}{},
+			expected: config.DateSourceCtime,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dt, source := extractor.parseDatetimeWithSource(tt.filePath, tt.metadata, stat)
+
+			require.NotNil(t, dt)
+			assert.Equal(t, tt.expected, source)
+		})
+	}
+}
+
 // TestParseDatetimeFallbackToCtime tests falling back to file modification time
 func TestParseDatetimeFallbackToCtime(t *testing.T) {
 	extractor := &MetadataExtractor{}
@@ -248,8 +742,61 @@ func TestParseDatetimeFallbackToCtime(t *testing.T) {
 	dt := extractor.parseDatetime("/test/no_date.jpg", metadata, stat)
 
 	require.NotNil(t, dt)
-	// Should fall back to file's ModTime
-	assert.Equal(t, stat.ModTime().Unix(), dt.Unix())
+	// Should fall back to the file's birth time, never later than its ModTime
+	assert.False(t, dt.After(stat.ModTime()))
+}
+
+// TestParseDatetimePreferFilename tests that a filename date beats a
+// conflicting EXIF date only with PreferFilenameDate
+func TestParseDatetimePreferFilename(t *testing.T) {
+	stat, _ := os.Stat(".")
+	metadata := map[string]interface{}{
+		"EXIF:DateTimeOriginal": "2024:01:15 12:30:45",
+	}
+
+	dt, source := (&MetadataExtractor{}).parseDatetimeWithSource("/scans/1985-07-04_picnic.jpg", metadata, stat)
+	require.NotNil(t, dt)
+	assert.Equal(t, config.DateSourceEXIF, source)
+	assert.Equal(t, time.Date(2024, 1, 15, 12, 30, 45, 0, time.UTC), *dt)
+
+	extractor := &MetadataExtractor{PreferFilenameDate: true}
+	dt, source = extractor.parseDatetimeWithSource("/scans/1985-07-04_picnic.jpg", metadata, stat)
+	require.NotNil(t, dt)
+	assert.Equal(t, config.DateSourceFilename, source)
+	assert.Equal(t, time.Date(1985, 7, 4, 0, 0, 0, 0, time.UTC), *dt)
+
+	// Without a filename date, EXIF is still used
+	dt, source = extractor.parseDatetimeWithSource("/scans/picnic.jpg", metadata, stat)
+	require.NotNil(t, dt)
+	assert.Equal(t, config.DateSourceEXIF, source)
+	assert.Equal(t, time.Date(2024, 1, 15, 12, 30, 45, 0, time.UTC), *dt)
+}
+
+// TestParseDatetimeAudio tests that audio files, which usually carry a duration
+// but no capture date, fall back to the filename or file time
+func TestParseDatetimeAudio(t *testing.T) {
+	extractor := &MetadataExtractor{}
+	stat, _ := os.Stat(".")
+	audio := map[string]interface{}{
+		"File:FileType":      "WAV",
+		"Composite:Duration": "12.50 s",
+	}
+
+	dt, source := extractor.parseDatetimeWithSource("/test/20240115-123045.wav", audio, stat)
+	require.NotNil(t, dt)
+	assert.Equal(t, config.DateSourceFilename, source)
+	assert.Equal(t, time.Date(2024, 1, 15, 12, 30, 45, 0, time.UTC), *dt)
+
+	dt, source = extractor.parseDatetimeWithSource("/test/Voice Memo 3.m4a", audio, stat)
+	require.NotNil(t, dt)
+	assert.Equal(t, config.DateSourceCtime, source)
+
+	// M4A recordings from phones carry a QuickTime creation date
+	dt, source = extractor.parseDatetimeWithSource("/test/memo.m4a", map[string]interface{}{
+		"QuickTime:CreateDate": "2024:01:15 12:30:45",
+	}, stat)
+	require.NotNil(t, dt)
+	assert.Equal(t, config.DateSourceQuickTime, source)
 }
 
 // TestExtractWithTimeAdjust tests time adjustment
@@ -0,0 +1,29 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LoadModelMap reads a JSON object mapping camera models to the names to use
+// instead, e.g. {"ILCE-7M3": "A7III", "Canon EOS 5D Mark IV": "5DMarkIV"}.
+// Keys match the model tag as written or with the make removed (see
+// MetadataExtractor.ModelMap).
+func LoadModelMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model map: %w", err)
+	}
+
+	var modelMap map[string]string
+	if err := json.Unmarshal(data, &modelMap); err != nil {
+		return nil, fmt.Errorf("invalid model map %s: %w", path, err)
+	}
+	for model, name := range modelMap {
+		if name == "" {
+			return nil, fmt.Errorf("invalid model map %s: empty name for %q", path, model)
+		}
+	}
+	return modelMap, nil
+}
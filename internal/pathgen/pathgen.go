@@ -3,11 +3,58 @@ package pathgen
 import (
 	"fmt"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/cacack/sortpics-go/pkg/config"
 )
 
+// Name case modes for the camera part of filenames
+const (
+	NameCaseCamel    = "camel"    // Normalized CamelCase (default): Canon-EOS5dMarkII
+	NameCaseUpper    = "upper"    // All uppercase: CANON-EOS5DMARKII
+	NameCaseLower    = "lower"    // All lowercase: canon-eos5dmarkii
+	NameCasePreserve = "preserve" // EXIF capitalization kept: Canon-EOS5DMarkII
+)
+
+// NameCases lists the accepted name case modes.
+var NameCases = []string{NameCaseCamel, NameCaseUpper, NameCaseLower, NameCasePreserve}
+
+// DefaultSeparator is the delimiter placed between make and model.
+const DefaultSeparator = "-"
+
+// DefaultMaxFilenameLength is the filename limit, in bytes, of common
+// filesystems such as ext4, APFS, and NTFS.
+const DefaultMaxFilenameLength = 255
+
+// DefaultUnknownLabel names the directory and filename prefix of files with no datetime.
+const DefaultUnknownLabel = "unknown"
+
+// separatorChars are the characters allowed as a make/model separator.
+//
+// "." is excluded so the camera part can't be mistaken for an extension.
+const separatorChars = "-_+~"
+
+// ValidateSeparator checks that sep is a single filename-safe character.
+func ValidateSeparator(sep string) error {
+	if len(sep) != 1 || !strings.Contains(separatorChars, sep) {
+		return fmt.Errorf("separator must be one character from %q, got %q", separatorChars, sep)
+	}
+	return nil
+}
+
+// ValidateUnknownLabel checks that label can serve as both a directory name
+// and a filename prefix (see PathGenerator.UnknownLabel).
+func ValidateUnknownLabel(label string) error {
+	if label == "" || label == "." || label == ".." || strings.ContainsAny(label, illegalNameChars) {
+		return fmt.Errorf("label must be a non-empty name without any of %s, got %q", illegalNameChars, label)
+	}
+	return nil
+}
+
 // PathGenerator generates destination paths and filenames for organized photo archives.
 //
 // Filename format: YYYYMMDD-HHMMSS.subsec_Make-Model.ext
@@ -20,6 +67,44 @@ type PathGenerator struct {
 	// OldNaming uses the legacy naming convention with no hyphen between make and model.
 	// Format: YYYYMMDD-HHMMSS.subsec_MakeModel.ext (no hyphen between make and model)
 	OldNaming bool
+
+	// NameCase controls capitalization of the make and model (see NameCase*).
+	// Empty means NameCaseCamel.
+	NameCase string
+
+	// Separator is placed between make and model. Empty means DefaultSeparator.
+	// Ignored with OldNaming, which never uses a separator.
+	Separator string
+
+	// KeepOriginalName appends metadata.OriginalName after the camera part:
+	// YYYYMMDD-HHMMSS.subsec_Make-Model_IMG_1234.ext
+	KeepOriginalName bool
+
+	// RatingDirs files each image under a directory for its star rating
+	// (see RatingDir) above the date directories: rating-5/YYYY/MM/YYYY-MM-DD/
+	RatingDirs bool
+
+	// Prefix is joined with a hyphen to the top-level directory under the base,
+	// so files from one source can be told apart: card1-2024/MM/YYYY-MM-DD/
+	Prefix string
+
+	// Subdir is appended under the date directory, keeping part of the source
+	// layout: YYYY/MM/YYYY-MM-DD/100CANON/
+	Subdir string
+
+	// MaxFilenameLength caps generated filenames, in bytes. The camera part is
+	// shortened to fit, keeping the date prefix, _N suffix, and extension.
+	// 0 means DefaultMaxFilenameLength.
+	MaxFilenameLength int
+
+	// WindowsSafe avoids names that NTFS and SMB shares reject or alter:
+	// trailing dots and spaces are trimmed and reserved device names such as
+	// CON or LPT1 get a "_" appended (see WindowsSafeName)
+	WindowsSafe bool
+
+	// UnknownLabel replaces "unknown" as the directory and filename prefix of
+	// files with no datetime (e.g. "_NoDate"). Empty means DefaultUnknownLabel.
+	UnknownLabel string
 }
 
 // New creates a new PathGenerator with the specified precision and naming convention.
@@ -45,34 +130,119 @@ func (pg *PathGenerator) GeneratePath(metadata *config.ImageMetadata, baseDir, e
 	return filepath.Join(directory, filename)
 }
 
+// GenerateInPlacePath generates a canonical path that keeps the source's directory.
+//
+// Only the filename changes: filepath.Dir(source)/YYYYMMDD-HHMMSS.subsec_Make-Model.ext
+func (pg *PathGenerator) GenerateInPlacePath(metadata *config.ImageMetadata, source, extension string, increment int) string {
+	filename := pg.GenerateFilename(metadata, extension, increment)
+	return filepath.Join(filepath.Dir(source), filename)
+}
+
+// NoDateDir is the directory under the base that holds files with no reliable date.
+const NoDateDir = "no-date"
+
+// GenerateNoDatePath generates a quarantine path for a file with no reliable date.
+//
+// The original filename is kept since any generated name would embed a guessed
+// date: baseDir/no-date/IMG_0001.JPG
+func (pg *PathGenerator) GenerateNoDatePath(baseDir, source string) string {
+	return filepath.Join(baseDir, pg.prefixed(NoDateDir), pg.safeName(filepath.Base(source)))
+}
+
 // GenerateDirectory generates the directory structure: baseDir/YYYY/MM/YYYY-MM-DD/
 //
-// If metadata.DateTime is nil, returns: baseDir/unknown/
+// If metadata.DateTime is nil, returns: baseDir/unknown/ (see UnknownLabel)
+// With RatingDirs, the rating directory comes first: baseDir/rating-5/YYYY/MM/YYYY-MM-DD/
+// With Subdir, it comes last: baseDir/YYYY/MM/YYYY-MM-DD/100CANON/
 func (pg *PathGenerator) GenerateDirectory(metadata *config.ImageMetadata, baseDir string) string {
+	var dirs []string
+	if pg.RatingDirs {
+		dirs = append(dirs, RatingDir(metadata.Rating))
+	}
+
 	if metadata.DateTime == nil {
-		return filepath.Join(baseDir, "unknown")
+		dirs = append(dirs, pg.safeName(pg.unknownLabel()))
+	} else {
+		dt := metadata.DateTime
+		year := fmt.Sprintf("%04d", dt.Year())
+		month := fmt.Sprintf("%02d", int(dt.Month()))
+		day := fmt.Sprintf("%02d", dt.Day())
+
+		// Format: YYYY/MM/YYYY-MM-DD
+		dirs = append(dirs, year, month, fmt.Sprintf("%s-%s-%s", year, month, day))
 	}
 
-	dt := metadata.DateTime
-	year := fmt.Sprintf("%04d", dt.Year())
-	month := fmt.Sprintf("%02d", int(dt.Month()))
-	day := fmt.Sprintf("%02d", dt.Day())
+	dirs[0] = pg.prefixed(dirs[0])
+	for _, dir := range strings.Split(pg.Subdir, string(filepath.Separator)) {
+		if dir != "" {
+			dirs = append(dirs, pg.safeName(dir))
+		}
+	}
+	return filepath.Join(append([]string{baseDir}, dirs...)...)
+}
 
-	// Format: YYYY/MM/YYYY-MM-DD
-	yearMonth := filepath.Join(year, month)
-	fullDate := fmt.Sprintf("%s-%s-%s", year, month, day)
+// prefixed joins Prefix to a top-level directory name
+func (pg *PathGenerator) prefixed(dir string) string {
+	if pg.Prefix == "" {
+		return dir
+	}
+	return pg.safeName(pg.Prefix + "-" + dir)
+}
 
-	return filepath.Join(baseDir, yearMonth, fullDate)
+// safeName returns WindowsSafeName(name) in WindowsSafe mode, otherwise name
+func (pg *PathGenerator) safeName(name string) string {
+	if !pg.WindowsSafe {
+		return name
+	}
+	return WindowsSafeName(name)
+}
+
+// windowsReserved are device names Windows reserves regardless of extension
+var windowsReserved = regexp.MustCompile(`(?i)^(CON|PRN|AUX|NUL|COM[1-9]|LPT[1-9])$`)
+
+// WindowsSafeName makes a single path component valid on NTFS and SMB shares:
+// illegal characters become "_", trailing dots and spaces (which Windows
+// strips) are trimmed, and a reserved device name gets "_" appended to its
+// base ("CON.jpg" becomes "CON_.jpg").
+func WindowsSafeName(name string) string {
+	name = strings.TrimRight(sanitizeName(name), ". ")
+	if name == "" {
+		return "_"
+	}
+
+	base, ext, _ := strings.Cut(name, ".")
+	if windowsReserved.MatchString(strings.TrimRight(base, " ")) {
+		name = base + "_"
+		if ext != "" {
+			name += "." + ext
+		}
+	}
+	return name
+}
+
+// RatingDir returns the directory name for a star rating: "rating-1" through
+// "rating-5", "rejected" for -1, and "unrated" for 0 or no rating.
+func RatingDir(rating *int) string {
+	switch {
+	case rating == nil || *rating == 0:
+		return "unrated"
+	case *rating < 0:
+		return "rejected"
+	}
+	return fmt.Sprintf("rating-%d", *rating)
 }
 
 // GenerateFilename generates the filename: YYYYMMDD-HHMMSS.subsec_Make-Model.ext
 //
-// If metadata.DateTime is nil, returns: unknown_Make-Model.ext
+// If metadata.DateTime is nil, returns: unknown_Make-Model.ext (see UnknownLabel)
 // If both make and model are empty, uses "Unknown" for the camera part.
-// Extension is always converted to lowercase.
+// With KeepOriginalName, "_" and the original name follow the camera part.
+// Extension is always converted to lowercase. An empty extension produces a
+// filename with no trailing dot. Characters that are illegal on common
+// filesystems (/ \ : * ? " < > |) become "_" in the camera part, which is
+// also shortened if the name would exceed MaxFilenameLength (see CameraTruncated).
 func (pg *PathGenerator) GenerateFilename(metadata *config.ImageMetadata, extension string, increment int) string {
-	// Generate camera part
-	camera := pg.generateCameraPart(metadata)
+	prefix, camera, ext := pg.filenameParts(metadata, extension)
 
 	// Generate increment suffix
 	incrementStr := ""
@@ -80,12 +250,45 @@ func (pg *PathGenerator) GenerateFilename(metadata *config.ImageMetadata, extens
 		incrementStr = fmt.Sprintf("_%d", increment)
 	}
 
-	// Convert extension to lowercase
-	ext := strings.ToLower(extension)
+	camera = truncateBytes(camera, pg.maxFilenameLength()-len(prefix)-len(incrementStr)-len(ext))
+	if pg.WindowsSafe {
+		// Shortening can leave a trailing dot or space again
+		camera = strings.TrimRight(camera, ". ")
+	}
+	return prefix + camera + incrementStr + ext
+}
+
+// CameraTruncated reports whether GenerateFilename shortens the camera part
+// of this file's name to fit MaxFilenameLength.
+func (pg *PathGenerator) CameraTruncated(metadata *config.ImageMetadata, extension string) bool {
+	prefix, camera, ext := pg.filenameParts(metadata, extension)
+	return len(prefix)+len(camera)+len(ext) > pg.maxFilenameLength()
+}
 
-	// Generate filename based on whether datetime is available
+// filenameParts returns the date prefix (including the trailing "_"), the
+// camera part, and the lowercase extension (including the dot) of a filename
+func (pg *PathGenerator) filenameParts(metadata *config.ImageMetadata, extension string) (prefix, camera, ext string) {
+	// Generate camera part
+	camera = pg.generateCameraPart(metadata)
+	if pg.KeepOriginalName && metadata.OriginalName != "" {
+		camera += "_" + metadata.OriginalName
+	}
+	camera = sanitizeName(camera)
+	if pg.WindowsSafe {
+		camera = strings.TrimRight(camera, ". ")
+		if camera == "" {
+			camera = "Unknown"
+		}
+	}
+
+	// Convert extension to lowercase (omit the dot entirely if there is no extension)
+	if extension != "" {
+		ext = "." + strings.ToLower(extension)
+	}
+
+	// Generate prefix based on whether datetime is available
 	if metadata.DateTime == nil {
-		return fmt.Sprintf("unknown_%s%s.%s", camera, incrementStr, ext)
+		return pg.safeName(pg.unknownLabel()) + "_", camera, ext
 	}
 
 	// Generate datetime and subsecond parts
@@ -96,37 +299,137 @@ func (pg *PathGenerator) GenerateFilename(metadata *config.ImageMetadata, extens
 
 	subsec := pg.generateSubsecPart(metadata)
 
-	return fmt.Sprintf("%s.%s_%s%s.%s", datePart, subsec, camera, incrementStr, ext)
+	return fmt.Sprintf("%s.%s_", datePart, subsec), camera, ext
+}
+
+// illegalNameChars are characters that some filesystems (NTFS, SMB shares)
+// reject in filenames, replaced by sanitizeName
+const illegalNameChars = `/\:*?"<>|`
+
+// sanitizeName replaces characters that are illegal in filenames on common
+// filesystems, and control characters, with "_"
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(illegalNameChars, r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// unknownLabel returns UnknownLabel, or DefaultUnknownLabel if it is empty
+func (pg *PathGenerator) unknownLabel() string {
+	if pg.UnknownLabel == "" {
+		return DefaultUnknownLabel
+	}
+	return pg.UnknownLabel
+}
+
+// maxFilenameLength returns MaxFilenameLength, defaulting to DefaultMaxFilenameLength
+func (pg *PathGenerator) maxFilenameLength() int {
+	if pg.MaxFilenameLength <= 0 {
+		return DefaultMaxFilenameLength
+	}
+	return pg.MaxFilenameLength
+}
+
+// truncateBytes shortens s to at most n bytes (but never below one character)
+// without splitting a UTF-8 sequence
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	n = max(n, 1)
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	if n == 0 {
+		_, n = utf8.DecodeRuneInString(s)
+	}
+	return s[:n]
+}
+
+// generatedName matches a generated filename: date, subseconds, camera part,
+// and an optional lowercase extension
+var generatedName = regexp.MustCompile(`^(\d{8}-\d{6})\.(\d*)_[^.]+(\.[^.A-Z]+)?$`)
+
+// ParseFilename returns the datetime encoded in a filename that GenerateFilename
+// could have produced at this precision, or false if name doesn't have that form.
+//
+// Only the date, subseconds, and extension case are checked; the camera part
+// can't be confirmed without the file's metadata.
+func (pg *PathGenerator) ParseFilename(name string) (*time.Time, bool) {
+	match := generatedName.FindStringSubmatch(name)
+	if match == nil {
+		return nil, false
+	}
+
+	dt, err := time.Parse("20060102-150405", match[1])
+	if err != nil {
+		return nil, false
+	}
+	if subsec := match[2]; subsec != "" {
+		nanos, err := strconv.Atoi((subsec + "000000000")[:9])
+		if err != nil {
+			return nil, false
+		}
+		dt = dt.Add(time.Duration(nanos))
+	}
+
+	// Regenerating must reproduce the same prefix (e.g. the subsecond precision)
+	prefix := pg.GenerateFilename(&config.ImageMetadata{DateTime: &dt}, "", 0)
+	prefix = prefix[:strings.Index(prefix, "_")+1]
+	if !strings.HasPrefix(name, prefix) {
+		return nil, false
+	}
+	return &dt, true
 }
 
 // generateCameraPart creates the camera portion of the filename.
 //
 // Returns one of:
-//   - "Make-Model" (default)
+//   - "Make-Model" (default, "-" replaced by Separator if set)
 //   - "MakeModel" (old naming, no hyphen)
 //   - "Make" (if only make is present)
 //   - "Model" (if only model is present)
 //   - "Unknown" (if both are empty)
+//
+// The result is then cased according to NameCase.
 func (pg *PathGenerator) generateCameraPart(metadata *config.ImageMetadata) string {
-	hasMake := metadata.Make != ""
-	hasModel := metadata.Model != ""
+	make, model := metadata.Make, metadata.Model
+	if pg.NameCase == NameCasePreserve {
+		make, model = metadata.MakeOriginal, metadata.ModelOriginal
+	}
 
-	if hasMake && hasModel {
+	camera := "Unknown"
+	switch {
+	case make != "" && model != "":
 		if pg.OldNaming {
-			return fmt.Sprintf("%s%s", metadata.Make, metadata.Model)
+			camera = fmt.Sprintf("%s%s", make, model)
+		} else {
+			camera = fmt.Sprintf("%s%s%s", make, pg.separator(), model)
 		}
-		return fmt.Sprintf("%s-%s", metadata.Make, metadata.Model)
+	case make != "":
+		camera = make
+	case model != "":
+		camera = model
 	}
 
-	if hasMake {
-		return metadata.Make
+	switch pg.NameCase {
+	case NameCaseUpper:
+		return strings.ToUpper(camera)
+	case NameCaseLower:
+		return strings.ToLower(camera)
 	}
+	return camera
+}
 
-	if hasModel {
-		return metadata.Model
+// separator returns the make/model separator, defaulting to DefaultSeparator
+func (pg *PathGenerator) separator() string {
+	if pg.Separator == "" {
+		return DefaultSeparator
 	}
-
-	return "Unknown"
+	return pg.Separator
 }
 
 // generateSubsecPart creates the subsecond portion of the filename.
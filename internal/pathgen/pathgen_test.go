@@ -1,12 +1,16 @@
 package pathgen
 
 import (
+	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/cacack/sortpics-go/pkg/config"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestNew tests PathGenerator initialization
@@ -40,6 +44,19 @@ func TestGenerateDirectory(t *testing.T) {
 	assert.Equal(t, expected, directory)
 }
 
+// TestGenerateDirectoryLocalDay tests that files are bucketed by the day in
+// their own time zone, not the UTC day
+func TestGenerateDirectoryLocalDay(t *testing.T) {
+	// 11pm in New York is 4am the next day in UTC
+	eastern := time.FixedZone("EST", -5*60*60)
+	dt := time.Date(2024, 1, 16, 4, 0, 0, 0, time.UTC).In(eastern)
+	metadata := &config.ImageMetadata{DateTime: &dt, Make: "Canon", Model: "EOS5d"}
+	generator := New(3, false)
+
+	assert.Equal(t, filepath.Join("/archive", "2024", "01", "2024-01-15"), generator.GenerateDirectory(metadata, "/archive"))
+	assert.Equal(t, "20240115-230000.000_Canon-EOS5d.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+}
+
 // TestGenerateDirectoryNoDatetime tests directory generation without datetime
 func TestGenerateDirectoryNoDatetime(t *testing.T) {
 	metadata := &config.ImageMetadata{
@@ -205,6 +222,23 @@ func TestGenerateFilenameLowercaseExtension(t *testing.T) {
 	assert.True(t, filepath.Ext(filename) == ".jpg")
 }
 
+// TestGenerateFilenameNoExtension tests that an empty extension leaves no dangling dot
+func TestGenerateFilenameNoExtension(t *testing.T) {
+	dt := time.Date(2024, 1, 15, 12, 30, 45, 123456000, time.UTC)
+	metadata := &config.ImageMetadata{
+		DateTime: &dt,
+		Make:     "Canon",
+		Model:    "EOS5d",
+	}
+	generator := New(6, false)
+
+	assert.Equal(t, "20240115-123045.123456_Canon-EOS5d", generator.GenerateFilename(metadata, "", 0))
+	assert.Equal(t, "20240115-123045.123456_Canon-EOS5d_1", generator.GenerateFilename(metadata, "", 1))
+
+	metadata.DateTime = nil
+	assert.Equal(t, "unknown_Canon-EOS5d", generator.GenerateFilename(metadata, "", 0))
+}
+
 // TestGeneratePathFull tests full path generation
 func TestGeneratePathFull(t *testing.T) {
 	dt := time.Date(2024, 1, 15, 12, 30, 45, 123456000, time.UTC)
@@ -288,3 +322,371 @@ func TestGenerateFilenamePrecisionGreaterThan6(t *testing.T) {
 	// Should return full 6-digit subsecond precision (maximum available)
 	assert.Equal(t, "20240115-123045.123456_Canon-EOS5d.jpg", filename)
 }
+
+// TestGenerateInPlacePath tests that in-place paths keep the source directory
+func TestGenerateInPlacePath(t *testing.T) {
+	dt := time.Date(2024, 1, 15, 12, 30, 45, 123456000, time.UTC)
+	metadata := &config.ImageMetadata{
+		DateTime: &dt,
+		Make:     "Canon",
+		Model:    "EOS5d",
+	}
+	generator := New(6, false)
+
+	path := generator.GenerateInPlacePath(metadata, "/photos/trip/IMG_0001.JPG", "JPG", 1)
+
+	expected := filepath.Join("/photos/trip", "20240115-123045.123456_Canon-EOS5d_1.jpg")
+	assert.Equal(t, expected, path)
+}
+
+// TestGenerateFilenameNameCase tests make/model capitalization for each name case mode
+func TestGenerateFilenameNameCase(t *testing.T) {
+	dt := time.Date(2024, 1, 15, 12, 30, 45, 123456000, time.UTC)
+	metadata := &config.ImageMetadata{
+		DateTime:      &dt,
+		Make:          "Canon",
+		Model:         "Eos5d",
+		MakeOriginal:  "Canon",
+		ModelOriginal: "EOS5D",
+	}
+
+	tests := []struct {
+		nameCase string
+		expected string
+	}{
+		{"", "20240115-123045.123456_Canon-Eos5d.jpg"},
+		{NameCaseCamel, "20240115-123045.123456_Canon-Eos5d.jpg"},
+		{NameCaseUpper, "20240115-123045.123456_CANON-EOS5D.jpg"},
+		{NameCaseLower, "20240115-123045.123456_canon-eos5d.jpg"},
+		{NameCasePreserve, "20240115-123045.123456_Canon-EOS5D.jpg"},
+	}
+
+	for _, tt := range tests {
+		t.Run("case "+tt.nameCase, func(t *testing.T) {
+			generator := New(6, false)
+			generator.NameCase = tt.nameCase
+
+			filename := generator.GenerateFilename(metadata, "JPG", 0)
+
+			assert.Equal(t, tt.expected, filename)
+		})
+	}
+}
+
+// TestGenerateFilenameNameCaseOldNaming tests name case modes combined with old naming
+func TestGenerateFilenameNameCaseOldNaming(t *testing.T) {
+	dt := time.Date(2024, 1, 15, 12, 30, 45, 123456000, time.UTC)
+	metadata := &config.ImageMetadata{
+		DateTime:      &dt,
+		Make:          "Nikon",
+		Model:         "D850",
+		MakeOriginal:  "NIKON",
+		ModelOriginal: "D850",
+	}
+
+	generator := New(2, true)
+	generator.NameCase = NameCaseUpper
+	assert.Equal(t, "20240115-123045.12_NIKOND850.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+
+	generator.NameCase = NameCasePreserve
+	assert.Equal(t, "20240115-123045.12_NIKOND850.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+
+	generator.NameCase = NameCaseLower
+	assert.Equal(t, "20240115-123045.12_nikond850.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+}
+
+// TestGenerateFilenameNameCaseUnknown tests name case modes without camera information
+func TestGenerateFilenameNameCaseUnknown(t *testing.T) {
+	metadata := &config.ImageMetadata{}
+
+	generator := New(6, false)
+	generator.NameCase = NameCaseUpper
+	assert.Equal(t, "unknown_UNKNOWN.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+
+	generator.NameCase = NameCasePreserve
+	assert.Equal(t, "unknown_Unknown.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+}
+
+// TestGenerateFilenameSeparator tests a custom make/model separator
+func TestGenerateFilenameSeparator(t *testing.T) {
+	dt := time.Date(2024, 1, 15, 12, 30, 45, 123456000, time.UTC)
+	metadata := &config.ImageMetadata{
+		DateTime: &dt,
+		Make:     "Canon",
+		Model:    "EOS5d",
+	}
+
+	generator := New(6, false)
+	generator.Separator = "_"
+	assert.Equal(t, "20240115-123045.123456_Canon_EOS5d.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+
+	// Old naming never uses a separator
+	generator.OldNaming = true
+	assert.Equal(t, "20240115-123045.123456_CanonEOS5d.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+}
+
+// TestGenerateFilenameKeepOriginalName tests appending the source stem
+func TestGenerateFilenameKeepOriginalName(t *testing.T) {
+	dt := time.Date(2024, 1, 15, 12, 30, 45, 123456000, time.UTC)
+	metadata := &config.ImageMetadata{
+		DateTime:     &dt,
+		Make:         "Canon",
+		Model:        "EOS5d",
+		OriginalName: "IMG_1234",
+	}
+
+	generator := New(6, false)
+	assert.Equal(t, "20240115-123045.123456_Canon-EOS5d.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+
+	generator.KeepOriginalName = true
+	assert.Equal(t, "20240115-123045.123456_Canon-EOS5d_IMG_1234.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+	assert.Equal(t, "20240115-123045.123456_Canon-EOS5d_IMG_1234_1.jpg", generator.GenerateFilename(metadata, "jpg", 1))
+
+	// The original name's case is kept regardless of NameCase
+	generator.NameCase = NameCaseLower
+	assert.Equal(t, "20240115-123045.123456_canon-eos5d_IMG_1234.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+
+	metadata.DateTime = nil
+	assert.Equal(t, "unknown_canon-eos5d_IMG_1234.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+}
+
+// TestParseFilename tests recognizing generated filenames
+func TestParseFilename(t *testing.T) {
+	generator := New(6, false)
+
+	dt, ok := generator.ParseFilename("20240115-123045.123456_Canon-EOS5d.jpg")
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 1, 15, 12, 30, 45, 123456000, time.UTC), *dt)
+
+	for _, name := range []string{
+		"20240115-123045.123456_Canon-EOS5d_1.jpg",
+		"20240115-123045.000000_Unknown",
+		"20240115-123045.123456_Canon-EOS5d_IMG_1234.cr2",
+	} {
+		_, ok := generator.ParseFilename(name)
+		assert.True(t, ok, name)
+	}
+
+	for _, name := range []string{
+		"IMG_1234.jpg",
+		"20240115-123045.123456_Canon-EOS5d.JPG", // extension not lowercased
+		"20240115-123045.123_Canon-EOS5d.jpg",    // different precision
+		"20240115-123045_Canon-EOS5d.jpg",
+		"20241315-123045.123456_Canon-EOS5d.jpg", // month 13
+		"unknown_Canon-EOS5d.jpg",
+	} {
+		_, ok := generator.ParseFilename(name)
+		assert.False(t, ok, name)
+	}
+
+	_, ok = New(3, false).ParseFilename("20240115-123045.123_Canon-EOS5d.jpg")
+	assert.True(t, ok)
+}
+
+// TestGenerateDirectoryRatingDirs tests inserting the rating directory
+func TestGenerateDirectoryRatingDirs(t *testing.T) {
+	dt := time.Date(2024, 1, 15, 12, 30, 45, 0, time.UTC)
+	rating := func(n int) *int { return &n }
+
+	generator := New(6, false)
+	metadata := &config.ImageMetadata{DateTime: &dt, Rating: rating(5)}
+	assert.Equal(t, filepath.Join("/archive", "2024", "01", "2024-01-15"), generator.GenerateDirectory(metadata, "/archive"))
+
+	generator.RatingDirs = true
+	tests := []struct {
+		rating *int
+		want   string
+	}{
+		{rating(5), "rating-5"},
+		{rating(1), "rating-1"},
+		{rating(0), "unrated"},
+		{nil, "unrated"},
+		{rating(-1), "rejected"},
+	}
+	for _, tt := range tests {
+		metadata := &config.ImageMetadata{DateTime: &dt, Rating: tt.rating}
+		assert.Equal(t, filepath.Join("/archive", tt.want, "2024", "01", "2024-01-15"), generator.GenerateDirectory(metadata, "/archive"), tt.want)
+	}
+
+	// Undated files still go under their rating
+	metadata = &config.ImageMetadata{Rating: rating(4)}
+	assert.Equal(t, filepath.Join("/archive", "rating-4", "unknown"), generator.GenerateDirectory(metadata, "/archive"))
+	assert.Equal(t, filepath.Join("/archive", "rating-4", "unknown", "unknown_Unknown.jpg"), generator.GeneratePath(metadata, "/archive", "jpg", 0))
+}
+
+func TestGenerateDirectoryPrefix(t *testing.T) {
+	dt := time.Date(2024, 1, 15, 12, 30, 45, 0, time.UTC)
+	rating := 5
+
+	generator := New(6, false)
+	generator.Prefix = "card1"
+	metadata := &config.ImageMetadata{DateTime: &dt, Rating: &rating}
+	assert.Equal(t, filepath.Join("/archive", "card1-2024", "01", "2024-01-15"), generator.GenerateDirectory(metadata, "/archive"))
+	assert.Equal(t, filepath.Join("/archive", "card1-unknown"), generator.GenerateDirectory(&config.ImageMetadata{}, "/archive"))
+	assert.Equal(t, filepath.Join("/archive", "card1-no-date", "IMG_0001.JPG"), generator.GenerateNoDatePath("/archive", "/src/IMG_0001.JPG"))
+
+	// The prefix goes on whichever directory comes first
+	generator.RatingDirs = true
+	assert.Equal(t, filepath.Join("/archive", "card1-rating-5", "2024", "01", "2024-01-15"), generator.GenerateDirectory(metadata, "/archive"))
+}
+
+func TestGenerateDirectorySubdir(t *testing.T) {
+	dt := time.Date(2024, 1, 15, 12, 30, 45, 0, time.UTC)
+
+	generator := New(6, false)
+	generator.Subdir = filepath.Join("DCIM", "100CANON")
+	metadata := &config.ImageMetadata{DateTime: &dt}
+	assert.Equal(t, filepath.Join("/archive", "2024", "01", "2024-01-15", "DCIM", "100CANON"), generator.GenerateDirectory(metadata, "/archive"))
+	assert.Equal(t, filepath.Join("/archive", "unknown", "DCIM", "100CANON"), generator.GenerateDirectory(&config.ImageMetadata{}, "/archive"))
+}
+
+func TestGenerateFilenameMaxLength(t *testing.T) {
+	dt := time.Date(2024, 1, 15, 12, 30, 45, 123456000, time.UTC)
+	metadata := &config.ImageMetadata{
+		DateTime: &dt,
+		Make:     "Canon",
+		Model:    strings.Repeat("Eos5dMarkIv", 40),
+	}
+
+	generator := New(6, false)
+	assert.True(t, generator.CameraTruncated(metadata, "jpg"))
+
+	for _, increment := range []int{0, 1, 123} {
+		filename := generator.GenerateFilename(metadata, "jpg", increment)
+		assert.Len(t, filename, DefaultMaxFilenameLength)
+		assert.True(t, strings.HasPrefix(filename, "20240115-123045.123456_Canon-Eos5dMarkIv"), filename)
+		suffix := ".jpg"
+		if increment > 0 {
+			suffix = fmt.Sprintf("_%d.jpg", increment)
+		}
+		assert.True(t, strings.HasSuffix(filename, suffix), filename)
+	}
+
+	// A custom limit, without splitting a multi-byte character
+	generator.MaxFilenameLength = 41
+	metadata.Model = "Modèle" + strings.Repeat("é", 20)
+	filename := generator.GenerateFilename(metadata, "jpg", 0)
+	assert.True(t, utf8.ValidString(filename), filename)
+	assert.Equal(t, "20240115-123045.123456_Canon-Modèle.jpg", filename)
+
+	// Short names are left alone
+	metadata.Model = "Eos5d"
+	assert.False(t, generator.CameraTruncated(metadata, "jpg"))
+	assert.Equal(t, "20240115-123045.123456_Canon-Eos5d.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+}
+
+func TestGenerateFilenameIllegalCharacters(t *testing.T) {
+	dt := time.Date(2024, 1, 15, 12, 30, 45, 123456000, time.UTC)
+	generator := New(6, false)
+
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"Iphone15Pro(3rdGen)", "Apple-Iphone15Pro(3rdGen)"},
+		{"A/B", "Apple-A_B"},
+		{`X\Y:Z*?"<>|`, "Apple-X_Y_Z______"},
+		{"Tab\tNul\x00", "Apple-Tab_Nul_"},
+	}
+	for _, tt := range tests {
+		metadata := &config.ImageMetadata{DateTime: &dt, Make: "Apple", Model: tt.model}
+		filename := generator.GenerateFilename(metadata, "heic", 0)
+		assert.Equal(t, "20240115-123045.123456_"+tt.want+".heic", filename, tt.model)
+		assert.Equal(t, filename, filepath.Base(filepath.Join("/archive", filename)))
+	}
+
+	// The original name is sanitized too
+	generator.KeepOriginalName = true
+	metadata := &config.ImageMetadata{DateTime: &dt, Make: "Apple", Model: "A:B", OriginalName: "IMG:0001"}
+	assert.Equal(t, "20240115-123045.123456_Apple-A_B_IMG_0001.heic", generator.GenerateFilename(metadata, "heic", 0))
+}
+
+func TestWindowsSafeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"100CANON", "100CANON"},
+		{"CON", "CON_"},
+		{"con", "con_"},
+		{"Lpt1", "Lpt1_"},
+		{"NUL.jpg", "NUL_.jpg"},
+		{"aux.tar.gz", "aux_.tar.gz"},
+		{"CONSOLE", "CONSOLE"},
+		{"COM0", "COM0"},
+		{"trip.", "trip"},
+		{"trip. . ", "trip"},
+		{"...", "_"},
+		{"a:b", "a_b"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, WindowsSafeName(tt.name), tt.name)
+	}
+}
+
+func TestGenerateWindowsSafe(t *testing.T) {
+	dt := time.Date(2024, 1, 15, 12, 30, 45, 123456000, time.UTC)
+	metadata := &config.ImageMetadata{DateTime: &dt, Make: "Canon", Model: "EosR."}
+
+	generator := New(6, false)
+	assert.Equal(t, "20240115-123045.123456_Canon-EosR..jpg", generator.GenerateFilename(metadata, "jpg", 0))
+
+	generator.WindowsSafe = true
+	assert.Equal(t, "20240115-123045.123456_Canon-EosR.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+	assert.Equal(t, "20240115-123045.123456_Canon-EosR", generator.GenerateFilename(metadata, "", 0))
+
+	// A camera part of nothing but dots falls back to Unknown
+	metadata = &config.ImageMetadata{DateTime: &dt, Model: "..."}
+	assert.Equal(t, "20240115-123045.123456_Unknown.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+
+	// Reserved names from the source layout, prefix, and quarantined files
+	generator.Subdir = filepath.Join("AUX", "trip.")
+	generator.Prefix = "prn"
+	assert.Equal(t, filepath.Join("/archive", "prn-2024", "01", "2024-01-15", "AUX_", "trip"), generator.GenerateDirectory(metadata, "/archive"))
+	assert.Equal(t, filepath.Join("/archive", "prn-no-date", "CON_.JPG"), generator.GenerateNoDatePath("/archive", "/src/CON.JPG"))
+}
+
+// TestValidateSeparator tests separator validation
+func TestValidateSeparator(t *testing.T) {
+	for _, sep := range []string{"-", "_", "+", "~"} {
+		assert.NoError(t, ValidateSeparator(sep), sep)
+	}
+	for _, sep := range []string{"", "/", ".", " ", "--", "\\", ":"} {
+		assert.Error(t, ValidateSeparator(sep), sep)
+	}
+}
+
+// TestUnknownLabel tests the label used for files with no datetime
+func TestUnknownLabel(t *testing.T) {
+	metadata := &config.ImageMetadata{Make: "Canon", Model: "Eos5d"}
+
+	generator := New(6, false)
+	assert.Equal(t, filepath.Join("/archive", "unknown"), generator.GenerateDirectory(metadata, "/archive"))
+	assert.Equal(t, "unknown_Canon-Eos5d.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+
+	generator.UnknownLabel = "_NoDate"
+	assert.Equal(t, filepath.Join("/archive", "_NoDate"), generator.GenerateDirectory(metadata, "/archive"))
+	assert.Equal(t, "_NoDate_Canon-Eos5d.jpg", generator.GenerateFilename(metadata, "jpg", 0))
+	assert.Equal(t, filepath.Join("/archive", "_NoDate", "_NoDate_Canon-Eos5d_2.jpg"), generator.GeneratePath(metadata, "/archive", "jpg", 2))
+
+	// Dated files are unaffected
+	dt := time.Date(2024, 1, 15, 12, 30, 45, 0, time.UTC)
+	metadata.DateTime = &dt
+	assert.Equal(t, filepath.Join("/archive", "2024", "01", "2024-01-15"), generator.GenerateDirectory(metadata, "/archive"))
+
+	for _, label := range []string{"unknown", "_NoDate", "Sans date"} {
+		assert.NoError(t, ValidateUnknownLabel(label), label)
+	}
+	for _, label := range []string{"", ".", "..", "a/b", "a\\b", "what?"} {
+		assert.Error(t, ValidateUnknownLabel(label), label)
+	}
+}
+
+// TestGenerateNoDatePath tests quarantine paths keep the original filename
+func TestGenerateNoDatePath(t *testing.T) {
+	generator := New(6, false)
+
+	path := generator.GenerateNoDatePath("/archive", "/photos/trip/IMG_0001.JPG")
+
+	assert.Equal(t, filepath.Join("/archive", "no-date", "IMG_0001.JPG"), path)
+}
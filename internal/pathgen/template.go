@@ -0,0 +1,82 @@
+package pathgen
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cacack/sortpics-go/pkg/config"
+)
+
+// templatePlaceholder matches a {name} placeholder in a metadata template
+var templatePlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// TemplatePlaceholders lists the placeholders accepted by ExpandTemplate.
+var TemplatePlaceholders = []string{"year", "month", "day", "make", "model", "lens", "origname"}
+
+// ValidateTemplate checks that every {placeholder} in tmpl is known.
+func ValidateTemplate(tmpl string) error {
+	for _, match := range templatePlaceholder.FindAllStringSubmatch(tmpl, -1) {
+		if _, ok := templateValue(match[1], &config.ImageMetadata{}); !ok {
+			return fmt.Errorf("unknown placeholder {%s}: must be one of {%s}", match[1], strings.Join(TemplatePlaceholders, "}, {"))
+		}
+	}
+	return nil
+}
+
+// ExpandTemplate replaces {year}, {month}, {day}, {make}, {model}, {lens},
+// and {origname} in tmpl with values from metadata, e.g. "{year}-{month}"
+// becomes "2024-01".
+//
+// Date placeholders expand to "unknown" when there is no datetime; make and
+// model expand to their normalized form, or "Unknown" when empty; {lens}
+// expands to the normalized lens model with characters illegal in filenames
+// replaced, or nothing when absent; {origname} expands to the source filename
+// without its extension. Unknown placeholders are left as-is (see
+// ValidateTemplate).
+func ExpandTemplate(tmpl string, metadata *config.ImageMetadata) string {
+	return templatePlaceholder.ReplaceAllStringFunc(tmpl, func(placeholder string) string {
+		value, ok := templateValue(strings.Trim(placeholder, "{}"), metadata)
+		if !ok {
+			return placeholder
+		}
+		return value
+	})
+}
+
+// templateValue returns the value of one placeholder name
+func templateValue(name string, metadata *config.ImageMetadata) (string, bool) {
+	dt := metadata.DateTime
+	switch name {
+	case "year":
+		if dt == nil {
+			return "unknown", true
+		}
+		return fmt.Sprintf("%04d", dt.Year()), true
+	case "month":
+		if dt == nil {
+			return "unknown", true
+		}
+		return fmt.Sprintf("%02d", int(dt.Month())), true
+	case "day":
+		if dt == nil {
+			return "unknown", true
+		}
+		return fmt.Sprintf("%02d", dt.Day()), true
+	case "make":
+		if metadata.Make == "" {
+			return "Unknown", true
+		}
+		return metadata.Make, true
+	case "model":
+		if metadata.Model == "" {
+			return "Unknown", true
+		}
+		return metadata.Model, true
+	case "lens":
+		return sanitizeName(metadata.Lens), true
+	case "origname":
+		return metadata.OriginalName, true
+	}
+	return "", false
+}
@@ -0,0 +1,42 @@
+package pathgen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	dt := time.Date(2024, 1, 5, 12, 30, 45, 0, time.UTC)
+	meta := &config.ImageMetadata{DateTime: &dt, Make: "Canon", Model: "EOS5dMarkII", OriginalName: "IMG_1234"}
+
+	tests := []struct {
+		template string
+		metadata *config.ImageMetadata
+		want     string
+	}{
+		{"{year}-{month}", meta, "2024-01"},
+		{"{year}/{month}/{day}", meta, "2024/01/05"},
+		{"Trip {year} ({make} {model})", meta, "Trip 2024 (Canon EOS5dMarkII)"},
+		{"Vacation", meta, "Vacation"},
+		{"{year}-{month}", &config.ImageMetadata{}, "unknown-unknown"},
+		{"{make}", &config.ImageMetadata{}, "Unknown"},
+		{"{year} {origname}", meta, "2024 IMG_1234"},
+		{"{model} {lens}", &config.ImageMetadata{Model: "EOS5dMarkII", Lens: "Ef24-105mmF/4lIsUsm"}, "EOS5dMarkII Ef24-105mmF_4lIsUsm"},
+		{"Shot on {lens}", meta, "Shot on "},
+		{"{bogus} {year}", meta, "{bogus} 2024"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ExpandTemplate(tt.template, tt.metadata), tt.template)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	assert.NoError(t, ValidateTemplate("{year}-{month}"))
+	assert.NoError(t, ValidateTemplate("Static album"))
+	assert.NoError(t, ValidateTemplate("{lens}"))
+	assert.Error(t, ValidateTemplate("{yaer}"))
+	assert.Error(t, ValidateTemplate("{}"))
+}
@@ -1,10 +1,13 @@
 package rename
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/barasher/go-exiftool"
 	"github.com/cacack/sortpics-go/pkg/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,7 +34,7 @@ func TestIntegrationParseMetadata(t *testing.T) {
 	defer ir.Close()
 
 	// Parse metadata
-	err = ir.ParseMetadata()
+	err = ir.ParseMetadata(context.Background())
 	require.NoError(t, err)
 
 	// Verify that datetime was extracted
@@ -67,13 +70,13 @@ func TestIntegrationPerform(t *testing.T) {
 	defer ir.Close()
 
 	// Parse metadata
-	err = ir.ParseMetadata()
+	err = ir.ParseMetadata(context.Background())
 	require.NoError(t, err)
 
 	destination := ir.GetDestination()
 
 	// Perform the operation
-	err = ir.Perform()
+	err = ir.Perform(context.Background())
 	require.NoError(t, err)
 
 	// Verify destination file exists
@@ -111,13 +114,13 @@ func TestIntegrationPerformWithAlbum(t *testing.T) {
 	defer ir.Close()
 
 	// Parse metadata
-	err = ir.ParseMetadata()
+	err = ir.ParseMetadata(context.Background())
 	require.NoError(t, err)
 
 	destination := ir.GetDestination()
 
 	// Perform the operation
-	err = ir.Perform()
+	err = ir.Perform(context.Background())
 	require.NoError(t, err)
 
 	// Verify destination file exists
@@ -146,13 +149,13 @@ func TestIntegrationDryRun(t *testing.T) {
 	defer ir.Close()
 
 	// Parse metadata
-	err = ir.ParseMetadata()
+	err = ir.ParseMetadata(context.Background())
 	require.NoError(t, err)
 
 	destination := ir.GetDestination()
 
 	// Perform the operation (should do nothing in dry run)
-	err = ir.Perform()
+	err = ir.Perform(context.Background())
 	require.NoError(t, err)
 
 	// Verify destination file does NOT exist (dry run)
@@ -180,10 +183,10 @@ func TestIntegrationDuplicateDetection(t *testing.T) {
 	require.NoError(t, err)
 	defer ir1.Close()
 
-	err = ir1.ParseMetadata()
+	err = ir1.ParseMetadata(context.Background())
 	require.NoError(t, err)
 
-	err = ir1.Perform()
+	err = ir1.Perform(context.Background())
 	require.NoError(t, err)
 
 	destination1 := ir1.GetDestination()
@@ -194,7 +197,7 @@ func TestIntegrationDuplicateDetection(t *testing.T) {
 	require.NoError(t, err)
 	defer ir2.Close()
 
-	err = ir2.ParseMetadata()
+	err = ir2.ParseMetadata(context.Background())
 	require.NoError(t, err)
 
 	// Should detect as duplicate or generate different name
@@ -230,10 +233,10 @@ func TestIntegrationCollisionResolution(t *testing.T) {
 	require.NoError(t, err)
 	defer ir1.Close()
 
-	err = ir1.ParseMetadata()
+	err = ir1.ParseMetadata(context.Background())
 	require.NoError(t, err)
 
-	err = ir1.Perform()
+	err = ir1.Perform(context.Background())
 	require.NoError(t, err)
 
 	destination1 := ir1.GetDestination()
@@ -243,7 +246,7 @@ func TestIntegrationCollisionResolution(t *testing.T) {
 	require.NoError(t, err)
 	defer ir2.Close()
 
-	err = ir2.ParseMetadata()
+	err = ir2.ParseMetadata(context.Background())
 	require.NoError(t, err)
 
 	destination2 := ir2.GetDestination()
@@ -252,14 +255,14 @@ func TestIntegrationCollisionResolution(t *testing.T) {
 	// and generate different filenames
 	if destination1 == destination2 {
 		t.Log("Files have identical metadata, testing collision resolution")
-		err = ir2.Perform()
+		err = ir2.Perform(context.Background())
 		require.NoError(t, err)
 
 		// After perform, a new filename should be generated
 		// (This is handled in the Perform method's re-check logic)
 	} else {
 		// Different destinations expected
-		err = ir2.Perform()
+		err = ir2.Perform(context.Background())
 		require.NoError(t, err)
 		assert.FileExists(t, destination2)
 	}
@@ -267,3 +270,213 @@ func TestIntegrationCollisionResolution(t *testing.T) {
 	// Both files should exist
 	assert.FileExists(t, destination1)
 }
+
+// TestIntegrationHierarchicalTags tests that "a|b" tags are written as hierarchical subjects
+func TestIntegrationHierarchicalTags(t *testing.T) {
+	fixtureDir := "/Users/chris/devel/home/sortpics/tests/integration/fixtures/basic"
+
+	// Check if fixtures are available
+	if _, err := os.Stat(fixtureDir); os.IsNotExist(err) {
+		t.Skip("Integration test fixtures not available")
+	}
+
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(fixtureDir, "test_001.jpg")
+
+	cfg := &config.ProcessingConfig{
+		Precision: 6,
+		Tags:      []string{"family", "Places|France|Paris"},
+	}
+
+	ir, err := NewImageRename(testFile, tmpDir, cfg)
+	require.NoError(t, err)
+	defer ir.Close()
+
+	err = ir.ParseMetadata(context.Background())
+	require.NoError(t, err)
+
+	err = ir.Perform(context.Background())
+	require.NoError(t, err)
+
+	// Read the written tags back
+	et, err := exiftool.NewExiftool()
+	require.NoError(t, err)
+	defer et.Close()
+
+	fm := et.ExtractMetadata(ir.GetDestination())[0]
+	require.NoError(t, fm.Err)
+
+	hierarchical, err := fm.GetStrings("HierarchicalSubject")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Places|France|Paris"}, hierarchical)
+
+	keywords, err := fm.GetStrings("Keywords")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"family", "Places", "France", "Paris"}, keywords)
+}
+
+// TestIntegrationMergeKeywords tests that keywords already on a file are kept when tagging
+func TestIntegrationMergeKeywords(t *testing.T) {
+	fixtureDir := "/Users/chris/devel/home/sortpics/tests/integration/fixtures/basic"
+
+	// Check if fixtures are available
+	if _, err := os.Stat(fixtureDir); os.IsNotExist(err) {
+		t.Skip("Integration test fixtures not available")
+	}
+
+	et, err := exiftool.NewExiftool()
+	require.NoError(t, err)
+	defer et.Close()
+
+	// Plant existing keywords on a copy of the fixture
+	sourceDir := t.TempDir()
+	testFile := filepath.Join(sourceDir, "test_001.jpg")
+	require.NoError(t, SafeCopy(context.Background(), filepath.Join(fixtureDir, "test_001.jpg"), testFile))
+
+	fm := et.ExtractMetadata(testFile)[0]
+	require.NoError(t, fm.Err)
+	fm.SetStrings("Keywords", []string{"camera", "family"})
+	et.WriteMetadata([]exiftool.FileMetadata{fm})
+	require.NoError(t, fm.Err)
+
+	cfg := &config.ProcessingConfig{
+		Precision: 6,
+		Move:      true,
+		Tags:      []string{"family", "vacation"},
+	}
+
+	ir, err := NewImageRename(testFile, t.TempDir(), cfg)
+	require.NoError(t, err)
+	defer ir.Close()
+
+	err = ir.ParseMetadata(context.Background())
+	require.NoError(t, err)
+
+	err = ir.Perform(context.Background())
+	require.NoError(t, err)
+
+	// Existing and new keywords should both be present, once each
+	fm = et.ExtractMetadata(ir.GetDestination())[0]
+	require.NoError(t, fm.Err)
+
+	keywords, err := fm.GetStrings("Keywords")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"camera", "family", "vacation"}, keywords)
+}
+
+// TestIntegrationStripGPS tests that --strip-gps clears location from the copy but not the source
+func TestIntegrationStripGPS(t *testing.T) {
+	fixtureDir := "/Users/chris/devel/home/sortpics/tests/integration/fixtures/basic"
+
+	// Check if fixtures are available
+	if _, err := os.Stat(fixtureDir); os.IsNotExist(err) {
+		t.Skip("Integration test fixtures not available")
+	}
+
+	et, err := exiftool.NewExiftool()
+	require.NoError(t, err)
+	defer et.Close()
+
+	// Plant a location on a copy of the fixture
+	sourceDir := t.TempDir()
+	testFile := filepath.Join(sourceDir, "test_001.jpg")
+	require.NoError(t, SafeCopy(context.Background(), filepath.Join(fixtureDir, "test_001.jpg"), testFile))
+
+	fm := et.ExtractMetadata(testFile)[0]
+	require.NoError(t, fm.Err)
+	fm.SetString("GPSLatitude", "48.8584")
+	fm.SetString("GPSLatitudeRef", "N")
+	fm.SetString("GPSLongitude", "2.2945")
+	fm.SetString("GPSLongitudeRef", "E")
+	et.WriteMetadata([]exiftool.FileMetadata{fm})
+	require.NoError(t, fm.Err)
+
+	cfg := &config.ProcessingConfig{
+		Precision: 6,
+		StripGPS:  true,
+	}
+
+	ir, err := NewImageRename(testFile, t.TempDir(), cfg)
+	require.NoError(t, err)
+	defer ir.Close()
+
+	err = ir.ParseMetadata(context.Background())
+	require.NoError(t, err)
+
+	err = ir.Perform(context.Background())
+	require.NoError(t, err)
+
+	fm = et.ExtractMetadata(ir.GetDestination())[0]
+	require.NoError(t, fm.Err)
+	for k := range fm.Fields {
+		assert.False(t, isGPSTag(k), "destination still has %s", k)
+	}
+
+	// The source keeps its location
+	fm = et.ExtractMetadata(testFile)[0]
+	require.NoError(t, fm.Err)
+	assert.Contains(t, fm.Fields, "GPSLatitude")
+}
+
+// TestIntegrationSidecarMetadata tests that --sidecar-metadata tags a RAW file through an .xmp sidecar
+func TestIntegrationSidecarMetadata(t *testing.T) {
+	fixtureDir := "/Users/chris/devel/home/sortpics/tests/integration/fixtures/basic"
+
+	// Check if fixtures are available
+	if _, err := os.Stat(fixtureDir); os.IsNotExist(err) {
+		t.Skip("Integration test fixtures not available")
+	}
+
+	// RAW handling goes by extension, so the fixture stands in for a RAW file
+	sourceDir := t.TempDir()
+	testFile := filepath.Join(sourceDir, "IMG_0001.cr2")
+	require.NoError(t, SafeCopy(context.Background(), filepath.Join(fixtureDir, "test_001.jpg"), testFile))
+	original, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+
+	cfg := &config.ProcessingConfig{
+		Precision:       6,
+		Album:           "Vacation 2024",
+		Tags:            []string{"family", "Places|France"},
+		SidecarMetadata: true,
+	}
+
+	ir, err := NewImageRename(testFile, t.TempDir(), cfg)
+	require.NoError(t, err)
+	defer ir.Close()
+
+	err = ir.ParseMetadata(context.Background())
+	require.NoError(t, err)
+
+	err = ir.Perform(context.Background())
+	require.NoError(t, err)
+
+	// The RAW is copied byte for byte
+	copied, err := os.ReadFile(ir.GetDestination())
+	require.NoError(t, err)
+	assert.Equal(t, original, copied)
+
+	sidecar := strings.TrimSuffix(ir.GetDestination(), ".cr2") + ".xmp"
+	require.FileExists(t, sidecar)
+
+	et, err := exiftool.NewExiftool()
+	require.NoError(t, err)
+	defer et.Close()
+
+	fm := et.ExtractMetadata(sidecar)[0]
+	require.NoError(t, fm.Err)
+
+	album, err := fm.GetString("Album")
+	require.NoError(t, err)
+	assert.Equal(t, "Vacation 2024", album)
+
+	subject, err := fm.GetStrings("Subject")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"family", "Places", "France"}, subject)
+
+	assert.Contains(t, fm.Fields, "DateTimeOriginal")
+
+	// Undo removes the sidecar with the copy
+	require.NoError(t, ir.Undo())
+	assert.NoFileExists(t, sidecar)
+}
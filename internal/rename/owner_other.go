@@ -0,0 +1,15 @@
+//go:build !unix
+
+package rename
+
+import "github.com/cacack/sortpics-go/pkg/config"
+
+// CheckOwner returns ErrOwnerUnsupported; this platform has no Unix file ownership.
+func CheckOwner(owner config.Owner) error {
+	return ErrOwnerUnsupported
+}
+
+// chown returns ErrOwnerUnsupported; this platform has no Unix file ownership.
+func chown(path string, owner config.Owner) error {
+	return ErrOwnerUnsupported
+}
@@ -0,0 +1,43 @@
+//go:build unix
+
+package rename
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+
+	"github.com/cacack/sortpics-go/pkg/config"
+)
+
+// CheckOwner reports whether this process may give files the owner, so a
+// run that can't fails up front instead of on every file. Root may set any
+// owner; other users may only keep their own uid and choose one of their
+// groups.
+func CheckOwner(owner config.Owner) error {
+	euid := os.Geteuid()
+	if euid == 0 {
+		return nil
+	}
+	if owner.UID != -1 && owner.UID != euid {
+		return fmt.Errorf("changing the owner to uid %d requires root privileges (running as uid %d)", owner.UID, euid)
+	}
+	if owner.GID != -1 && owner.GID != os.Getegid() {
+		groups, err := os.Getgroups()
+		if err != nil || !slices.Contains(groups, owner.GID) {
+			return fmt.Errorf("changing the group to gid %d requires root privileges or membership of the group", owner.GID)
+		}
+	}
+	return nil
+}
+
+// chown gives path the owner, explaining a permission error
+func chown(path string, owner config.Owner) error {
+	err := os.Lchown(path, owner.UID, owner.GID)
+	if errors.Is(err, fs.ErrPermission) {
+		return fmt.Errorf("%w (changing the owner of %s to %d:%d requires root privileges)", err, path, owner.UID, owner.GID)
+	}
+	return err
+}
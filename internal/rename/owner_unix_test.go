@@ -0,0 +1,56 @@
+//go:build unix
+
+package rename
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestChown tests that chown gives a file the owner, and that CheckOwner
+// refuses owners an unprivileged user can't set
+func TestChown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+
+	owned := func() (int, int) {
+		info, err := os.Lstat(path)
+		require.NoError(t, err)
+		stat := info.Sys().(*syscall.Stat_t)
+		return int(stat.Uid), int(stat.Gid)
+	}
+
+	// Anyone may keep their own uid and gid
+	self := config.Owner{UID: os.Geteuid(), GID: os.Getegid()}
+	require.NoError(t, CheckOwner(self))
+	require.NoError(t, chown(path, self))
+
+	if os.Geteuid() != 0 {
+		err := CheckOwner(config.Owner{UID: 0, GID: -1})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "root")
+
+		err = chown(path, config.Owner{UID: 0, GID: -1})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "root")
+		t.Skip("changing the owner to another user requires root")
+	}
+
+	require.NoError(t, CheckOwner(config.Owner{UID: 1234, GID: 5678}))
+	require.NoError(t, chown(path, config.Owner{UID: 1234, GID: 5678}))
+	uid, gid := owned()
+	assert.Equal(t, 1234, uid)
+	assert.Equal(t, 5678, gid)
+
+	// -1 leaves that ID alone
+	require.NoError(t, chown(path, config.Owner{UID: -1, GID: 4321}))
+	uid, gid = owned()
+	assert.Equal(t, 1234, uid)
+	assert.Equal(t, 4321, gid)
+}
@@ -1,10 +1,21 @@
 package rename
 
 import (
+	"cmp"
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"math"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -16,16 +27,22 @@ import (
 	"github.com/cacack/sortpics-go/pkg/config"
 )
 
-// ValidExtensions lists all supported image and video file extensions
+// ValidExtensions lists all supported image, video and audio file extensions
 var ValidExtensions = []string{
 	// Standard images
-	"jpg", "jpeg", "png", "tiff", "tif",
+	"jpg", "jpeg", "png", "tiff", "tif", "heic", "heif",
 	// RAW formats
-	"arw", "cr2", "crw", "dcr", "dng", "mrw", "nef", "nrw",
-	"orf", "pef", "ptx", "raw", "rw2", "rwl", "srf", "sr2",
-	"srw", "x3f",
+	"arw", "cr2", "crw", "dcr", "dng", "gpr", "mrw", "nef",
+	"nrw", "orf", "pef", "ptx", "raw", "rw2", "rwl", "srf",
+	"sr2", "srw", "x3f",
 	// Video formats
 	"mov", "mp4", "m4v", "avi", "mpg", "mpeg",
+	// AVCHD camcorder formats
+	"mts", "m2ts",
+	// 360-camera formats (Insta360 photo and video)
+	"insp", "insv",
+	// Audio formats (voice memos); usually dated by filename or file time
+	"m4a", "wav", "mp3",
 }
 
 // RawExtensions lists all RAW image file extensions
@@ -34,6 +51,7 @@ var RawExtensions = []string{
 	"crw", // Canon
 	"cr2", // Canon
 	"dng", // Adobe, Leica
+	"gpr", // GoPro
 	"mrw", // Minolta
 	"nef", // Nikon
 	"nrw", // Nikon
@@ -49,6 +67,61 @@ var RawExtensions = []string{
 	"x3f", // Sigma
 }
 
+// SidecarExtensions lists companion files that travel with the file sharing
+// their basename and are renamed to match it. They aren't processed on their own.
+var SidecarExtensions = []string{
+	"srt", // DJI drone flight telemetry
+}
+
+// fileTypeExtensions maps the ExifTool FileType of still images to the
+// extensions files of that type may carry. With config.FixExtension, a file
+// whose extension isn't listed for its type gets the first one.
+var fileTypeExtensions = map[string][]string{
+	"JPEG": {"jpg", "jpeg", "insp"},
+	"PNG":  {"png"},
+	"HEIC": {"heic", "heif"},
+	"HEIF": {"heif", "heic"},
+	"TIFF": {"tif", "tiff"},
+}
+
+// ErrSourceNotRemoved is returned by SafeMove and Perform when the file reached
+// its destination but the source couldn't be deleted, e.g. on a read-only card.
+// The destination is complete; only the source is left behind.
+var ErrSourceNotRemoved = errors.New("copied but could not remove source")
+
+// ErrModeNotSet is returned by SafeMove and Perform under WithMode when the
+// file was renamed into place but its permissions couldn't be set. The file
+// is at its destination with the source's mode.
+var ErrModeNotSet = errors.New("moved but could not set permissions")
+
+// ErrCopyMismatch is returned by SafeCopy and SafeMove under WithVerify when
+// the copy read back differs from the source. The copy is removed.
+var ErrCopyMismatch = errors.New("copy does not match its source")
+
+// ErrOwnerUnsupported is returned when config.Owner is set on a platform
+// without Unix file ownership
+var ErrOwnerUnsupported = errors.New("setting the file owner is only supported on Unix")
+
+// ChecksumExt is appended to a destination path to name its checksum sidecar
+const ChecksumExt = ".sha256"
+
+// BackupExt is appended to a destination path to name the backup of the file
+// it replaced with Overwrite and Backup set (a number is added before it if
+// that name is taken)
+const BackupExt = ".bak"
+
+// TempFilePrefix is the filename prefix SafeCopy uses for in-progress copies
+const TempFilePrefix = ".tmp-"
+
+// tempFilePattern matches names produced by os.CreateTemp(dir, TempFilePrefix+"*"):
+// the prefix followed by a random suffix with no extension
+var tempFilePattern = regexp.MustCompile(`^` + regexp.QuoteMeta(TempFilePrefix) + `[^.]+$`)
+
+// IsTempFile reports whether a filename is a SafeCopy temporary file
+func IsTempFile(name string) bool {
+	return tempFilePattern.MatchString(name)
+}
+
 // ImageRename orchestrates metadata extraction, path generation, and file operations
 type ImageRename struct {
 	config              *config.ProcessingConfig
@@ -68,13 +141,57 @@ type ImageRename struct {
 	destinationDir      string
 	isDuplicate         bool
 	datetime            *time.Time
+	dateSource          config.DateSource
 	make                string
 	model               string
+	lens                string
 	rawMetadata         map[string]interface{}
+	parseError          string
+	sourceHash          string
+	cameraTruncated     bool
+	replaces            bool
+
+	// Results from Perform, used by Undo
+	transferred         bool
+	sidecars            map[string]string
+	backup              string
+	sidecarBackups      map[string]string
+	xmpSidecar          string
+
+	// run is shared with the other files of the run, if any
+	run                 *Run
+}
+
+// NewExtractor creates a metadata extractor that reads dates the way cfg
+// asks, sharing run's ExifTool processes if run is non-nil. Anything that
+// reads datetimes ahead of NewImageRename uses it so both agree.
+func NewExtractor(cfg *config.ProcessingConfig, run *Run) (*metadata.MetadataExtractor, error) {
+	var extractor *metadata.MetadataExtractor
+	if pool := run.pool(); pool != nil {
+		extractor = metadata.NewPooledMetadataExtractor(pool)
+	} else {
+		var err error
+		extractor, err = metadata.NewMetadataExtractor()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create metadata extractor: %w", err)
+		}
+	}
+	extractor.Location = cfg.Location
+	extractor.ModelMap = cfg.ModelMap
+	extractor.PreferFilenameDate = cfg.PreferFilenameDate
+	if cfg.QuickTimeUTC {
+		extractor.QuickTimeLocation = cmp.Or(cfg.Location, time.Local)
+	}
+	return extractor, nil
 }
 
 // NewImageRename creates a new ImageRename instance
-func NewImageRename(sourceFilename string, destinationBaseDir string, cfg *config.ProcessingConfig) (*ImageRename, error) {
+func NewImageRename(sourceFilename string, destinationBaseDir string, cfg *config.ProcessingConfig, opts ...Option) (*ImageRename, error) {
+	var optioned ImageRename
+	for _, opt := range opts {
+		opt(&optioned)
+	}
+
 	if cfg == nil {
 		cfg = &config.ProcessingConfig{
 			Precision: 6,
@@ -122,10 +239,24 @@ func NewImageRename(sourceFilename string, destinationBaseDir string, cfg *confi
 		album = filepath.Base(filepath.Dir(absSource))
 	}
 
-	// Initialize metadata extractor
-	metaExtractor, err := metadata.NewMetadataExtractor()
+	metaExtractor, err := NewExtractor(cfg, optioned.run)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create metadata extractor: %w", err)
+		return nil, err
+	}
+
+	detector := duplicate.NewWithLimit(cfg.MaxCollisions)
+	detector.PreserveCompoundExt = cfg.PreserveCompoundExt
+	detector.CaseInsensitive = cfg.WindowsSafe
+	detector.HashSuffix = cfg.HashSuffix
+	detector.Overwrite = cfg.Overwrite
+	if optioned.run != nil {
+		detector.Protected = optioned.run.wrote
+	}
+
+	pathGenerator := newPathGenerator(cfg)
+	pathGenerator.Prefix = sourcePrefix(absSource, cfg.SourcePrefixes)
+	if cfg.PreserveTree {
+		pathGenerator.Subdir = sourceSubdir(absSource, cfg.SourceDirs)
 	}
 
 	return &ImageRename{
@@ -138,8 +269,9 @@ func NewImageRename(sourceFilename string, destinationBaseDir string, cfg *confi
 		album:             album,
 		tags:              cfg.Tags,
 		metadataExtractor: metaExtractor,
-		pathGenerator:     pathgen.New(cfg.Precision, cfg.OldNaming),
-		duplicateDetector: duplicate.New(),
+		pathGenerator:     pathGenerator,
+		duplicateDetector: detector,
+		run:               optioned.run,
 	}, nil
 }
 
@@ -158,42 +290,246 @@ func (ir *ImageRename) IsRaw() bool {
 	return IsRaw(ir.extension)
 }
 
-// ParseMetadata extracts metadata and generates destination path
-func (ir *ImageRename) ParseMetadata() error {
+// ParseMetadata extracts metadata and generates destination path.
+// Canceling ctx abandons a running metadata extraction and returns ctx.Err().
+func (ir *ImageRename) ParseMetadata(ctx context.Context) error {
+	initialDestination, _, err := ir.plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Resolve collisions
+	finalDestination, isDuplicate, sourceHash, err := ir.duplicateDetector.CheckAndResolveWithHash(ir.source, initialDestination)
+	if err != nil {
+		return fmt.Errorf("failed to check duplicates: %w", err)
+	}
+	if sourceHash != nil {
+		ir.sourceHash = *sourceHash
+	}
+
+	// A file that already has its canonical name (renamed in place, or in a
+	// reorganized archive) "collides" with itself
+	if finalDestination == ir.source {
+		isDuplicate = false
+	}
+
+	ir.destination = finalDestination
+	ir.destinationDir = filepath.Dir(finalDestination)
+	if !isDuplicate {
+		sibling, err := ir.identicalSibling()
+		if err != nil {
+			return fmt.Errorf("failed to check duplicates: %w", err)
+		}
+		if sibling != "" {
+			finalDestination = sibling
+			ir.destination, ir.destinationDir = sibling, filepath.Dir(sibling)
+			isDuplicate = true
+		}
+	}
+	ir.isDuplicate = isDuplicate
+	ir.replaces = ir.config.Overwrite && sourceHash != nil && !isDuplicate && finalDestination != ir.source && fileExists(finalDestination)
+
+	return nil
+}
+
+// identicalSibling returns a file next to the destination, named for the same
+// second, whose content matches the source (config.DedupSiblings), or ""
+func (ir *ImageRename) identicalSibling() (string, error) {
+	if !ir.config.DedupSiblings || ir.datetime == nil {
+		return "", nil
+	}
+	return ir.duplicateDetector.FindIdenticalSibling(ir.source, ir.destination, ir.datetime.Format("20060102-150405"))
+}
+
+// PlannedDestination extracts metadata and returns the destination path
+// before collision resolution (increment 0). Nothing is written to disk.
+func (ir *ImageRename) PlannedDestination() (string, error) {
+	destination, _, err := ir.plan(context.Background())
+	return destination, err
+}
+
+// newPathGenerator creates a path generator for the naming options in cfg
+func newPathGenerator(cfg *config.ProcessingConfig) *pathgen.PathGenerator {
+	pathGenerator := pathgen.New(cfg.Precision, cfg.OldNaming)
+	pathGenerator.NameCase = cfg.NameCase
+	pathGenerator.Separator = cfg.Separator
+	pathGenerator.KeepOriginalName = cfg.KeepOriginalName
+	pathGenerator.RatingDirs = cfg.RatingDirs
+	pathGenerator.MaxFilenameLength = cfg.MaxFilenameLength
+	pathGenerator.WindowsSafe = cfg.WindowsSafe
+	pathGenerator.UnknownLabel = cfg.UnknownLabel
+	return pathGenerator
+}
+
+// sourceRoot returns the innermost of dirs that path lies in
+func sourceRoot(path string, dirs []string) (string, bool) {
+	root := ""
+	for _, dir := range dirs {
+		if path != dir && !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			continue
+		}
+		if len(dir) > len(root) {
+			root = dir
+		}
+	}
+	return root, root != ""
+}
+
+// sourcePrefix returns the prefix of the source directory that source lies in.
+// When source directories are nested, the innermost one with a prefix wins.
+func sourcePrefix(source string, prefixes map[string]string) string {
+	root, _ := sourceRoot(source, slices.Collect(maps.Keys(prefixes)))
+	return prefixes[root]
+}
+
+// sourceSubdir returns the directory of source relative to the source directory
+// it lies in, or "" when it sits directly in it or in none of dirs
+func sourceSubdir(source string, dirs []string) string {
+	dir := filepath.Dir(source)
+	root, ok := sourceRoot(dir, dirs)
+	if !ok {
+		return ""
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return rel
+}
+
+// IsOrganized reports whether source already has a canonical name and sits in
+// the date directory that name implies under destinationBaseDir (or cfg.RawPath
+// for RAW files). It's judged from the filename alone, without reading
+// metadata; in place, only the name is checked. With cfg.RatingDirs the
+// directory depends on the rating, so files are never judged organized.
+func IsOrganized(source, destinationBaseDir string, cfg *config.ProcessingConfig) bool {
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return false
+	}
+
+	pathGenerator := newPathGenerator(cfg)
+	pathGenerator.Prefix = sourcePrefix(absSource, cfg.SourcePrefixes)
+	if cfg.PreserveTree {
+		pathGenerator.Subdir = sourceSubdir(absSource, cfg.SourceDirs)
+	}
+	dt, ok := pathGenerator.ParseFilename(filepath.Base(absSource))
+	if !ok {
+		return false
+	}
+	if cfg.InPlace {
+		return true
+	}
+	if cfg.RatingDirs {
+		return false
+	}
+
+	destBase := destinationBaseDir
+	if IsRaw(strings.TrimPrefix(filepath.Ext(absSource), ".")) && cfg.RawPath != "" {
+		destBase = cfg.RawPath
+	}
+	absDestBase, err := filepath.Abs(destBase)
+	if err != nil {
+		return false
+	}
+
+	dir := pathGenerator.GenerateDirectory(&config.ImageMetadata{DateTime: dt}, absDestBase)
+	return filepath.Dir(absSource) == dir
+}
+
+// PlanDestination reports where source would be organized under destBase,
+// along with its extracted metadata, without any side effects.
+//
+// Collisions with existing files are not resolved (the path never has a _N
+// suffix) and nothing is copied, moved, or tagged. A nil cfg uses defaults.
+func PlanDestination(source, destBase string, cfg *config.ProcessingConfig) (string, *config.ImageMetadata, error) {
+	ir, err := NewImageRename(source, destBase, cfg)
+	if err != nil {
+		return "", nil, err
+	}
+	defer ir.Close()
+
+	return ir.plan(context.Background())
+}
+
+// plan extracts and stores metadata, then generates the uncollided destination
+func (ir *ImageRename) plan(ctx context.Context) (string, *config.ImageMetadata, error) {
 	// Extract metadata
-	meta, err := ir.metadataExtractor.Extract(ir.source, ir.timeDelta, ir.dayDelta)
+	meta, err := ir.metadataExtractor.ExtractContext(ctx, ir.source, ir.timeDelta, ir.dayDelta)
 	if err != nil {
-		return fmt.Errorf("failed to extract metadata: %w", err)
+		return "", nil, fmt.Errorf("failed to extract metadata: %w", err)
 	}
 
 	// Store extracted values
 	ir.datetime = meta.DateTime
+	ir.dateSource = meta.DateSource
 	ir.make = meta.Make
 	ir.model = meta.Model
+	ir.lens = meta.Lens
 	ir.rawMetadata = meta.RawMetadata
+	ir.parseError = meta.ParseError
+	if ir.config.FixExtension {
+		if fixed := trueExtension(meta.RawMetadata, ir.extension); fixed != "" {
+			ir.extension = fixed
+		}
+	}
+	meta.OriginalName = strings.TrimSuffix(filepath.Base(ir.source), filepath.Ext(ir.source))
+
+	// A templated album needs the parsed date, so it's resolved here
+	if ir.config.AlbumTemplate != "" {
+		ir.album = pathgen.ExpandTemplate(ir.config.AlbumTemplate, meta)
+	}
 
 	// Generate destination path (increment=0 for initial path)
+	ir.cameraTruncated = ir.pathGenerator.CameraTruncated(meta, ir.extension)
 	initialDestination := ir.pathGenerator.GeneratePath(meta, ir.destinationBase, ir.extension, 0)
+	if ir.config.InPlace {
+		initialDestination = ir.pathGenerator.GenerateInPlacePath(meta, ir.source, ir.extension, 0)
+	}
 
-	// Resolve collisions
-	finalDestination, isDuplicate, err := ir.duplicateDetector.CheckAndResolve(ir.source, initialDestination)
-	if err != nil {
-		return fmt.Errorf("failed to check duplicates: %w", err)
+	// Route burst members into their shared subdirectory under the date folder
+	if group, ok := ir.config.BurstGroups[ir.source]; ok {
+		initialDestination = filepath.Join(filepath.Dir(initialDestination), group, filepath.Base(initialDestination))
 	}
 
-	ir.destination = finalDestination
-	ir.destinationDir = filepath.Dir(finalDestination)
-	ir.isDuplicate = isDuplicate
+	// Files dated only by filesystem time go aside for manual review
+	if ir.config.QuarantineNoDate && meta.DateSource == config.DateSourceCtime {
+		initialDestination = ir.pathGenerator.GenerateNoDatePath(ir.destinationBase, ir.source)
+	}
 
-	return nil
+	return initialDestination, meta, nil
 }
 
-// Perform executes the file operation (copy or move)
-func (ir *ImageRename) Perform() error {
+// Perform executes the file operation (copy or move).
+//
+// Canceling ctx before or during the copy aborts it, removes the temp file,
+// and returns ctx.Err(). Once the file is in place, tagging runs to completion
+// so a destination is never left without its metadata.
+//
+// A move whose source can't be deleted still completes the destination and
+// then returns an error wrapping ErrSourceNotRemoved.
+func (ir *ImageRename) Perform(ctx context.Context) error {
 	if ir.config.DryRun {
 		// In dry run mode, just return without doing anything
 		return nil
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ir.config.Durable {
+		ctx = WithDurable(ctx)
+	}
+	if ir.config.VerifyCopy {
+		ctx = WithVerify(ctx)
+	}
+	if ir.config.FileMode != 0 {
+		ctx = WithMode(ctx, ir.config.FileMode)
+	}
+
+	// Already canonically named in place; nothing to do
+	if ir.destination == ir.source {
+		return nil
+	}
 
 	// Create destination directory
 	if err := os.MkdirAll(ir.destinationDir, 0755); err != nil {
@@ -202,54 +538,407 @@ func (ir *ImageRename) Perform() error {
 
 	// Re-check for collisions (race condition in multiprocessing)
 	if _, err := os.Stat(ir.destination); err == nil {
-		finalDestination, isDuplicate, err := ir.duplicateDetector.CheckAndResolve(ir.source, ir.destination)
+		isDuplicate, err := ir.resolveAgain()
 		if err != nil {
-			return fmt.Errorf("failed to recheck duplicates: %w", err)
+			return err
 		}
 		if isDuplicate {
 			// Skip duplicate files
 			return nil
 		}
-		ir.destination = finalDestination
-		ir.destinationDir = filepath.Dir(finalDestination)
-		if err := os.MkdirAll(ir.destinationDir, 0755); err != nil {
-			return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	// A destination another file of the run claimed first, whether written
+	// yet or not, is resolved again to an _N suffix rather than replaced:
+	// Overwrite only replaces files that were there before the run
+	for !ir.run.claim(ir.destination) {
+		isDuplicate, err := ir.resolveAgain()
+		if err != nil {
+			return err
+		}
+		if isDuplicate {
+			return nil
 		}
 	}
 
-	// Perform copy or move
+	// Another worker may have written the same content under a nearby name
+	sibling, err := ir.identicalSibling()
+	if err != nil {
+		return fmt.Errorf("failed to recheck duplicates: %w", err)
+	}
+	if sibling != "" {
+		ir.destination, ir.destinationDir = sibling, filepath.Dir(sibling)
+		ir.isDuplicate = true
+		return nil
+	}
+
+	// A sidecar name taken at the destination fails the file before anything
+	// is transferred, rather than leaving it organized without its sidecars
+	if !ir.config.StripGPS && !ir.config.Overwrite {
+		for _, sidecar := range ir.sidecarSources() {
+			if dst := ir.sidecarDestination(sidecar); fileExists(dst) {
+				return fmt.Errorf("failed to transfer sidecar: %s already exists", dst)
+			}
+		}
+	}
+
+	// The copy or move below replaces an overwritten file atomically; keep
+	// it first if asked to
+	if ir.config.Overwrite && ir.backsUp() && fileExists(ir.destination) {
+		backup, err := ir.backUp(ctx, ir.destination)
+		if err != nil {
+			return fmt.Errorf("failed to back up %s: %w", ir.destination, err)
+		}
+		ir.backup = backup
+	}
+
+	// Perform copy or move. A source that can't be removed doesn't undo the
+	// move: the destination is finished and the leftover reported at the end.
+	var sourceErr error
 	if ir.config.Move {
-		if err := SafeMove(ir.source, ir.destination); err != nil {
-			return fmt.Errorf("failed to move file: %w", err)
+		if err := SafeMove(ctx, ir.source, ir.destination); err != nil {
+			if errors.Is(err, ErrModeNotSet) {
+				// The file moved all the same, so Undo can put it back
+				ir.transferred = true
+			}
+			if !errors.Is(err, ErrSourceNotRemoved) {
+				return fmt.Errorf("failed to move file: %w", err)
+			}
+			sourceErr = err
 		}
 	} else {
-		if err := SafeCopy(ir.source, ir.destination); err != nil {
+		if err := SafeCopy(ctx, ir.source, ir.destination); err != nil {
 			return fmt.Errorf("failed to copy file: %w", err)
 		}
 	}
+	ir.transferred = true
+
+	// Telemetry sidecars are a GPS track, so they stay behind when stripping location
+	if !ir.config.StripGPS {
+		if err := ir.transferSidecars(ctx); err != nil {
+			return fmt.Errorf("failed to transfer sidecar: %w", err)
+		}
+	}
+
+	// Write metadata tags (skipped for a pure bytewise copy/move)
+	if !ir.config.NoMetadataWrite {
+		if err := ir.writeMetadata(); err != nil {
+			err = fmt.Errorf("failed to write metadata: %w", err)
+			// A copy that may still carry its location isn't left behind
+			if ir.config.StripGPS {
+				err = errors.Join(err, ir.Undo())
+			}
+			return err
+		}
+	}
+
+	if ir.config.WriteChecksums {
+		if err := ir.writeChecksum(); err != nil {
+			return fmt.Errorf("failed to write checksum: %w", err)
+		}
+	}
+
+	// Last, as ExifTool writes metadata to a new file
+	if owner := ir.config.Owner; owner != nil {
+		if err := ir.setOwner(*owner); err != nil {
+			return fmt.Errorf("failed to set owner: %w", err)
+		}
+	}
+
+	return sourceErr
+}
+
+// resolveAgain resolves the destination again for a file that got there
+// since ParseMetadata, and reports whether that file is a duplicate of the
+// source
+func (ir *ImageRename) resolveAgain() (bool, error) {
+	finalDestination, isDuplicate, err := ir.duplicateDetector.CheckAndResolve(ir.source, ir.destination)
+	if err != nil {
+		return false, fmt.Errorf("failed to recheck duplicates: %w", err)
+	}
+	if isDuplicate {
+		return true, nil
+	}
+	ir.replaces = ir.config.Overwrite && finalDestination == ir.destination && fileExists(finalDestination)
+	ir.destination = finalDestination
+	ir.destinationDir = filepath.Dir(finalDestination)
+	if err := os.MkdirAll(ir.destinationDir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create destination directory: %w", err)
+	}
+	return false, nil
+}
+
+// setOwner gives the destination, and every sidecar Perform wrote next to
+// it, the owner
+func (ir *ImageRename) setOwner(owner config.Owner) error {
+	paths := []string{ir.destination}
+	for _, dst := range ir.sidecars {
+		paths = append(paths, dst)
+	}
+	if ir.xmpSidecar != "" {
+		paths = append(paths, ir.xmpSidecar)
+	}
+	if ir.config.WriteChecksums {
+		paths = append(paths, ir.destination+ChecksumExt)
+	}
+
+	for _, path := range paths {
+		if err := chown(path, owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findSidecars returns the files next to source that share its basename and
+// have one of SidecarExtensions, in either case (DJI_0001.SRT for DJI_0001.MP4)
+func findSidecars(source string) []string {
+	stem := strings.TrimSuffix(source, filepath.Ext(source))
+
+	var sidecars []string
+	var found []os.FileInfo
+	for _, ext := range SidecarExtensions {
+		for _, candidate := range []string{stem + "." + ext, stem + "." + strings.ToUpper(ext)} {
+			info, err := os.Stat(candidate)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			// On case-insensitive filesystems both spellings are the same file
+			if slices.ContainsFunc(found, func(f os.FileInfo) bool { return os.SameFile(f, info) }) {
+				continue
+			}
+			found = append(found, info)
+			sidecars = append(sidecars, candidate)
+		}
+	}
+	return sidecars
+}
 
-	// Write metadata tags
-	if err := ir.writeMetadata(); err != nil {
-		return fmt.Errorf("failed to write metadata: %w", err)
+// sidecarSources returns the source's sidecars, and its Live Photo video if
+// paired
+func (ir *ImageRename) sidecarSources() []string {
+	sidecars := findSidecars(ir.source)
+	if video, ok := ir.config.LivePhotos[ir.source]; ok {
+		sidecars = append(sidecars, video)
 	}
+	return sidecars
+}
 
+// sidecarDestination returns where a sidecar goes: next to the destination,
+// with its basename and a lowercase extension
+func (ir *ImageRename) sidecarDestination(sidecar string) string {
+	destStem := strings.TrimSuffix(ir.destination, filepath.Ext(ir.destination))
+	return destStem + strings.ToLower(filepath.Ext(sidecar))
+}
+
+// transferSidecars copies or moves the source's sidecars, and its Live Photo
+// video if paired, next to the destination, renamed to its basename with a
+// lowercase extension. A sidecar replaced with Overwrite is backed up like
+// the file.
+func (ir *ImageRename) transferSidecars(ctx context.Context) error {
+	for _, sidecar := range ir.sidecarSources() {
+		dst := ir.sidecarDestination(sidecar)
+		if fileExists(dst) {
+			if !ir.config.Overwrite {
+				return fmt.Errorf("%s already exists", dst)
+			}
+			if ir.backsUp() {
+				backup, err := ir.backUp(ctx, dst)
+				if err != nil {
+					return fmt.Errorf("failed to back up %s: %w", dst, err)
+				}
+				if ir.sidecarBackups == nil {
+					ir.sidecarBackups = make(map[string]string)
+				}
+				ir.sidecarBackups[dst] = backup
+			}
+		}
+
+		if ir.config.Move {
+			// A sidecar left on a read-only card is reported with its file
+			if err := SafeMove(ctx, sidecar, dst); err != nil && !errors.Is(err, ErrSourceNotRemoved) {
+				if errors.Is(err, ErrModeNotSet) {
+					ir.noteSidecar(sidecar, dst)
+				}
+				return err
+			}
+		} else {
+			if err := SafeCopy(ctx, sidecar, dst); err != nil {
+				return err
+			}
+		}
+
+		ir.noteSidecar(sidecar, dst)
+	}
 	return nil
 }
 
+// noteSidecar records a transferred sidecar for Undo
+func (ir *ImageRename) noteSidecar(sidecar, dst string) {
+	if ir.sidecars == nil {
+		ir.sidecars = make(map[string]string)
+	}
+	ir.sidecars[sidecar] = dst
+}
+
+// Undo reverses a Perform, including one that failed after the file was
+// transferred. Copies (and moves whose source couldn't be removed) are
+// deleted from the destination with their sidecars and checksum; moved files
+// are moved back to their source. A file replaced with Overwrite is put back
+// from its backup. Metadata written to a moved file is not reverted, but an
+// XMP sidecar created for it is removed. Undo does nothing if the file was
+// never transferred.
+func (ir *ImageRename) Undo() error {
+	if !ir.transferred {
+		return nil
+	}
+
+	if ir.config.WriteChecksums {
+		if err := os.Remove(ir.destination + ChecksumExt); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove checksum: %w", err)
+		}
+	}
+	if ir.xmpSidecar != "" {
+		if err := os.Remove(ir.xmpSidecar); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove XMP sidecar: %w", err)
+		}
+	}
+	for sidecar, dst := range ir.sidecars {
+		if err := restore(sidecar, dst); err != nil {
+			return err
+		}
+	}
+	for dst, backup := range ir.sidecarBackups {
+		if err := SafeMove(context.Background(), backup, dst); err != nil {
+			return fmt.Errorf("failed to restore %s from %s: %w", dst, backup, err)
+		}
+	}
+	if err := restore(ir.source, ir.destination); err != nil {
+		return err
+	}
+	if ir.backup != "" {
+		if err := SafeMove(context.Background(), ir.backup, ir.destination); err != nil {
+			return fmt.Errorf("failed to restore %s from %s: %w", ir.destination, ir.backup, err)
+		}
+	}
+
+	ir.transferred = false
+	ir.sidecars = nil
+	ir.sidecarBackups = nil
+	ir.backup = ""
+	ir.xmpSidecar = ""
+	return nil
+}
+
+// backsUp reports whether files replaced with Overwrite are kept
+func (ir *ImageRename) backsUp() bool {
+	return ir.config.Backup || ir.config.BackupDir != ""
+}
+
+// backUp keeps the file at path, the destination or one of its sidecars, at
+// backupPath and returns where. A hard link is used where the filesystem
+// supports one, so the file never goes missing, and a copy otherwise; either
+// way the replaced content ends up only in the backup once the new file takes
+// its place.
+func (ir *ImageRename) backUp(ctx context.Context, path string) (string, error) {
+	backup := ir.backupPath(path)
+	if err := os.MkdirAll(filepath.Dir(backup), 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	if err := os.Link(path, backup); err != nil {
+		if err := SafeCopy(ctx, path, backup); err != nil {
+			return "", err
+		}
+	}
+	return backup, nil
+}
+
+// backupPath returns a free path for the backup of a file at path: next to
+// it with BackupExt, or with config.BackupDir under that directory at its
+// path relative to the destination base. A taken name gets ".1", ".2", and so
+// on before its extension.
+func (ir *ImageRename) backupPath(path string) string {
+	candidate := path + BackupExt
+	if ir.config.BackupDir != "" {
+		rel, err := filepath.Rel(ir.destinationBase, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			rel = filepath.Base(path)
+		}
+		candidate = filepath.Join(ir.config.BackupDir, rel)
+	}
+
+	ext := filepath.Ext(candidate)
+	backup := candidate
+	for n := 1; fileExists(backup); n++ {
+		backup = fmt.Sprintf("%s.%d%s", strings.TrimSuffix(candidate, ext), n, ext)
+	}
+	return backup
+}
+
+// fileExists reports whether anything exists at path
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// restore undoes the transfer of src to dst: if src is still there it was a
+// copy and dst is deleted, otherwise dst is moved back to src
+func restore(src, dst string) error {
+	if _, err := os.Lstat(src); err == nil {
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", dst, err)
+		}
+		return nil
+	}
+
+	if err := SafeMove(context.Background(), dst, src); err != nil {
+		return fmt.Errorf("failed to move %s back to %s: %w", dst, src, err)
+	}
+	return nil
+}
+
+// writeChecksum writes a sha256sum-compatible sidecar next to the destination.
+//
+// The source hash from collision resolution is reused when the destination is
+// a bytewise copy; otherwise the destination is hashed after metadata writing.
+func (ir *ImageRename) writeChecksum() error {
+	hash := ir.sourceHash
+	if hash == "" || !ir.config.NoMetadataWrite {
+		var err error
+		hash, err = duplicate.FileSHA256(ir.destination)
+		if err != nil {
+			return err
+		}
+	}
+
+	line := fmt.Sprintf("%s  %s\n", hash, filepath.Base(ir.destination))
+	return os.WriteFile(ir.destination+ChecksumExt, []byte(line), 0644)
+}
+
 // writeMetadata writes EXIF and XMP tags to the destination file
 func (ir *ImageRename) writeMetadata() error {
-	if ir.datetime == nil {
+	if ir.datetime == nil && !ir.config.StripGPS {
 		return nil
 	}
 
-	et, err := exiftool.NewExiftool()
+	et, release, err := ir.exifTool()
 	if err != nil {
 		return fmt.Errorf("failed to initialize exiftool: %w", err)
 	}
-	defer et.Close()
+	defer release()
 
-	// Format datetime for EXIF
-	datetimeStr := ir.datetime.Format("2006:01:02 15:04:05")
+	// RAW files keep their tags in a sidecar; only stripping GPS still
+	// touches the RAW itself
+	sidecar := ir.config.SidecarMetadata && ir.IsRaw()
+	if sidecar {
+		if err := ir.writeXMPSidecar(et); err != nil {
+			return err
+		}
+		if !ir.config.StripGPS {
+			return nil
+		}
+	}
 
 	// Extract metadata first to get FileMetadata structure
 	fmList := et.ExtractMetadata(ir.destination)
@@ -263,26 +952,230 @@ func (ir *ImageRename) writeMetadata() error {
 	}
 
 	// Set datetime tags
-	fm.SetString("EXIF:DateTimeOriginal", datetimeStr)
-	fm.SetString("EXIF:CreateDate", datetimeStr)
-	fm.SetString("EXIF:ModifyDate", datetimeStr)
+	if ir.datetime != nil && !sidecar {
+		datetimeStr := ir.datetime.Format("2006:01:02 15:04:05")
+		fm.SetString("EXIF:DateTimeOriginal", datetimeStr)
+		fm.SetString("EXIF:CreateDate", datetimeStr)
+		fm.SetString("EXIF:ModifyDate", datetimeStr)
+	}
+
+	// Drop location data. The extracted GPS values would otherwise be written
+	// straight back; GPS:all removes the EXIF GPS directory and GPS* catches
+	// copies in other groups such as XMP.
+	if ir.config.StripGPS {
+		maps.DeleteFunc(fm.Fields, func(k string, _ interface{}) bool { return isGPSTag(k) })
+		fm.Clear("GPS:all")
+		fm.Clear("GPS*")
+	}
 
 	// Add album if specified
-	if ir.album != "" {
+	if ir.album != "" && !sidecar {
 		fm.SetString("XMP:Album", ir.album)
 	}
 
-	// Add keywords if specified
-	if len(ir.tags) > 0 {
-		fm.SetStrings("Keywords", ir.tags)
+	// Add keywords if specified; "a|b" tags also go to HierarchicalSubject.
+	// Keywords already on the file are kept rather than overwritten.
+	if len(ir.tags) > 0 && !sidecar {
+		keywords, hierarchical := splitTags(ir.tags)
+		fm.SetStrings("Keywords", mergeTags(existingTags(fm, "Keywords", "Subject"), keywords))
+		if len(hierarchical) > 0 {
+			fm.SetStrings("XMP:HierarchicalSubject", mergeTags(existingTags(fm, "HierarchicalSubject"), hierarchical))
+		}
 	}
 
 	// Write metadata back
 	et.WriteMetadata([]exiftool.FileMetadata{fm})
 
+	// Write errors are otherwise tolerated, but a copy that still has its
+	// location must not pass silently
+	if ir.config.StripGPS {
+		for _, written := range et.ExtractMetadata(ir.destination) {
+			if written.Err != nil {
+				return fmt.Errorf("failed to verify GPS removal: %w", written.Err)
+			}
+			for k := range written.Fields {
+				if isGPSTag(k) {
+					return fmt.Errorf("failed to strip GPS: %s remains on %s", k, ir.destination)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// xmpPacket is an empty XMP sidecar for ExifTool to fill in; it only edits
+// XMP files that already exist
+const xmpPacket = "<?xpacket begin='\ufeff' id='W5M0MpCehiHzreSzNTczkc9d'?>\n" + `<x:xmpmeta xmlns:x='adobe:ns:meta/'>
+<rdf:RDF xmlns:rdf='http://www.w3.org/1999/02/22-rdf-syntax-ns#'>
+</rdf:RDF>
+</x:xmpmeta>
+<?xpacket end='w'?>
+`
+
+// writeXMPSidecar writes the datetime, album and keywords to an .xmp sidecar
+// sharing the destination's basename (the Adobe convention), leaving the
+// destination itself untouched. An existing sidecar is updated in place and
+// keeps its keywords; one created here is recorded for Undo.
+func (ir *ImageRename) writeXMPSidecar(et *exiftool.Exiftool) error {
+	path := strings.TrimSuffix(ir.destination, filepath.Ext(ir.destination)) + ".xmp"
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err == nil {
+		_, err = f.WriteString(xmpPacket)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		ir.xmpSidecar = path
+	} else if os.IsExist(err) {
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create XMP sidecar: %w", err)
+	}
+
+	fmList := et.ExtractMetadata(path)
+	if len(fmList) == 0 {
+		return fmt.Errorf("failed to extract metadata from %s", path)
+	}
+	fm := fmList[0]
+	if fm.Err != nil {
+		return fmt.Errorf("failed to extract metadata from %s: %w", path, fm.Err)
+	}
+
+	if ir.datetime != nil {
+		datetimeStr := ir.datetime.Format("2006:01:02 15:04:05")
+		fm.SetString("XMP:DateTimeOriginal", datetimeStr)
+		fm.SetString("XMP:CreateDate", datetimeStr)
+	}
+	if ir.album != "" {
+		fm.SetString("XMP:Album", ir.album)
+	}
+	if len(ir.tags) > 0 {
+		keywords, hierarchical := splitTags(ir.tags)
+		fm.SetStrings("XMP:Subject", mergeTags(existingTags(fm, "Subject"), keywords))
+		if len(hierarchical) > 0 {
+			fm.SetStrings("XMP:HierarchicalSubject", mergeTags(existingTags(fm, "HierarchicalSubject"), hierarchical))
+		}
+	}
+
+	fmList = []exiftool.FileMetadata{fm}
+	et.WriteMetadata(fmList)
+	if fmList[0].Err != nil {
+		return fmt.Errorf("failed to write XMP sidecar: %w", fmList[0].Err)
+	}
 	return nil
 }
 
+// exifTool returns an ExifTool process for writing, borrowed from the run's
+// pool when there is one, and a function that gives it back or closes it
+func (ir *ImageRename) exifTool() (*exiftool.Exiftool, func(), error) {
+	if pool := ir.run.pool(); pool != nil {
+		et, err := pool.Get()
+		if err != nil {
+			return nil, nil, err
+		}
+		return et, func() { pool.Put(et) }, nil
+	}
+
+	et, err := exiftool.NewExiftool()
+	if err != nil {
+		return nil, nil, err
+	}
+	return et, func() { et.Close() }, nil
+}
+
+// isGPSTag reports whether an ExifTool tag name, with or without its group, is a GPS tag
+func isGPSTag(name string) bool {
+	return strings.HasPrefix(name[strings.LastIndex(name, ":")+1:], "GPS")
+}
+
+// splitTags separates tags into flat keywords and hierarchical subjects.
+//
+// A tag such as "Places|France|Paris" is kept whole as a hierarchical subject
+// and each of its levels is added to the flat keywords, so tools that only read
+// Keywords still find it. Simple tags pass through unchanged.
+func splitTags(tags []string) (keywords, hierarchical []string) {
+	seen := make(map[string]bool)
+	addKeyword := func(k string) {
+		k = strings.TrimSpace(k)
+		if k != "" && !seen[k] {
+			seen[k] = true
+			keywords = append(keywords, k)
+		}
+	}
+
+	for _, tag := range tags {
+		if !strings.Contains(tag, "|") {
+			addKeyword(tag)
+			continue
+		}
+		hierarchical = append(hierarchical, tag)
+		for _, level := range strings.Split(tag, "|") {
+			addKeyword(level)
+		}
+	}
+	return keywords, hierarchical
+}
+
+// existingTags returns the values of the given list fields already present in fm
+func existingTags(fm exiftool.FileMetadata, fields ...string) []string {
+	var tags []string
+	for _, field := range fields {
+		values, err := fm.GetStrings(field)
+		if err != nil {
+			continue
+		}
+		tags = append(tags, values...)
+	}
+	return tags
+}
+
+// mergeTags returns the union of existing and added, in order, without duplicates
+func mergeTags(existing, added []string) []string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, tags := range [][]string{existing, added} {
+		for _, tag := range tags {
+			tag = strings.TrimSpace(tag)
+			if tag != "" && !seen[tag] {
+				seen[tag] = true
+				merged = append(merged, tag)
+			}
+		}
+	}
+	return merged
+}
+
+// CameraTruncated reports whether the camera part of the destination filename
+// was shortened to fit the filename length limit
+func (ir *ImageRename) CameraTruncated() bool {
+	return ir.cameraTruncated
+}
+
+// Replaces reports whether Perform will replace a different file already at
+// the destination (config.Overwrite)
+func (ir *ImageRename) Replaces() bool {
+	return ir.replaces
+}
+
+// Backup returns where Perform kept the file it replaced, or "" if none
+func (ir *ImageRename) Backup() string {
+	return ir.backup
+}
+
+// Transferred reports whether Perform copied or moved the file to its
+// destination (and Undo hasn't reversed it since)
+func (ir *ImageRename) Transferred() bool {
+	return ir.transferred
+}
+
+// TransferredSidecar reports whether Perform copied or moved sidecar, such as
+// a paired Live Photo video, next to the destination
+func (ir *ImageRename) TransferredSidecar(sidecar string) bool {
+	_, ok := ir.sidecars[sidecar]
+	return ok
+}
+
 // GetDestination returns the destination path after ParseMetadata
 func (ir *ImageRename) GetDestination() string {
 	return ir.destination
@@ -293,6 +1186,56 @@ func (ir *ImageRename) IsDuplicate() bool {
 	return ir.isDuplicate
 }
 
+// GetDateTime returns the (adjusted) datetime found by ParseMetadata
+func (ir *ImageRename) GetDateTime() *time.Time {
+	return ir.datetime
+}
+
+// GetDateSource returns which fallback tier produced the datetime
+func (ir *ImageRename) GetDateSource() config.DateSource {
+	return ir.dateSource
+}
+
+// ParseError returns the error ExifTool reported for a source it couldn't
+// parse, or "". ParseMetadata then dated it from the filename or filesystem.
+func (ir *ImageRename) ParseError() string {
+	return ir.parseError
+}
+
+// GetMake returns the camera make found by ParseMetadata
+func (ir *ImageRename) GetMake() string {
+	return ir.make
+}
+
+// GetModel returns the camera model found by ParseMetadata
+func (ir *ImageRename) GetModel() string {
+	return ir.model
+}
+
+// GetRawMetadata returns every tag ExifTool extracted from the source, keyed
+// by tag name (e.g. "FNumber", "ISO"). Populated by ParseMetadata; nil before.
+//
+// The map is shared, not copied, and must not be modified.
+func (ir *ImageRename) GetRawMetadata() map[string]interface{} {
+	return ir.rawMetadata
+}
+
+// SourceHash returns the SHA256 hash of the source file.
+//
+// The hash computed during collision resolution is reused when available;
+// otherwise it is calculated on first call. Call before Perform in move mode,
+// as the source no longer exists afterwards.
+func (ir *ImageRename) SourceHash() (string, error) {
+	if ir.sourceHash == "" {
+		hash, err := ir.duplicateDetector.CalculateSHA256(ir.source)
+		if err != nil {
+			return "", err
+		}
+		ir.sourceHash = hash
+	}
+	return ir.sourceHash, nil
+}
+
 // IsValidExtension checks if the given extension is supported
 func IsValidExtension(ext string) bool {
 	extLower := strings.ToLower(ext)
@@ -304,6 +1247,21 @@ func IsValidExtension(ext string) bool {
 	return false
 }
 
+// trueExtension returns the extension for the file type ExifTool detected
+// when ext doesn't match it (e.g. "png" for a PNG named .jpg), or "" when it
+// matches or the type isn't in fileTypeExtensions
+func trueExtension(rawMetadata map[string]interface{}, ext string) string {
+	fileType, _ := rawMetadata["File:FileType"].(string)
+	if fileType == "" {
+		fileType, _ = rawMetadata["FileType"].(string)
+	}
+	extensions, ok := fileTypeExtensions[strings.ToUpper(fileType)]
+	if !ok || slices.Contains(extensions, strings.ToLower(ext)) {
+		return ""
+	}
+	return extensions[0]
+}
+
 // IsRaw checks if the given extension is a RAW format
 func IsRaw(ext string) bool {
 	extLower := strings.ToLower(ext)
@@ -315,65 +1273,192 @@ func IsRaw(ext string) bool {
 	return false
 }
 
-// CalculateTimeDelta parses a time adjustment string in "HH:MM:SS" format
+// CalculateTimeDelta parses a time adjustment string in "HH:MM:SS" format.
+//
+// A single leading "-" (or "+") applies to the whole duration, so "-00:30:00"
+// is minus thirty minutes. Each component must be a non-negative integer;
+// signs anywhere else (e.g. "01:-30:00") are rejected, as is a total past
+// the roughly 2.5 million hours a time.Duration holds.
 func CalculateTimeDelta(timeDelta string) (time.Duration, error) {
-	parts := strings.Split(timeDelta, ":")
-	if len(parts) != 3 {
-		return 0, fmt.Errorf("invalid time format, expected HH:MM:SS")
+	negate := false
+	value := timeDelta
+	if strings.HasPrefix(value, "-") {
+		negate = true
+		value = value[1:]
+	} else if strings.HasPrefix(value, "+") {
+		value = value[1:]
 	}
 
-	var hours, minutes, seconds int
-	var negate bool
-
-	// Check for negative sign on hours
-	if strings.HasPrefix(parts[0], "-") {
-		negate = true
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time format, expected HH:MM:SS")
 	}
 
-	// Parse hours (will be negative if prefixed with -)
-	if _, err := fmt.Sscanf(parts[0], "%d", &hours); err != nil {
+	hours, err := parseTimeComponent(parts[0])
+	if err != nil {
 		return 0, fmt.Errorf("invalid hours: %w", err)
 	}
-	if _, err := fmt.Sscanf(parts[1], "%d", &minutes); err != nil {
+	minutes, err := parseTimeComponent(parts[1])
+	if err != nil {
 		return 0, fmt.Errorf("invalid minutes: %w", err)
 	}
-	if _, err := fmt.Sscanf(parts[2], "%d", &seconds); err != nil {
+	seconds, err := parseTimeComponent(parts[2])
+	if err != nil {
 		return 0, fmt.Errorf("invalid seconds: %w", err)
 	}
 
-	// Apply negation to minutes and seconds if hours were negative
-	if negate {
-		minutes = -minutes
-		seconds = -seconds
+	total := float64(hours)*3600 + float64(minutes)*60 + float64(seconds)
+	if total >= maxTimeDelta {
+		return 0, fmt.Errorf("time delta out of range: %s", timeDelta)
 	}
 
 	duration := time.Duration(hours)*time.Hour +
 		time.Duration(minutes)*time.Minute +
 		time.Duration(seconds)*time.Second
 
+	if negate {
+		duration = -duration
+	}
+
 	return duration, nil
 }
 
-// CalculateDayDelta parses a day adjustment string
+// maxTimeDelta is the number of seconds past which a time.Duration overflows
+var maxTimeDelta = float64(math.MaxInt64) / float64(time.Second)
+
+// parseTimeComponent parses a single unsigned HH, MM, or SS component
+func parseTimeComponent(component string) (int, error) {
+	if component == "" {
+		return 0, fmt.Errorf("empty value")
+	}
+	for _, r := range component {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("%q is not a non-negative integer", component)
+		}
+	}
+	return strconv.Atoi(component)
+}
+
+// maxDayDelta is the number of days past which a time.Duration overflows
+var maxDayDelta = float64(math.MaxInt64) / float64(24*time.Hour)
+
+// CalculateDayDelta parses a day adjustment string.
+//
+// Accepts whole or fractional days (e.g. "1", "-7", "1.5", "-0.25"), up to
+// the roughly 292 years a time.Duration holds either way.
 func CalculateDayDelta(dayDelta string) (time.Duration, error) {
-	var days int
-	if _, err := fmt.Sscanf(dayDelta, "%d", &days); err != nil {
+	days, err := strconv.ParseFloat(strings.TrimSpace(dayDelta), 64)
+	if err != nil {
 		return 0, fmt.Errorf("invalid day delta: %w", err)
 	}
-	return time.Duration(days) * 24 * time.Hour, nil
+	if math.IsNaN(days) || math.IsInf(days, 0) {
+		return 0, fmt.Errorf("invalid day delta: %s", dayDelta)
+	}
+	if math.Abs(days) >= maxDayDelta {
+		return 0, fmt.Errorf("day delta out of range: %s (at most %d days)", dayDelta, int(maxDayDelta))
+	}
+	return time.Duration(days * 24 * float64(time.Hour)), nil
 }
 
-// SafeCopy copies a file atomically using a temporary file
-func SafeCopy(src, dst string) error {
-	// Read source file
-	data, err := os.ReadFile(src)
+// SafeCopy copies a file atomically using a temporary file.
+//
+// The copy is streamed and checks ctx between chunks; if ctx is canceled the
+// temp file is removed and the returned error wraps ctx.Err().
+func SafeCopy(ctx context.Context, src, dst string) error {
+	srcFile, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to read source file: %w", err)
 	}
+	defer srcFile.Close()
+
+	srcInfo, err := srcFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat source file: %w", err)
+	}
+
+	return writeAtomic(ctx, srcFile, srcInfo.Mode(), dst)
+}
+
+// durableKey is the context key set by WithDurable
+type durableKey struct{}
+
+// WithDurable returns a context under which SafeCopy, and SafeMove across
+// filesystems, fsync the copy and its directory before returning, so a move
+// doesn't delete its source until the copy would survive a power failure.
+// This is slower, especially on spinning disks and network shares.
+func WithDurable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, durableKey{}, true)
+}
+
+// isDurable reports whether ctx came from WithDurable
+func isDurable(ctx context.Context) bool {
+	durable, _ := ctx.Value(durableKey{}).(bool)
+	return durable
+}
+
+// verifyKey is the context key set by WithVerify
+type verifyKey struct{}
+
+// WithVerify returns a context under which SafeCopy, and SafeMove across
+// filesystems, read each copy back and compare its SHA256 with that of the
+// bytes read from the source before renaming it into place. On a mismatch the
+// copy is removed and an error wrapping ErrCopyMismatch returned, so a move
+// keeps its source. A rename within one filesystem copies nothing to verify.
+func WithVerify(ctx context.Context) context.Context {
+	return context.WithValue(ctx, verifyKey{}, true)
+}
+
+// isVerified reports whether ctx came from WithVerify
+func isVerified(ctx context.Context) bool {
+	verify, _ := ctx.Value(verifyKey{}).(bool)
+	return verify
+}
+
+// modeKey is the context key set by WithMode
+type modeKey struct{}
+
+// WithMode returns a context under which SafeCopy and SafeMove give the
+// destination mode's permission bits instead of keeping the source's, e.g. to
+// normalize the 0777 of files from FAT32 cards
+func WithMode(ctx context.Context, mode os.FileMode) context.Context {
+	return context.WithValue(ctx, modeKey{}, mode.Perm())
+}
+
+// fileMode returns the mode set with WithMode, if any
+func fileMode(ctx context.Context) (os.FileMode, bool) {
+	mode, ok := ctx.Value(modeKey{}).(os.FileMode)
+	return mode, ok
+}
+
+// tempWriter returns the writer a copy is streamed into; tests replace it
+// to corrupt copies
+var tempWriter = func(f *os.File) io.Writer { return f }
+
+// chmod sets a renamed file's mode; tests replace it to fail
+var chmod = os.Chmod
+
+// syncDir fsyncs a directory so entries renamed into it are on disk. Windows
+// can't sync directories and doesn't need to, so it's a no-op there.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
 
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// writeAtomic streams r into a temp file next to dst, then renames it into
+// place. Under WithDurable the data and the rename are fsynced, and under
+// WithVerify the temp file is checked against what was read from r first.
+func writeAtomic(ctx context.Context, r io.Reader, mode os.FileMode, dst string) (err error) {
 	// Create temp file in destination directory
 	destDir := filepath.Dir(dst)
-	tmpFile, err := os.CreateTemp(destDir, ".tmp-*")
+	tmpFile, err := os.CreateTemp(destDir, TempFilePrefix+"*")
 	if err != nil {
 		return fmt.Errorf("failed to create temp file: %w", err)
 	}
@@ -386,21 +1471,41 @@ func SafeCopy(src, dst string) error {
 		}
 	}()
 
-	// Write data to temp file
-	if _, err = tmpFile.Write(data); err != nil {
+	// Write data to temp file, hashing what is read when it will be verified
+	sourceHash := sha256.New()
+	if isVerified(ctx) {
+		r = io.TeeReader(r, sourceHash)
+	}
+	if _, err = io.Copy(tempWriter(tmpFile), &contextReader{ctx: ctx, r: r}); err != nil {
 		tmpFile.Close()
 		return fmt.Errorf("failed to write temp file: %w", err)
 	}
+	if isDurable(ctx) {
+		if err = tmpFile.Sync(); err != nil {
+			tmpFile.Close()
+			return fmt.Errorf("failed to sync temp file: %w", err)
+		}
+	}
 	if err = tmpFile.Close(); err != nil {
 		return fmt.Errorf("failed to close temp file: %w", err)
 	}
 
-	// Copy file permissions
-	srcInfo, err := os.Stat(src)
-	if err != nil {
-		return fmt.Errorf("failed to stat source file: %w", err)
+	if isVerified(ctx) {
+		var copyHash string
+		if copyHash, err = duplicate.FileSHA256(tmpPath); err != nil {
+			return fmt.Errorf("failed to verify copy: %w", err)
+		}
+		if copyHash != fmt.Sprintf("%x", sourceHash.Sum(nil)) {
+			err = fmt.Errorf("%w: %s", ErrCopyMismatch, dst)
+			return err
+		}
 	}
-	if err = os.Chmod(tmpPath, srcInfo.Mode()); err != nil {
+
+	// Copy file permissions, unless set with WithMode
+	if m, ok := fileMode(ctx); ok {
+		mode = m
+	}
+	if err = os.Chmod(tmpPath, mode); err != nil {
 		return fmt.Errorf("failed to set permissions: %w", err)
 	}
 
@@ -409,34 +1514,85 @@ func SafeCopy(src, dst string) error {
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
 
+	if isDurable(ctx) {
+		if err := syncDir(destDir); err != nil {
+			return fmt.Errorf("failed to sync directory %s: %w", destDir, err)
+		}
+	}
+
 	return nil
 }
 
-// SafeMove moves a file atomically, handling cross-filesystem moves
-func SafeMove(src, dst string) error {
+// contextReader fails reads with ctx.Err() once ctx is canceled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// SafeMove moves a file atomically, handling cross-filesystem moves.
+// Apart from WithMode, ctx only matters for the copy fallback across filesystems.
+//
+// When the source can't be renamed or deleted (e.g. a read-only card), the
+// copy is kept and an error wrapping ErrSourceNotRemoved is returned. A
+// renamed file whose mode can't be set stays in place, with an error
+// wrapping ErrModeNotSet.
+func SafeMove(ctx context.Context, src, dst string) error {
 	// Try atomic rename first
 	err := os.Rename(src, dst)
 	if err == nil {
+		if mode, ok := fileMode(ctx); ok {
+			if err := chmod(dst, mode); err != nil {
+				return fmt.Errorf("%w: %s: %v", ErrModeNotSet, dst, err)
+			}
+		}
 		return nil
 	}
 
-	// Check if it's a cross-filesystem error
-	if linkErr, ok := err.(*os.LinkError); ok {
-		if errno, ok := linkErr.Err.(syscall.Errno); ok && errno == syscall.EXDEV {
-			// Cross-filesystem move: copy then delete
-			if err := SafeCopy(src, dst); err != nil {
-				return err
-			}
-			if err := os.Remove(src); err != nil {
-				return fmt.Errorf("failed to remove source after copy: %w", err)
-			}
-			return nil
+	// Cross-filesystem, or a source we may not rename (read-only card): copy then delete
+	var errno syscall.Errno
+	if errors.As(err, &errno) && (errno == syscall.EXDEV || errno == syscall.EROFS || errno == syscall.EACCES || errno == syscall.EPERM) {
+		if err := SafeCopy(ctx, src, dst); err != nil {
+			return err
+		}
+		if err := removeSource(src); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrSourceNotRemoved, src, err)
 		}
+		return nil
 	}
 
 	return fmt.Errorf("failed to move file: %w", err)
 }
 
+// removeSource deletes a moved file's source, making it writable first if
+// that's what stands in the way (read-only files can't be deleted on Windows)
+func removeSource(src string) error {
+	err := os.Remove(src)
+	if err == nil || !os.IsPermission(err) {
+		return err
+	}
+
+	info, statErr := os.Stat(src)
+	if statErr != nil || info.Mode().Perm()&0200 != 0 {
+		return err
+	}
+	if os.Chmod(src, info.Mode().Perm()|0200) != nil {
+		return err
+	}
+	if err := os.Remove(src); err != nil {
+		// Leave the source as we found it
+		os.Chmod(src, info.Mode().Perm())
+		return err
+	}
+	return nil
+}
+
 // generateUUID creates a simple UUID for temporary files
 func generateUUID() string {
 	b := make([]byte, 16)
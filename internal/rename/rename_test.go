@@ -1,12 +1,24 @@
 package rename
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/barasher/go-exiftool"
+	"github.com/cacack/sortpics-go/internal/duplicate"
+	"github.com/cacack/sortpics-go/internal/exifpool"
 	"github.com/cacack/sortpics-go/pkg/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -23,6 +35,10 @@ func TestCalculateTimeDelta(t *testing.T) {
 		{"1 hour", "01:00:00", 1 * time.Hour},
 		{"negative 3 hours 5 seconds", "-03:00:05", -3*time.Hour - 5*time.Second},
 		{"complex time", "01:02:03", 1*time.Hour + 2*time.Minute + 3*time.Second},
+		{"negative zero hours with minutes", "-00:30:00", -30 * time.Minute},
+		{"negative zero hours with seconds", "-00:00:30", -30 * time.Second},
+		{"single digits", "-0:5:5", -5*time.Minute - 5*time.Second},
+		{"explicit positive sign", "+03:30:00", 3*time.Hour + 30*time.Minute},
 	}
 
 	for _, tt := range tests {
@@ -43,6 +59,8 @@ func TestCalculateDayDelta(t *testing.T) {
 		{"1 day", "1", 24 * time.Hour},
 		{"5 days", "5", 5 * 24 * time.Hour},
 		{"negative 3 days", "-3", -3 * 24 * time.Hour},
+		{"one and a half days", "1.5", 36 * time.Hour},
+		{"negative quarter day", "-0.25", -6 * time.Hour},
 	}
 
 	for _, tt := range tests {
@@ -130,6 +148,48 @@ func TestRawPathRouting(t *testing.T) {
 	assert.Equal(t, absRawPath, ir.destinationBase)
 }
 
+func TestInRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.jpg")
+	require.NoError(t, os.WriteFile(testFile, []byte("test"), 0644))
+
+	pool := exifpool.New(1)
+	defer pool.Close()
+	run := NewRun(pool)
+
+	ir, err := NewImageRename(testFile, tmpDir, nil, InRun(run))
+	require.NoError(t, err)
+	defer ir.Close()
+	assert.Same(t, run, ir.run)
+	assert.Same(t, pool, ir.run.pool())
+
+	var none *Run
+	assert.Nil(t, none.pool())
+}
+
+// TestPerformStripGPSFailure tests that a copy whose location may not have
+// been stripped is removed again
+func TestPerformStripGPSFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	destDir := filepath.Join(tmpDir, "dest")
+	source := filepath.Join(tmpDir, "20240115-123045.jpg")
+	require.NoError(t, os.WriteFile(source, []byte("photo"), 0644))
+
+	pool := exifpool.New(1)
+	ir, err := NewImageRename(source, destDir, &config.ProcessingConfig{Precision: 6, StripGPS: true}, InRun(NewRun(pool)))
+	require.NoError(t, err)
+	defer ir.Close()
+	require.NoError(t, ir.ParseMetadata(context.Background()))
+
+	// No ExifTool to strip the location with
+	require.NoError(t, pool.Close())
+	err = ir.Perform(context.Background())
+	require.ErrorIs(t, err, exifpool.ErrClosed)
+	assert.False(t, ir.Transferred())
+	assert.NoFileExists(t, ir.GetDestination())
+	assert.FileExists(t, source)
+}
+
 func TestAlbumFromDirectory(t *testing.T) {
 	tmpDir := t.TempDir()
 	albumDir := filepath.Join(tmpDir, "Summer2023")
@@ -165,6 +225,37 @@ func TestAlbumExplicit(t *testing.T) {
 	assert.Equal(t, "Vacation", ir.album)
 }
 
+func TestAlbumTemplate(t *testing.T) {
+	sourceFile := filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg")
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{year}-{month}", "2024-01"},
+		{"{make} {year}/{month}/{day}", "Canon 2024/01/15"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			cfg := &config.ProcessingConfig{
+				Precision:     6,
+				Album:         "Vacation",
+				AlbumTemplate: tt.template,
+				DryRun:        true,
+			}
+			ir, err := NewImageRename(sourceFile, t.TempDir(), cfg)
+			require.NoError(t, err)
+			defer ir.Close()
+
+			// The static album applies until metadata is parsed
+			assert.Equal(t, "Vacation", ir.album)
+
+			require.NoError(t, ir.ParseMetadata(context.Background()))
+			assert.Equal(t, tt.want, ir.album)
+		})
+	}
+}
+
 func TestTimeAdjust(t *testing.T) {
 	tmpDir := t.TempDir()
 	testFile := filepath.Join(tmpDir, "test.jpg")
@@ -211,7 +302,7 @@ func TestSafeCopy(t *testing.T) {
 	require.NoError(t, os.MkdirAll(destDir, 0755))
 	dest := filepath.Join(destDir, "destination.txt")
 
-	err := SafeCopy(src, dest)
+	err := SafeCopy(context.Background(), src, dest)
 	require.NoError(t, err)
 
 	// Check destination exists
@@ -226,6 +317,132 @@ func TestSafeCopy(t *testing.T) {
 	assert.FileExists(t, src)
 }
 
+func TestSafeCopyDurable(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	src := filepath.Join(tmpDir, "source.jpg")
+	require.NoError(t, os.WriteFile(src, []byte("test content"), 0644))
+	dest := filepath.Join(tmpDir, "dest", "destination.jpg")
+	require.NoError(t, os.MkdirAll(filepath.Dir(dest), 0755))
+
+	ctx := WithDurable(context.Background())
+	assert.True(t, isDurable(ctx))
+	assert.False(t, isDurable(context.Background()))
+	require.NoError(t, SafeCopy(ctx, src, dest))
+
+	content, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "test content", string(content))
+
+	entries, err := os.ReadDir(filepath.Dir(dest))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temp file left behind")
+}
+
+// corruptWriter flips the first byte written through it, like a flaky mount
+type corruptWriter struct {
+	w       io.Writer
+	flipped bool
+}
+
+func (c *corruptWriter) Write(p []byte) (int, error) {
+	if !c.flipped && len(p) > 0 {
+		c.flipped = true
+		bad := slices.Clone(p)
+		bad[0] ^= 0xff
+		return c.w.Write(bad)
+	}
+	return c.w.Write(p)
+}
+
+func TestSafeCopyVerify(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	src := filepath.Join(tmpDir, "source.jpg")
+	require.NoError(t, os.WriteFile(src, []byte("test content"), 0644))
+	dest := filepath.Join(tmpDir, "dest", "destination.jpg")
+	require.NoError(t, os.MkdirAll(filepath.Dir(dest), 0755))
+
+	ctx := WithVerify(context.Background())
+	assert.True(t, isVerified(ctx))
+	assert.False(t, isVerified(context.Background()))
+	require.NoError(t, SafeCopy(ctx, src, dest))
+	content, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "test content", string(content))
+
+	defer func(orig func(*os.File) io.Writer) { tempWriter = orig }(tempWriter)
+	tempWriter = func(f *os.File) io.Writer { return &corruptWriter{w: f} }
+
+	// Unverified, the corruption goes unnoticed
+	other := filepath.Join(tmpDir, "dest", "other.jpg")
+	require.NoError(t, SafeCopy(context.Background(), src, other))
+	content, err = os.ReadFile(other)
+	require.NoError(t, err)
+	assert.NotEqual(t, "test content", string(content))
+	require.NoError(t, os.Remove(other))
+
+	// Verified, the bad copy never replaces the destination
+	err = SafeCopy(ctx, src, dest)
+	assert.ErrorIs(t, err, ErrCopyMismatch)
+	content, err = os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "test content", string(content))
+
+	entries, err := os.ReadDir(filepath.Dir(dest))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temp file left behind")
+}
+
+// slowReader yields one chunk, cancels, then keeps yielding data like a slow disk
+type slowReader struct {
+	chunks int
+	cancel context.CancelFunc
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	r.chunks++
+	if r.chunks == 2 {
+		r.cancel()
+	}
+	if r.chunks > 100 {
+		return 0, io.EOF
+	}
+	return copy(p, bytes.Repeat([]byte("x"), 1024)), nil
+}
+
+func TestSafeCopyCanceled(t *testing.T) {
+	tmpDir := t.TempDir()
+	dest := filepath.Join(tmpDir, "destination.jpg")
+
+	t.Run("mid-copy", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		reader := &slowReader{cancel: cancel}
+		err := writeAtomic(ctx, reader, 0644, dest)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, reader.chunks, 100, "copy should stop once canceled")
+	})
+
+	t.Run("before copy", func(t *testing.T) {
+		src := filepath.Join(tmpDir, "source.jpg")
+		require.NoError(t, os.WriteFile(src, []byte("test content"), 0644))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.ErrorIs(t, SafeCopy(ctx, src, dest), context.Canceled)
+	})
+
+	// Neither the destination nor a temp file is left behind
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.False(t, IsTempFile(entry.Name()), "temp file left behind: %s", entry.Name())
+	}
+	assert.NoFileExists(t, dest)
+}
+
 func TestSafeMoveSameFilesystem(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -234,7 +451,7 @@ func TestSafeMoveSameFilesystem(t *testing.T) {
 
 	dest := filepath.Join(tmpDir, "destination.txt")
 
-	err := SafeMove(src, dest)
+	err := SafeMove(context.Background(), src, dest)
 	require.NoError(t, err)
 
 	// Check destination exists
@@ -263,7 +480,7 @@ func TestSafeMoveCrossFilesystem(t *testing.T) {
 	// that SafeMove works correctly via the copy+delete fallback
 	// by using SafeCopy directly and then removing the source
 
-	err := SafeCopy(src, dest)
+	err := SafeCopy(context.Background(), src, dest)
 	require.NoError(t, err)
 
 	err = os.Remove(src)
@@ -285,6 +502,28 @@ func TestIsValidExtensionFunction(t *testing.T) {
 	assert.True(t, IsValidExtension("cr2"))
 	assert.False(t, IsValidExtension("txt"))
 	assert.False(t, IsValidExtension("doc"))
+
+	// 360-camera and GoPro RAW formats
+	assert.True(t, IsValidExtension("insp"))
+	assert.True(t, IsValidExtension("INSV"))
+	assert.True(t, IsValidExtension("gpr"))
+
+	// AVCHD camcorder video
+	assert.True(t, IsValidExtension("mts"))
+	assert.True(t, IsValidExtension("MTS"))
+	assert.True(t, IsValidExtension("m2ts"))
+	assert.True(t, IsValidExtension("M2TS"))
+	assert.False(t, IsRaw("mts"))
+
+	// Apple HEIC stills
+	assert.True(t, IsValidExtension("HEIC"))
+	assert.True(t, IsValidExtension("heif"))
+
+	// Audio formats
+	assert.True(t, IsValidExtension("m4a"))
+	assert.True(t, IsValidExtension("WAV"))
+	assert.True(t, IsValidExtension("mp3"))
+	assert.False(t, IsRaw("m4a"))
 }
 
 func TestIsRawFunction(t *testing.T) {
@@ -294,6 +533,11 @@ func TestIsRawFunction(t *testing.T) {
 	assert.True(t, IsRaw("dng"))
 	assert.False(t, IsRaw("jpg"))
 	assert.False(t, IsRaw("png"))
+
+	assert.True(t, IsRaw("gpr"))
+	assert.True(t, IsRaw("GPR"))
+	assert.False(t, IsRaw("insp"))
+	assert.False(t, IsRaw("insv"))
 }
 
 // Mock test to verify cross-filesystem error handling
@@ -363,7 +607,7 @@ func TestSafeCopySourceNotExists(t *testing.T) {
 	src := filepath.Join(tmpDir, "nonexistent.txt")
 	dest := filepath.Join(tmpDir, "destination.txt")
 
-	err := SafeCopy(src, dest)
+	err := SafeCopy(context.Background(), src, dest)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to read source file")
 }
@@ -377,7 +621,7 @@ func TestSafeCopyDestDirNotExists(t *testing.T) {
 
 	dest := filepath.Join(tmpDir, "nonexistent", "destination.txt")
 
-	err := SafeCopy(src, dest)
+	err := SafeCopy(context.Background(), src, dest)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to create temp file")
 }
@@ -393,7 +637,7 @@ func TestSafeCopyPermissions(t *testing.T) {
 	require.NoError(t, os.MkdirAll(destDir, 0755))
 	dest := filepath.Join(destDir, "destination.txt")
 
-	err := SafeCopy(src, dest)
+	err := SafeCopy(context.Background(), src, dest)
 	require.NoError(t, err)
 
 	srcInfo, err := os.Stat(src)
@@ -405,6 +649,30 @@ func TestSafeCopyPermissions(t *testing.T) {
 	assert.Equal(t, srcInfo.Mode(), destInfo.Mode())
 }
 
+func TestSafeCopyWithMode(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// As found on a FAT32 card
+	src := filepath.Join(tmpDir, "source.jpg")
+	require.NoError(t, os.WriteFile(src, []byte("test content"), 0644))
+	require.NoError(t, os.Chmod(src, 0777))
+
+	ctx := WithMode(context.Background(), 0640)
+
+	copied := filepath.Join(tmpDir, "copied.jpg")
+	require.NoError(t, SafeCopy(ctx, src, copied))
+	info, err := os.Stat(copied)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	// A move renamed within the filesystem gets the mode too
+	moved := filepath.Join(tmpDir, "moved.jpg")
+	require.NoError(t, SafeMove(ctx, src, moved))
+	info, err = os.Stat(moved)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
 // TestSafeMoveSourceNotExists tests SafeMove with non-existent source
 func TestSafeMoveSourceNotExists(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -412,7 +680,7 @@ func TestSafeMoveSourceNotExists(t *testing.T) {
 	src := filepath.Join(tmpDir, "nonexistent.txt")
 	dest := filepath.Join(tmpDir, "destination.txt")
 
-	err := SafeMove(src, dest)
+	err := SafeMove(context.Background(), src, dest)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to move file")
 }
@@ -426,11 +694,200 @@ func TestSafeMoveDestDirNotExists(t *testing.T) {
 
 	dest := filepath.Join(tmpDir, "nonexistent", "destination.txt")
 
-	err := SafeMove(src, dest)
+	err := SafeMove(context.Background(), src, dest)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to move file")
 }
 
+// TestSafeMoveModeFailure tests that a file renamed into place but left with
+// the wrong mode is reported as moved, so Perform can still undo it
+func TestSafeMoveModeFailure(t *testing.T) {
+	defer func(orig func(string, os.FileMode) error) { chmod = orig }(chmod)
+	chmod = func(string, os.FileMode) error { return os.ErrPermission }
+
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "source.jpg")
+	require.NoError(t, os.WriteFile(src, []byte("test content"), 0644))
+
+	dest := filepath.Join(tmpDir, "destination.jpg")
+	err := SafeMove(WithMode(context.Background(), 0600), src, dest)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrModeNotSet)
+	assert.FileExists(t, dest)
+	assert.NoFileExists(t, src)
+
+	// Perform marks the file transferred, and Undo moves it back
+	require.NoError(t, os.Rename(dest, src))
+	ir, err := NewImageRename(src, filepath.Join(tmpDir, "dest"), &config.ProcessingConfig{Precision: 6, Move: true, NoMetadataWrite: true})
+	require.NoError(t, err)
+	defer ir.Close()
+	require.NoError(t, ir.ParseMetadata(context.Background()))
+
+	err = ir.Perform(WithMode(context.Background(), 0600))
+	require.ErrorIs(t, err, ErrModeNotSet)
+	assert.True(t, ir.Transferred())
+	assert.FileExists(t, ir.GetDestination())
+	require.NoError(t, ir.Undo())
+	assert.FileExists(t, src)
+	assert.NoFileExists(t, ir.GetDestination())
+}
+
+// TestSafeMoveReadOnlySource tests moving off a read-only card: the copy is
+// kept and the undeletable source is reported rather than failing the move
+func TestSafeMoveReadOnlySource(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("directory permissions are not enforced for this user")
+	}
+
+	tmpDir := t.TempDir()
+	cardDir := filepath.Join(tmpDir, "card")
+	require.NoError(t, os.MkdirAll(cardDir, 0755))
+	src := filepath.Join(cardDir, "IMG_0001.JPG")
+	require.NoError(t, os.WriteFile(src, []byte("test content"), 0444))
+	require.NoError(t, os.Chmod(cardDir, 0555))
+	t.Cleanup(func() { os.Chmod(cardDir, 0755) })
+
+	dest := filepath.Join(tmpDir, "destination.jpg")
+	err := SafeMove(context.Background(), src, dest)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrSourceNotRemoved)
+
+	content, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "test content", string(content))
+	assert.FileExists(t, src, "source is left in place")
+
+	info, err := os.Stat(src)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0444), info.Mode().Perm(), "source permissions are restored")
+}
+
+// TestGetRawMetadata tests that extracted EXIF tags are exposed after ParseMetadata
+func TestGetRawMetadata(t *testing.T) {
+	sourceFile := filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg")
+
+	ir, err := NewImageRename(sourceFile, t.TempDir(), &config.ProcessingConfig{Precision: 6, DryRun: true})
+	require.NoError(t, err)
+	defer ir.Close()
+
+	assert.Nil(t, ir.GetRawMetadata())
+
+	require.NoError(t, ir.ParseMetadata(context.Background()))
+
+	raw := ir.GetRawMetadata()
+	require.NotEmpty(t, raw)
+	assert.Equal(t, "Canon", raw["Make"])
+	assert.Contains(t, raw, "DateTimeOriginal")
+}
+
+// TestPlanDestination tests planning a destination without touching disk
+func TestPlanDestination(t *testing.T) {
+	sourceFile := filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg")
+	destBase := filepath.Join(t.TempDir(), "archive")
+
+	// An existing file at the planned path must not change the plan
+	planned := filepath.Join(destBase, "2024", "01", "2024-01-15", "20240115-123045.123456_Canon-Eos5d.jpg")
+	require.NoError(t, os.MkdirAll(filepath.Dir(planned), 0755))
+	require.NoError(t, os.WriteFile(planned, []byte("different content"), 0644))
+
+	for i := 0; i < 2; i++ {
+		destination, meta, err := PlanDestination(sourceFile, destBase, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, planned, destination)
+		require.NotNil(t, meta)
+		assert.Equal(t, "Canon", meta.Make)
+		assert.Equal(t, "Eos5d", meta.Model)
+	}
+
+	content, err := os.ReadFile(planned)
+	require.NoError(t, err)
+	assert.Equal(t, "different content", string(content))
+	_, err = os.Stat(planned + "_original")
+	assert.True(t, os.IsNotExist(err), "no tags written")
+}
+
+func TestIsGPSTag(t *testing.T) {
+	for _, name := range []string{"GPSLatitude", "GPSPosition", "GPS:GPSAltitude", "XMP:GPSLongitude", "GPSCoordinates"} {
+		assert.True(t, isGPSTag(name), name)
+	}
+	for _, name := range []string{"Make", "EXIF:DateTimeOriginal", "XMP:Album", "LocationGPS"} {
+		assert.False(t, isGPSTag(name), name)
+	}
+}
+
+func TestSourcePrefix(t *testing.T) {
+	card := filepath.Join(string(filepath.Separator), "media", "card")
+	prefixes := map[string]string{
+		card:                        "card1",
+		filepath.Join(card, "DCIM"): "dcim",
+	}
+
+	assert.Equal(t, "card1", sourcePrefix(filepath.Join(card, "IMG_0001.JPG"), prefixes))
+	assert.Equal(t, "card1", sourcePrefix(filepath.Join(card, "MISC", "IMG_0001.JPG"), prefixes))
+	// The innermost source wins
+	assert.Equal(t, "dcim", sourcePrefix(filepath.Join(card, "DCIM", "100CANON", "IMG_0001.JPG"), prefixes))
+	// A sibling sharing the name isn't inside the source
+	assert.Equal(t, "", sourcePrefix(filepath.Join(card+"2", "IMG_0001.JPG"), prefixes))
+	assert.Equal(t, "", sourcePrefix(filepath.Join(card, "IMG_0001.JPG"), nil))
+}
+
+func TestSourceSubdir(t *testing.T) {
+	card := filepath.Join(string(filepath.Separator), "media", "card")
+	dirs := []string{card}
+
+	assert.Equal(t, filepath.Join("DCIM", "100CANON"), sourceSubdir(filepath.Join(card, "DCIM", "100CANON", "IMG_0001.JPG"), dirs))
+	assert.Equal(t, "", sourceSubdir(filepath.Join(card, "IMG_0001.JPG"), dirs))
+	assert.Equal(t, "", sourceSubdir(filepath.Join(card+"2", "DCIM", "IMG_0001.JPG"), dirs))
+
+	// Relative to the innermost source
+	dirs = append(dirs, filepath.Join(card, "DCIM"))
+	assert.Equal(t, "100CANON", sourceSubdir(filepath.Join(card, "DCIM", "100CANON", "IMG_0001.JPG"), dirs))
+}
+
+func TestIsOrganized(t *testing.T) {
+	destBase := t.TempDir()
+	dateDir := filepath.Join(destBase, "2024", "01", "2024-01-15")
+	cfg := &config.ProcessingConfig{Precision: 6}
+
+	assert.True(t, IsOrganized(filepath.Join(dateDir, "20240115-123045.123456_Canon-Eos5d.jpg"), destBase, cfg))
+	assert.True(t, IsOrganized(filepath.Join(dateDir, "20240115-123045.123456_Canon-Eos5d_1.jpg"), destBase, cfg))
+
+	// Right name, wrong directory
+	assert.False(t, IsOrganized(filepath.Join(destBase, "2024", "01", "2024-01-16", "20240115-123045.123456_Canon-Eos5d.jpg"), destBase, cfg))
+	assert.False(t, IsOrganized(filepath.Join(t.TempDir(), "20240115-123045.123456_Canon-Eos5d.jpg"), destBase, cfg))
+
+	// Right directory, non-canonical name
+	assert.False(t, IsOrganized(filepath.Join(dateDir, "IMG_1234.jpg"), destBase, cfg))
+
+	// RAW files belong under --raw-path when set
+	rawBase := t.TempDir()
+	rawCfg := &config.ProcessingConfig{Precision: 6, RawPath: rawBase}
+	assert.False(t, IsOrganized(filepath.Join(dateDir, "20240115-123045.123456_Canon-Eos5d.cr2"), destBase, rawCfg))
+	assert.True(t, IsOrganized(filepath.Join(rawBase, "2024", "01", "2024-01-15", "20240115-123045.123456_Canon-Eos5d.cr2"), destBase, rawCfg))
+
+	// The rating directory can't be judged without metadata
+	ratingCfg := &config.ProcessingConfig{Precision: 6, RatingDirs: true}
+	assert.False(t, IsOrganized(filepath.Join(destBase, "rating-5", "2024", "01", "2024-01-15", "20240115-123045.123456_Canon-Eos5d.jpg"), destBase, ratingCfg))
+
+	// In place, only the name matters
+	inPlaceCfg := &config.ProcessingConfig{Precision: 6, InPlace: true}
+	assert.True(t, IsOrganized(filepath.Join(t.TempDir(), "20240115-123045.123456_Canon-Eos5d.jpg"), "", inPlaceCfg))
+}
+
+// TestPlanDestinationKeepOriginalName tests that the source stem reaches the filename
+func TestPlanDestinationKeepOriginalName(t *testing.T) {
+	sourceFile := filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg")
+	destBase := t.TempDir()
+
+	cfg := &config.ProcessingConfig{Precision: 6, KeepOriginalName: true}
+	destination, meta, err := PlanDestination(sourceFile, destBase, cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "test_001", meta.OriginalName)
+	assert.Equal(t, "20240115-123045.123456_Canon-Eos5d_test_001.jpg", filepath.Base(destination))
+}
+
 // TestPerformDryRun tests that Perform doesn't actually move/copy in dry-run mode
 func TestPerformDryRun(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -450,11 +907,11 @@ func TestPerformDryRun(t *testing.T) {
 	defer ir.Close()
 
 	// Parse metadata to set destination
-	err = ir.ParseMetadata()
+	err = ir.ParseMetadata(context.Background())
 	require.NoError(t, err)
 
 	// Perform should succeed but not create destination
-	err = ir.Perform()
+	err = ir.Perform(context.Background())
 	require.NoError(t, err)
 
 	// Destination should not exist
@@ -479,11 +936,11 @@ func TestPerformCopy(t *testing.T) {
 	defer ir.Close()
 
 	// Parse metadata to set destination
-	err = ir.ParseMetadata()
+	err = ir.ParseMetadata(context.Background())
 	require.NoError(t, err)
 
 	// Perform copy
-	err = ir.Perform()
+	err = ir.Perform(context.Background())
 	require.NoError(t, err)
 
 	// Verify destination exists
@@ -498,6 +955,81 @@ func TestPerformCopy(t *testing.T) {
 	assert.Equal(t, "test content", string(content))
 }
 
+// TestQuarantineNoDate tests that files without a metadata date land in no-date/
+func TestQuarantineNoDate(t *testing.T) {
+	tmpDir := t.TempDir()
+	destDir := filepath.Join(tmpDir, "dest")
+
+	testFile := filepath.Join(tmpDir, "IMG_0001.JPG")
+	require.NoError(t, os.WriteFile(testFile, []byte("no exif here"), 0644))
+
+	cfg := &config.ProcessingConfig{
+		Precision:        6,
+		QuarantineNoDate: true,
+	}
+
+	ir, err := NewImageRename(testFile, destDir, cfg)
+	require.NoError(t, err)
+	defer ir.Close()
+
+	require.NoError(t, ir.ParseMetadata(context.Background()))
+	assert.Equal(t, config.DateSourceCtime, ir.GetDateSource())
+
+	expected := filepath.Join(destDir, "no-date", "IMG_0001.JPG")
+	assert.Equal(t, expected, ir.GetDestination())
+
+	require.NoError(t, ir.Perform(context.Background()))
+	assert.FileExists(t, expected)
+}
+
+// TestPerformWriteChecksums tests that the sidecar holds the destination's SHA256
+func TestPerformWriteChecksums(t *testing.T) {
+	for _, noMetadataWrite := range []bool{false, true} {
+		t.Run(fmt.Sprintf("noMetadataWrite=%v", noMetadataWrite), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			destDir := filepath.Join(tmpDir, "dest")
+
+			testFile := filepath.Join(tmpDir, "test.jpg")
+			require.NoError(t, os.WriteFile(testFile, []byte("test content"), 0644))
+
+			cfg := &config.ProcessingConfig{
+				Precision:       6,
+				WriteChecksums:  true,
+				NoMetadataWrite: noMetadataWrite,
+			}
+
+			ir, err := NewImageRename(testFile, destDir, cfg)
+			require.NoError(t, err)
+			defer ir.Close()
+
+			require.NoError(t, ir.ParseMetadata(context.Background()))
+			require.NoError(t, ir.Perform(context.Background()))
+
+			sidecar, err := os.ReadFile(ir.destination + ChecksumExt)
+			require.NoError(t, err)
+
+			hash, err := duplicate.FileSHA256(ir.destination)
+			require.NoError(t, err)
+			assert.Equal(t, hash+"  "+filepath.Base(ir.destination)+"\n", string(sidecar))
+		})
+	}
+
+	t.Run("dry run writes no sidecar", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		testFile := filepath.Join(tmpDir, "test.jpg")
+		require.NoError(t, os.WriteFile(testFile, []byte("test content"), 0644))
+
+		cfg := &config.ProcessingConfig{Precision: 6, WriteChecksums: true, DryRun: true}
+		ir, err := NewImageRename(testFile, filepath.Join(tmpDir, "dest"), cfg)
+		require.NoError(t, err)
+		defer ir.Close()
+
+		require.NoError(t, ir.ParseMetadata(context.Background()))
+		require.NoError(t, ir.Perform(context.Background()))
+		assert.NoFileExists(t, ir.destination+ChecksumExt)
+	})
+}
+
 // TestPerformMove tests the Perform method with move operation
 func TestPerformMove(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -516,11 +1048,11 @@ func TestPerformMove(t *testing.T) {
 	defer ir.Close()
 
 	// Parse metadata to set destination
-	err = ir.ParseMetadata()
+	err = ir.ParseMetadata(context.Background())
 	require.NoError(t, err)
 
 	// Perform move
-	err = ir.Perform()
+	err = ir.Perform(context.Background())
 	require.NoError(t, err)
 
 	// Verify destination exists
@@ -535,6 +1067,372 @@ func TestPerformMove(t *testing.T) {
 	assert.Equal(t, "test content", string(content))
 }
 
+// TestPerformSidecars tests that a DJI video's telemetry travels with it
+func TestPerformDurable(t *testing.T) {
+	tmpDir := t.TempDir()
+	source := filepath.Join(tmpDir, "source", "20240115-123045.jpg")
+	require.NoError(t, os.MkdirAll(filepath.Dir(source), 0755))
+	require.NoError(t, os.WriteFile(source, []byte("durable"), 0644))
+
+	for _, move := range []bool{false, true} {
+		destDir := filepath.Join(tmpDir, fmt.Sprintf("dest-%t", move))
+		ir, err := NewImageRename(source, destDir, &config.ProcessingConfig{Precision: 6, NoMetadataWrite: true, Durable: true, Move: move})
+		require.NoError(t, err)
+		defer ir.Close()
+		require.NoError(t, ir.ParseMetadata(context.Background()))
+		require.NoError(t, ir.Perform(context.Background()))
+
+		content, err := os.ReadFile(ir.GetDestination())
+		require.NoError(t, err)
+		assert.Equal(t, "durable", string(content))
+	}
+	assert.NoFileExists(t, source, "moved on the second pass")
+}
+
+func TestFixExtension(t *testing.T) {
+	tmpDir := t.TempDir()
+	source := filepath.Join(tmpDir, "20240115-123045.jpg")
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))))
+	require.NoError(t, os.WriteFile(source, buf.Bytes(), 0644))
+
+	for _, fix := range []bool{false, true} {
+		ir, err := NewImageRename(source, filepath.Join(tmpDir, "dest"), &config.ProcessingConfig{Precision: 6, FixExtension: fix})
+		require.NoError(t, err)
+		defer ir.Close()
+		require.NoError(t, ir.ParseMetadata(context.Background()))
+
+		want := ".jpg"
+		if fix {
+			want = ".png"
+		}
+		assert.Equal(t, want, filepath.Ext(ir.GetDestination()), "FixExtension=%v", fix)
+	}
+}
+
+func TestTrueExtension(t *testing.T) {
+	jpeg := map[string]interface{}{"File:FileType": "JPEG"}
+	assert.Equal(t, "", trueExtension(jpeg, "jpg"))
+	assert.Equal(t, "", trueExtension(jpeg, "JPEG"))
+	assert.Equal(t, "", trueExtension(jpeg, "insp"))
+	assert.Equal(t, "jpg", trueExtension(jpeg, "png"))
+	assert.Equal(t, "heic", trueExtension(map[string]interface{}{"FileType": "HEIC"}, "jpg"))
+	assert.Equal(t, "", trueExtension(map[string]interface{}{"File:FileType": "NEF"}, "jpg"), "only still image types are corrected")
+	assert.Equal(t, "", trueExtension(map[string]interface{}{}, "jpg"))
+}
+
+func TestDedupSiblings(t *testing.T) {
+	tmpDir := t.TempDir()
+	source := filepath.Join(tmpDir, "source", "20240115-123045.jpg")
+	require.NoError(t, os.MkdirAll(filepath.Dir(source), 0755))
+	require.NoError(t, os.WriteFile(source, []byte("same shot"), 0644))
+	cfg := &config.ProcessingConfig{Precision: 0, NoMetadataWrite: true, DedupSiblings: true}
+
+	// Found while planning: the same second under another camera name
+	destDir := filepath.Join(tmpDir, "dest")
+	dayDir := filepath.Join(destDir, "2024", "01", "2024-01-15")
+	require.NoError(t, os.MkdirAll(dayDir, 0755))
+	sibling := filepath.Join(dayDir, "20240115-123045_Canon-Eos5d.jpg")
+	require.NoError(t, os.WriteFile(sibling, []byte("same shot"), 0644))
+
+	ir, err := NewImageRename(source, destDir, cfg)
+	require.NoError(t, err)
+	defer ir.Close()
+	require.NoError(t, ir.ParseMetadata(context.Background()))
+	assert.True(t, ir.IsDuplicate())
+	assert.Equal(t, sibling, ir.GetDestination())
+
+	// Written by someone else between planning and Perform
+	destDir = filepath.Join(tmpDir, "dest2")
+	ir, err = NewImageRename(source, destDir, cfg)
+	require.NoError(t, err)
+	defer ir.Close()
+	require.NoError(t, ir.ParseMetadata(context.Background()))
+	require.False(t, ir.IsDuplicate())
+	planned := ir.GetDestination()
+
+	sibling = filepath.Join(filepath.Dir(planned), "20240115-123045_1.jpg")
+	require.NoError(t, os.MkdirAll(filepath.Dir(sibling), 0755))
+	require.NoError(t, os.WriteFile(sibling, []byte("same shot"), 0644))
+	require.NoError(t, ir.Perform(context.Background()))
+	assert.True(t, ir.IsDuplicate())
+	assert.Equal(t, sibling, ir.GetDestination())
+	assert.NoFileExists(t, planned)
+
+	// Different content is never merged
+	require.NoError(t, os.WriteFile(sibling, []byte("another shot"), 0644))
+	ir, err = NewImageRename(source, destDir, cfg)
+	require.NoError(t, err)
+	defer ir.Close()
+	require.NoError(t, ir.ParseMetadata(context.Background()))
+	assert.False(t, ir.IsDuplicate())
+	assert.Equal(t, planned, ir.GetDestination())
+}
+
+func TestPerformSidecars(t *testing.T) {
+	for _, move := range []bool{false, true} {
+		t.Run(fmt.Sprintf("move=%v", move), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			destDir := filepath.Join(tmpDir, "dest")
+
+			video := filepath.Join(tmpDir, "DJI_0001.MP4")
+			telemetry := filepath.Join(tmpDir, "DJI_0001.SRT")
+			require.NoError(t, os.WriteFile(video, []byte("video content"), 0644))
+			require.NoError(t, os.WriteFile(telemetry, []byte("1\n00:00:00,000 --> 00:00:00,033\n"), 0644))
+
+			cfg := &config.ProcessingConfig{
+				Precision:       6,
+				Move:            move,
+				NoMetadataWrite: true,
+			}
+
+			ir, err := NewImageRename(video, destDir, cfg)
+			require.NoError(t, err)
+			defer ir.Close()
+
+			require.NoError(t, ir.ParseMetadata(context.Background()))
+			require.NoError(t, ir.Perform(context.Background()))
+
+			destination := ir.GetDestination()
+			require.True(t, strings.HasSuffix(destination, ".mp4"))
+			sidecar := strings.TrimSuffix(destination, ".mp4") + ".srt"
+
+			content, err := os.ReadFile(sidecar)
+			require.NoError(t, err)
+			assert.Equal(t, "1\n00:00:00,000 --> 00:00:00,033\n", string(content))
+
+			if move {
+				assert.NoFileExists(t, telemetry)
+			} else {
+				assert.FileExists(t, telemetry)
+			}
+		})
+	}
+}
+
+// TestPerformSidecarCollision tests that a taken sidecar name fails the file
+// before it is moved
+func TestPerformSidecarCollision(t *testing.T) {
+	tmpDir := t.TempDir()
+	destDir := filepath.Join(tmpDir, "dest")
+
+	video := filepath.Join(tmpDir, "DJI_0001.MP4")
+	telemetry := filepath.Join(tmpDir, "DJI_0001.SRT")
+	require.NoError(t, os.WriteFile(video, []byte("video content"), 0644))
+	require.NoError(t, os.WriteFile(telemetry, []byte("telemetry"), 0644))
+
+	ir, err := NewImageRename(video, destDir, &config.ProcessingConfig{Precision: 6, Move: true, NoMetadataWrite: true})
+	require.NoError(t, err)
+	defer ir.Close()
+	require.NoError(t, ir.ParseMetadata(context.Background()))
+
+	// A stray track from another video already has the name
+	destination := ir.GetDestination()
+	stray := strings.TrimSuffix(destination, ".mp4") + ".srt"
+	require.NoError(t, os.MkdirAll(filepath.Dir(stray), 0755))
+	require.NoError(t, os.WriteFile(stray, []byte("other"), 0644))
+
+	err = ir.Perform(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already exists")
+	assert.False(t, ir.Transferred())
+	assert.NoFileExists(t, destination)
+	assert.FileExists(t, video)
+	assert.FileExists(t, telemetry)
+}
+
+// TestPerformUndo tests that Undo removes a copy and moves a moved file back,
+// sidecar and checksum included
+func TestPerformUndo(t *testing.T) {
+	for _, move := range []bool{false, true} {
+		t.Run(fmt.Sprintf("move=%v", move), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			destDir := filepath.Join(tmpDir, "dest")
+
+			video := filepath.Join(tmpDir, "DJI_0001.MP4")
+			telemetry := filepath.Join(tmpDir, "DJI_0001.SRT")
+			require.NoError(t, os.WriteFile(video, []byte("video content"), 0644))
+			require.NoError(t, os.WriteFile(telemetry, []byte("telemetry"), 0644))
+
+			cfg := &config.ProcessingConfig{
+				Precision:       6,
+				Move:            move,
+				NoMetadataWrite: true,
+				WriteChecksums:  true,
+			}
+
+			ir, err := NewImageRename(video, destDir, cfg)
+			require.NoError(t, err)
+			defer ir.Close()
+
+			// Nothing transferred yet
+			require.NoError(t, ir.ParseMetadata(context.Background()))
+			require.NoError(t, ir.Undo())
+
+			require.NoError(t, ir.Perform(context.Background()))
+			destination := ir.GetDestination()
+			require.FileExists(t, destination)
+
+			require.NoError(t, ir.Undo())
+			assert.NoFileExists(t, destination)
+			assert.NoFileExists(t, destination+ChecksumExt)
+			assert.NoFileExists(t, strings.TrimSuffix(destination, ".mp4")+".srt")
+
+			content, err := os.ReadFile(video)
+			require.NoError(t, err)
+			assert.Equal(t, "video content", string(content))
+			content, err = os.ReadFile(telemetry)
+			require.NoError(t, err)
+			assert.Equal(t, "telemetry", string(content))
+		})
+	}
+}
+
+// TestPerformOverwrite tests replacing a different file at the destination
+func TestPerformOverwrite(t *testing.T) {
+	tmpDir := t.TempDir()
+	destDir := filepath.Join(tmpDir, "dest")
+
+	// The same name on three cards, so all three map to one destination
+	perform := func(card, content string, cfg *config.ProcessingConfig) *ImageRename {
+		source := filepath.Join(tmpDir, card, "20240115-123045.jpg")
+		require.NoError(t, os.MkdirAll(filepath.Dir(source), 0755))
+		require.NoError(t, os.WriteFile(source, []byte(content), 0644))
+
+		ir, err := NewImageRename(source, destDir, cfg)
+		require.NoError(t, err)
+		t.Cleanup(func() { ir.Close() })
+		require.NoError(t, ir.ParseMetadata(context.Background()))
+		require.NoError(t, ir.Perform(context.Background()))
+		return ir
+	}
+
+	first := perform("a", "first", &config.ProcessingConfig{Precision: 6, NoMetadataWrite: true})
+	destination := first.GetDestination()
+	assert.False(t, first.Replaces())
+
+	second := perform("b", "second", &config.ProcessingConfig{Precision: 6, NoMetadataWrite: true, Overwrite: true})
+	assert.Equal(t, destination, second.GetDestination(), "no _N suffix")
+	assert.True(t, second.Replaces())
+	assert.Empty(t, second.Backup())
+	content, err := os.ReadFile(destination)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(content))
+	assert.NoFileExists(t, destination+BackupExt)
+
+	// With Backup, the replaced file is kept and Undo puts it back
+	third := perform("c", "third", &config.ProcessingConfig{Precision: 6, NoMetadataWrite: true, Overwrite: true, Backup: true})
+	assert.Equal(t, destination+BackupExt, third.Backup())
+	content, err = os.ReadFile(destination)
+	require.NoError(t, err)
+	assert.Equal(t, "third", string(content))
+	content, err = os.ReadFile(destination + BackupExt)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(content))
+
+	require.NoError(t, third.Undo())
+	content, err = os.ReadFile(destination)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(content))
+	assert.NoFileExists(t, destination+BackupExt)
+
+	// Identical content is still a duplicate, not a replacement
+	duplicate := perform("d", "second", &config.ProcessingConfig{Precision: 6, NoMetadataWrite: true, Overwrite: true, Backup: true})
+	assert.True(t, duplicate.IsDuplicate())
+	assert.False(t, duplicate.Replaces())
+	assert.NoFileExists(t, destination+BackupExt)
+}
+
+// TestPerformOverwriteRun tests that Overwrite only replaces files that were
+// there before the run, and backs up replaced sidecars
+func TestPerformOverwriteRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	destDir := filepath.Join(tmpDir, "dest")
+	cfg := &config.ProcessingConfig{Precision: 6, NoMetadataWrite: true, Overwrite: true, Backup: true}
+	perform := func(card, content string, run *Run) *ImageRename {
+		source := filepath.Join(tmpDir, card, "20240115-123045.mp4")
+		require.NoError(t, os.MkdirAll(filepath.Dir(source), 0755))
+		require.NoError(t, os.WriteFile(source, []byte(content), 0644))
+		require.NoError(t, os.WriteFile(strings.TrimSuffix(source, ".mp4")+".srt", []byte(content+" track"), 0644))
+
+		ir, err := NewImageRename(source, destDir, cfg, InRun(run))
+		require.NoError(t, err)
+		t.Cleanup(func() { ir.Close() })
+		require.NoError(t, ir.ParseMetadata(context.Background()))
+		require.NoError(t, ir.Perform(context.Background()))
+		return ir
+	}
+
+	// An earlier run left a video and its track at the destination
+	destination := perform("earlier", "earlier", NewRun(nil)).GetDestination()
+	track := strings.TrimSuffix(destination, ".mp4") + ".srt"
+
+	// The first file of this run replaces it, keeping both in backups
+	run := NewRun(nil)
+	first := perform("a", "first", run)
+	assert.Equal(t, destination, first.GetDestination())
+	assert.True(t, first.Replaces())
+	content, err := os.ReadFile(track)
+	require.NoError(t, err)
+	assert.Equal(t, "first track", string(content))
+	content, err = os.ReadFile(track + BackupExt)
+	require.NoError(t, err)
+	assert.Equal(t, "earlier track", string(content))
+
+	// The second doesn't replace the first
+	second := perform("b", "second", run)
+	assert.Equal(t, strings.TrimSuffix(destination, ".mp4")+"_1.mp4", second.GetDestination())
+	assert.False(t, second.Replaces())
+	content, err = os.ReadFile(destination)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(content))
+
+	// Undo puts the replaced track back
+	require.NoError(t, first.Undo())
+	content, err = os.ReadFile(track)
+	require.NoError(t, err)
+	assert.Equal(t, "earlier track", string(content))
+	assert.NoFileExists(t, track+BackupExt)
+}
+
+// TestPerformOverwriteBackupDir tests keeping replaced files in a backup directory
+func TestPerformOverwriteBackupDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	destDir := filepath.Join(tmpDir, "dest")
+	backupDir := filepath.Join(tmpDir, "replaced")
+	cfg := &config.ProcessingConfig{Precision: 6, NoMetadataWrite: true, Overwrite: true, BackupDir: backupDir}
+
+	var destination string
+	for i, content := range []string{"first", "second", "third"} {
+		source := filepath.Join(tmpDir, fmt.Sprintf("card%d", i), "20240115-123045.jpg")
+		require.NoError(t, os.MkdirAll(filepath.Dir(source), 0755))
+		require.NoError(t, os.WriteFile(source, []byte(content), 0644))
+
+		ir, err := NewImageRename(source, destDir, cfg)
+		require.NoError(t, err)
+		defer ir.Close()
+		require.NoError(t, ir.ParseMetadata(context.Background()))
+		require.NoError(t, ir.Perform(context.Background()))
+		destination = ir.GetDestination()
+	}
+
+	content, err := os.ReadFile(destination)
+	require.NoError(t, err)
+	assert.Equal(t, "third", string(content))
+	assert.NoFileExists(t, destination+BackupExt)
+
+	// Each replaced version is under the backup directory at the same relative path
+	rel, err := filepath.Rel(destDir, destination)
+	require.NoError(t, err)
+	backup := filepath.Join(backupDir, rel)
+	content, err = os.ReadFile(backup)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(content))
+	content, err = os.ReadFile(strings.TrimSuffix(backup, ".jpg") + ".1.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(content))
+}
+
 // TestPerformRaceConditionCollision tests the race condition recheck logic
 func TestPerformRaceConditionCollision(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -553,7 +1451,7 @@ func TestPerformRaceConditionCollision(t *testing.T) {
 	defer ir.Close()
 
 	// Parse metadata to set destination
-	err = ir.ParseMetadata()
+	err = ir.ParseMetadata(context.Background())
 	require.NoError(t, err)
 
 	// Simulate race condition: another process created the file first
@@ -562,7 +1460,7 @@ func TestPerformRaceConditionCollision(t *testing.T) {
 	require.NoError(t, os.WriteFile(ir.destination, []byte("different content"), 0644))
 
 	// Perform should handle collision and create a renamed version
-	err = ir.Perform()
+	err = ir.Perform(context.Background())
 	require.NoError(t, err)
 
 	// The file should have been successfully copied
@@ -570,6 +1468,48 @@ func TestPerformRaceConditionCollision(t *testing.T) {
 	assert.FileExists(t, ir.destination)
 }
 
+// TestIsTempFile tests matching of SafeCopy temporary filenames
+func TestIsTempFile(t *testing.T) {
+	assert.True(t, IsTempFile(".tmp-123456789"))
+	assert.False(t, IsTempFile(".tmp-"))
+	assert.True(t, IsTempFile(".tmp-abc"))
+	assert.False(t, IsTempFile(".tmp-notes.txt"))
+	assert.False(t, IsTempFile("photo.tmp-123"))
+	assert.False(t, IsTempFile("20240115-123045.123456_Canon-EOS5d.jpg"))
+
+	// Names from the real temp-file generator must match
+	tmpFile, err := os.CreateTemp(t.TempDir(), TempFilePrefix+"*")
+	require.NoError(t, err)
+	tmpFile.Close()
+	assert.True(t, IsTempFile(filepath.Base(tmpFile.Name())))
+}
+
+func TestSplitTags(t *testing.T) {
+	keywords, hierarchical := splitTags([]string{"family", "Places|France|Paris", "Places|France|Lyon", "family"})
+	assert.Equal(t, []string{"family", "Places", "France", "Paris", "Lyon"}, keywords)
+	assert.Equal(t, []string{"Places|France|Paris", "Places|France|Lyon"}, hierarchical)
+
+	// Simple tags pass through unchanged with no hierarchical subjects
+	keywords, hierarchical = splitTags([]string{"test", "integration"})
+	assert.Equal(t, []string{"test", "integration"}, keywords)
+	assert.Empty(t, hierarchical)
+}
+
+func TestMergeTags(t *testing.T) {
+	fm := exiftool.FileMetadata{Fields: map[string]interface{}{
+		"Keywords": []interface{}{"beach", "family"},
+		"Subject":  "sunset",
+	}}
+	existing := existingTags(fm, "Keywords", "Subject", "HierarchicalSubject")
+	assert.Equal(t, []string{"beach", "family", "sunset"}, existing)
+
+	merged := mergeTags(existing, []string{"family", "vacation"})
+	assert.Equal(t, []string{"beach", "family", "sunset", "vacation"}, merged)
+
+	// No existing keywords leaves the new ones as-is
+	assert.Equal(t, []string{"vacation"}, mergeTags(nil, []string{"vacation"}))
+}
+
 // TestCalculateTimeDeltaErrors tests error handling for invalid time formats
 func TestCalculateTimeDeltaErrors(t *testing.T) {
 	tests := []struct {
@@ -581,6 +1521,12 @@ func TestCalculateTimeDeltaErrors(t *testing.T) {
 		{"invalid hours", "XX:00:00"},
 		{"invalid minutes", "00:XX:00"},
 		{"invalid seconds", "00:00:XX"},
+		{"sign on minutes", "01:-30:00"},
+		{"sign on seconds", "01:30:+00"},
+		{"double sign", "--01:00:00"},
+		{"empty component", "01::00"},
+		{"out of range", "3000000:00:00"},
+		{"out of range - negative", "-00:00:9300000000"},
 	}
 
 	for _, tt := range tests {
@@ -599,6 +1545,9 @@ func TestCalculateDayDeltaErrors(t *testing.T) {
 	}{
 		{"invalid format - not a number", "abc"},
 		{"invalid format - empty", ""},
+		{"invalid format - not finite", "NaN"},
+		{"out of range", "200000"},
+		{"out of range - negative", "-106752"},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,70 @@
+package rename
+
+import (
+	"sync"
+
+	"github.com/cacack/sortpics-go/internal/exifpool"
+)
+
+// Run holds what the files of one run share, such as processor.Processor's
+// ExifTool pool and the destinations written so far. A nil *Run leaves each
+// file on its own.
+//
+// Safe for concurrent use.
+type Run struct {
+	exifPool *exifpool.Pool
+
+	mu      sync.Mutex
+	written map[string]bool // Destinations claimed by a file of the run
+}
+
+// NewRun returns a Run whose files borrow ExifTool processes from pool for
+// reading and writing metadata instead of each starting its own. pool may be nil.
+func NewRun(pool *exifpool.Pool) *Run {
+	return &Run{exifPool: pool}
+}
+
+// pool returns the run's ExifTool pool, or nil
+func (r *Run) pool() *exifpool.Pool {
+	if r == nil {
+		return nil
+	}
+	return r.exifPool
+}
+
+// claim records that a file of the run writes path, and reports whether no
+// other file of the run had
+func (r *Run) claim(path string) bool {
+	if r == nil {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.written[path] {
+		return false
+	}
+	if r.written == nil {
+		r.written = make(map[string]bool)
+	}
+	r.written[path] = true
+	return true
+}
+
+// wrote reports whether a file of the run claimed path. Overwrite only
+// replaces files that were there before the run.
+func (r *Run) wrote(path string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.written[path]
+}
+
+// Option configures NewImageRename
+type Option func(*ImageRename)
+
+// InRun makes the file part of run
+func InRun(run *Run) Option {
+	return func(ir *ImageRename) { ir.run = run }
+}
@@ -1,10 +1,31 @@
 package config
 
+import (
+	"os"
+	"time"
+)
+
 // ProcessingConfig holds all configuration options for image processing operations.
 type ProcessingConfig struct {
 	// OldNaming uses legacy filename format without make/model
 	OldNaming bool
 
+	// NameCase controls make/model capitalization in filenames: "camel" (default), "upper", "lower", or "preserve"
+	NameCase string
+
+	// Separator is the delimiter between make and model in filenames (default "-")
+	Separator string
+
+	// ModelMap replaces camera models in filenames, keyed by the model as
+	// written or with the make removed (see metadata.LoadModelMap)
+	ModelMap map[string]string
+
+	// KeepOriginalName appends the source filename stem to generated filenames
+	KeepOriginalName bool
+
+	// RatingDirs files images under rating-N/ (or unrated/, rejected/) directories by XMP:Rating
+	RatingDirs bool
+
 	// RawPath is an optional separate destination directory for RAW files
 	RawPath string
 
@@ -23,6 +44,50 @@ type ProcessingConfig struct {
 	// DayAdjust is a day adjustment string as an integer (can be negative)
 	DayAdjust string
 
+	// WriteChecksums writes a "<dest>.sha256" sidecar with the content hash of each organized file
+	WriteChecksums bool
+
+	// Durable fsyncs each copy and its directory before a move deletes the
+	// source, so no file is lost on a power failure (see rename.WithDurable)
+	Durable bool
+
+	// VerifyCopy reads each copy back and compares its hash with the source's
+	// before it replaces the destination or a move deletes the source (see
+	// rename.WithVerify)
+	VerifyCopy bool
+
+	// FileMode, when non-zero, sets the permissions of destinations instead of
+	// keeping the source's (see rename.WithMode)
+	FileMode os.FileMode
+
+	// Owner, when set, changes the owner of destinations and their sidecars
+	// once they are written. Unix only, and usually needs root.
+	Owner *Owner
+
+	// NoMetadataWrite skips writing EXIF/XMP tags, leaving destinations byte-identical to their sources
+	NoMetadataWrite bool
+
+	// SidecarMetadata writes the datetime, album and keywords of RAW files to
+	// an .xmp sidecar next to the destination instead of into the RAW itself
+	SidecarMetadata bool
+
+	// StripGPS removes GPS tags from destinations (sources are never touched).
+	// Telemetry sidecars, which record the flight path, are left behind.
+	StripGPS bool
+
+	// QuickTimeUTC treats QuickTime dates without an offset as UTC and converts them to local time
+	QuickTimeUTC bool
+
+	// Location is the local time zone UTC dates (GPS, and QuickTime with
+	// QuickTimeUTC) are converted to before naming and day bucketing, e.g.
+	// where the photos were shot when sorting on a UTC server. Nil uses the
+	// system's local time zone.
+	Location *time.Location
+
+	// PreferFilenameDate dates files from their filename before EXIF and
+	// QuickTime, for scans whose metadata holds the scan date
+	PreferFilenameDate bool
+
 	// Tags are keywords to add to image metadata
 	Tags []string
 
@@ -31,4 +96,112 @@ type ProcessingConfig struct {
 
 	// AlbumFromDir extracts the album name from the parent directory
 	AlbumFromDir bool
+
+	// AlbumTemplate derives the album from each file's metadata, e.g. "{year}-{month}".
+	// When set it overrides Album (see pathgen.ExpandTemplate for placeholders).
+	AlbumTemplate string
+
+	// MinSize is the minimum source file size in bytes; smaller files are skipped (0 disables)
+	MinSize int64
+
+	// DerivativePatterns are case-insensitive filename globs (e.g. "IMG_E[0-9][0-9][0-9][0-9]*.*")
+	// for edited derivatives and sidecars to skip; empty processes everything
+	DerivativePatterns []string
+
+	// SkipExistingHashes skips sources whose content already exists anywhere in the destination
+	SkipExistingHashes bool
+
+	// DeleteDuplicateSource removes a source in move mode when identical content
+	// is already in the archive, instead of leaving it in place
+	DeleteDuplicateSource bool
+
+	// SkipOrganized skips sources that already have their canonical name and
+	// directory, judged from the filename before any metadata is read
+	SkipOrganized bool
+
+	// MaxCollisions is the highest _N suffix tried when resolving name collisions (0 uses the default of 1000)
+	MaxCollisions int
+
+	// MaxFilenameLength caps generated filenames, in bytes, by shortening the camera part (0 uses the default of 255)
+	MaxFilenameLength int
+
+	// WindowsSafe avoids names NTFS and SMB shares reject (trailing dots, reserved
+	// device names like CON) and detects collisions case-insensitively
+	WindowsSafe bool
+
+	// UnknownLabel names the directory and filename prefix of files with no
+	// datetime, e.g. "_NoDate" (empty uses the default of "unknown")
+	UnknownLabel string
+
+	// PreserveCompoundExt keeps compound extensions like ".tar.gz" intact when adding _N suffixes
+	PreserveCompoundExt bool
+
+	// HashSuffix resolves name collisions with "_" and the first 8 hex digits of the
+	// file's SHA256 instead of _N, so names don't depend on processing order
+	HashSuffix bool
+
+	// FixExtension names destinations with the extension of the file type
+	// ExifTool detects when the source's doesn't match it (e.g. .png for a
+	// PNG named .jpg)
+	FixExtension bool
+
+	// DedupSiblings also treats a file as a duplicate when a file named for the
+	// same second (differing in subseconds, camera, or _N) next to its
+	// destination has identical content, checked again just before writing
+	DedupSiblings bool
+
+	// Overwrite replaces an existing destination with different content
+	// instead of adding a _N suffix; identical files are still skipped as
+	// duplicates
+	Overwrite bool
+
+	// Backup keeps each file replaced by Overwrite next to it with a ".bak"
+	// extension (see rename.BackupExt)
+	Backup bool
+
+	// BackupDir, when set, keeps each file replaced by Overwrite under this
+	// directory instead, at its path relative to the destination
+	BackupDir string
+
+	// SequenceOrder assigns collision suffixes in source filename sequence order (IMG_0123 before IMG_0124)
+	SequenceOrder bool
+
+	// Deterministic assigns collision suffixes in datetime order, then by
+	// source path, so re-runs over the same files produce the same names
+	Deterministic bool
+
+	// QuarantineNoDate routes files dated only by filesystem time into a "no-date" directory
+	// under the destination, keeping their original names, instead of guessing their date
+	QuarantineNoDate bool
+
+	// InPlace renames files within their source directory instead of filing them under a destination
+	InPlace bool
+
+	// BurstWindow is the maximum gap between consecutive shots of a burst (0 disables grouping)
+	BurstWindow time.Duration
+
+	// BurstGroups maps absolute source paths to their burst subdirectory name.
+	// Computed in a pre-pass over all files when BurstWindow is set.
+	BurstGroups map[string]string
+
+	// KeepLivePhotos keeps each Apple Live Photo's video with its still: the
+	// video is transferred alongside it, renamed to the still's basename, like
+	// a sidecar. Ignored with StripGPS, which would leave the video's location.
+	KeepLivePhotos bool
+
+	// LivePhotos maps the absolute source path of a Live Photo still to its
+	// paired video. Computed in a pre-pass over all files when KeepLivePhotos is set.
+	LivePhotos map[string]string
+
+	// SourcePrefixes maps absolute source directories to a prefix for the top-level
+	// destination directory of the files found in them ("card1" files under card1-2024/)
+	SourcePrefixes map[string]string
+
+	// PreserveTree keeps each file's directory relative to its source directory
+	// under the date directory (DCIM/100CANON/x.jpg goes under YYYY-MM-DD/100CANON/
+	// when DCIM is the source). Needs SourceDirs.
+	PreserveTree bool
+
+	// SourceDirs are the absolute source directories files were collected from
+	SourceDirs []string
 }
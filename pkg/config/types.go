@@ -2,6 +2,21 @@ package config
 
 import "time"
 
+// DateSource identifies which fallback tier produced ImageMetadata.DateTime.
+type DateSource string
+
+// Date sources, in order of priority
+const (
+	DateSourceEXIF      DateSource = "exif"
+	DateSourceQuickTime DateSource = "quicktime"
+	DateSourceFilename  DateSource = "filename"
+	DateSourceGPS       DateSource = "gps"
+	DateSourceCtime     DateSource = "ctime"
+)
+
+// DateSources lists every DateSource in order of priority.
+var DateSources = []DateSource{DateSourceEXIF, DateSourceQuickTime, DateSourceFilename, DateSourceGPS, DateSourceCtime}
+
 // ImageMetadata represents metadata extracted from an image file.
 // This struct is used for generating destination paths and filenames.
 type ImageMetadata struct {
@@ -9,6 +24,11 @@ type ImageMetadata struct {
 	// video metadata, filename pattern, or filesystem ctime (in order of priority).
 	DateTime *time.Time
 
+	// DateSource records which tier DateTime came from. DateSourceCtime means
+	// no date was found in metadata or the filename, so DateTime is only the
+	// filesystem time and may be unreliable.
+	DateSource DateSource
+
 	// Make is the camera manufacturer (e.g., "Canon", "Nikon").
 	// Normalized to be capitalized.
 	Make string
@@ -17,7 +37,37 @@ type ImageMetadata struct {
 	// Normalized with make prefix removed and capitalized.
 	Model string
 
+	// MakeOriginal and ModelOriginal are Make and Model with their EXIF
+	// capitalization kept (e.g., "NIKON", "D850"; "Canon", "EOS5DMarkII").
+	// Used when filenames should preserve the camera's own spelling.
+	MakeOriginal  string
+	ModelOriginal string
+
+	// Lens is the lens model (e.g., "Ef24-105mmF4lIsUsm" for "EF24-105mm f4L IS USM").
+	// Normalized with spaces converted to CamelCase. Empty if not present.
+	Lens string
+
+	// Rating is the star rating from XMP:Rating: 1-5, 0 for unrated, or -1
+	// for rejected. Nil if the file has no rating.
+	Rating *int
+
+	// ParseError is the error ExifTool reported for a file it couldn't parse
+	// (e.g. "File format error" for a corrupt file), or "". The other fields
+	// then come from the filename or the filesystem.
+	ParseError string
+
+	// OriginalName is the source filename without its extension (e.g., "IMG_1234").
+	// Set by the rename pipeline, not by metadata extraction.
+	OriginalName string
+
 	// RawMetadata contains the raw EXIF data as returned by ExifTool.
 	// This is kept for potential future use or debugging.
 	RawMetadata map[string]interface{}
 }
+
+// Owner is the user and group destinations are given with ProcessingConfig.Owner.
+// -1 leaves that ID unchanged, as with os.Chown.
+type Owner struct {
+	UID int
+	GID int
+}
@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cacack/sortpics-go/pkg/config"
+)
+
+// BenchmarkProcessFiles benchmarks the file processing function
+func BenchmarkProcessFiles(b *testing.B) {
+	testDataDir := filepath.Join("..", "..", "test", "testdata", "basic")
+	files, err := collectFiles([]string{testDataDir}, collectOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	cfg := &config.ProcessingConfig{
+		Precision: 6,
+		OldNaming: false,
+		DryRun:    true, // Use dry-run to avoid actual file I/O in benchmark
+	}
+
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tmpDir, err := os.MkdirTemp("", "sortpics-bench-*")
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		_, err = New(tmpDir, cfg, Options{Workers: 8}).Process(ctx, files)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		os.RemoveAll(tmpDir)
+	}
+}
+
+// BenchmarkCollectFiles benchmarks directory walking
+func BenchmarkCollectFiles(b *testing.B) {
+	testDataRoot := filepath.Join("..", "..", "test", "testdata")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := collectFiles([]string{testDataRoot}, collectOptions{Recursive: true})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCollectFilesManySources benchmarks walking several source directories
+func BenchmarkCollectFilesManySources(b *testing.B) {
+	tmpDir := b.TempDir()
+	var sources []string
+	for i := 0; i < 8; i++ {
+		dir := filepath.Join(tmpDir, fmt.Sprintf("card%d", i), "DCIM")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < 200; j++ {
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("IMG_%04d.jpg", j)), nil, 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+		sources = append(sources, filepath.Dir(dir))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := collectFiles(sources, collectOptions{Recursive: true})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkProcessFilesParallel benchmarks with different worker counts
+func BenchmarkProcessFilesParallel(b *testing.B) {
+	testDataDir := filepath.Join("..", "..", "test", "testdata", "basic")
+	files, err := collectFiles([]string{testDataDir}, collectOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	cfg := &config.ProcessingConfig{
+		Precision: 6,
+		OldNaming: false,
+		DryRun:    true,
+	}
+
+	ctx := context.Background()
+
+	for _, workers := range []int{1, 2, 4, 8, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tmpDir, err := os.MkdirTemp("", "sortpics-bench-*")
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				_, err = New(tmpDir, cfg, Options{Workers: workers}).Process(ctx, files)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				os.RemoveAll(tmpDir)
+			}
+		})
+	}
+}
@@ -0,0 +1,561 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/cacack/sortpics-go/internal/rename"
+)
+
+// collectOptions controls how source directories are scanned
+type collectOptions struct {
+	Recursive bool
+
+	// MaxDepth limits recursion to this many directory levels below each
+	// source root. 0 means unlimited (a depth of 0 is just non-recursive).
+	MaxDepth int
+
+	FollowSymlinks bool
+	Verbose        int
+
+	// Exclude holds doublestar globs matched against paths relative to the source root
+	Exclude []string
+
+	// Include, when non-empty, restricts files to those matching at least one glob.
+	// Exclude wins when a path matches both.
+	Include []string
+
+	// ModifiedSince, when non-zero, skips files (and zip archives) last
+	// modified before it
+	ModifiedSince time.Time
+
+	// Zips, when set, extracts supported entries of .zip files found in the
+	// sources. Entries are matched against Exclude/Include as "<zip>/<entry>".
+	Zips *zipExtractor
+
+	// Derivatives holds config.DerivativePatterns. Matching files, such as
+	// .AAE edit sidecars, are collected whatever their extension so they are
+	// counted as skipped.
+	Derivatives []string
+}
+
+// collectFiles walks source directories and collects all supported image/video files
+//
+// Materializes the full list; processing uses streamFiles instead so memory
+// stays bounded on very large libraries.
+func collectFiles(sourceDirs []string, opts collectOptions) ([]string, error) {
+	var files []string
+	err := walkSources(context.Background(), sourceDirs, opts, func(path string) error {
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// countFiles counts supported files without keeping their paths in memory
+func countFiles(sourceDirs []string, opts collectOptions) (int, error) {
+	count := 0
+	err := walkSources(context.Background(), sourceDirs, opts, func(string) error {
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// streamFiles walks source directories in the background and sends each
+// supported file over the returned channel as it is found.
+//
+// The path channel is closed when the walk finishes; the error channel then
+// receives the walk result (nil on success). Canceling ctx stops the walk; a
+// caller that stops reading early must cancel it, or the walk blocks forever.
+func streamFiles(ctx context.Context, sourceDirs []string, opts collectOptions) (<-chan string, <-chan error) {
+	paths := make(chan string, 64)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(paths)
+
+		errc <- walkSources(ctx, sourceDirs, opts, func(path string) error {
+			select {
+			case paths <- path:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	return paths, errc
+}
+
+// listFiles sends a pre-built file list over a channel, matching streamFiles' signature
+func listFiles(files []string) (<-chan string, <-chan error) {
+	paths := make(chan string, len(files))
+	errc := make(chan error, 1)
+	for _, file := range files {
+		paths <- file
+	}
+	close(paths)
+	errc <- nil
+	close(errc)
+	return paths, errc
+}
+
+// maxParallelWalks bounds how many source directories are walked at once
+const maxParallelWalks = 4
+
+// errWalkStopped stops the other walks once one source has failed
+var errWalkStopped = errors.New("walk stopped")
+
+// walkSources walks each source directory and calls emit with the absolute
+// path of every supported file, until ctx is canceled.
+//
+// Duplicate source directories (and, when recursive, sources nested inside
+// another source) are dropped up front so files are emitted at most once
+// without tracking every path seen. Followed symlinks can still reach a file
+// by a second path, so with opts.FollowSymlinks every emitted file is tracked
+// by its resolved path and emitted only once. Several sources, e.g. on slow
+// network mounts, are walked concurrently; emit calls are serialized and
+// still in source order: the earliest unfinished source emits as it walks,
+// while later ones buffer their files until it's done. If walks fail, the
+// error of the first failing source in argument order wins.
+func walkSources(ctx context.Context, sourceDirs []string, opts collectOptions, emit func(string) error) error {
+	roots, err := normalizeSources(sourceDirs, opts.Recursive)
+	if err != nil {
+		return err
+	}
+	if opts.FollowSymlinks {
+		emit = emitOnce(emit)
+	}
+	if len(roots) == 1 {
+		return walkRoot(ctx, roots[0], opts, emit)
+	}
+
+	var (
+		mu      sync.Mutex
+		stopped bool
+		wg      sync.WaitGroup
+
+		// next is the source emitting as it walks; pending buffers the files
+		// of later sources until it's their turn
+		next     int
+		pending  = make([][]string, len(roots))
+		finished = make([]bool, len(roots))
+	)
+	errs := make([]error, len(roots))
+
+	emitFrom := func(i int) func(string) error {
+		return func(path string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if stopped {
+				return errWalkStopped
+			}
+			if i != next {
+				pending[i] = append(pending[i], path)
+				return nil
+			}
+			return emit(path)
+		}
+	}
+
+	// finish records a source's walk and, once the sources before it are
+	// done, flushes the files buffered by the sources after it
+	finish := func(i int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		finished[i] = true
+		if err != nil {
+			errs[i] = err
+			stopped = true
+			return
+		}
+		for !stopped && next < len(roots) && finished[next] {
+			next++
+			if next == len(roots) {
+				break
+			}
+			for _, path := range pending[next] {
+				if err := emit(path); err != nil {
+					errs[next] = err
+					stopped = true
+					break
+				}
+			}
+			pending[next] = nil
+		}
+	}
+
+	slots := make(chan struct{}, maxParallelWalks)
+	for i, root := range roots {
+		slots <- struct{}{}
+		mu.Lock()
+		done := stopped
+		mu.Unlock()
+		if done {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			finish(i, walkRoot(ctx, root, opts, emitFrom(i)))
+		}()
+	}
+	wg.Wait()
+
+	// A walk that stopped because another failed reports errWalkStopped,
+	// possibly wrapped; report the real failure instead
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, errWalkStopped) {
+			return err
+		}
+	}
+	return nil
+}
+
+// emitOnce wraps emit to skip files already emitted under another path,
+// compared with symlinks resolved. Not safe for concurrent use.
+func emitOnce(emit func(string) error) func(string) error {
+	seen := make(map[string]bool)
+	return func(path string) error {
+		realPath, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			realPath = path
+		}
+		if seen[realPath] {
+			return nil
+		}
+		seen[realPath] = true
+		return emit(path)
+	}
+}
+
+// walkRoot walks one source directory and calls emit with the absolute path
+// of every supported file
+func walkRoot(ctx context.Context, sourceDir string, opts collectOptions, emit func(string) error) error {
+
+	// filtered reports whether --exclude/--include rule out a path (rel to its root)
+	filtered := func(path, rel string) bool {
+		if matchesAny(opts.Exclude, rel) {
+			if opts.Verbose > 1 {
+				fmt.Printf("Skipping (excluded): %s\n", path)
+			}
+			return true
+		}
+		if len(opts.Include) > 0 && !matchesAny(opts.Include, rel) {
+			if opts.Verbose > 1 {
+				fmt.Printf("Skipping (not included): %s\n", path)
+			}
+			return true
+		}
+		return false
+	}
+
+	addZip := func(root, path string) error {
+		rel := relativePath(root, path)
+		if matchesAny(opts.Exclude, rel) {
+			if opts.Verbose > 1 {
+				fmt.Printf("Skipping (excluded): %s\n", path)
+			}
+			return nil
+		}
+
+		// A bad archive shouldn't abort the whole import
+		entries, err := opts.Zips.Extract(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping zip: %v\n", err)
+			return nil
+		}
+		for _, entry := range entries {
+			if filtered(path+"/"+entry.Name, rel+"/"+entry.Name) {
+				continue
+			}
+			if err := emit(entry.Path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	addFile := func(root, path string) error {
+		// Check if file has valid extension (extensionless files are skipped)
+		ext := strings.TrimPrefix(filepath.Ext(path), ".")
+		isZip := opts.Zips != nil && strings.EqualFold(ext, "zip")
+		supported := ext != "" && rename.IsValidExtension(ext)
+		if !isZip && !supported && !isDerivative(filepath.Base(path), opts.Derivatives) {
+			return nil
+		}
+		if unmodified(path, opts) {
+			return nil
+		}
+		if isZip {
+			return addZip(root, path)
+		}
+		if filtered(path, relativePath(root, path)) {
+			return nil
+		}
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		return emit(absPath)
+	}
+
+	addRootFile := func(path string) error {
+		return addFile(sourceDir, path)
+	}
+
+	if opts.Recursive {
+		// Track resolved directories so symlink cycles are only walked once
+		visited := make(map[string]bool)
+		if err := walkSource(ctx, sourceDir, sourceDir, opts, visited, nil, addRootFile); err != nil {
+			return fmt.Errorf("failed to walk directory %s: %w", sourceDir, err)
+		}
+		return nil
+	}
+
+	// Non-recursive: only process files directly in the directory
+	ignores, err := loadIgnore(nil, sourceDir, "")
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(sourceDir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory %s: %w", sourceDir, err)
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(sourceDir, entry.Name())
+		if ignoredPath(ignores, sourceDir, path, false, opts) {
+			continue
+		}
+		if err := addRootFile(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// normalizeSources removes duplicate source directories and, when recursive,
+// any source that lies inside another source. Order is otherwise preserved.
+func normalizeSources(sourceDirs []string, recursive bool) ([]string, error) {
+	absDirs := make([]string, 0, len(sourceDirs))
+	for _, dir := range sourceDirs {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		absDirs = append(absDirs, absDir)
+	}
+
+	var roots []string
+	for i, dir := range absDirs {
+		keep := true
+		for j, other := range absDirs {
+			if i == j {
+				continue
+			}
+			if dir == other && j < i {
+				keep = false
+				break
+			}
+			if recursive && dir != other && strings.HasPrefix(dir, other+string(filepath.Separator)) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			roots = append(roots, sourceDirs[i])
+		}
+	}
+
+	return roots, nil
+}
+
+// walkSource recursively walks dir, calling addFile for every non-directory
+// entry, until ctx is canceled.
+//
+// When opts.FollowSymlinks is set, symlinks to directories are descended into.
+// visited holds the resolved real paths of walked directories to guard against cycles.
+// Directories matching opts.Exclude (relative to root) are not descended into.
+// ignores holds the .sortpicsignore rules of dir's ancestors; dir's own file is added.
+func walkSource(ctx context.Context, root, dir string, opts collectOptions, visited map[string]bool, ignores ignoreStack, addFile func(string) error) error {
+	if opts.FollowSymlinks {
+		if realDir, err := resolveDir(dir); err == nil {
+			visited[realDir] = true
+		}
+	}
+
+	ignores, err := loadIgnore(ignores, dir, relativePath(root, dir))
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, entry.Name())
+
+		if entry.IsDir() {
+			if excludedDir(root, path, opts) || ignoredPath(ignores, root, path, true, opts) || tooDeep(root, path, opts) {
+				continue
+			}
+			if err := walkSource(ctx, root, path, opts, visited, ignores, addFile); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if opts.FollowSymlinks && entry.Type()&fs.ModeSymlink != 0 {
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				realDir, err := resolveDir(path)
+				if err != nil {
+					return err
+				}
+				if visited[realDir] {
+					if opts.Verbose > 1 {
+						fmt.Printf("Skipping (symlink cycle): %s\n", path)
+					}
+					continue
+				}
+				if excludedDir(root, path, opts) || ignoredPath(ignores, root, path, true, opts) || tooDeep(root, path, opts) {
+					continue
+				}
+				if err := walkSource(ctx, root, path, opts, visited, ignores, addFile); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if ignoredPath(ignores, root, path, false, opts) {
+			continue
+		}
+		if err := addFile(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ignoredPath reports whether a .sortpicsignore file in effect ignores path
+func ignoredPath(ignores ignoreStack, root, path string, isDir bool, opts collectOptions) bool {
+	if !ignores.Ignored(relativePath(root, path), isDir) {
+		return false
+	}
+	if opts.Verbose > 1 {
+		fmt.Printf("Skipping (%s): %s\n", ignoreFileName, path)
+	}
+	return true
+}
+
+// unmodified reports whether path was last modified before opts.ModifiedSince.
+// A file that can't be stat'ed is kept so processing reports the error.
+func unmodified(path string, opts collectOptions) bool {
+	if opts.ModifiedSince.IsZero() {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.ModTime().Before(opts.ModifiedSince) {
+		return false
+	}
+	if opts.Verbose > 1 {
+		fmt.Printf("Skipping (not modified since last run): %s\n", path)
+	}
+	return true
+}
+
+// excludedDir reports whether a directory matches an exclude pattern
+func excludedDir(root, dir string, opts collectOptions) bool {
+	if !matchesAny(opts.Exclude, relativePath(root, dir)) {
+		return false
+	}
+	if opts.Verbose > 1 {
+		fmt.Printf("Skipping (excluded): %s\n", dir)
+	}
+	return true
+}
+
+// tooDeep reports whether dir lies more than opts.MaxDepth levels below root
+func tooDeep(root, dir string, opts collectOptions) bool {
+	if opts.MaxDepth <= 0 {
+		return false
+	}
+	depth := strings.Count(relativePath(root, dir), "/") + 1
+	if depth <= opts.MaxDepth {
+		return false
+	}
+	if opts.Verbose > 1 {
+		fmt.Printf("Skipping (beyond --max-depth): %s\n", dir)
+	}
+	return true
+}
+
+// resolveDir returns the absolute path of dir with all symlinks resolved
+func resolveDir(dir string) (string, error) {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(realDir)
+}
+
+// matchesAny reports whether rel, a slash-separated path relative to the
+// source root, matches any doublestar glob in patterns.
+//
+// Patterns without a "/" also match the base name at any depth, so "*.LRV"
+// excludes proxies in every subdirectory; "private/**" only matches the
+// top-level private directory.
+func matchesAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(pattern)
+		if ok, _ := doublestar.Match(pattern, rel); ok {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := doublestar.Match(pattern, rel[strings.LastIndex(rel, "/")+1:]); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// relativePath returns path relative to root using forward slashes
+func relativePath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
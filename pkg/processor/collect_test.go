@@ -0,0 +1,297 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectFiles(t *testing.T) {
+	testDataDir := filepath.Join("..", "..", "test", "testdata", "basic")
+
+	t.Run("non-recursive", func(t *testing.T) {
+		files, err := collectFiles([]string{testDataDir}, collectOptions{})
+		require.NoError(t, err)
+		assert.NotEmpty(t, files)
+
+		// All files should be from the basic directory
+		for _, file := range files {
+			assert.Contains(t, file, "basic", "file should be from basic directory")
+		}
+	})
+
+	t.Run("recursive", func(t *testing.T) {
+		testDataRoot := filepath.Join("..", "..", "test", "testdata")
+		files, err := collectFiles([]string{testDataRoot}, collectOptions{Recursive: true})
+		require.NoError(t, err)
+		assert.NotEmpty(t, files)
+
+		// Should find files from multiple subdirectories
+		hasBasic := false
+		hasRaw := false
+		for _, file := range files {
+			if filepath.Dir(file) == filepath.Clean(testDataDir) {
+				hasBasic = true
+			}
+			if filepath.Base(filepath.Dir(file)) == "raw" {
+				hasRaw = true
+			}
+		}
+		assert.True(t, hasBasic || hasRaw, "should find files from subdirectories")
+	})
+
+	t.Run("invalid directory", func(t *testing.T) {
+		_, err := collectFiles([]string{"/nonexistent/directory"}, collectOptions{})
+		assert.Error(t, err)
+	})
+}
+
+func TestCollectFilesFollowSymlinks(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	sourceDir := filepath.Join(tmpDir, "source")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "local.jpg"), []byte("local"), 0644))
+
+	// Directory outside the source tree, reachable only through a symlink
+	linkedDir := filepath.Join(tmpDir, "linked")
+	require.NoError(t, os.MkdirAll(linkedDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(linkedDir, "linked.jpg"), []byte("linked"), 0644))
+	if err := os.Symlink(linkedDir, filepath.Join(sourceDir, "album")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	// Symlink back to the source root to create a cycle
+	require.NoError(t, os.Symlink(sourceDir, filepath.Join(linkedDir, "loop")))
+
+	// Symlink to a sibling directory, walked before the directory itself
+	realDir := filepath.Join(sourceDir, "real")
+	require.NoError(t, os.MkdirAll(realDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(realDir, "real.jpg"), []byte("real"), 0644))
+	require.NoError(t, os.Symlink(realDir, filepath.Join(sourceDir, "alias")))
+
+	t.Run("disabled by default", func(t *testing.T) {
+		files, err := collectFiles([]string{sourceDir}, collectOptions{Recursive: true})
+		require.NoError(t, err)
+		var names []string
+		for _, file := range files {
+			names = append(names, filepath.Base(file))
+		}
+		assert.ElementsMatch(t, []string{"local.jpg", "real.jpg"}, names)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		files, err := collectFiles([]string{sourceDir}, collectOptions{Recursive: true, FollowSymlinks: true})
+		require.NoError(t, err)
+
+		var names []string
+		for _, file := range files {
+			names = append(names, filepath.Base(file))
+		}
+		assert.ElementsMatch(t, []string{"local.jpg", "linked.jpg", "real.jpg"}, names, "each file once")
+	})
+}
+
+func TestStreamFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	subDir := filepath.Join(tmpDir, "sub")
+	require.NoError(t, os.MkdirAll(subDir, 0755))
+	for _, name := range []string{"a.jpg", "b.mov", "notes.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(subDir, "c.nef"), []byte("c"), 0644))
+
+	t.Run("streams supported files", func(t *testing.T) {
+		// Overlapping sources should not produce duplicates
+		paths, errc := streamFiles(context.Background(), []string{tmpDir, subDir, tmpDir}, collectOptions{Recursive: true})
+
+		var names []string
+		for path := range paths {
+			assert.True(t, filepath.IsAbs(path))
+			names = append(names, filepath.Base(path))
+		}
+		require.NoError(t, <-errc)
+		assert.ElementsMatch(t, []string{"a.jpg", "b.mov", "c.nef"}, names)
+
+		total, err := countFiles([]string{tmpDir, subDir, tmpDir}, collectOptions{Recursive: true})
+		require.NoError(t, err)
+		assert.Equal(t, len(names), total)
+	})
+
+	t.Run("stops on cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		paths, errc := streamFiles(ctx, []string{tmpDir}, collectOptions{Recursive: true})
+		for range paths {
+		}
+		assert.ErrorIs(t, <-errc, context.Canceled)
+	})
+
+	t.Run("stops when the reader cancels", func(t *testing.T) {
+		// More files than the channel buffers
+		manyDir := t.TempDir()
+		for i := range 100 {
+			require.NoError(t, os.WriteFile(filepath.Join(manyDir, fmt.Sprintf("%03d.jpg", i)), nil, 0644))
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		paths, errc := streamFiles(ctx, []string{manyDir}, collectOptions{})
+		<-paths
+		cancel()
+
+		// The walk ends without the rest of the paths being read
+		select {
+		case err := <-errc:
+			assert.ErrorIs(t, err, context.Canceled)
+		case <-time.After(5 * time.Second):
+			t.Fatal("walk did not stop")
+		}
+	})
+
+	t.Run("reports walk errors", func(t *testing.T) {
+		paths, errc := streamFiles(context.Background(), []string{"/nonexistent/directory"}, collectOptions{})
+		for range paths {
+		}
+		assert.Error(t, <-errc)
+	})
+}
+
+func TestCollectFilesExclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, rel := range []string{
+		"keep.jpg",
+		"clip.mov",
+		"private/secret.jpg",
+		"private/nested/deeper.jpg",
+		"trip/photo.jpg",
+		"trip/proxy.mov",
+		"trip/private/not-top-level.jpg",
+	} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(rel))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(rel), 0644))
+	}
+
+	files, err := collectFiles([]string{tmpDir}, collectOptions{
+		Recursive: true,
+		Exclude:   []string{"private/**", "*.mov"},
+	})
+	require.NoError(t, err)
+
+	var rels []string
+	for _, file := range files {
+		rels = append(rels, relativePath(tmpDir, file))
+	}
+	assert.ElementsMatch(t, []string{"keep.jpg", "trip/photo.jpg", "trip/private/not-top-level.jpg"}, rels)
+
+	total, err := countFiles([]string{tmpDir}, collectOptions{Recursive: true, Exclude: []string{"private/**", "*.mov"}})
+	require.NoError(t, err)
+	assert.Equal(t, len(files), total)
+}
+
+func TestCollectFilesInclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, rel := range []string{
+		"DCIM/100CANON/IMG_0001.jpg",
+		"DCIM/100CANON/IMG_0002.cr2",
+		"DCIM/100CANON/notes.txt",
+		"DCIM/101CANON/IMG_0100.jpg",
+		"MISC/other.jpg",
+	} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(rel))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(rel), 0644))
+	}
+
+	collect := func(t *testing.T, opts collectOptions) []string {
+		opts.Recursive = true
+		files, err := collectFiles([]string{tmpDir}, opts)
+		require.NoError(t, err)
+
+		var rels []string
+		for _, file := range files {
+			rels = append(rels, relativePath(tmpDir, file))
+		}
+		return rels
+	}
+
+	t.Run("include only", func(t *testing.T) {
+		rels := collect(t, collectOptions{Include: []string{"DCIM/100CANON/*"}})
+		assert.ElementsMatch(t, []string{"DCIM/100CANON/IMG_0001.jpg", "DCIM/100CANON/IMG_0002.cr2"}, rels)
+	})
+
+	t.Run("multiple includes", func(t *testing.T) {
+		rels := collect(t, collectOptions{Include: []string{"DCIM/100CANON/*.jpg", "MISC/**"}})
+		assert.ElementsMatch(t, []string{"DCIM/100CANON/IMG_0001.jpg", "MISC/other.jpg"}, rels)
+	})
+
+	t.Run("exclude wins over include", func(t *testing.T) {
+		rels := collect(t, collectOptions{
+			Include: []string{"DCIM/**"},
+			Exclude: []string{"*.cr2", "DCIM/101CANON/**"},
+		})
+		assert.ElementsMatch(t, []string{"DCIM/100CANON/IMG_0001.jpg"}, rels)
+	})
+}
+
+func TestCollectFilesMaxDepth(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, rel := range []string{
+		"top.jpg",
+		"a/one.jpg",
+		"a/b/two.jpg",
+		"a/b/c/three.jpg",
+	} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(rel))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(rel), 0644))
+	}
+
+	collect := func(opts collectOptions) []string {
+		files, err := collectFiles([]string{tmpDir}, opts)
+		require.NoError(t, err)
+		var rels []string
+		for _, file := range files {
+			rels = append(rels, relativePath(tmpDir, file))
+		}
+		return rels
+	}
+
+	assert.ElementsMatch(t, []string{"top.jpg", "a/one.jpg"}, collect(collectOptions{Recursive: true, MaxDepth: 1}))
+	assert.ElementsMatch(t, []string{"top.jpg", "a/one.jpg", "a/b/two.jpg"}, collect(collectOptions{Recursive: true, MaxDepth: 2}))
+	assert.Len(t, collect(collectOptions{Recursive: true}), 4, "zero MaxDepth is unlimited")
+}
+
+func TestCollectFilesManySources(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var sources, want []string
+	for i := 0; i < 6; i++ {
+		dir := filepath.Join(tmpDir, fmt.Sprintf("card%d", i))
+		for _, rel := range []string{"a.jpg", "sub/b.jpg"} {
+			path := filepath.Join(dir, filepath.FromSlash(rel))
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+			require.NoError(t, os.WriteFile(path, []byte(rel), 0644))
+			want = append(want, path)
+		}
+		sources = append(sources, dir)
+	}
+
+	// A repeated source is still walked once, and sources walked at the
+	// same time still list their files in source order
+	files, err := collectFiles(append(sources, sources[0]), collectOptions{Recursive: true})
+	require.NoError(t, err)
+	assert.Equal(t, want, files)
+
+	// The failing source is reported regardless of walk timing
+	_, err = collectFiles(append(sources, "/nonexistent/card"), collectOptions{Recursive: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/nonexistent/card")
+}
@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"errors"
+	"io/fs"
+	"sync/atomic"
+)
+
+// Error categories for per-file failures, matched with errors.Is
+var (
+	errMetadata = errors.New("metadata error")
+	errIO       = errors.New("I/O error")
+)
+
+// categorizedError tags an error with a category sentinel without changing its message
+type categorizedError struct {
+	category error
+	err      error
+}
+
+func (e *categorizedError) Error() string { return e.err.Error() }
+
+func (e *categorizedError) Unwrap() error { return e.err }
+
+func (e *categorizedError) Is(target error) bool { return target == e.category }
+
+// categorize tags err with category; a nil err stays nil
+func categorize(category, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &categorizedError{category: category, err: err}
+}
+
+// recordError counts a processFile failure in Errors and in its category.
+//
+// Permission errors are counted as such whichever step hit them; other
+// errors fall back to their tagged category.
+func (s *Stats) recordError(err error) {
+	atomic.AddInt64(&s.Errors, 1)
+
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		atomic.AddInt64(&s.PermissionErrors, 1)
+	case errors.Is(err, errMetadata):
+		atomic.AddInt64(&s.MetadataErrors, 1)
+	case errors.Is(err, errIO):
+		atomic.AddInt64(&s.IOErrors, 1)
+	default:
+		atomic.AddInt64(&s.OtherErrors, 1)
+	}
+}
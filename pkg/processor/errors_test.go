@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategorize(t *testing.T) {
+	base := errors.New("boom")
+	err := categorize(errIO, fmt.Errorf("failed to copy: %w", base))
+
+	assert.Equal(t, "failed to copy: boom", err.Error())
+	assert.ErrorIs(t, err, errIO)
+	assert.ErrorIs(t, err, base)
+	assert.NotErrorIs(t, err, errMetadata)
+	assert.NoError(t, categorize(errIO, nil))
+}
+
+func TestStatsRecordError(t *testing.T) {
+	stats := &Stats{}
+
+	stats.recordError(categorize(errMetadata, errors.New("no metadata")))
+	stats.recordError(categorize(errIO, errors.New("disk full")))
+	stats.recordError(categorize(errIO, fmt.Errorf("failed to copy: %w", &fs.PathError{Op: "open", Path: "/x", Err: fs.ErrPermission})))
+	stats.recordError(errors.New("uncategorized"))
+
+	assert.Equal(t, int64(4), stats.Errors)
+	assert.Equal(t, int64(1), stats.MetadataErrors)
+	assert.Equal(t, int64(1), stats.IOErrors)
+	assert.Equal(t, int64(1), stats.PermissionErrors, "permission errors win over their step's category")
+	assert.Equal(t, int64(1), stats.OtherErrors)
+}
+
+func TestProcessFilePermissionError(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("directory permissions are not enforced for this user")
+	}
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "IMG_0001.JPG")
+	require.NoError(t, os.WriteFile(sourceFile, []byte("image"), 0644))
+
+	// A read-only destination root can't receive the date directories
+	destDir := filepath.Join(tmpDir, "dest")
+	require.NoError(t, os.MkdirAll(destDir, 0555))
+	t.Cleanup(func() { os.Chmod(destDir, 0755) })
+
+	cfg := &config.ProcessingConfig{Precision: 6}
+	stats := &Stats{}
+	err := processFile(context.Background(), sourceFile, destDir, cfg, nil, stats, nil, nil, nil, nil, 0)
+	require.Error(t, err)
+
+	stats.recordError(err)
+	assert.ErrorIs(t, err, fs.ErrPermission)
+	assert.Equal(t, int64(1), stats.PermissionErrors)
+}
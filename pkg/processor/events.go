@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Event types written to the event stream
+const (
+	EventStart     = "start"     // a worker picked up the file
+	EventCopied    = "copied"    // the file was copied, moved, or renamed (see Event.Action)
+	EventSkipped   = "skipped"   // the file was filtered out (see Event.Reason)
+	EventDuplicate = "duplicate" // identical content already exists at the destination
+	EventError     = "error"     // processing failed (see Event.Error)
+)
+
+// Event is one line of the event stream
+type Event struct {
+	Event       string `json:"event"`
+	Path        string `json:"path"`
+	Destination string `json:"destination,omitempty"`
+	// Action is the manifest action for copied events: copied, moved, or
+	// renamed, prefixed with "dry-run-" in a dry run
+	Action string `json:"action,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Elapsed is seconds since the EventWriter was created
+	Elapsed float64 `json:"elapsed"`
+}
+
+// eventBuffer is how many events workers can queue before blocking on the writer
+const eventBuffer = 64
+
+// EventWriter writes per-file events as JSON lines, one object per line.
+//
+// Safe for concurrent use by workers; events are funneled through a channel to
+// a single encoder, so lines never interleave and keep roughly the order in
+// which they happened.
+type EventWriter struct {
+	events chan Event
+	done   chan struct{}
+	start  time.Time
+	err    error
+}
+
+// NewEventWriter starts writing events to w until Close is called
+func NewEventWriter(w io.Writer) *EventWriter {
+	e := &EventWriter{
+		events: make(chan Event, eventBuffer),
+		done:   make(chan struct{}),
+		start:  time.Now(),
+	}
+	go e.run(json.NewEncoder(w))
+	return e
+}
+
+// run encodes queued events until the channel is closed, keeping the first error
+func (e *EventWriter) run(enc *json.Encoder) {
+	defer close(e.done)
+	for event := range e.events {
+		if e.err == nil {
+			e.err = enc.Encode(event)
+		}
+	}
+}
+
+// emit queues an event, stamping its elapsed time. A nil EventWriter is a no-op.
+func (e *EventWriter) emit(event Event) {
+	if e == nil {
+		return
+	}
+	event.Elapsed = time.Since(e.start).Round(time.Millisecond).Seconds()
+	e.events <- event
+}
+
+// Close writes any queued events and stops the writer. A nil EventWriter is a no-op.
+func (e *EventWriter) Close() error {
+	if e == nil {
+		return nil
+	}
+
+	close(e.events)
+	<-e.done
+	if e.err != nil {
+		return fmt.Errorf("failed to write event: %w", e.err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventStream(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	destDir := filepath.Join(tmpDir, "dest")
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg"))
+	require.NoError(t, err)
+	photo := filepath.Join(tmpDir, "test_001.jpg")
+	require.NoError(t, os.WriteFile(photo, data, 0644))
+
+	thumb := filepath.Join(tmpDir, "thumb.jpg")
+	require.NoError(t, os.WriteFile(thumb, make([]byte, 10), 0644))
+
+	missing := filepath.Join(tmpDir, "missing.jpg")
+
+	var out bytes.Buffer
+	events := NewEventWriter(&out)
+
+	cfg := &config.ProcessingConfig{Precision: 6, MinSize: 1024}
+	p := New(destDir, cfg, Options{Workers: 1, Events: events})
+	defer p.Close()
+
+	_, err = p.Process(context.Background(), []string{photo, thumb, missing})
+	require.NoError(t, err)
+	require.NoError(t, events.Close())
+
+	// Every line is a standalone JSON object
+	byPath := make(map[string][]Event)
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event), scanner.Text())
+		assert.GreaterOrEqual(t, event.Elapsed, 0.0)
+		byPath[event.Path] = append(byPath[event.Path], event)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.Len(t, byPath[photo], 2)
+	assert.Equal(t, EventStart, byPath[photo][0].Event)
+	assert.Equal(t, EventCopied, byPath[photo][1].Event)
+	assert.Equal(t, manifestActionCopied, byPath[photo][1].Action)
+	assert.Equal(t, filepath.Join(destDir, "2024", "01", "2024-01-15", "20240115-123045.123456_Canon-Eos5d.jpg"), byPath[photo][1].Destination)
+
+	require.Len(t, byPath[thumb], 2)
+	assert.Equal(t, EventSkipped, byPath[thumb][1].Event)
+	assert.Equal(t, "too small", byPath[thumb][1].Reason)
+
+	require.Len(t, byPath[missing], 2)
+	assert.Equal(t, EventError, byPath[missing][1].Event)
+	assert.Contains(t, byPath[missing][1].Error, "failed to stat file")
+}
+
+func TestEventWriterNil(t *testing.T) {
+	var events *EventWriter
+	events.emit(Event{Event: EventStart, Path: "a.jpg"})
+	assert.NoError(t, events.Close())
+}
@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+)
+
+// ValidateExec checks an Options.Exec command line
+func ValidateExec(command string) error {
+	if len(strings.Fields(command)) == 0 {
+		return errors.New("command is empty")
+	}
+	return nil
+}
+
+// execHook runs Options.Exec after each organized file.
+//
+// The command line is split on whitespace and {src} and {dst} are replaced
+// within each argument, so paths with spaces stay one argument and nothing
+// is interpreted by a shell. Hooks run on the worker that organized the
+// file, so at most Options.Workers run at once. A nil *execHook runs nothing.
+type execHook struct {
+	args    []string
+	dryRun  bool
+	verbose int
+	out     io.Writer
+}
+
+// newExecHook creates the hook for command, or nil if command is empty.
+// Printed commands go to out.
+func newExecHook(command string, dryRun bool, verbose int, out io.Writer) *execHook {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil
+	}
+	return &execHook{args: args, dryRun: dryRun, verbose: verbose, out: out}
+}
+
+// expand returns the hook's arguments for one file
+func (h *execHook) expand(src, dst string) []string {
+	replacer := strings.NewReplacer("{src}", src, "{dst}", dst)
+	args := make([]string, len(h.args))
+	for i, arg := range h.args {
+		args[i] = replacer.Replace(arg)
+	}
+	return args
+}
+
+// run runs the hook for a file organized from src to dst. In a dry run the
+// command is only printed; otherwise it's printed with -v. Failures are
+// reported on stderr and counted in stats.HookErrors; the file itself stays
+// organized.
+func (h *execHook) run(ctx context.Context, src, dst string, stats *Stats) {
+	if h == nil {
+		return
+	}
+
+	args := h.expand(src, dst)
+	if h.dryRun {
+		fmt.Fprintf(h.out, "[DRY RUN] Exec: %s\n", strings.Join(args, " "))
+		return
+	}
+	if h.verbose > 0 {
+		fmt.Fprintf(h.out, "Exec: %s\n", strings.Join(args, " "))
+	}
+
+	// Hook output goes to stderr so stdout stays clean for --events
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		atomic.AddInt64(&stats.HookErrors, 1)
+		fmt.Fprintf(os.Stderr, "Warning: --exec failed for %s: %v\n", dst, err)
+	}
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateExec(t *testing.T) {
+	assert.NoError(t, ValidateExec("touch {dst}.done"))
+	assert.Error(t, ValidateExec(""))
+	assert.Error(t, ValidateExec("  "))
+}
+
+func TestExecHookExpand(t *testing.T) {
+	hook := newExecHook("notify --from={src} {dst}", false, 0, io.Discard)
+	assert.Equal(t,
+		[]string{"notify", "--from=/card/IMG 0001.JPG", "/archive/2024/a.jpg"},
+		hook.expand("/card/IMG 0001.JPG", "/archive/2024/a.jpg"),
+		"a path with spaces stays one argument")
+
+	assert.Nil(t, newExecHook("", false, 0, io.Discard))
+}
+
+func TestExecHookRun(t *testing.T) {
+	if _, err := exec.LookPath("touch"); err != nil {
+		t.Skip("touch not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	dst := filepath.Join(tmpDir, "photo.jpg")
+	stats := &Stats{}
+
+	newExecHook("touch {dst}.done", false, 0, io.Discard).run(context.Background(), "/card/photo.jpg", dst, stats)
+	assert.FileExists(t, dst+".done")
+	assert.Equal(t, int64(0), stats.HookErrors)
+
+	var out bytes.Buffer
+	newExecHook("touch {dst}.dry", true, 0, &out).run(context.Background(), "/card/photo.jpg", dst, stats)
+	assert.NoFileExists(t, dst+".dry", "dry run only prints the command")
+	assert.Equal(t, "[DRY RUN] Exec: touch "+dst+".dry\n", out.String(), "printed without -v")
+
+	newExecHook("touch "+filepath.Join(tmpDir, "missing", "{dst}"), false, 0, io.Discard).run(context.Background(), "/card/photo.jpg", "x", stats)
+	newExecHook("sortpics-no-such-command {dst}", false, 0, io.Discard).run(context.Background(), "/card/photo.jpg", dst, stats)
+	assert.Equal(t, int64(2), stats.HookErrors)
+
+	var nilHook *execHook
+	nilHook.run(context.Background(), "/card/photo.jpg", dst, stats)
+}
+
+func TestProcessorExec(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	destDir := filepath.Join(tmpDir, "dest")
+	marker := filepath.Join(tmpDir, "marker")
+	sourceFile := filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg")
+
+	cfg := &config.ProcessingConfig{Precision: 6, NoMetadataWrite: true}
+	p := New(destDir, cfg, Options{Workers: 1, Exec: "cp {dst} " + marker, KeepDestinations: true})
+	defer p.Close()
+	stats, err := p.Process(context.Background(), []string{sourceFile})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stats.Processed)
+	assert.Equal(t, int64(0), stats.HookErrors)
+	require.Len(t, stats.Destinations, 1)
+
+	organized, err := os.ReadFile(stats.Destinations[0])
+	require.NoError(t, err)
+	copied, err := os.ReadFile(marker)
+	require.NoError(t, err, "hook should run with the destination path")
+	assert.Equal(t, organized, copied)
+}
@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ignoreFileName is the per-directory file of gitignore-style patterns
+const ignoreFileName = ".sortpicsignore"
+
+// ignoreRule is one pattern line of an ignore file
+type ignoreRule struct {
+	pattern  string
+	negate   bool // "!pattern" re-includes a previously ignored path
+	dirOnly  bool // "pattern/" only matches directories
+	anchored bool // a "/" before the end ties the pattern to the ignore file's directory
+}
+
+// ignoreFile holds the rules of one ignore file
+type ignoreFile struct {
+	// dir is the directory holding the file, slash-separated relative to the source root ("" for the root)
+	dir   string
+	rules []ignoreRule
+}
+
+// ignoreStack is the ignore files in effect for a directory, outermost first
+type ignoreStack []ignoreFile
+
+// loadIgnore returns stack extended with the ignore file in dir, if there is one.
+//
+// rel is dir relative to the source root. The returned stack never shares
+// its backing array with stack, so sibling directories stay independent.
+func loadIgnore(stack ignoreStack, dir, rel string) (ignoreStack, error) {
+	f, err := os.Open(filepath.Join(dir, ignoreFileName))
+	if os.IsNotExist(err) {
+		return stack, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", ignoreFileName, err)
+	}
+	defer f.Close()
+
+	rules, err := parseIgnore(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Join(dir, ignoreFileName), err)
+	}
+	if rel == "." {
+		rel = ""
+	}
+
+	extended := make(ignoreStack, len(stack), len(stack)+1)
+	copy(extended, stack)
+	return append(extended, ignoreFile{dir: rel, rules: rules}), nil
+}
+
+// parseIgnore parses gitignore-style pattern lines.
+//
+// Blank lines and "#" comments are skipped; "\#" and "\!" escape a leading
+// character. Invalid globs are rejected.
+func parseIgnore(r io.Reader) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		if !doublestar.ValidatePattern(line) {
+			return nil, fmt.Errorf("invalid pattern %q", line)
+		}
+
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// Ignored reports whether rel, a slash-separated path relative to the source
+// root, is ignored. As with gitignore, the last matching rule wins and rules
+// in deeper ignore files override those above them.
+func (s ignoreStack) Ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, file := range s {
+		target := rel
+		if file.dir != "" {
+			if !strings.HasPrefix(rel, file.dir+"/") {
+				continue
+			}
+			target = strings.TrimPrefix(rel, file.dir+"/")
+		}
+		base := target[strings.LastIndex(target, "/")+1:]
+
+		for _, rule := range file.rules {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			name := base
+			if rule.anchored {
+				name = target
+			}
+			if ok, _ := doublestar.Match(rule.pattern, name); ok {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIgnore(t *testing.T) {
+	input := "# comment\n\n*.mov\n!keep.mov\nraw/\n/top.jpg\ndocs/**/*.jpg\n\\#hash.jpg\n"
+
+	rules, err := parseIgnore(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, []ignoreRule{
+		{pattern: "*.mov"},
+		{pattern: "keep.mov", negate: true},
+		{pattern: "raw", dirOnly: true},
+		{pattern: "top.jpg", anchored: true},
+		{pattern: "docs/**/*.jpg", anchored: true},
+		{pattern: "#hash.jpg"},
+	}, rules)
+
+	_, err = parseIgnore(strings.NewReader("[unclosed\n"))
+	assert.Error(t, err)
+}
+
+func TestIgnoreStack(t *testing.T) {
+	stack := ignoreStack{
+		{dir: "", rules: []ignoreRule{{pattern: "*.mov"}, {pattern: "keep.mov", negate: true}, {pattern: "raw", dirOnly: true}, {pattern: "top.jpg", anchored: true}}},
+		{dir: "trip", rules: []ignoreRule{{pattern: "*.jpg"}}},
+	}
+
+	tests := []struct {
+		rel     string
+		isDir   bool
+		ignored bool
+	}{
+		{"clip.mov", false, true},
+		{"a/b/clip.mov", false, true},
+		{"a/keep.mov", false, false},
+		{"raw", true, true},
+		{"raw", false, false},
+		{"top.jpg", false, true},
+		{"a/top.jpg", false, false},
+		{"trip/photo.jpg", false, true},
+		{"other/photo.jpg", false, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.ignored, stack.Ignored(tt.rel, tt.isDir), tt.rel)
+	}
+}
+
+func TestCollectFilesSortpicsIgnore(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, rel := range []string{
+		"keep.jpg",
+		"clip.mov",
+		"screenshots/shot.png",
+		"trip/photo.jpg",
+		"trip/proxy.mov",
+		"trip/edits/edit.jpg",
+		"trip/edits/final.jpg",
+	} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(rel))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(rel), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, ignoreFileName), []byte("*.mov\nscreenshots/\n"), 0644))
+	// Nested files stack on top of the root's
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "trip", "edits", ignoreFileName), []byte("*.jpg\n!final.jpg\n"), 0644))
+
+	rels := func(files []string) []string {
+		var out []string
+		for _, file := range files {
+			out = append(out, relativePath(tmpDir, file))
+		}
+		return out
+	}
+
+	files, err := collectFiles([]string{tmpDir}, collectOptions{Recursive: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"keep.jpg", "trip/photo.jpg", "trip/edits/final.jpg"}, rels(files))
+
+	files, err = collectFiles([]string{tmpDir}, collectOptions{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"keep.jpg"}, rels(files))
+}
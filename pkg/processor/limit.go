@@ -0,0 +1,59 @@
+package processor
+
+import "os"
+
+// limiter stops a run once Options.Limit files or Options.LimitBytes bytes of
+// sources have been submitted. Only the submitting goroutine uses it. A nil
+// *limiter never stops.
+type limiter struct {
+	maxFiles int64
+	maxBytes int64
+	files    int64
+	bytes    int64
+
+	// pairs maps Live Photo stills to the videos organized with them
+	pairs map[string]string
+}
+
+// newLimiter creates a limiter, or returns nil when neither limit is set.
+// A still in pairs counts together with its video.
+func newLimiter(maxFiles int, maxBytes int64, pairs map[string]string) *limiter {
+	if maxFiles <= 0 && maxBytes <= 0 {
+		return nil
+	}
+	return &limiter{maxFiles: int64(maxFiles), maxBytes: maxBytes, pairs: pairs}
+}
+
+// take returns the leading files of batch to submit and whether a limit has
+// now been reached, after which nothing more should be submitted. The file
+// that crosses the byte limit is still taken, as is a Live Photo pair that
+// crosses either limit: a pair is never split.
+func (l *limiter) take(batch []string) ([]string, bool) {
+	if l == nil {
+		return batch, false
+	}
+
+	for i, file := range batch {
+		if l.reached() {
+			return batch[:i], true
+		}
+		l.add(file)
+		if video, ok := l.pairs[file]; ok {
+			l.add(video)
+		}
+	}
+	return batch, l.reached()
+}
+
+// add counts one submitted file
+func (l *limiter) add(file string) {
+	l.files++
+	if info, err := os.Stat(file); err == nil {
+		l.bytes += info.Size()
+	}
+}
+
+// reached reports whether either limit has been met
+func (l *limiter) reached() bool {
+	return (l.maxFiles > 0 && l.files >= l.maxFiles) || (l.maxBytes > 0 && l.bytes >= l.maxBytes)
+}
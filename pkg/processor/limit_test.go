@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterTake(t *testing.T) {
+	tmpDir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg"} {
+		path := filepath.Join(tmpDir, name)
+		require.NoError(t, os.WriteFile(path, make([]byte, 100), 0644))
+		files = append(files, path)
+	}
+
+	t.Run("no limit", func(t *testing.T) {
+		l := newLimiter(0, 0, nil)
+		assert.Nil(t, l)
+		batch, limited := l.take(files)
+		assert.Equal(t, files, batch)
+		assert.False(t, limited)
+	})
+
+	t.Run("files", func(t *testing.T) {
+		l := newLimiter(3, 0, nil)
+		batch, limited := l.take(files[:2])
+		assert.Equal(t, files[:2], batch)
+		assert.False(t, limited)
+
+		batch, limited = l.take(files[2:])
+		assert.Equal(t, files[2:3], batch, "a batch is cut at the limit")
+		assert.True(t, limited)
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		l := newLimiter(0, 150, nil)
+		batch, limited := l.take(files[:1])
+		assert.Equal(t, files[:1], batch)
+		assert.False(t, limited)
+
+		batch, limited = l.take(files[1:])
+		assert.Equal(t, files[1:2], batch, "the file crossing the limit is taken")
+		assert.True(t, limited)
+	})
+
+	t.Run("live photo pairs", func(t *testing.T) {
+		video := filepath.Join(tmpDir, "b.mov")
+		require.NoError(t, os.WriteFile(video, make([]byte, 100), 0644))
+		pairs := map[string]string{files[1]: video}
+
+		l := newLimiter(2, 0, pairs)
+		batch, limited := l.take(files)
+		assert.Equal(t, files[:2], batch, "the video counts against the limit")
+		assert.True(t, limited)
+
+		l = newLimiter(0, 250, pairs)
+		batch, limited = l.take(files)
+		assert.Equal(t, files[:2], batch, "and so do its bytes")
+		assert.True(t, limited)
+	})
+}
+
+func TestProcessorLimit(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	destDir := filepath.Join(tmpDir, "dest")
+	testDataDir := filepath.Join("..", "..", "test", "testdata", "basic")
+
+	p := New(destDir, &config.ProcessingConfig{Precision: 6, DryRun: true}, Options{Workers: 2, Limit: 2, KeepDestinations: true})
+	defer p.Close()
+	files, err := p.Collect([]string{testDataDir})
+	require.NoError(t, err)
+	require.Len(t, files, 5)
+
+	stats, err := p.Process(context.Background(), files)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), stats.Processed)
+	assert.Len(t, stats.Destinations, 2)
+	assert.True(t, stats.LimitReached)
+}
@@ -0,0 +1,153 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/cacack/sortpics-go/internal/metadata"
+)
+
+// livePhotoStills and livePhotoVideos are the extensions of the two halves of
+// an Apple Live Photo (older iPhones shot JPEG stills)
+var (
+	livePhotoStills = []string{"heic", "heif", "jpg", "jpeg"}
+	livePhotoVideos = []string{"mov"}
+)
+
+// detectLivePhotos reads the ContentIdentifier of every Live Photo candidate
+// and pairs stills with their videos (see pairLivePhotos). The result maps
+// each paired still to its video.
+func detectLivePhotos(ctx context.Context, files []string, verbose int) (map[string]string, error) {
+	if verbose > 0 {
+		fmt.Printf("Detecting Live Photos in %d files\n", len(files))
+	}
+
+	extractor, err := metadata.NewMetadataExtractor()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metadata extractor: %w", err)
+	}
+	defer extractor.Close()
+
+	ids := make(map[string]string)
+	for _, file := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		if livePhotoKind(file) == "" {
+			continue
+		}
+
+		meta, err := extractor.Extract(file, nil, nil)
+		if err != nil {
+			// Reported when the file itself is processed
+			continue
+		}
+		if id := contentIdentifier(meta.RawMetadata); id != "" {
+			ids[file] = id
+		}
+	}
+
+	pairs := pairLivePhotos(files, ids)
+	if verbose > 0 {
+		fmt.Printf("Found %d Live Photos\n", len(pairs))
+	}
+	return pairs, nil
+}
+
+// pairLivePhotos pairs Live Photo stills with their videos.
+//
+// ids holds the ContentIdentifier of the files that have one. A still and a
+// video sharing an identifier are a pair wherever they are. Left-over files
+// fall back to a same-directory, same-stem match (IMG_0001.HEIC with
+// IMG_0001.MOV), unless both carry identifiers that differ. The first
+// candidate in files order wins, and every file is in at most one pair.
+func pairLivePhotos(files []string, ids map[string]string) map[string]string {
+	pairs := make(map[string]string)
+	paired := make(map[string]bool)
+
+	stillsByID := make(map[string]string)
+	for _, file := range files {
+		if id, ok := ids[file]; ok && livePhotoKind(file) == "still" {
+			if _, seen := stillsByID[id]; !seen {
+				stillsByID[id] = file
+			}
+		}
+	}
+	for _, file := range files {
+		id, ok := ids[file]
+		if !ok || livePhotoKind(file) != "video" {
+			continue
+		}
+		if still, ok := stillsByID[id]; ok && !paired[still] {
+			pairs[still] = file
+			paired[still], paired[file] = true, true
+		}
+	}
+
+	stillsByStem := make(map[string]string)
+	for _, file := range files {
+		if livePhotoKind(file) == "still" && !paired[file] {
+			if _, seen := stillsByStem[stemKey(file)]; !seen {
+				stillsByStem[stemKey(file)] = file
+			}
+		}
+	}
+	for _, file := range files {
+		if livePhotoKind(file) != "video" || paired[file] {
+			continue
+		}
+		still, ok := stillsByStem[stemKey(file)]
+		if !ok || paired[still] {
+			continue
+		}
+		stillID, stillHasID := ids[still]
+		videoID, videoHasID := ids[file]
+		if stillHasID && videoHasID && stillID != videoID {
+			continue
+		}
+		pairs[still] = file
+		paired[still], paired[file] = true, true
+	}
+
+	return pairs
+}
+
+// livePhotoKind reports whether path could be the "still" or "video" half of
+// a Live Photo, or "" if neither
+func livePhotoKind(path string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	for _, still := range livePhotoStills {
+		if ext == still {
+			return "still"
+		}
+	}
+	for _, video := range livePhotoVideos {
+		if ext == video {
+			return "video"
+		}
+	}
+	return ""
+}
+
+// stemKey returns path without its extension and with a lowercased name, so
+// both halves of a pair map to the same key
+func stemKey(path string) string {
+	name := filepath.Base(path)
+	return filepath.Join(filepath.Dir(path), strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name))))
+}
+
+// contentIdentifier returns the Live Photo ContentIdentifier from any group
+// (Apple maker notes on the still, QuickTime keys on the video), or ""
+func contentIdentifier(raw map[string]interface{}) string {
+	for key, value := range raw {
+		if key != "ContentIdentifier" && !strings.HasSuffix(key, ":ContentIdentifier") {
+			continue
+		}
+		if id, ok := value.(string); ok && id != "" {
+			return id
+		}
+	}
+	return ""
+}
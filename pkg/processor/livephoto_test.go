@@ -0,0 +1,131 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPairLivePhotos(t *testing.T) {
+	files := []string{
+		"/card/IMG_0001.HEIC",
+		"/card/IMG_0001.MOV",
+		"/card/IMG_0002.HEIC",
+		"/card/export/clip.mov",
+		"/card/IMG_0003.JPG",
+		"/card/IMG_0003.MOV",
+		"/card/IMG_0004.HEIC",
+		"/card/IMG_0004.MOV",
+		"/card/other/IMG_0005.HEIC",
+		"/card/IMG_0005.MOV",
+		"/card/IMG_0006.PNG",
+		"/card/IMG_0006.MOV",
+	}
+	ids := map[string]string{
+		"/card/IMG_0002.HEIC":       "A",
+		"/card/export/clip.mov":     "A",
+		"/card/IMG_0004.HEIC":       "B",
+		"/card/IMG_0004.MOV":        "C",
+		"/card/IMG_0001.MOV":        "D",
+		"/card/other/IMG_0005.HEIC": "E",
+	}
+
+	assert.Equal(t, map[string]string{
+		// Same stem, only one side has an identifier
+		"/card/IMG_0001.HEIC": "/card/IMG_0001.MOV",
+		// Same identifier, different names and directories
+		"/card/IMG_0002.HEIC": "/card/export/clip.mov",
+		// Same stem, JPEG still from an older iPhone
+		"/card/IMG_0003.JPG": "/card/IMG_0003.MOV",
+		// IMG_0004: same stem but different identifiers, not a pair
+		// IMG_0005: same stem in different directories, not a pair
+		// IMG_0006: PNG screenshots aren't Live Photos
+	}, pairLivePhotos(files, ids))
+}
+
+func TestContentIdentifier(t *testing.T) {
+	assert.Equal(t, "ABC", contentIdentifier(map[string]interface{}{"MakerNotes:ContentIdentifier": "ABC"}))
+	assert.Equal(t, "ABC", contentIdentifier(map[string]interface{}{"ContentIdentifier": "ABC"}))
+	assert.Equal(t, "", contentIdentifier(map[string]interface{}{"ContentIdentifierX": "ABC"}))
+	assert.Equal(t, "", contentIdentifier(map[string]interface{}{}))
+}
+
+func TestProcessorKeepLivePhotos(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg"))
+	require.NoError(t, err)
+	still := filepath.Join(sourceDir, "IMG_0001.HEIC")
+	video := filepath.Join(sourceDir, "IMG_0001.MOV")
+	require.NoError(t, os.WriteFile(still, data, 0644))
+	require.NoError(t, os.WriteFile(video, []byte("live photo video"), 0644))
+
+	cfg := &config.ProcessingConfig{Precision: 6, Move: true, NoMetadataWrite: true, KeepLivePhotos: true}
+	p := New(destDir, cfg, Options{Workers: 1, KeepDestinations: true})
+	defer p.Close()
+	files, err := p.Collect([]string{sourceDir})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	stats, err := p.Process(context.Background(), files)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Processed, "the video travels with its still")
+	require.Len(t, stats.Destinations, 1)
+
+	destination := stats.Destinations[0]
+	require.True(t, strings.HasSuffix(destination, ".heic"), destination)
+	pairedVideo := strings.TrimSuffix(destination, ".heic") + ".mov"
+	content, err := os.ReadFile(pairedVideo)
+	require.NoError(t, err)
+	assert.Equal(t, "live photo video", string(content))
+	assert.NoFileExists(t, still)
+	assert.NoFileExists(t, video)
+}
+
+// TestProcessorKeepLivePhotosStillSkipped tests that a paired video whose
+// still isn't organized is organized on its own
+func TestProcessorKeepLivePhotosStillSkipped(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+
+	// The still is a truncated thumbnail, below --min-size
+	still := filepath.Join(sourceDir, "IMG_0001.HEIC")
+	video := filepath.Join(sourceDir, "IMG_0001.MOV")
+	require.NoError(t, os.WriteFile(still, []byte("tiny"), 0644))
+	require.NoError(t, os.WriteFile(video, []byte(strings.Repeat("live photo video ", 10)), 0644))
+
+	cfg := &config.ProcessingConfig{Precision: 6, NoMetadataWrite: true, KeepLivePhotos: true, MinSize: 100}
+	p := New(destDir, cfg, Options{Workers: 1, KeepDestinations: true})
+	defer p.Close()
+	files, err := p.Collect([]string{sourceDir})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	stats, err := p.Process(context.Background(), files)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Skipped)
+	assert.Equal(t, int64(1), stats.Processed, "the video is organized without its still")
+	require.Len(t, stats.Destinations, 1)
+	assert.True(t, strings.HasSuffix(stats.Destinations[0], ".mov"), stats.Destinations[0])
+	assert.FileExists(t, stats.Destinations[0])
+}
@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Manifest actions recorded per file
+const (
+	manifestActionCopied    = "copied"
+	manifestActionMoved     = "moved"
+	manifestActionRenamed   = "renamed"
+	manifestActionDuplicate = "skipped-duplicate"
+)
+
+// manifestDryRunPrefix marks the copied, moved, and renamed actions of a dry
+// run, which only plans them ("dry-run-copied")
+const manifestDryRunPrefix = "dry-run-"
+
+// manifestHeader is the first row of every manifest
+var manifestHeader = []string{"source", "destination", "datetime", "make", "model", "action", "hash"}
+
+// manifestEntry is one row of the operations manifest
+type manifestEntry struct {
+	Source      string
+	Destination string
+	DateTime    *time.Time
+	Make        string
+	Model       string
+	Action      string
+	Hash        string
+}
+
+// ManifestWriter writes a CSV manifest of operations.
+//
+// Safe for concurrent use by workers; rows are serialized by a mutex.
+type ManifestWriter struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *csv.Writer
+}
+
+// NewManifestWriter creates the manifest file at path and writes the header
+func NewManifestWriter(path string) (*ManifestWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create manifest: %w", err)
+	}
+
+	m := &ManifestWriter{
+		file:   file,
+		writer: csv.NewWriter(file),
+	}
+	if err := m.writer.Write(manifestHeader); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write manifest header: %w", err)
+	}
+
+	return m, nil
+}
+
+// record appends an entry to the manifest. A nil ManifestWriter is a no-op.
+func (m *ManifestWriter) record(entry manifestEntry) error {
+	if m == nil {
+		return nil
+	}
+
+	datetime := ""
+	if entry.DateTime != nil {
+		datetime = entry.DateTime.Format(time.RFC3339Nano)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.writer.Write([]string{
+		entry.Source,
+		entry.Destination,
+		datetime,
+		entry.Make,
+		entry.Model,
+		entry.Action,
+		entry.Hash,
+	}); err != nil {
+		return fmt.Errorf("failed to write manifest row: %w", err)
+	}
+	return nil
+}
+
+// Close flushes buffered rows and closes the manifest file. A nil ManifestWriter is a no-op.
+func (m *ManifestWriter) Close() error {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.writer.Flush()
+	if err := m.writer.Error(); err != nil {
+		m.file.Close()
+		return fmt.Errorf("failed to flush manifest: %w", err)
+	}
+	return m.file.Close()
+}
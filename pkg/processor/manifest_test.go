@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestWriter(t *testing.T) {
+	t.Run("concurrent records", func(t *testing.T) {
+		manifestFile := filepath.Join(t.TempDir(), "manifest.csv")
+		manifest, err := NewManifestWriter(manifestFile)
+		require.NoError(t, err)
+
+		dt := time.Date(2024, 1, 15, 12, 30, 45, 0, time.UTC)
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				err := manifest.record(manifestEntry{
+					Source:      fmt.Sprintf("/src/IMG_%04d.jpg", i),
+					Destination: fmt.Sprintf("/dest/%d.jpg", i),
+					DateTime:    &dt,
+					Make:        "Canon",
+					Model:       "EOS 5D, Mark II",
+					Action:      manifestActionCopied,
+					Hash:        "abc",
+				})
+				assert.NoError(t, err)
+			}(i)
+		}
+		wg.Wait()
+		require.NoError(t, manifest.Close())
+
+		f, err := os.Open(manifestFile)
+		require.NoError(t, err)
+		defer f.Close()
+
+		rows, err := csv.NewReader(f).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 21)
+		assert.Equal(t, manifestHeader, rows[0])
+		assert.Equal(t, "2024-01-15T12:30:45Z", rows[1][2])
+		assert.Equal(t, "EOS 5D, Mark II", rows[1][4], "commas should be quoted")
+	})
+
+	t.Run("nil writer is a no-op", func(t *testing.T) {
+		var manifest *ManifestWriter
+		assert.NoError(t, manifest.record(manifestEntry{Source: "a.jpg"}))
+		assert.NoError(t, manifest.Close())
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		_, err := NewManifestWriter("/nonexistent/directory/manifest.csv")
+		assert.Error(t, err)
+	})
+}
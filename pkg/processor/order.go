@@ -0,0 +1,287 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cacack/sortpics-go/internal/burst"
+	"github.com/cacack/sortpics-go/internal/rename"
+	"github.com/cacack/sortpics-go/pkg/config"
+)
+
+// singleBatches wraps each file in its own batch until files is closed or ctx is canceled
+func singleBatches(ctx context.Context, files <-chan string) <-chan []string {
+	batches := make(chan []string)
+	go func() {
+		defer close(batches)
+		for file := range files {
+			select {
+			case batches <- []string{file}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return batches
+}
+
+// batchChan returns a closed channel pre-filled with batches
+func batchChan(batches [][]string) <-chan []string {
+	ch := make(chan []string, len(batches))
+	for _, batch := range batches {
+		ch <- batch
+	}
+	close(ch)
+	return ch
+}
+
+// plannedFile is a file's destination before collision resolution and its datetime
+type plannedFile struct {
+	dest     string
+	dateTime *time.Time
+}
+
+// orderCollisions plans every file's destination and batches files that
+// would collide, ordered by source sequence number (see groupBySequence), or
+// with cfg.Deterministic by datetime and path (see groupByTime). Files whose
+// destination can't be planned are left in their own batch.
+func orderCollisions(ctx context.Context, files []string, destDir string, cfg *config.ProcessingConfig, run *rename.Run, workers int, verbose int) [][]string {
+	if verbose > 0 {
+		fmt.Printf("Planning destinations for %d files\n", len(files))
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	planned := make(map[string]plannedFile, len(files))
+	sem := make(chan struct{}, workers)
+
+	for _, file := range files {
+		if ctx.Err() != nil {
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(file string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			plan, err := planFile(file, destDir, cfg, run)
+			if err != nil {
+				// Reported when the file itself is processed
+				return
+			}
+			mu.Lock()
+			planned[file] = plan
+			mu.Unlock()
+		}(file)
+	}
+	wg.Wait()
+
+	if cfg.Deterministic {
+		return groupByTime(files, planned)
+	}
+	dests := make(map[string]string, len(planned))
+	for file, plan := range planned {
+		dests[file] = plan.dest
+	}
+	return groupBySequence(files, dests)
+}
+
+// planDestination returns a supported file's destination before collision resolution
+func planDestination(file string, destDir string, cfg *config.ProcessingConfig) (string, error) {
+	plan, err := planFile(file, destDir, cfg, nil)
+	return plan.dest, err
+}
+
+// planFile returns a supported file's destination before collision
+// resolution, with the datetime it was named by
+func planFile(file string, destDir string, cfg *config.ProcessingConfig, run *rename.Run) (plannedFile, error) {
+	ir, err := rename.NewImageRename(file, destDir, cfg, rename.InRun(run))
+	if err != nil {
+		return plannedFile{}, err
+	}
+	defer ir.Close()
+
+	if !ir.IsValidExtension() {
+		return plannedFile{}, fmt.Errorf("unsupported file: %s", file)
+	}
+	dest, err := ir.PlannedDestination()
+	if err != nil {
+		return plannedFile{}, err
+	}
+	return plannedFile{dest: dest, dateTime: ir.GetDateTime()}, nil
+}
+
+// groupBySequence batches files sharing a planned destination, keeping the
+// order in which each destination is first seen. Within a batch, files are
+// sorted by source sequence number so IMG_0123 gets its suffix before IMG_0124.
+// Files missing from planned get their own batch.
+func groupBySequence(files []string, planned map[string]string) [][]string {
+	var batches [][]string
+	index := make(map[string]int)
+
+	for _, file := range files {
+		dest, ok := planned[file]
+		if !ok {
+			batches = append(batches, []string{file})
+			continue
+		}
+		if i, seen := index[dest]; seen {
+			batches[i] = append(batches[i], file)
+			continue
+		}
+		index[dest] = len(batches)
+		batches = append(batches, []string{file})
+	}
+
+	for _, batch := range batches {
+		if len(batch) > 1 {
+			sort.SliceStable(batch, func(i, j int) bool {
+				return lessSequence(batch[i], batch[j])
+			})
+		}
+	}
+
+	return batches
+}
+
+// groupByTime sorts files by datetime, then source path, and batches those
+// sharing a planned destination in that order, so _N suffixes are the same on
+// every run regardless of the order files were found in. Files without a
+// datetime sort last, and files missing from planned get their own batch.
+func groupByTime(files []string, planned map[string]plannedFile) [][]string {
+	sorted := slices.Clone(files)
+	slices.SortFunc(sorted, func(a, b string) int {
+		if c := compareTimes(planned[a].dateTime, planned[b].dateTime); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+
+	var batches [][]string
+	index := make(map[string]int)
+	for _, file := range sorted {
+		plan, ok := planned[file]
+		if !ok {
+			batches = append(batches, []string{file})
+			continue
+		}
+		if i, seen := index[plan.dest]; seen {
+			batches[i] = append(batches[i], file)
+			continue
+		}
+		index[plan.dest] = len(batches)
+		batches = append(batches, []string{file})
+	}
+	return batches
+}
+
+// compareTimes orders datetimes chronologically, with nil last
+func compareTimes(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return a.Compare(*b)
+}
+
+// sequencePattern matches the last run of digits in a filename stem
+var sequencePattern = regexp.MustCompile(`(\d+)\D*$`)
+
+// sourceSequence returns the camera sequence number in a filename
+// (e.g. 123 for "IMG_0123.JPG"), if it has one.
+func sourceSequence(path string) (int, bool) {
+	base := filepath.Base(path)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+
+	match := sequencePattern.FindStringSubmatch(stem)
+	if match == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// lessSequence orders files by sequence number; numbered files come before
+// unnumbered ones and ties fall back to the filename
+func lessSequence(a, b string) bool {
+	seqA, okA := sourceSequence(a)
+	seqB, okB := sourceSequence(b)
+	if okA != okB {
+		return okA
+	}
+	if okA && seqA != seqB {
+		return seqA < seqB
+	}
+	return filepath.Base(a) < filepath.Base(b)
+}
+
+// detectBursts reads each file's (adjusted) datetime and groups runs of
+// shots within cfg.BurstWindow. Files dated only by their filesystem time are
+// never grouped: a batch copied at once would look like one long burst.
+func detectBursts(ctx context.Context, files []string, cfg *config.ProcessingConfig, verbose int) (map[string]string, error) {
+	if verbose > 0 {
+		fmt.Printf("Detecting bursts in %d files\n", len(files))
+	}
+
+	var timeDelta, dayDelta *time.Duration
+	if cfg.TimeAdjust != "" {
+		td, err := rename.CalculateTimeDelta(cfg.TimeAdjust)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time adjustment: %w", err)
+		}
+		timeDelta = &td
+	}
+	if cfg.DayAdjust != "" {
+		dd, err := rename.CalculateDayDelta(cfg.DayAdjust)
+		if err != nil {
+			return nil, fmt.Errorf("invalid day adjustment: %w", err)
+		}
+		dayDelta = &dd
+	}
+
+	extractor, err := rename.NewExtractor(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer extractor.Close()
+
+	shots := make([]burst.Shot, 0, len(files))
+	for _, file := range files {
+		if ctx.Err() != nil {
+			break
+		}
+
+		meta, err := extractor.Extract(file, timeDelta, dayDelta)
+		if err != nil {
+			// Reported when the file itself is processed
+			continue
+		}
+		if meta.DateTime == nil || meta.DateSource == config.DateSourceCtime {
+			continue
+		}
+		shots = append(shots, burst.Shot{Path: file, Time: *meta.DateTime})
+	}
+
+	groups := burst.Group(shots, cfg.BurstWindow)
+	if verbose > 0 {
+		fmt.Printf("Grouped %d files into bursts\n", len(groups))
+	}
+	return groups, nil
+}
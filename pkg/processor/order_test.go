@@ -0,0 +1,232 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceSequence(t *testing.T) {
+	tests := []struct {
+		path string
+		want int
+		ok   bool
+	}{
+		{"/dcim/IMG_0123.JPG", 123, true},
+		{"DSC01234.ARW", 1234, true},
+		{"GOPR0042.MP4", 42, true},
+		{"P1000005-edit.jpg", 1000005, true},
+		{"holiday.jpg", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := sourceSequence(tt.path)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGroupBySequence(t *testing.T) {
+	files := []string{"/src/IMG_0124.jpg", "/src/other.jpg", "/src/IMG_0009.jpg", "/src/IMG_0123.jpg", "/src/broken.jpg"}
+	planned := map[string]string{
+		"/src/IMG_0124.jpg": "/dest/20240115-123045.000000_Canon.jpg",
+		"/src/other.jpg":    "/dest/20240115-130000.000000_Canon.jpg",
+		"/src/IMG_0009.jpg": "/dest/20240115-123045.000000_Canon.jpg",
+		"/src/IMG_0123.jpg": "/dest/20240115-123045.000000_Canon.jpg",
+	}
+
+	batches := groupBySequence(files, planned)
+
+	assert.Equal(t, [][]string{
+		{"/src/IMG_0009.jpg", "/src/IMG_0123.jpg", "/src/IMG_0124.jpg"},
+		{"/src/other.jpg"},
+		{"/src/broken.jpg"},
+	}, batches)
+}
+
+func TestProcessFilesSequenceOrder(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+
+	// Same EXIF timestamp and camera, different content, created out of order
+	data, err := os.ReadFile(filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg"))
+	require.NoError(t, err)
+	var files []string
+	for _, n := range []int{3, 1, 2} {
+		path := filepath.Join(sourceDir, fmt.Sprintf("IMG_%04d.jpg", n))
+		require.NoError(t, os.WriteFile(path, append(append([]byte{}, data...), byte(n)), 0644))
+		files = append(files, path)
+	}
+
+	cfg := &config.ProcessingConfig{Precision: 6, SequenceOrder: true}
+	planned := make(map[string]string)
+	for _, file := range files {
+		dest, err := planDestination(file, destDir, cfg)
+		require.NoError(t, err)
+		planned[dest] = file
+	}
+	if len(planned) != 1 {
+		t.Skip("fixture EXIF datetime not available, files don't collide")
+	}
+	var base string
+	for dest := range planned {
+		base = dest
+	}
+
+	stats, err := New(destDir, cfg, Options{Workers: 3}).Process(context.Background(), files)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), stats.Processed)
+
+	// Suffixes follow the source numbering, not the order files were found
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for i, dest := range []string{base, stem + "_1" + ext, stem + "_2" + ext} {
+		content, err := os.ReadFile(dest)
+		require.NoError(t, err)
+		assert.Equal(t, byte(i+1), content[len(content)-1], "%s should hold IMG_%04d", filepath.Base(dest), i+1)
+	}
+}
+
+func TestGroupByTime(t *testing.T) {
+	early := time.Date(2024, 1, 15, 12, 30, 45, 0, time.UTC)
+	late := early.Add(500 * time.Millisecond)
+	files := []string{"/b/IMG_0002.jpg", "/a/IMG_0009.jpg", "/src/other.jpg", "/a/IMG_0001.jpg", "/src/broken.jpg", "/src/undated.jpg"}
+	planned := map[string]plannedFile{
+		"/b/IMG_0002.jpg":  {dest: "/dest/20240115-123045_Canon.jpg", dateTime: &early},
+		"/a/IMG_0009.jpg":  {dest: "/dest/20240115-123045_Canon.jpg", dateTime: &early},
+		"/a/IMG_0001.jpg":  {dest: "/dest/20240115-123045_Canon.jpg", dateTime: &late},
+		"/src/other.jpg":   {dest: "/dest/20240115-130000_Canon.jpg", dateTime: &late},
+		"/src/undated.jpg": {dest: "/dest/unknown_Canon.jpg"},
+	}
+
+	want := [][]string{
+		// Same second: subseconds first, then the path
+		{"/a/IMG_0009.jpg", "/b/IMG_0002.jpg", "/a/IMG_0001.jpg"},
+		{"/src/other.jpg"},
+		// Without a datetime, by path
+		{"/src/broken.jpg"},
+		{"/src/undated.jpg"},
+	}
+	assert.Equal(t, want, groupByTime(files, planned))
+
+	// The order files were found in doesn't matter
+	slices.Reverse(files)
+	assert.Equal(t, want, groupByTime(files, planned))
+}
+
+func TestProcessFilesDeterministic(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+
+	// Same timestamp and camera, different content
+	data, err := os.ReadFile(filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg"))
+	require.NoError(t, err)
+	var files []string
+	for n := 1; n <= 4; n++ {
+		path := filepath.Join(sourceDir, fmt.Sprintf("20240115-123045_%c.jpg", 'a'+n-1))
+		require.NoError(t, os.WriteFile(path, append(append([]byte{}, data...), byte(n)), 0644))
+		files = append(files, path)
+	}
+
+	cfg := &config.ProcessingConfig{Precision: 6, Deterministic: true}
+	planned := make(map[string]bool)
+	for _, file := range files {
+		dest, err := planDestination(file, filepath.Join(tmpDir, "plan"), cfg)
+		require.NoError(t, err)
+		planned[dest] = true
+	}
+	if len(planned) != 1 {
+		t.Skip("fixture datetimes differ, files don't collide")
+	}
+
+	// Two runs over the files found in different orders
+	run := func(destDir string, files []string) map[string]byte {
+		stats, err := New(destDir, cfg, Options{Workers: 4, KeepDestinations: true}).Process(context.Background(), files)
+		require.NoError(t, err)
+		require.Equal(t, int64(4), stats.Processed)
+
+		names := make(map[string]byte)
+		for _, dest := range stats.Destinations {
+			content, err := os.ReadFile(dest)
+			require.NoError(t, err)
+			names[filepath.Base(dest)] = content[len(content)-1]
+		}
+		return names
+	}
+	first := run(filepath.Join(tmpDir, "dest1"), files)
+	reversed := slices.Clone(files)
+	slices.Reverse(reversed)
+	second := run(filepath.Join(tmpDir, "dest2"), reversed)
+
+	assert.Equal(t, first, second, "each _N suffix should hold the same source on every run")
+}
+
+func TestDetectBurstsSkipsFileTimes(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	// Files with no date in metadata or name, copied in one batch
+	tmpDir := t.TempDir()
+	ts := time.Date(2024, 1, 15, 12, 30, 45, 0, time.Local)
+	var files []string
+	for i := 1; i <= 3; i++ {
+		path := filepath.Join(tmpDir, fmt.Sprintf("scan_%d.txt", i))
+		require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf("scan %d", i)), 0644))
+		require.NoError(t, os.Chtimes(path, ts, ts))
+		files = append(files, path)
+	}
+
+	groups, err := detectBursts(context.Background(), files, &config.ProcessingConfig{BurstWindow: time.Second}, 0)
+	require.NoError(t, err)
+	assert.Empty(t, groups, "filesystem times are not capture times")
+}
+
+func TestDetectBurstsPreferFilenameDate(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	// Same EXIF time, but named ten minutes apart
+	data, err := os.ReadFile(filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg"))
+	require.NoError(t, err)
+	tmpDir := t.TempDir()
+	var files []string
+	for _, name := range []string{"20240301-080000.jpg", "20240301-081000.jpg", "20240301-082000.jpg"} {
+		path := filepath.Join(tmpDir, name)
+		require.NoError(t, os.WriteFile(path, data, 0644))
+		files = append(files, path)
+	}
+
+	groups, err := detectBursts(context.Background(), files, &config.ProcessingConfig{BurstWindow: time.Second}, 0)
+	require.NoError(t, err)
+	assert.Len(t, groups, 3, "grouped by EXIF time")
+
+	// Grouping follows the date the files are named by
+	groups, err = detectBursts(context.Background(), files, &config.ProcessingConfig{BurstWindow: time.Second, PreferFilenameDate: true}, 0)
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+}
@@ -0,0 +1,1044 @@
+// Package processor organizes photos and videos programmatically.
+//
+// A Processor collects supported files from source directories and runs each
+// one through the rename pipeline (metadata, path generation, duplicate
+// detection, atomic copy/move) on a bounded worker pool. The sortpics CLI is a
+// thin wrapper around it.
+//
+// Typical use:
+//
+//	p := processor.New(destDir, &config.ProcessingConfig{Precision: 6}, processor.Options{Recursive: true})
+//	defer p.Close()
+//
+//	files, err := p.Collect([]string{sourceDir})
+//	if err != nil {
+//		return err
+//	}
+//	stats, err := p.Process(ctx, files)
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alitto/pond"
+	"github.com/cacack/sortpics-go/internal/duplicate"
+	"github.com/cacack/sortpics-go/internal/exifpool"
+	"github.com/cacack/sortpics-go/internal/metadata"
+	"github.com/cacack/sortpics-go/internal/rename"
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/schollz/progressbar/v3"
+	"golang.org/x/term"
+)
+
+// ErrCanceled is returned by Process when ctx was canceled before every file was handled
+var ErrCanceled = errors.New("processing canceled by user")
+
+// Options controls how a Processor scans sources and runs its workers
+type Options struct {
+	// Workers is the number of files processed concurrently (default: AutoWorkers())
+	Workers int
+
+	// ExifTools is the number of ExifTool processes the workers share for
+	// reading and writing metadata (default: exifpool.DefaultSize(Workers)).
+	// Fewer than Workers saves memory at the cost of workers waiting.
+	ExifTools int
+
+	// Verbose mirrors the CLI's -v count: 1 prints each operation, 2+ also prints skip reasons
+	Verbose int
+
+	// Progress shows a progress bar on stderr while counting and processing
+	// files (only when Verbose is 0), described by the current phase. When
+	// stderr is not a terminal, plain progress lines are printed every
+	// progressInterval while processing instead.
+	Progress bool
+
+	// Manifest, when set, receives a row for every file acted on
+	Manifest *ManifestWriter
+
+	// Events, when set, receives a JSON event as each file starts and finishes
+	Events *EventWriter
+
+	// Recursive descends into subdirectories of each source
+	Recursive bool
+
+	// MaxDepth limits recursion to this many directory levels below each
+	// source root. 0 means unlimited.
+	MaxDepth int
+
+	// FollowSymlinks descends into symlinked directories when recursive
+	FollowSymlinks bool
+
+	// Exclude holds doublestar globs matched against paths relative to the source root
+	Exclude []string
+
+	// Include, when non-empty, restricts files to those matching at least one glob.
+	// Exclude wins when a path matches both.
+	Include []string
+
+	// ScanZips extracts supported entries of .zip files found in the sources
+	// into a temp directory so they are organized too. Call Close to remove them.
+	ScanZips bool
+
+	// ModifiedSince, when non-zero, skips source files last modified before
+	// it, e.g. the start of the last run read with ReadLastRun
+	ModifiedSince time.Time
+
+	// RollbackOnError makes a run all-or-nothing: the first failed file stops
+	// it, and every file already copied or moved is undone (see
+	// rename.ImageRename.Undo), along with the directories the run created.
+	// Process then returns ErrRolledBack.
+	RollbackOnError bool
+
+	// Exec runs a command after each file is organized, with {src} and {dst}
+	// replaced by its source and destination paths (see ValidateExec). In a
+	// dry run it isn't run, only printed when Verbose.
+	Exec string
+
+	// Limit and LimitBytes stop a run after this many files, or once the
+	// sources submitted add up to this many bytes, e.g. for a spot check of a
+	// large source. Files already started finish, and Stats.LimitReached is
+	// set. A paired Live Photo video counts too, but is never split from its
+	// still, so a run can end one file over Limit. ProcessStream stops
+	// reading its channel then, so cancel the context of a Stream walk
+	// afterwards. 0 means no limit.
+	Limit      int
+	LimitBytes int64
+
+	// KeepDestinations lists the destination of every processed file in
+	// Stats.Destinations, e.g. to preview the layout of a dry run
+	KeepDestinations bool
+}
+
+// Processor organizes files into a destination directory
+type Processor struct {
+	destDir string
+	cfg     *config.ProcessingConfig
+	opts    Options
+	zips    *zipExtractor
+}
+
+// MaxAutoWorkers caps AutoWorkers. Past it, more files in flight only add
+// contention on the disk and the ExifTool pool.
+const MaxAutoWorkers = 32
+
+// AutoWorkers returns the worker count used when none is given: twice the
+// CPU count, since workers mostly wait on file copies and ExifTool rather
+// than compute, up to MaxAutoWorkers
+func AutoWorkers() int {
+	return autoWorkers(runtime.NumCPU())
+}
+
+// autoWorkers returns AutoWorkers for a number of CPUs
+func autoWorkers(cpus int) int {
+	return max(1, min(2*cpus, MaxAutoWorkers))
+}
+
+// New creates a Processor that organizes files into destDir.
+//
+// A nil cfg uses the defaults (6-digit subsecond precision, copy mode).
+// With cfg.InPlace set, destDir is ignored.
+func New(destDir string, cfg *config.ProcessingConfig, opts Options) *Processor {
+	if cfg == nil {
+		cfg = &config.ProcessingConfig{
+			Precision: 6,
+		}
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = AutoWorkers()
+	}
+	if opts.ExifTools <= 0 {
+		opts.ExifTools = exifpool.DefaultSize(opts.Workers)
+	}
+
+	p := &Processor{
+		destDir: destDir,
+		cfg:     cfg,
+		opts:    opts,
+	}
+	if opts.ScanZips {
+		p.zips = newZipExtractor()
+	}
+	return p
+}
+
+// Close removes files extracted from ZIP archives. It is safe to call more than once.
+func (p *Processor) Close() error {
+	return p.zips.Cleanup()
+}
+
+// scanOptions returns the scan settings for walkSources
+func (p *Processor) scanOptions() collectOptions {
+	return collectOptions{
+		Recursive:      p.opts.Recursive,
+		MaxDepth:       p.opts.MaxDepth,
+		FollowSymlinks: p.opts.FollowSymlinks,
+		Verbose:        p.opts.Verbose,
+		Exclude:        p.opts.Exclude,
+		Include:        p.opts.Include,
+		ModifiedSince:  p.opts.ModifiedSince,
+		Zips:           p.zips,
+		Derivatives:    p.cfg.DerivativePatterns,
+	}
+}
+
+// Collect walks the sources and returns the absolute path of every supported file
+func (p *Processor) Collect(sources []string) ([]string, error) {
+	return collectFiles(sources, p.scanOptions())
+}
+
+// Count returns the number of supported files in the sources without keeping
+// their paths. With Options.Progress, a spinner shows the walk.
+func (p *Processor) Count(sources []string) (int, error) {
+	if !p.opts.Progress || p.opts.Verbose > 0 {
+		return countFiles(sources, p.scanOptions())
+	}
+	bar := NewProgressBar(PhaseScanning, -1, "files")
+	if bar == nil {
+		return countFiles(sources, p.scanOptions())
+	}
+
+	count := 0
+	err := walkSources(context.Background(), sources, p.scanOptions(), func(string) error {
+		count++
+		bar.Add(1)
+		return nil
+	})
+	bar.Finish()
+	return count, err
+}
+
+// Stream walks the sources in the background, sending each supported file as
+// it is found. The path channel is closed when the walk finishes; the error
+// channel then receives the walk result (nil on success). Cancel ctx once
+// done reading, e.g. after ProcessStream returns early, to stop the walk.
+func (p *Processor) Stream(ctx context.Context, sources []string) (<-chan string, <-chan error) {
+	return streamFiles(ctx, sources, p.scanOptions())
+}
+
+// Process organizes files and returns the resulting statistics.
+//
+// Per-file failures are counted in Stats rather than returned. If ctx is
+// canceled, files already running finish and ErrCanceled is returned along
+// with the partial Stats.
+func (p *Processor) Process(ctx context.Context, files []string) (*Stats, error) {
+	paths, _ := listFiles(files)
+	return p.ProcessStream(ctx, paths, len(files))
+}
+
+// Stats tracks processing statistics
+type Stats struct {
+	Processed  int64
+	Duplicates int64
+	Skipped    int64
+	Errors     int64
+	Canceled   int64
+
+	// RolledBack counts files undone by Options.RollbackOnError;
+	// RollbackFailed those that couldn't be restored
+	RolledBack     int64
+	RollbackFailed int64
+
+	// DuplicatesDeleted counts duplicate sources removed by DeleteDuplicateSource
+	// (or that would be, in a dry run)
+	DuplicatesDeleted int64
+
+	// Replaced counts existing destinations with different content that
+	// processed files replaced (config.Overwrite)
+	Replaced int64
+
+	// SourcesKept counts moved files whose source couldn't be deleted
+	// (e.g. on a read-only card); they were copied instead
+	SourcesKept int64
+
+	// BytesProcessed sums the source sizes of processed files; Elapsed is
+	// the wall time of the run. Together they give the throughput.
+	BytesProcessed int64
+	Elapsed        time.Duration
+
+	// Errors broken down by category (see recordError)
+	MetadataErrors   int64
+	IOErrors         int64
+	PermissionErrors int64
+	OtherErrors      int64
+
+	// carried holds the paired Live Photo videos that went with their still
+	// (config.LivePhotos). Guarded by mu.
+	carried map[string]bool
+
+	// DateSources counts processed files by the tier their datetime came from;
+	// CtimeFiles lists those dated only by filesystem time. Guarded by mu.
+	mu          sync.Mutex
+	DateSources map[config.DateSource]int64
+	CtimeFiles  []string
+
+	// Unparseable lists files ExifTool couldn't parse, whether they failed
+	// or were dated from their filename or filesystem. Guarded by mu.
+	Unparseable []string
+
+	// Destinations lists where processed files went (or would go, in a dry
+	// run) when Options.KeepDestinations is set, in no particular order.
+	// Guarded by mu.
+	Destinations     []string
+	keepDestinations bool
+
+	// HookErrors counts Options.Exec commands that failed or exited non-zero
+	HookErrors int64
+
+	// LimitReached is set when Options.Limit or Options.LimitBytes stopped
+	// the run before every file was submitted
+	LimitReached bool
+
+	// undo records performed files when Options.RollbackOnError is set
+	undo *undoLog
+}
+
+// Throughput returns the bytes processed per second, or 0 before any time has elapsed
+func (s *Stats) Throughput() float64 {
+	return bytesPerSecond(atomic.LoadInt64(&s.BytesProcessed), s.Elapsed)
+}
+
+// bytesPerSecond returns bytes/d, or 0 for a zero duration
+func bytesPerSecond(bytes int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes) / d.Seconds()
+}
+
+// byteUnits are the binary size suffixes used by FormatBytes
+var byteUnits = []string{"KB", "MB", "GB", "TB"}
+
+// FormatBytes formats a byte count with a binary KB/MB/GB/TB suffix (e.g. "1.5 MB")
+func FormatBytes(n int64) string {
+	if n < 1<<10 {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n) / (1 << 10)
+	unit := 0
+	for value >= 1<<10 && unit < len(byteUnits)-1 {
+		value /= 1 << 10
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", value, byteUnits[unit])
+}
+
+// recordDateSource counts a processed file under the tier that dated it
+func (s *Stats) recordDateSource(source config.DateSource, file string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.DateSources == nil {
+		s.DateSources = make(map[config.DateSource]int64)
+	}
+	s.DateSources[source]++
+	if source == config.DateSourceCtime {
+		s.CtimeFiles = append(s.CtimeFiles, file)
+	}
+}
+
+// recordUnparseable adds a file ExifTool couldn't parse
+func (s *Stats) recordUnparseable(file string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Unparseable = append(s.Unparseable, file)
+}
+
+// carry records that a paired Live Photo video went with its still
+func (s *Stats) carry(video string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.carried == nil {
+		s.carried = make(map[string]bool)
+	}
+	s.carried[video] = true
+}
+
+// isCarried reports whether a paired Live Photo video went with its still
+func (s *Stats) isCarried(video string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.carried[video]
+}
+
+// recordDestination adds a processed file's destination when they are kept
+func (s *Stats) recordDestination(destination string) {
+	if !s.keepDestinations {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Destinations = append(s.Destinations, destination)
+}
+
+// ProcessStream organizes files from the channel, as produced by Stream, using the worker pool.
+//
+// total sizes the progress bar and the canceled count; pass -1 if unknown.
+// The channel is drained until closed or ctx is canceled.
+func (p *Processor) ProcessStream(ctx context.Context, files <-chan string, total int) (*Stats, error) {
+	stats := &Stats{keepDestinations: p.opts.KeepDestinations}
+	if p.opts.RollbackOnError && !p.cfg.DryRun {
+		stats.undo = newUndoLog()
+	}
+	destDir, cfg, manifest, events := p.destDir, p.cfg, p.opts.Manifest, p.opts.Events
+	workers, verbose := p.opts.Workers, p.opts.Verbose
+	// Event mode keeps stdout for JSON, so previewed commands go to stderr
+	hookOut := io.Writer(os.Stdout)
+	if events != nil {
+		hookOut = os.Stderr
+	}
+	hook := newExecHook(p.opts.Exec, p.cfg.DryRun, verbose, hookOut)
+
+	// Workers borrow ExifTool processes rather than each file starting its own
+	exifTools := exifpool.New(p.opts.ExifTools)
+	defer exifTools.Close()
+	run := rename.NewRun(exifTools)
+
+	// Index every hash already in the archive so duplicates are caught regardless of name
+	var knownHashes *duplicate.HashIndex
+	if cfg.SkipExistingHashes {
+		knownHashes = duplicate.NewHashIndex()
+		indexDirs := []string{destDir}
+		if cfg.RawPath != "" {
+			indexDirs = append(indexDirs, cfg.RawPath)
+		}
+		detector := duplicate.New()
+		for _, dir := range indexDirs {
+			if verbose > 0 {
+				fmt.Printf("Indexing existing files in %s\n", dir)
+			}
+			failed, err := detector.IndexDirectoryParallel(dir, knownHashes, workers)
+			if err != nil {
+				return stats, fmt.Errorf("failed to index destination %s: %w", dir, err)
+			}
+			if failed > 0 {
+				fmt.Fprintf(os.Stderr, "Warning: could not hash %d existing files in %s\n", failed, dir)
+			}
+		}
+		if verbose > 0 {
+			fmt.Printf("Indexed %d existing files\n", knownHashes.Len())
+		}
+	}
+
+	// Burst grouping, collision ordering, and Live Photo pairing compare files
+	// against each other, so they need the whole list before any destination
+	// path is generated
+	keepLivePhotos := cfg.KeepLivePhotos && !cfg.StripGPS
+	var list []string
+	if cfg.BurstWindow > 0 || cfg.SequenceOrder || cfg.Deterministic || keepLivePhotos {
+		for file := range files {
+			list = append(list, file)
+		}
+		files, _ = listFiles(list)
+	}
+
+	// A paired video travels with its still, so it isn't queued on its own;
+	// the worker queues it after the still if the still didn't take it
+	if keepLivePhotos {
+		pairs, err := detectLivePhotos(ctx, list, verbose)
+		if err != nil {
+			return stats, err
+		}
+
+		videos := make(map[string]bool, len(pairs))
+		for _, video := range pairs {
+			videos[video] = true
+		}
+		list = slices.DeleteFunc(list, func(file string) bool { return videos[file] })
+		files, _ = listFiles(list)
+
+		liveCfg := *cfg
+		liveCfg.LivePhotos = pairs
+		cfg = &liveCfg
+	}
+
+	if cfg.BurstWindow > 0 {
+		groups, err := detectBursts(ctx, list, cfg, verbose)
+		if err != nil {
+			return stats, err
+		}
+
+		burstCfg := *cfg
+		burstCfg.BurstGroups = groups
+		cfg = &burstCfg
+	}
+
+	// Each batch is processed in order by a single worker. Files are normally
+	// their own batch; with sequence or deterministic ordering, files that
+	// would collide share one so their _N suffixes follow the source numbering
+	// or datetime order.
+	var batches <-chan []string
+	if cfg.SequenceOrder || cfg.Deterministic {
+		batches = batchChan(orderCollisions(ctx, list, destDir, cfg, run, workers, verbose))
+	} else {
+		batches = singleBatches(ctx, files)
+	}
+
+	// Counters used to report progress and how many files were canceled on interrupt
+	var submitted, completed int64
+	limit := newLimiter(p.opts.Limit, p.opts.LimitBytes, cfg.LivePhotos)
+	if p.opts.Limit > 0 && total > p.opts.Limit {
+		total = p.opts.Limit
+	}
+
+	// Create progress bar (only if not verbose). A redrawn bar is unreadable
+	// in a log file or pipe, so there progress is printed as plain lines.
+	var bar *progressbar.ProgressBar
+	showProgress := p.opts.Progress && verbose == 0
+	if showProgress {
+		bar = NewProgressBar(PhaseProcessing, total, "files")
+	}
+
+	// Throughput is measured over processing only, not indexing or planning
+	start := time.Now()
+	defer func() { stats.Elapsed = time.Since(start) }()
+	if showProgress && bar == nil {
+		stop := reportProgress(progressOutput, &completed, total, progressInterval)
+		defer stop()
+	}
+	if verbose > 0 {
+		stop := reportThroughput(stats, start)
+		defer stop()
+	}
+
+	// With rollback, the first failure stops the run like an interrupt would
+	parent := ctx
+	ctx, stopRun := context.WithCancel(ctx)
+	defer stopRun()
+
+	// advance counts a file as completed and updates the progress bar, with
+	// the data rate so far
+	advance := func() {
+		atomic.AddInt64(&completed, 1)
+		if bar != nil {
+			rate := bytesPerSecond(atomic.LoadInt64(&stats.BytesProcessed), time.Since(start))
+			bar.Describe(fmt.Sprintf("%s %s/s", PhaseProcessing, FormatBytes(int64(rate))))
+			bar.Add(1)
+		}
+	}
+
+	// process organizes one file, and reports false if canceling aborted it
+	process := func(file string) bool {
+		events.emit(Event{Event: EventStart, Path: file})
+		err := processFile(ctx, file, destDir, cfg, run, stats, knownHashes, manifest, events, hook, verbose)
+		if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			// Aborted mid-file and rolled back; counted as canceled
+			return false
+		}
+		if err != nil {
+			stats.recordError(err)
+			events.emit(Event{Event: EventError, Path: file, Error: err.Error()})
+			if verbose > 0 {
+				fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", file, err)
+			}
+			if stats.undo != nil {
+				stopRun()
+			}
+		}
+		advance()
+		return true
+	}
+
+	// Create worker pool with a small bounded queue and context cancellation.
+	// slots caps queued plus running tasks at the queue size, so Submit never
+	// blocks and the directory walk is throttled to the processing rate.
+	queueSize := workers * 2
+	pool := pond.New(workers, queueSize, pond.Context(ctx))
+	slots := make(chan struct{}, queueSize)
+
+	// Submit tasks in a separate goroutine so the main thread can respond to cancellation
+	submitDone := make(chan struct{})
+	go func() {
+		defer close(submitDone)
+		for batch := range batches {
+			batch := batch // Capture for closure
+
+			// Wait for a free slot, or stop if context is canceled
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			// The limit may cut the batch short, and ends the run once reached
+			batch, limited := limit.take(batch)
+
+			atomic.AddInt64(&submitted, int64(len(batch)))
+			pool.Submit(func() {
+				defer func() { <-slots }()
+
+				for _, file := range batch {
+					// Don't start new files once canceled
+					if ctx.Err() != nil {
+						return
+					}
+					if !process(file) {
+						return
+					}
+
+					// A paired Live Photo video went with its still, or is
+					// organized on its own if the still wasn't (a duplicate,
+					// skipped, or failed)
+					video, ok := cfg.LivePhotos[file]
+					if !ok {
+						continue
+					}
+					atomic.AddInt64(&submitted, 1)
+					if stats.isCarried(video) {
+						advance()
+					} else if ctx.Err() != nil || !process(video) {
+						return
+					}
+				}
+			})
+
+			if limited {
+				stats.LimitReached = true
+				return
+			}
+		}
+	}()
+
+	// Wait for all submissions, then for the pool to drain. Once ctx is
+	// canceled the submitter stops taking new files, the pool discards queued
+	// tasks, and running tasks abort their extraction or copy, removing any
+	// temp file, so no file is left half-copied
+	<-submitDone
+	pool.StopAndWait()
+
+	if ctx.Err() != nil {
+		remaining := atomic.LoadInt64(&submitted)
+		if total >= 0 {
+			remaining = int64(total)
+		}
+		stats.Canceled = remaining - atomic.LoadInt64(&completed)
+
+		if bar != nil {
+			bar.Exit()
+			fmt.Fprint(progressOutput, "\n")
+		}
+
+		if stats.undo != nil {
+			stats.RolledBack, stats.RollbackFailed = stats.undo.rollback()
+			if parent.Err() == nil {
+				return stats, ErrRolledBack
+			}
+		}
+		return stats, ErrCanceled
+	}
+
+	// Finish progress bar
+	if bar != nil {
+		bar.Finish()
+	}
+
+	return stats, nil
+}
+
+// Phases of a run, named by the description of its progress bar
+const (
+	PhaseScanning   = "Scanning"
+	PhaseProcessing = "Processing"
+	PhaseCleaning   = "Cleaning"
+)
+
+// progressOutput receives progress bars and lines; stderrIsTerminal decides
+// between them. Tests replace both.
+var (
+	progressOutput   io.Writer = os.Stderr
+	stderrIsTerminal           = func() bool { return term.IsTerminal(int(os.Stderr.Fd())) }
+)
+
+// NewProgressBar returns a progress bar on stderr described by phase, or nil
+// when stderr is not a terminal. A total of -1 shows a spinner with a
+// running count for phases whose size isn't known up front, such as scanning.
+func NewProgressBar(phase string, total int, items string) *progressbar.ProgressBar {
+	if !stderrIsTerminal() {
+		return nil
+	}
+	out := progressOutput
+	return progressbar.NewOptions(total,
+		progressbar.OptionSetDescription(phase),
+		progressbar.OptionSetWriter(out),
+		progressbar.OptionShowCount(),
+		progressbar.OptionSetItsString(items),
+		progressbar.OptionShowIts(),
+		progressbar.OptionSetPredictTime(total >= 0), // ETA from the item rate
+		progressbar.OptionSetWidth(40),
+		progressbar.OptionThrottle(65*1000000), // 65ms
+		progressbar.OptionSetRenderBlankState(true),
+		progressbar.OptionShowElapsedTimeOnFinish(),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprint(out, "\n")
+		}),
+	)
+}
+
+// throughputInterval is how often verbose runs print a throughput line
+const throughputInterval = 10 * time.Second
+
+// reportThroughput prints the files and data processed so far every
+// throughputInterval until the returned stop function is called
+func reportThroughput(stats *Stats, start time.Time) (stop func()) {
+	ticker := time.NewTicker(throughputInterval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				bytes := atomic.LoadInt64(&stats.BytesProcessed)
+				rate := bytesPerSecond(bytes, time.Since(start))
+				fmt.Printf("Progress: %d files, %s (%s/s)\n", atomic.LoadInt64(&stats.Processed), FormatBytes(bytes), FormatBytes(int64(rate)))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// progressInterval is how often plain progress lines are printed when stderr
+// is not a terminal
+const progressInterval = 5 * time.Second
+
+// reportProgress writes a "Processing: N/total files" line to w every
+// interval, and a final one when the returned stop function is called. Each
+// line is newline-terminated so the output stays readable in logs and pipes.
+// An unknown total (-1) prints "Processing: N files".
+func reportProgress(w io.Writer, completed *int64, total int, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	line := func() {
+		if total < 0 {
+			fmt.Fprintf(w, "Processing: %d files\n", atomic.LoadInt64(completed))
+			return
+		}
+		fmt.Fprintf(w, "Processing: %d/%d files\n", atomic.LoadInt64(completed), total)
+	}
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-ticker.C:
+				line()
+			case <-done:
+				line()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-finished
+	}
+}
+
+// processFile processes a single file
+//
+// knownHashes, when non-nil, holds hashes already present in the archive;
+// sources matching one are counted as duplicates without further work.
+// manifest, when non-nil, receives a row for every file acted on, and events
+// an event for every outcome other than an error (reported by the caller).
+// hook, when non-nil, runs after each file is organized.
+func processFile(ctx context.Context, file string, destDir string, cfg *config.ProcessingConfig, run *rename.Run, stats *Stats, knownHashes *duplicate.HashIndex, manifest *ManifestWriter, events *EventWriter, hook *execHook, verbose int) error {
+	// Stat up front: the size feeds --min-size and throughput, and a move removes the source
+	info, err := os.Stat(file)
+	if err != nil {
+		return categorize(errIO, fmt.Errorf("failed to stat file: %w", err))
+	}
+
+	// Skip files below the minimum size before doing any metadata work
+	if cfg.MinSize > 0 && info.Size() < cfg.MinSize {
+		atomic.AddInt64(&stats.Skipped, 1)
+		if verbose > 1 {
+			fmt.Printf("Skipping (too small, %d bytes): %s\n", info.Size(), file)
+		}
+		events.emit(Event{Event: EventSkipped, Path: file, Reason: "too small"})
+		return nil
+	}
+
+	// Skip edited copies and edit sidecars so only originals are archived
+	if isDerivative(filepath.Base(file), cfg.DerivativePatterns) {
+		atomic.AddInt64(&stats.Skipped, 1)
+		if verbose > 1 {
+			fmt.Printf("Skipping (derivative): %s\n", file)
+		}
+		events.emit(Event{Event: EventSkipped, Path: file, Reason: "derivative"})
+		return nil
+	}
+
+	// Skip files a previous run already organized, before hashing or extracting metadata
+	if cfg.SkipOrganized && rename.IsOrganized(file, destDir, cfg) {
+		atomic.AddInt64(&stats.Skipped, 1)
+		if verbose > 1 {
+			fmt.Printf("Skipping (already organized): %s\n", file)
+		}
+		events.emit(Event{Event: EventSkipped, Path: file, Reason: "already organized"})
+		return nil
+	}
+
+	// Skip content that already exists anywhere in the archive
+	var sourceHash string
+	if knownHashes != nil {
+		hash, err := duplicate.New().CalculateSHA256(file)
+		if err != nil {
+			return categorize(errIO, fmt.Errorf("failed to hash file: %w", err))
+		}
+		if knownHashes.Contains(hash) {
+			atomic.AddInt64(&stats.Duplicates, 1)
+			if verbose > 1 {
+				fmt.Printf("Skipping (already in archive): %s\n", file)
+			}
+			events.emit(Event{Event: EventDuplicate, Path: file, Reason: "already in archive"})
+			if err := deleteDuplicateSource(file, "", destDir, cfg, stats, verbose); err != nil {
+				return categorize(errIO, err)
+			}
+			return categorize(errIO, manifest.record(manifestEntry{
+				Source: file,
+				Action: manifestActionDuplicate,
+				Hash:   hash,
+			}))
+		}
+		sourceHash = hash
+	}
+
+	// Create ImageRename instance
+	ir, err := rename.NewImageRename(file, destDir, cfg, rename.InRun(run))
+	if err != nil {
+		return categorize(errMetadata, fmt.Errorf("failed to create rename instance: %w", err))
+	}
+	defer ir.Close()
+
+	// Check if valid extension
+	if !ir.IsValidExtension() {
+		atomic.AddInt64(&stats.Skipped, 1)
+		if verbose > 1 {
+			fmt.Printf("Skipping (unsupported): %s\n", file)
+		}
+		events.emit(Event{Event: EventSkipped, Path: file, Reason: "unsupported"})
+		return nil
+	}
+
+	// Parse metadata
+	if err := ir.ParseMetadata(ctx); err != nil {
+		var unparseable *metadata.UnparseableError
+		if errors.As(err, &unparseable) {
+			stats.recordUnparseable(file)
+		}
+		return categorize(errMetadata, fmt.Errorf("failed to parse metadata: %w", err))
+	}
+	if msg := ir.ParseError(); msg != "" {
+		stats.recordUnparseable(file)
+		if verbose > 1 {
+			fmt.Printf("ExifTool couldn't parse %s (%s); dated by %s\n", file, msg, ir.GetDateSource())
+		}
+	}
+	if ir.CameraTruncated() {
+		fmt.Fprintf(os.Stderr, "Warning: shortened camera name to fit the filename length limit: %s -> %s\n", file, ir.GetDestination())
+	}
+
+	// Hash the source while it still exists (a move removes it)
+	var hash string
+	if manifest != nil {
+		hash, err = ir.SourceHash()
+		if err != nil {
+			return categorize(errIO, fmt.Errorf("failed to hash file: %w", err))
+		}
+	}
+	entry := manifestEntry{
+		Source:      file,
+		Destination: ir.GetDestination(),
+		DateTime:    ir.GetDateTime(),
+		Make:        ir.GetMake(),
+		Model:       ir.GetModel(),
+		Hash:        hash,
+	}
+
+	// Check if duplicate
+	skipDuplicate := func() error {
+		atomic.AddInt64(&stats.Duplicates, 1)
+		if verbose > 1 {
+			fmt.Printf("Skipping (duplicate): %s\n", file)
+		}
+		events.emit(Event{Event: EventDuplicate, Path: file, Destination: ir.GetDestination()})
+		if err := deleteDuplicateSource(file, ir.GetDestination(), destDir, cfg, stats, verbose); err != nil {
+			return categorize(errIO, err)
+		}
+		entry.Destination = ir.GetDestination()
+		entry.Action = manifestActionDuplicate
+		return categorize(errIO, manifest.record(entry))
+	}
+	if ir.IsDuplicate() {
+		return skipDuplicate()
+	}
+
+	// Show what we're doing
+	if verbose > 0 {
+		operation := "Copying"
+		if cfg.InPlace {
+			operation = "Renaming"
+		} else if cfg.Move {
+			operation = "Moving"
+		}
+		if cfg.DryRun {
+			operation = "[DRY RUN] " + operation
+		}
+		replacing := ""
+		if ir.Replaces() {
+			replacing = " (replacing existing file)"
+		}
+		fmt.Printf("%s: %s -> %s%s\n", operation, file, ir.GetDestination(), replacing)
+	}
+
+	// Perform the operation. A move that couldn't delete its source still
+	// landed the file, so it counts as a copy with a warning
+	sourceKept := false
+	stats.undo.noteDirs(filepath.Dir(ir.GetDestination()))
+	err = ir.Perform(ctx)
+	stats.undo.record(ir)
+	if video, ok := cfg.LivePhotos[file]; ok && !ir.IsDuplicate() && (cfg.DryRun || ir.TransferredSidecar(video)) {
+		stats.carry(video)
+	}
+	if err != nil {
+		if !errors.Is(err, rename.ErrSourceNotRemoved) {
+			return categorize(errIO, fmt.Errorf("failed to perform operation: %w", err))
+		}
+		sourceKept = true
+		atomic.AddInt64(&stats.SourcesKept, 1)
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	// With cfg.DedupSiblings, another worker may have just written the same
+	// content under a nearby name; Perform then wrote nothing
+	if ir.IsDuplicate() {
+		return skipDuplicate()
+	}
+
+	// Later sources with identical content are now duplicates too
+	if knownHashes != nil {
+		knownHashes.Add(sourceHash)
+	}
+
+	atomic.AddInt64(&stats.Processed, 1)
+	atomic.AddInt64(&stats.BytesProcessed, info.Size())
+	if ir.Replaces() {
+		atomic.AddInt64(&stats.Replaced, 1)
+	}
+	stats.recordDateSource(ir.GetDateSource(), file)
+	stats.recordDestination(ir.GetDestination())
+	hook.run(ctx, file, ir.GetDestination(), stats)
+
+	entry.Destination = ir.GetDestination()
+	entry.Action = manifestActionCopied
+	if cfg.InPlace && !sourceKept {
+		entry.Action = manifestActionRenamed
+	} else if cfg.Move && !sourceKept {
+		entry.Action = manifestActionMoved
+	}
+	if cfg.DryRun {
+		entry.Action = manifestDryRunPrefix + entry.Action
+	}
+	events.emit(Event{Event: EventCopied, Path: file, Destination: entry.Destination, Action: entry.Action})
+	return categorize(errIO, manifest.record(entry))
+}
+
+// deleteDuplicateSource removes a moved file's source once its content is
+// confirmed to be in the archive already (cfg.DeleteDuplicateSource).
+//
+// duplicate is the matching archive file, or "" when the match came from the
+// hash index. The source is never deleted when it could be that match itself.
+func deleteDuplicateSource(file, duplicate, destDir string, cfg *config.ProcessingConfig, stats *Stats, verbose int) error {
+	if !cfg.DeleteDuplicateSource || !cfg.Move || cfg.InPlace {
+		return nil
+	}
+	if duplicate == "" {
+		if insideDir(file, destDir) || (cfg.RawPath != "" && insideDir(file, cfg.RawPath)) {
+			return nil
+		}
+	} else if sameFile(file, duplicate) {
+		return nil
+	}
+
+	if cfg.DryRun {
+		atomic.AddInt64(&stats.DuplicatesDeleted, 1)
+		if verbose > 0 {
+			fmt.Printf("[DRY RUN] Deleting duplicate source: %s\n", file)
+		}
+		return nil
+	}
+
+	if err := os.Remove(file); err != nil {
+		return fmt.Errorf("failed to delete duplicate source: %w", err)
+	}
+	atomic.AddInt64(&stats.DuplicatesDeleted, 1)
+	if verbose > 0 {
+		fmt.Printf("Deleted duplicate source: %s\n", file)
+	}
+	return nil
+}
+
+// insideDir reports whether path is dir or below it
+func insideDir(path, dir string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return true
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return true
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// sameFile reports whether a and b are the same file, erring on the side of
+// true when either can't be checked
+func sameFile(a, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return true
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return true
+	}
+	return os.SameFile(infoA, infoB)
+}
+
+// DefaultDerivativePatterns match Apple's edited copies (IMG_E1234.JPG) and edit sidecars (.AAE)
+var DefaultDerivativePatterns = []string{
+	"IMG_E[0-9][0-9][0-9][0-9]*.*",
+	"*.aae",
+}
+
+// isDerivative reports whether a filename matches any derivative glob, ignoring case
+func isDerivative(filename string, patterns []string) bool {
+	name := strings.ToLower(filename)
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(strings.ToLower(pattern), name); matched {
+			return true
+		}
+	}
+	return false
+}
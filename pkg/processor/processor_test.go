@@ -0,0 +1,757 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cacack/sortpics-go/internal/duplicate"
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessorCollectAndProcess(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	nested := filepath.Join(sourceDir, "DCIM", "100CANON")
+	require.NoError(t, os.MkdirAll(nested, 0755))
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(nested, "test_001.jpg"), data, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(nested, "notes.txt"), []byte("notes"), 0644))
+
+	p := New(destDir, &config.ProcessingConfig{Precision: 6}, Options{Workers: 2, Recursive: true})
+	defer p.Close()
+
+	files, err := p.Collect([]string{sourceDir})
+	require.NoError(t, err)
+	require.Len(t, files, 1, "unsupported files are not collected")
+
+	count, err := p.Count([]string{sourceDir})
+	require.NoError(t, err)
+	assert.Equal(t, len(files), count)
+
+	stats, err := p.Process(context.Background(), files)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Processed)
+	assert.Equal(t, int64(0), stats.Errors)
+	assert.FileExists(t, filepath.Join(destDir, "2024", "01", "2024-01-15", "20240115-123045.123456_Canon-Eos5d.jpg"))
+	assert.FileExists(t, filepath.Join(nested, "test_001.jpg"), "copy mode leaves the source")
+
+	// Streaming the same source again finds the copy already in place
+	paths, walkErr := p.Stream(context.Background(), []string{sourceDir})
+	stats, err = p.ProcessStream(context.Background(), paths, -1)
+	require.NoError(t, err)
+	require.NoError(t, <-walkErr)
+	assert.Equal(t, int64(1), stats.Duplicates)
+}
+
+func TestProcessorSourcePrefixes(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	card1 := filepath.Join(tmpDir, "card1")
+	card2 := filepath.Join(tmpDir, "card2")
+	destDir := filepath.Join(tmpDir, "dest")
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg"))
+	require.NoError(t, err)
+	for _, card := range []string{card1, card2} {
+		require.NoError(t, os.MkdirAll(card, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(card, "test_001.jpg"), data, 0644))
+	}
+
+	cfg := &config.ProcessingConfig{
+		Precision:      6,
+		SourcePrefixes: map[string]string{card1: "card1", card2: "card2"},
+	}
+	p := New(destDir, cfg, Options{Workers: 2})
+	defer p.Close()
+
+	files, err := p.Collect([]string{card1, card2})
+	require.NoError(t, err)
+	stats, err := p.Process(context.Background(), files)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Processed)
+
+	// Identical shots from each card land side by side instead of colliding
+	name := "20240115-123045.123456_Canon-Eos5d.jpg"
+	assert.FileExists(t, filepath.Join(destDir, "card1-2024", "01", "2024-01-15", name))
+	assert.FileExists(t, filepath.Join(destDir, "card2-2024", "01", "2024-01-15", name))
+	assert.NoDirExists(t, filepath.Join(destDir, "2024"))
+}
+
+func TestProcessorPreserveTree(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "DCIM")
+	destDir := filepath.Join(tmpDir, "dest")
+	nested := filepath.Join(sourceDir, "100CANON")
+	require.NoError(t, os.MkdirAll(nested, 0755))
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(nested, "test_001.jpg"), data, 0644))
+
+	cfg := &config.ProcessingConfig{Precision: 6, PreserveTree: true, SourceDirs: []string{sourceDir}}
+	p := New(destDir, cfg, Options{Workers: 1, Recursive: true})
+	defer p.Close()
+
+	files, err := p.Collect([]string{sourceDir})
+	require.NoError(t, err)
+	stats, err := p.Process(context.Background(), files)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Processed)
+	assert.FileExists(t, filepath.Join(destDir, "2024", "01", "2024-01-15", "100CANON", "20240115-123045.123456_Canon-Eos5d.jpg"))
+}
+
+func TestProcessorKeepDestinations(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	destDir := filepath.Join(t.TempDir(), "dest")
+	photo := filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg")
+
+	for _, keep := range []bool{false, true} {
+		p := New(destDir, &config.ProcessingConfig{Precision: 6, DryRun: true}, Options{Workers: 1, KeepDestinations: keep})
+		stats, err := p.Process(context.Background(), []string{photo})
+		require.NoError(t, err)
+		require.NoError(t, p.Close())
+
+		if !keep {
+			assert.Empty(t, stats.Destinations)
+			continue
+		}
+		assert.Equal(t, []string{filepath.Join(destDir, "2024", "01", "2024-01-15", "20240115-123045.123456_Canon-Eos5d.jpg")}, stats.Destinations)
+	}
+	assert.NoDirExists(t, destDir)
+}
+
+func TestProcessorSharedExifTools(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	destDir := filepath.Join(t.TempDir(), "dest")
+	testDataDir := filepath.Join("..", "..", "test", "testdata", "basic")
+
+	// More workers than processes: workers wait their turn for ExifTool
+	p := New(destDir, &config.ProcessingConfig{Precision: 6}, Options{Workers: 4, ExifTools: 1})
+	defer p.Close()
+	files, err := p.Collect([]string{testDataDir})
+	require.NoError(t, err)
+
+	stats, err := p.Process(context.Background(), files)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(files)), stats.Processed)
+	assert.Zero(t, stats.Errors)
+}
+
+func TestProcessorRollbackOnError(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+
+	// Dated by filename; the February file fails because 2024/02 is a
+	// regular file, after the January files were already moved
+	var files []string
+	for _, name := range []string{"20240115-120000.jpg", "20240116-120000.jpg", "20240215-120000.jpg", "20240117-120000.jpg"} {
+		path := filepath.Join(sourceDir, name)
+		require.NoError(t, os.WriteFile(path, []byte(name), 0644))
+		files = append(files, path)
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(destDir, "2024"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(destDir, "2024", "02"), nil, 0644))
+
+	cfg := &config.ProcessingConfig{Precision: 6, Move: true, NoMetadataWrite: true}
+	stats, err := New(destDir, cfg, Options{Workers: 1, RollbackOnError: true}).Process(context.Background(), files)
+	require.ErrorIs(t, err, ErrRolledBack)
+
+	assert.Equal(t, int64(1), stats.Errors)
+	assert.Equal(t, int64(2), stats.RolledBack)
+	assert.Equal(t, int64(0), stats.RollbackFailed)
+	assert.Equal(t, int64(1), stats.Canceled, "files after the failure are not started")
+
+	// The destination is back to what it held before the run
+	var left []string
+	require.NoError(t, filepath.WalkDir(destDir, func(path string, d fs.DirEntry, err error) error {
+		left = append(left, relativePath(destDir, path))
+		return err
+	}))
+	assert.Equal(t, []string{".", "2024", "2024/02"}, left)
+
+	for _, file := range files {
+		assert.FileExists(t, file)
+	}
+}
+
+func TestProcessorCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := New(t.TempDir(), nil, Options{Workers: 1})
+	stats, err := p.Process(ctx, []string{"/src/a.jpg", "/src/b.jpg"})
+	require.ErrorIs(t, err, ErrCanceled)
+	assert.Equal(t, int64(2), stats.Canceled)
+	assert.Equal(t, int64(0), stats.Processed)
+}
+
+func TestStatsBytesProcessed(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	testDataDir := filepath.Join("..", "..", "test", "testdata", "basic")
+	var files []string
+	var want int64
+	for _, name := range []string{"test_001.jpg", "test_002.jpg"} {
+		path := filepath.Join(testDataDir, name)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		files = append(files, path)
+		want += info.Size()
+	}
+
+	destDir := t.TempDir()
+	p := New(destDir, &config.ProcessingConfig{Precision: 6}, Options{Workers: 2})
+	stats, err := p.Process(context.Background(), files)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), stats.Processed)
+	assert.Equal(t, want, stats.BytesProcessed)
+	assert.Positive(t, stats.Elapsed)
+	assert.Positive(t, stats.Throughput())
+
+	// Duplicates are not counted
+	stats, err = p.Process(context.Background(), files)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Duplicates)
+	assert.Equal(t, int64(0), stats.BytesProcessed)
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		want  string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536 * 1024, "1.5 MB"},
+		{5 << 30, "5.0 GB"},
+		{3 << 40, "3.0 TB"},
+		{2048 << 40, "2048.0 TB"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, FormatBytes(tt.bytes))
+	}
+
+	assert.Equal(t, float64(0), (&Stats{BytesProcessed: 100}).Throughput(), "no elapsed time")
+}
+
+func TestReportProgressPlainLines(t *testing.T) {
+	var buf bytes.Buffer
+	completed := int64(3)
+
+	stop := reportProgress(&buf, &completed, 10, 10*time.Millisecond)
+	time.Sleep(35 * time.Millisecond)
+	stop()
+
+	out := buf.String()
+	require.True(t, strings.HasSuffix(out, "\n"), "output ends with a newline")
+	assert.NotContains(t, out, "\r", "no carriage returns redrawing a bar")
+
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	assert.GreaterOrEqual(t, len(lines), 2, "periodic lines plus a final one")
+	for _, line := range lines {
+		assert.Equal(t, "Processing: 3/10 files", line)
+	}
+
+	// While the walk is still counting, only the count is known
+	buf.Reset()
+	reportProgress(&buf, &completed, -1, time.Hour)()
+	assert.Equal(t, "Processing: 3 files\n", buf.String())
+}
+
+func TestAutoWorkers(t *testing.T) {
+	assert.Equal(t, 2, autoWorkers(1))
+	assert.Equal(t, 16, autoWorkers(8))
+	assert.Equal(t, MaxAutoWorkers, autoWorkers(64))
+	assert.Equal(t, 1, autoWorkers(0))
+
+	// Workers 0 means auto; explicit values are kept exactly
+	p := New(t.TempDir(), nil, Options{Workers: 0})
+	assert.Equal(t, AutoWorkers(), p.opts.Workers)
+	assert.Equal(t, autoWorkers(runtime.NumCPU()), p.opts.Workers)
+
+	p = New(t.TempDir(), nil, Options{Workers: 3})
+	assert.Equal(t, 3, p.opts.Workers)
+}
+
+func TestProgressPhases(t *testing.T) {
+	var buf bytes.Buffer
+	oldOutput, oldTerminal := progressOutput, stderrIsTerminal
+	progressOutput, stderrIsTerminal = &buf, func() bool { return true }
+	t.Cleanup(func() { progressOutput, stderrIsTerminal = oldOutput, oldTerminal })
+
+	sourceDir := t.TempDir()
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		require.NoError(t, os.WriteFile(filepath.Join(sourceDir, name), make([]byte, 10), 0644))
+	}
+
+	// Files under MinSize are skipped before ExifTool is needed
+	cfg := &config.ProcessingConfig{Precision: 6, MinSize: 1024}
+	p := New(filepath.Join(t.TempDir(), "dest"), cfg, Options{Workers: 1, Progress: true})
+
+	total, err := p.Count([]string{sourceDir})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Contains(t, buf.String(), PhaseScanning)
+	assert.NotContains(t, buf.String(), PhaseProcessing)
+
+	buf.Reset()
+	files, err := p.Collect([]string{sourceDir})
+	require.NoError(t, err)
+	stats, err := p.Process(context.Background(), files)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Skipped)
+	assert.Contains(t, buf.String(), PhaseProcessing)
+	assert.NotContains(t, buf.String(), PhaseScanning)
+
+	// Verbose runs print lines instead of bars
+	buf.Reset()
+	p = New(filepath.Join(t.TempDir(), "dest"), cfg, Options{Workers: 1, Progress: true, Verbose: 1})
+	_, err = p.Count([]string{sourceDir})
+	require.NoError(t, err)
+	assert.Empty(t, buf.String())
+}
+
+func TestProcessFileMinSize(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	smallFile := filepath.Join(tmpDir, "thumb.jpg")
+	require.NoError(t, os.WriteFile(smallFile, make([]byte, 10), 0644))
+
+	cfg := &config.ProcessingConfig{Precision: 6, MinSize: 1024}
+	stats := &Stats{}
+
+	err := processFile(context.Background(), smallFile, filepath.Join(tmpDir, "dest"), cfg, nil, stats, nil, nil, nil, nil, 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), stats.Skipped)
+	assert.Equal(t, int64(0), stats.Processed)
+	assert.NoDirExists(t, filepath.Join(tmpDir, "dest"))
+}
+
+func TestProcessFileSkipOrganized(t *testing.T) {
+	destDir := t.TempDir()
+	dateDir := filepath.Join(destDir, "2024", "01", "2024-01-15")
+	require.NoError(t, os.MkdirAll(dateDir, 0755))
+
+	// Content isn't a real image: a skipped file is never read
+	organized := filepath.Join(dateDir, "20240115-123045.123456_Canon-Eos5d.jpg")
+	require.NoError(t, os.WriteFile(organized, []byte("already organized"), 0644))
+
+	cfg := &config.ProcessingConfig{Precision: 6, Move: true, SkipOrganized: true}
+	stats := &Stats{}
+
+	err := processFile(context.Background(), organized, destDir, cfg, nil, stats, nil, nil, nil, nil, 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), stats.Skipped)
+	assert.Equal(t, int64(0), stats.Processed)
+	assert.FileExists(t, organized)
+}
+
+func TestProcessFileSkipExistingHashes(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Plant a file in the archive under an unrelated name
+	destDir := filepath.Join(tmpDir, "dest")
+	archivedDir := filepath.Join(destDir, "2020", "01", "2020-01-01")
+	require.NoError(t, os.MkdirAll(archivedDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(archivedDir, "20200101-000000.000000_Canon.jpg"), []byte("same content"), 0644))
+
+	sourceFile := filepath.Join(tmpDir, "IMG_0001.jpg")
+	require.NoError(t, os.WriteFile(sourceFile, []byte("same content"), 0644))
+
+	knownHashes := duplicate.NewHashIndex()
+	_, err := duplicate.New().IndexDirectoryParallel(destDir, knownHashes, 1)
+	require.NoError(t, err)
+
+	cfg := &config.ProcessingConfig{Precision: 6, SkipExistingHashes: true}
+	stats := &Stats{}
+
+	err = processFile(context.Background(), sourceFile, destDir, cfg, nil, stats, knownHashes, nil, nil, nil, 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), stats.Duplicates)
+	assert.Equal(t, int64(0), stats.Processed)
+	assert.FileExists(t, sourceFile)
+}
+
+func TestProcessFileDeleteDuplicateSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.ProcessingConfig
+		deleted bool
+	}{
+		{"move with flag", config.ProcessingConfig{Move: true, DeleteDuplicateSource: true}, true},
+		{"move without flag", config.ProcessingConfig{Move: true}, false},
+		{"copy with flag", config.ProcessingConfig{DeleteDuplicateSource: true}, false},
+		{"dry run", config.ProcessingConfig{Move: true, DeleteDuplicateSource: true, DryRun: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			destDir := filepath.Join(tmpDir, "dest")
+			archivedDir := filepath.Join(destDir, "2020", "01", "2020-01-01")
+			require.NoError(t, os.MkdirAll(archivedDir, 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(archivedDir, "20200101-000000.000000_Canon.jpg"), []byte("same content"), 0644))
+
+			sourceFile := filepath.Join(tmpDir, "IMG_0001.jpg")
+			require.NoError(t, os.WriteFile(sourceFile, []byte("same content"), 0644))
+
+			knownHashes := duplicate.NewHashIndex()
+			_, err := duplicate.New().IndexDirectoryParallel(destDir, knownHashes, 1)
+			require.NoError(t, err)
+
+			cfg := tt.cfg
+			cfg.Precision = 6
+			cfg.SkipExistingHashes = true
+			stats := &Stats{}
+
+			err = processFile(context.Background(), sourceFile, destDir, &cfg, nil, stats, knownHashes, nil, nil, nil, 0)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), stats.Duplicates)
+
+			if tt.deleted {
+				assert.NoFileExists(t, sourceFile)
+				assert.Equal(t, int64(1), stats.DuplicatesDeleted)
+			} else {
+				assert.FileExists(t, sourceFile)
+			}
+			if cfg.DryRun {
+				assert.Equal(t, int64(1), stats.DuplicatesDeleted, "dry run previews the deletion")
+			}
+		})
+	}
+
+	// A source inside the archive may be the indexed match itself, so it is kept
+	t.Run("source in archive", func(t *testing.T) {
+		destDir := t.TempDir()
+		sourceFile := filepath.Join(destDir, "IMG_0001.jpg")
+		require.NoError(t, os.WriteFile(sourceFile, []byte("only copy"), 0644))
+
+		knownHashes := duplicate.NewHashIndex()
+		_, err := duplicate.New().IndexDirectoryParallel(destDir, knownHashes, 1)
+		require.NoError(t, err)
+
+		cfg := &config.ProcessingConfig{Precision: 6, Move: true, SkipExistingHashes: true, DeleteDuplicateSource: true}
+		stats := &Stats{}
+
+		require.NoError(t, processFile(context.Background(), sourceFile, destDir, cfg, nil, stats, knownHashes, nil, nil, nil, 0))
+		assert.Equal(t, int64(1), stats.Duplicates)
+		assert.FileExists(t, sourceFile)
+		assert.Equal(t, int64(0), stats.DuplicatesDeleted)
+	})
+}
+
+func TestProcessFilesCancelLeavesNoTempFiles(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	// Build a source directory with enough files that cancellation lands mid-run
+	sourceDir := t.TempDir()
+	fixtures, err := collectFiles([]string{filepath.Join("..", "..", "test", "testdata", "basic")}, collectOptions{})
+	require.NoError(t, err)
+	require.NotEmpty(t, fixtures)
+	for i := 0; i < 40; i++ {
+		data, err := os.ReadFile(fixtures[i%len(fixtures)])
+		require.NoError(t, err)
+		// Append a byte so every copy has unique content
+		data = append(data, byte(i))
+		require.NoError(t, os.WriteFile(filepath.Join(sourceDir, fmt.Sprintf("img_%03d.jpg", i)), data, 0644))
+	}
+
+	files, err := collectFiles([]string{sourceDir}, collectOptions{})
+	require.NoError(t, err)
+
+	destDir := t.TempDir()
+	cfg := &config.ProcessingConfig{Precision: 6}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	stats, err := New(destDir, cfg, Options{Workers: 2, Verbose: 1}).Process(ctx, files)
+	if err != nil {
+		require.ErrorIs(t, err, ErrCanceled)
+	}
+
+	// Every file is either finished or canceled; none is abandoned mid-copy
+	finished := stats.Processed + stats.Duplicates + stats.Skipped + stats.Errors
+	assert.Equal(t, int64(len(files)), finished+stats.Canceled)
+
+	err = filepath.WalkDir(destDir, func(path string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		assert.False(t, strings.HasPrefix(d.Name(), ".tmp-"), "temp file left behind: %s", path)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestProcessFilesManifest(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	testDataDir := filepath.Join("..", "..", "test", "testdata", "basic")
+	files, err := collectFiles([]string{testDataDir}, collectOptions{})
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	tmpDir := t.TempDir()
+	manifestFile := filepath.Join(tmpDir, "manifest.csv")
+	manifest, err := NewManifestWriter(manifestFile)
+	require.NoError(t, err)
+
+	cfg := &config.ProcessingConfig{Precision: 6, DryRun: true}
+	stats, err := New(filepath.Join(tmpDir, "dest"), cfg, Options{Workers: 2, Manifest: manifest}).Process(context.Background(), files)
+	require.NoError(t, err)
+	require.NoError(t, manifest.Close())
+
+	f, err := os.Open(manifestFile)
+	require.NoError(t, err)
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	require.NotEmpty(t, rows)
+
+	assert.Equal(t, []string{"source", "destination", "datetime", "make", "model", "action", "hash"}, rows[0])
+	assert.Len(t, rows[1:], int(stats.Processed+stats.Duplicates))
+	assert.Len(t, rows[1:], len(files))
+	for _, row := range rows[1:] {
+		assert.Equal(t, "dry-run-copied", row[5], "a dry run copies nothing")
+		assert.Len(t, row[6], 64, "hash should be a SHA256 hex digest")
+	}
+}
+
+func TestProcessFilesGroupBursts(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+
+	// Three shots with the same timestamp but different content
+	data, err := os.ReadFile(filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg"))
+	require.NoError(t, err)
+	var files []string
+	for i := 1; i <= 3; i++ {
+		path := filepath.Join(sourceDir, fmt.Sprintf("BURST_%d.jpg", i))
+		require.NoError(t, os.WriteFile(path, append(append([]byte{}, data...), byte(i)), 0644))
+		files = append(files, path)
+	}
+	ts := time.Date(2024, 1, 15, 12, 30, 45, 0, time.Local)
+	for _, path := range files {
+		require.NoError(t, os.Chtimes(path, ts, ts))
+	}
+
+	cfg := &config.ProcessingConfig{Precision: 6, BurstWindow: 500 * time.Millisecond}
+	stats, err := New(destDir, cfg, Options{Workers: 2}).Process(context.Background(), files)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Processed)
+
+	var burstDirs []string
+	err = filepath.WalkDir(destDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			dir := filepath.Dir(path)
+			assert.True(t, strings.HasPrefix(filepath.Base(dir), "burst_"), "%s should be in a burst folder", path)
+			burstDirs = append(burstDirs, dir)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, burstDirs, 3)
+	assert.Equal(t, burstDirs[0], burstDirs[1])
+	assert.Equal(t, burstDirs[0], burstDirs[2])
+	assert.Nil(t, cfg.BurstGroups, "caller's config should not be modified")
+}
+
+func TestProcessFileNoMetadataWrite(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	destDir := filepath.Join(tmpDir, "dest")
+	sourceFile := filepath.Join("..", "..", "test", "testdata", "basic", "test_002.jpg")
+
+	cfg := &config.ProcessingConfig{Precision: 6, NoMetadataWrite: true}
+	stats := &Stats{}
+	require.NoError(t, processFile(context.Background(), sourceFile, destDir, cfg, nil, stats, nil, nil, nil, nil, 0))
+	require.Equal(t, int64(1), stats.Processed)
+
+	var written []string
+	err := filepath.WalkDir(destDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			written = append(written, path)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, written, 1, "no _original backup should be created")
+
+	detector := duplicate.New()
+	sourceHash, err := detector.CalculateSHA256(sourceFile)
+	require.NoError(t, err)
+	destHash, err := detector.CalculateSHA256(written[0])
+	require.NoError(t, err)
+	assert.Equal(t, sourceHash, destHash)
+}
+
+func TestProcessFileUnparseable(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	destDir := filepath.Join(tmpDir, "dest")
+	corrupt := filepath.Join(tmpDir, "20240115-123045.jpg")
+	require.NoError(t, os.WriteFile(corrupt, []byte("corrupt: not a JPEG"), 0644))
+	good := filepath.Join("..", "..", "test", "testdata", "basic", "test_002.jpg")
+
+	cfg := &config.ProcessingConfig{Precision: 6, NoMetadataWrite: true}
+	stats := &Stats{}
+	require.NoError(t, processFile(context.Background(), corrupt, destDir, cfg, nil, stats, nil, nil, nil, nil, 0))
+	require.NoError(t, processFile(context.Background(), good, destDir, cfg, nil, stats, nil, nil, nil, nil, 0))
+
+	assert.Equal(t, int64(2), stats.Processed, "a corrupt file is still dated by its filename")
+	assert.Equal(t, int64(1), stats.DateSources[config.DateSourceFilename])
+	assert.Equal(t, []string{corrupt}, stats.Unparseable)
+}
+
+func TestStatsRecordDateSource(t *testing.T) {
+	stats := &Stats{}
+	stats.recordDateSource(config.DateSourceEXIF, "/src/a.jpg")
+	stats.recordDateSource(config.DateSourceEXIF, "/src/b.jpg")
+	stats.recordDateSource(config.DateSourceCtime, "/src/c.jpg")
+
+	assert.Equal(t, int64(2), stats.DateSources[config.DateSourceEXIF])
+	assert.Equal(t, int64(1), stats.DateSources[config.DateSourceCtime])
+	assert.Equal(t, []string{"/src/c.jpg"}, stats.CtimeFiles)
+}
+
+func TestIsDerivative(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"IMG_E1234.JPG", true},
+		{"img_e1234.heic.jpg", true},
+		{"IMG_1234.AAE", true},
+		{"IMG_O1234.aae", true},
+		{"IMG_1234.JPG", false},
+		{"IMG_E12.JPG", false},
+		{"EDIT_1234.jpg", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isDerivative(tt.name, DefaultDerivativePatterns), tt.name)
+	}
+
+	assert.False(t, isDerivative("IMG_E1234.JPG", nil), "no patterns means nothing is a derivative")
+	assert.True(t, isDerivative("PXL_0001-edited.jpg", []string{"*-edited.*"}))
+}
+
+func TestProcessFileSkipDerivatives(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+
+	original := filepath.Join(sourceDir, "IMG_1234.JPG")
+	edited := filepath.Join(sourceDir, "IMG_E1234.JPG")
+	require.NoError(t, os.WriteFile(original, []byte("original"), 0644))
+	require.NoError(t, os.WriteFile(edited, []byte("edited"), 0644))
+
+	cfg := &config.ProcessingConfig{Precision: 6, DerivativePatterns: DefaultDerivativePatterns}
+	stats := &Stats{}
+	require.NoError(t, processFile(context.Background(), original, destDir, cfg, nil, stats, nil, nil, nil, nil, 0))
+	require.NoError(t, processFile(context.Background(), edited, destDir, cfg, nil, stats, nil, nil, nil, nil, 0))
+
+	assert.Equal(t, int64(1), stats.Processed)
+	assert.Equal(t, int64(1), stats.Skipped)
+}
+
+// TestCollectDerivatives tests that derivatives with unsupported extensions,
+// like .AAE, are collected and counted as skipped
+func TestCollectDerivatives(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+	for _, name := range []string{"IMG_E1234.JPG", "IMG_1234.AAE", "notes.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(sourceDir, name), []byte(name), 0644))
+	}
+
+	files, err := New(tmpDir, &config.ProcessingConfig{Precision: 6}, Options{}).Collect([]string{sourceDir})
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "without derivative patterns .AAE is unsupported")
+
+	cfg := &config.ProcessingConfig{Precision: 6, DerivativePatterns: DefaultDerivativePatterns}
+	p := New(filepath.Join(tmpDir, "dest"), cfg, Options{Workers: 1})
+	files, err = p.Collect([]string{sourceDir})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{filepath.Join(sourceDir, "IMG_E1234.JPG"), filepath.Join(sourceDir, "IMG_1234.AAE")}, files)
+
+	stats, err := p.Process(context.Background(), files)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Skipped)
+	assert.Zero(t, stats.Processed)
+	assert.Zero(t, stats.Errors)
+}
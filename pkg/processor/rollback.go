@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/cacack/sortpics-go/internal/rename"
+)
+
+// ErrRolledBack is returned by Process when a file failed with
+// Options.RollbackOnError set and the files already done were undone
+var ErrRolledBack = errors.New("processing failed; completed files were rolled back")
+
+// undoLog records what a run did so it can be reversed (Options.RollbackOnError).
+//
+// Safe for concurrent use by workers. A nil *undoLog records nothing.
+type undoLog struct {
+	mu   sync.Mutex
+	done []*rename.ImageRename
+
+	// dirs holds destination directories that didn't exist when a file was
+	// about to be written there, so the run created them
+	dirs map[string]bool
+}
+
+// newUndoLog creates an empty undoLog
+func newUndoLog() *undoLog {
+	return &undoLog{dirs: make(map[string]bool)}
+}
+
+// noteDirs records dir and its ancestors that don't exist yet, before a file
+// is written into dir
+func (u *undoLog) noteDirs(dir string) {
+	if u == nil {
+		return
+	}
+
+	var missing []string
+	for ; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) || dir == filepath.Dir(dir) {
+			break
+		}
+		missing = append(missing, dir)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, d := range missing {
+		u.dirs[d] = true
+	}
+}
+
+// record adds a file whose Perform was attempted, whether or not it succeeded
+func (u *undoLog) record(ir *rename.ImageRename) {
+	if u == nil {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.done = append(u.done, ir)
+}
+
+// rollback undoes every recorded file, newest first, then removes the
+// directories the run created once they are empty. Files that can't be
+// restored are reported on stderr and counted in failed.
+func (u *undoLog) rollback() (undone, failed int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for i := len(u.done) - 1; i >= 0; i-- {
+		ir := u.done[i]
+		if !ir.Transferred() {
+			continue
+		}
+		if err := ir.Undo(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: rollback failed: %v\n", err)
+			failed++
+			continue
+		}
+		undone++
+	}
+	u.done = nil
+
+	// Deepest first, so parents are empty by the time they're reached.
+	// Directories still holding a file that couldn't be restored stay.
+	dirs := slices.Collect(maps.Keys(u.dirs))
+	slices.SortFunc(dirs, func(a, b string) int { return len(b) - len(a) })
+	for _, dir := range dirs {
+		os.Remove(dir)
+	}
+
+	return undone, failed
+}
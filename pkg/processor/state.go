@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ReadLastRun returns the start time of the last successful run recorded in
+// the state file at path. A missing state file is a first run and returns
+// the zero time, so every file is processed.
+func ReadLastRun(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	lastRun, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	return lastRun, nil
+}
+
+// WriteLastRun records start as the time of the last successful run.
+//
+// The state is written to a temp file and renamed over path, so an
+// interrupted write never leaves a truncated state behind.
+func WriteLastRun(path string, start time.Time) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".sortpics-state-*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := fmt.Fprintln(tmp, start.Format(time.RFC3339Nano)); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastRunRoundTrip(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "sortpics.state")
+
+	lastRun, err := ReadLastRun(statePath)
+	require.NoError(t, err)
+	assert.True(t, lastRun.IsZero(), "missing state file is a first run")
+
+	start := time.Date(2024, 1, 15, 12, 30, 45, 123456789, time.UTC)
+	require.NoError(t, WriteLastRun(statePath, start))
+
+	lastRun, err = ReadLastRun(statePath)
+	require.NoError(t, err)
+	assert.True(t, start.Equal(lastRun))
+
+	require.NoError(t, os.WriteFile(statePath, []byte("yesterday\n"), 0644))
+	_, err = ReadLastRun(statePath)
+	assert.Error(t, err)
+}
+
+func TestCollectFilesModifiedSince(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+
+	oldFile := filepath.Join(sourceDir, "old.jpg")
+	newFile := filepath.Join(sourceDir, "new.jpg")
+	require.NoError(t, os.WriteFile(oldFile, []byte("old"), 0644))
+	require.NoError(t, os.WriteFile(newFile, []byte("new"), 0644))
+
+	lastRun := time.Now().Add(-time.Hour)
+	old := lastRun.Add(-24 * time.Hour)
+	require.NoError(t, os.Chtimes(oldFile, old, old))
+
+	statePath := filepath.Join(tmpDir, "sortpics.state")
+	require.NoError(t, WriteLastRun(statePath, lastRun))
+
+	since, err := ReadLastRun(statePath)
+	require.NoError(t, err)
+	p := New(filepath.Join(tmpDir, "dest"), nil, Options{ModifiedSince: since})
+	files, err := p.Collect([]string{sourceDir})
+	require.NoError(t, err)
+	assert.Equal(t, []string{newFile}, files)
+
+	// Without a recorded run every file is collected
+	files, err = New(filepath.Join(tmpDir, "dest"), nil, Options{}).Collect([]string{sourceDir})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{oldFile, newFile}, files)
+}
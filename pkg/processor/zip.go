@@ -0,0 +1,150 @@
+package processor
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cacack/sortpics-go/internal/rename"
+)
+
+// zipEntry is a supported file extracted from a ZIP archive
+type zipEntry struct {
+	// Name is the slash-separated path inside the archive
+	Name string
+
+	// Path is the absolute path of the extracted copy
+	Path string
+}
+
+// zipExtractor extracts supported entries of ZIP archives into a temp directory.
+//
+// ExifTool needs real files, so entries are extracted rather than streamed.
+// Each archive is extracted once; later walks (the count pass, then the
+// processing pass) reuse the result. Call Cleanup to remove the temp files.
+type zipExtractor struct {
+	mu        sync.Mutex
+	dir       string
+	extracted map[string][]zipEntry
+}
+
+// newZipExtractor creates a zipExtractor; the temp directory is created on first use
+func newZipExtractor() *zipExtractor {
+	return &zipExtractor{extracted: make(map[string][]zipEntry)}
+}
+
+// Extract extracts the supported entries of the archive at zipPath.
+//
+// Entries are written to <tmp>/<N>/<archive name>/<entry path>, so the parent
+// directory of a top-level entry is named after the archive (as seen by
+// --album-from-directory), and get the entry's modification time. Entries with
+// unsafe paths (absolute or containing "..") are skipped. An archive that fails
+// to extract reports its error once and yields no entries afterwards.
+func (z *zipExtractor) Extract(zipPath string) ([]zipEntry, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	if entries, ok := z.extracted[zipPath]; ok {
+		return entries, nil
+	}
+
+	if z.dir == "" {
+		dir, err := os.MkdirTemp("", "sortpics-zip-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zip temp directory: %w", err)
+		}
+		z.dir = dir
+	}
+
+	base := filepath.Join(z.dir, strconv.Itoa(len(z.extracted)))
+	entries, err := extractZip(zipPath, base)
+	if err != nil {
+		z.extracted[zipPath] = nil
+		return nil, err
+	}
+
+	z.extracted[zipPath] = entries
+	return entries, nil
+}
+
+// extractZip extracts the supported entries of zipPath under base/<archive name>/
+func extractZip(zipPath, base string) ([]zipEntry, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip %s: %w", zipPath, err)
+	}
+	defer reader.Close()
+
+	stem := strings.TrimSuffix(filepath.Base(zipPath), filepath.Ext(zipPath))
+	base = filepath.Join(base, stem)
+
+	var entries []zipEntry
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() || !filepath.IsLocal(f.Name) {
+			continue
+		}
+		ext := strings.TrimPrefix(filepath.Ext(f.Name), ".")
+		if ext == "" || !rename.IsValidExtension(ext) {
+			continue
+		}
+
+		dest := filepath.Join(base, filepath.FromSlash(f.Name))
+		if err := extractZipFile(f, dest); err != nil {
+			return nil, fmt.Errorf("failed to extract %s from %s: %w", f.Name, zipPath, err)
+		}
+		entries = append(entries, zipEntry{Name: f.Name, Path: dest})
+	}
+
+	return entries, nil
+}
+
+// Cleanup removes every extracted file. A nil zipExtractor is a no-op.
+func (z *zipExtractor) Cleanup() error {
+	if z == nil {
+		return nil
+	}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	if z.dir == "" {
+		return nil
+	}
+	err := os.RemoveAll(z.dir)
+	z.dir = ""
+	z.extracted = make(map[string][]zipEntry)
+	return err
+}
+
+// extractZipFile writes one archive entry to dest, keeping its modification time
+func extractZipFile(f *zip.File, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	// Entries without EXIF fall back to file time, so keep the archived one
+	return os.Chtimes(dest, f.Modified, f.Modified)
+}
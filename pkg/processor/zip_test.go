@@ -0,0 +1,154 @@
+package processor
+
+import (
+	"archive/zip"
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cacack/sortpics-go/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestZip creates a zip at path with the given entry name -> content
+func writeTestZip(t *testing.T, path string, entries map[string][]byte) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for name, content := range entries {
+		entry, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = entry.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+}
+
+func TestZipExtractor(t *testing.T) {
+	tmpDir := t.TempDir()
+	zipPath := filepath.Join(tmpDir, "backup.zip")
+	writeTestZip(t, zipPath, map[string][]byte{
+		"DCIM/IMG_0001.JPG": []byte("jpeg"),
+		"notes.txt":         []byte("text"),
+		"../escape.jpg":     []byte("unsafe"),
+	})
+
+	z := newZipExtractor()
+	entries, err := z.Extract(zipPath)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	assert.Equal(t, "DCIM/IMG_0001.JPG", entries[0].Name)
+	assert.True(t, strings.HasSuffix(entries[0].Path, filepath.Join("backup", "DCIM", "IMG_0001.JPG")), entries[0].Path)
+	content, err := os.ReadFile(entries[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", string(content))
+
+	// A second walk reuses the extraction
+	again, err := z.Extract(zipPath)
+	require.NoError(t, err)
+	assert.Equal(t, entries, again)
+
+	require.NoError(t, z.Cleanup())
+	assert.NoFileExists(t, entries[0].Path)
+
+	t.Run("corrupt zip", func(t *testing.T) {
+		bad := filepath.Join(tmpDir, "bad.zip")
+		require.NoError(t, os.WriteFile(bad, []byte("not a zip"), 0644))
+
+		z := newZipExtractor()
+		defer z.Cleanup()
+
+		_, err := z.Extract(bad)
+		assert.Error(t, err)
+
+		// Reported once; later walks just see no entries
+		entries, err := z.Extract(bad)
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestCollectFilesScanZips(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "loose.jpg"), []byte("loose"), 0644))
+	writeTestZip(t, filepath.Join(tmpDir, "backup.zip"), map[string][]byte{
+		"a.jpg":         []byte("a"),
+		"private/b.jpg": []byte("b"),
+	})
+
+	t.Run("zips ignored by default", func(t *testing.T) {
+		files, err := collectFiles([]string{tmpDir}, collectOptions{})
+		require.NoError(t, err)
+		assert.Len(t, files, 1)
+	})
+
+	t.Run("entries are collected and filtered", func(t *testing.T) {
+		z := newZipExtractor()
+		defer z.Cleanup()
+
+		files, err := collectFiles([]string{tmpDir}, collectOptions{
+			Zips:    z,
+			Exclude: []string{"backup.zip/private/**"},
+		})
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+
+		var names []string
+		for _, file := range files {
+			names = append(names, filepath.Base(file))
+		}
+		assert.ElementsMatch(t, []string{"loose.jpg", "a.jpg"}, names)
+	})
+}
+
+func TestProcessFilesFromZip(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("ExifTool not available, skipping test")
+	}
+
+	jpeg, err := os.ReadFile(filepath.Join("..", "..", "test", "testdata", "basic", "test_001.jpg"))
+	require.NoError(t, err)
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+	zipPath := filepath.Join(sourceDir, "phone-backup.zip")
+	writeTestZip(t, zipPath, map[string][]byte{"DCIM/test_001.jpg": jpeg})
+
+	p := New(destDir, &config.ProcessingConfig{Precision: 6}, Options{Workers: 1, ScanZips: true})
+	files, err := p.Collect([]string{sourceDir})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	stats, err := p.Process(context.Background(), files)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Processed)
+
+	var organized []string
+	err = filepath.WalkDir(destDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".jpg" {
+			organized = append(organized, path)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, organized, 1)
+
+	require.NoError(t, p.Close())
+	assert.NoFileExists(t, files[0])
+	assert.FileExists(t, zipPath, "the archive itself is left untouched")
+}